@@ -8,9 +8,6 @@ import (
     "golang.org/x/crypto/bcrypt"
 )
 
-//Только бы не забыть вынести в продакшене!!!!!!
-var jwtSecret = []byte("your-secret-key-change-in-production-please")
-
 //Сами данные JWT
 type Claims struct {
 	UserID   int    `json:"user_id"`
@@ -31,32 +28,63 @@ func HashPassword(password string) (string, error) {
 func CheckPassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
+// GenerateToken выпускает access-токен. jti — случайный идентификатор, по нему токен можно отозвать
 func GenerateToken(userID int, username string) (string, error) {
+	return signToken(userID, username, config.Audience, config.TTL)
+}
+
+// signToken подписывает токен пользователя для аудитории audience со сроком ttl
+func signToken(userID int, username, audience string, ttl time.Duration) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // ttl токена
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			Issuer:    config.Issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(config.Secret)
 }
 
+// ValidateToken проверяет подпись, срок, издателя, аудиторию и наличие jti с допуском config.Leeway.
+// Отзыв токена проверяет middleware.AuthMiddleware
 func ValidateToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, config.Audience)
+}
+
+// parseToken проверяет токен, выпущенный signToken для аудитории audience
+func parseToken(tokenString, audience string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
-	})
+		return config.Secret, nil
+	},
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		// Токен без jti нельзя отозвать выходом
+		if claims.ID == "" {
+			return nil, errors.New("token has no jti")
+		}
 		return claims, nil
 	}
 	
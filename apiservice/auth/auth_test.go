@@ -182,6 +182,26 @@ func TestGenerateTokenSuccess(t *testing.T) {
 }
 
 // TestGenerateTokenEmptyUsername проверяет генерацию с пустым username
+// TestGenerateTokenSubSecondIssuedAt проверяет, что iat переживает подпись и разбор с точностью до микросекунды:
+// по нему отзыв отличает токены, выданные в одну секунду до и после выхода на всех устройствах
+func TestGenerateTokenSubSecondIssuedAt(t *testing.T) {
+	before := time.Now().Truncate(time.Microsecond)
+	token, err := GenerateToken(1, "testuser")
+	if err != nil {
+		t.Fatalf("GenerateToken() вернул ошибку: %v", err)
+	}
+	after := time.Now()
+
+	claims, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() вернул ошибку: %v", err)
+	}
+	// При разборе дробных секунд iat может потерять одну микросекунду
+	if iat := claims.IssuedAt.Time; iat.Before(before.Add(-time.Microsecond)) || iat.After(after) {
+		t.Errorf("iat = %v, ожидалось между %v и %v", iat, before, after)
+	}
+}
+
 func TestGenerateTokenEmptyUsername(t *testing.T) {
 	userID := 123
 	username := ""
@@ -308,8 +328,8 @@ func TestValidateTokenExpiry(t *testing.T) {
 		t.Error("ValidateToken() вернул claims с ExpiresAt в прошлом")
 	}
 
-	// Проверяем, что ExpiresAt примерно через 24 часа (±1 минута)
-	expectedExpiry := time.Now().Add(24 * time.Hour)
+	// Проверяем, что ExpiresAt примерно через DefaultTTL (±1 минута)
+	expectedExpiry := time.Now().Add(DefaultTTL)
 	diff := claims.ExpiresAt.Time.Sub(expectedExpiry)
 	if diff < -time.Minute || diff > time.Minute {
 		t.Errorf("ValidateToken() вернул claims с неправильным ExpiresAt: разница %v", diff)
@@ -634,7 +654,7 @@ func TestValidateTokenExpired(t *testing.T) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString(jwtSecret)
+	tokenString, _ := token.SignedString(config.Secret)
 
 	_, err := ValidateToken(tokenString)
 	if err == nil {
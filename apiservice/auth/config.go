@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Время в токенах (iat, exp) хранится с точностью до микросекунды, как users.tokens_revoked_at в PostgreSQL.
+// С секундной точностью токен, выданный сразу после выхода на всех устройствах, не отличить от выданного до него
+func init() {
+	jwt.TimePrecision = time.Microsecond
+}
+
+// DefaultSecret секрет для локальной разработки. С ним сервис стартует только в dev-режиме
+const DefaultSecret = "your-secret-key-change-in-production-please"
+
+const (
+	DefaultIssuer   = "todo-apiservice"
+	DefaultAudience = "todo-app"
+	DefaultTTL      = 15 * time.Minute
+	DefaultLeeway   = 30 * time.Second
+	// DefaultRefreshTTL срок жизни refresh-токена; при каждой ротации отсчёт начинается заново
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// MinSecretLength минимальная длина секрета HS256 в байтах
+const MinSecretLength = 32
+
+var ErrDefaultSecret = errors.New("default JWT secret is allowed only with APP_ENV=dev; set JWT_SECRET or JWT_SECRET_FILE")
+
+// Config параметры выпуска и проверки токенов
+type Config struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Leeway допуск расхождения часов при проверке exp, nbf и iat
+	Leeway time.Duration
+	// RefreshTTL срок жизни refresh-токена
+	RefreshTTL time.Duration
+}
+
+// DefaultConfig настройки для разработки и тестов
+func DefaultConfig() Config {
+	return Config{
+		Secret:     []byte(DefaultSecret),
+		Issuer:     DefaultIssuer,
+		Audience:   DefaultAudience,
+		TTL:        DefaultTTL,
+		Leeway:     DefaultLeeway,
+		RefreshTTL: DefaultRefreshTTL,
+	}
+}
+
+// IsDevMode сообщает, что сервис запущен для разработки (APP_ENV=dev или development)
+func IsDevMode() bool {
+	env := strings.ToLower(os.Getenv("APP_ENV"))
+	return env == "dev" || env == "development"
+}
+
+// ConfigFromEnv читает настройки из JWT_SECRET или JWT_SECRET_FILE, JWT_ISSUER, JWT_AUDIENCE, JWT_TTL, JWT_LEEWAY
+// и JWT_REFRESH_TTL.
+// Незаданные параметры берутся из DefaultConfig; секрет по умолчанию вне dev-режима — ErrDefaultSecret
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	secret, secretFile := os.Getenv("JWT_SECRET"), os.Getenv("JWT_SECRET_FILE")
+	switch {
+	case secret != "" && secretFile != "":
+		return cfg, errors.New("set only one of JWT_SECRET and JWT_SECRET_FILE")
+	case secret != "":
+		cfg.Secret = []byte(secret)
+	case secretFile != "":
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return cfg, fmt.Errorf("read JWT_SECRET_FILE: %w", err)
+		}
+		// Файлы секретов обычно заканчиваются переводом строки, он не часть ключа
+		cfg.Secret = []byte(strings.TrimSpace(string(data)))
+	}
+
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		cfg.Issuer = v
+	}
+	if v := os.Getenv("JWT_AUDIENCE"); v != "" {
+		cfg.Audience = v
+	}
+
+	var err error
+	if cfg.TTL, err = durationFromEnv("JWT_TTL", cfg.TTL); err != nil {
+		return cfg, err
+	}
+	if cfg.Leeway, err = durationFromEnv("JWT_LEEWAY", cfg.Leeway); err != nil {
+		return cfg, err
+	}
+	if cfg.RefreshTTL, err = durationFromEnv("JWT_REFRESH_TTL", cfg.RefreshTTL); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(IsDevMode()); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// durationFromEnv читает длительность в формате Go (24h, 15m, 30s); пустая переменная — def
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative duration such as 24h or 30s", name, v)
+	}
+	return d, nil
+}
+
+// Validate проверяет настройки; dev разрешает секрет по умолчанию
+func (c Config) Validate(dev bool) error {
+	if len(c.Secret) < MinSecretLength {
+		return fmt.Errorf("JWT secret must be at least %d bytes", MinSecretLength)
+	}
+	if string(c.Secret) == DefaultSecret && !dev {
+		return ErrDefaultSecret
+	}
+	if c.Issuer == "" || c.Audience == "" {
+		return errors.New("JWT issuer and audience must not be empty")
+	}
+	if c.TTL <= 0 {
+		return errors.New("JWT TTL must be positive")
+	}
+	if c.Leeway < 0 {
+		return errors.New("JWT leeway must not be negative")
+	}
+	if c.RefreshTTL <= c.TTL {
+		return errors.New("JWT refresh TTL must be longer than access token TTL")
+	}
+	return nil
+}
+
+// config текущие настройки пакета; до Configure действуют настройки по умолчанию
+var config = DefaultConfig()
+
+// Configure задаёт настройки для GenerateToken, ValidateToken и RefreshTTL. Вызывается один раз при старте
+func Configure(c Config) {
+	config = c
+}
+
+// RefreshTTL срок жизни refresh-токенов по текущим настройкам
+func RefreshTTL() time.Duration {
+	return config.RefreshTTL
+}
+
+// TTL срок жизни access-токенов по текущим настройкам
+func TTL() time.Duration {
+	return config.TTL
+}
+
+// Leeway допуск расхождения часов по текущим настройкам
+func Leeway() time.Duration {
+	return config.Leeway
+}
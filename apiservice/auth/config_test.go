@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef-test"
+
+// withConfig подменяет настройки пакета на время теста
+func withConfig(t *testing.T, c Config) {
+	t.Helper()
+	prev := config
+	Configure(c)
+	t.Cleanup(func() { Configure(prev) })
+}
+
+// clearAuthEnv сбрасывает переменные окружения настроек, чтобы на тест не влияло окружение запуска
+func clearAuthEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"APP_ENV", "JWT_SECRET", "JWT_SECRET_FILE", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_TTL", "JWT_LEEWAY", "JWT_REFRESH_TTL"} {
+		t.Setenv(name, "")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ConfigFromEnv
+// ============================================================================
+
+func TestConfigFromEnvDefaultsInDevMode(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("APP_ENV", "dev")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() вернул ошибку: %v", err)
+	}
+	if string(cfg.Secret) != DefaultSecret || cfg.Issuer != DefaultIssuer || cfg.Audience != DefaultAudience ||
+		cfg.TTL != DefaultTTL || cfg.Leeway != DefaultLeeway || cfg.RefreshTTL != DefaultRefreshTTL {
+		t.Errorf("Ожидались настройки по умолчанию, получено %+v", cfg)
+	}
+}
+
+func TestConfigFromEnvDefaultSecretOutsideDevMode(t *testing.T) {
+	clearAuthEnv(t)
+	for _, env := range []string{"", "production"} {
+		t.Setenv("APP_ENV", env)
+
+		_, err := ConfigFromEnv()
+		if !errors.Is(err, ErrDefaultSecret) {
+			t.Errorf("APP_ENV=%q: ожидалась ErrDefaultSecret, получено %v", env, err)
+		}
+	}
+}
+
+func TestConfigFromEnvOverrides(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("JWT_ISSUER", "issuer")
+	t.Setenv("JWT_AUDIENCE", "audience")
+	t.Setenv("JWT_TTL", "15m")
+	t.Setenv("JWT_LEEWAY", "5s")
+	t.Setenv("JWT_REFRESH_TTL", "168h")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() вернул ошибку: %v", err)
+	}
+	if string(cfg.Secret) != testSecret || cfg.Issuer != "issuer" || cfg.Audience != "audience" ||
+		cfg.TTL != 15*time.Minute || cfg.Leeway != 5*time.Second || cfg.RefreshTTL != 7*24*time.Hour {
+		t.Errorf("Неправильные настройки: %+v", cfg)
+	}
+}
+
+func TestConfigFromEnvSecretFile(t *testing.T) {
+	clearAuthEnv(t)
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	if err := os.WriteFile(path, []byte(testSecret+"\n"), 0600); err != nil {
+		t.Fatalf("Не удалось записать файл секрета: %v", err)
+	}
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_SECRET_FILE", path)
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv() вернул ошибку: %v", err)
+	}
+	if string(cfg.Secret) != testSecret {
+		t.Errorf("Перевод строки должен обрезаться, получено %q", cfg.Secret)
+	}
+}
+
+func TestConfigFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"оба источника секрета", map[string]string{"JWT_SECRET": testSecret, "JWT_SECRET_FILE": "/run/secrets/jwt"}},
+		{"нет файла секрета", map[string]string{"JWT_SECRET_FILE": filepath.Join(os.TempDir(), "missing-jwt-secret")}},
+		{"короткий секрет", map[string]string{"JWT_SECRET": "short"}},
+		{"неверный TTL", map[string]string{"JWT_SECRET": testSecret, "JWT_TTL": "day"}},
+		{"нулевой TTL", map[string]string{"JWT_SECRET": testSecret, "JWT_TTL": "0s"}},
+		{"отрицательный leeway", map[string]string{"JWT_SECRET": testSecret, "JWT_LEEWAY": "-1s"}},
+		{"refresh короче access", map[string]string{"JWT_SECRET": testSecret, "JWT_TTL": "1h", "JWT_REFRESH_TTL": "30m"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAuthEnv(t)
+			t.Setenv("APP_ENV", "production")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Error("Ожидалась ошибка конфигурации")
+			}
+		})
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ проверки издателя, аудитории и срока токена
+// ============================================================================
+
+func TestGenerateTokenUsesConfig(t *testing.T) {
+	withConfig(t, Config{Secret: []byte(testSecret), Issuer: "issuer", Audience: "audience", TTL: time.Hour, Leeway: time.Second})
+
+	token, err := GenerateToken(1, "testuser")
+	if err != nil {
+		t.Fatalf("GenerateToken() вернул ошибку: %v", err)
+	}
+	claims, err := ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() вернул ошибку: %v", err)
+	}
+
+	if claims.Issuer != "issuer" || len(claims.Audience) != 1 || claims.Audience[0] != "audience" {
+		t.Errorf("Неправильные iss/aud: %+v", claims.RegisteredClaims)
+	}
+	diff := claims.ExpiresAt.Time.Sub(time.Now().Add(time.Hour))
+	if diff < -time.Minute || diff > time.Minute {
+		t.Errorf("ExpiresAt не соответствует TTL: разница %v", diff)
+	}
+}
+
+func TestValidateTokenRejectsForeignIssuerAndAudience(t *testing.T) {
+	cfg := Config{Secret: []byte(testSecret), Issuer: "issuer", Audience: "audience", TTL: time.Hour}
+	withConfig(t, cfg)
+
+	sign := func(issuer, audience string) string {
+		claims := &Claims{
+			UserID: 1,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "test-jti",
+				Issuer:    issuer,
+				Audience:  jwt.ClaimStrings{audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.Secret)
+		return token
+	}
+
+	if _, err := ValidateToken(sign("issuer", "audience")); err != nil {
+		t.Fatalf("ValidateToken() отклонил правильный токен: %v", err)
+	}
+	if _, err := ValidateToken(sign("other", "audience")); err == nil {
+		t.Error("ValidateToken() должен отклонить токен другого издателя")
+	}
+	if _, err := ValidateToken(sign("issuer", "other")); err == nil {
+		t.Error("ValidateToken() должен отклонить токен для другой аудитории")
+	}
+}
+
+func TestValidateTokenRequiresExpiration(t *testing.T) {
+	withConfig(t, DefaultConfig())
+
+	claims := &Claims{
+		UserID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   DefaultIssuer,
+			Audience: jwt.ClaimStrings{DefaultAudience},
+		},
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(DefaultSecret))
+
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("ValidateToken() должен отклонить токен без exp")
+	}
+}
+
+func TestValidateTokenLeeway(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Leeway = time.Minute
+	withConfig(t, cfg)
+
+	// Истёк 10 секунд назад — в пределах допуска
+	claims := &Claims{
+		UserID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "test-jti",
+			Issuer:    DefaultIssuer,
+			Audience:  jwt.ClaimStrings{DefaultAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-10 * time.Second)),
+		},
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.Secret)
+
+	if _, err := ValidateToken(token); err != nil {
+		t.Errorf("ValidateToken() должен принять токен в пределах leeway: %v", err)
+	}
+
+	cfg.Leeway = 0
+	Configure(cfg)
+	if _, err := ValidateToken(token); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Без leeway ожидалась ошибка истечения, получено %v", err)
+	}
+}
+
+func TestGenerateTokenSetsUniqueJTI(t *testing.T) {
+	withConfig(t, DefaultConfig())
+
+	first, _ := GenerateToken(1, "testuser")
+	second, _ := GenerateToken(1, "testuser")
+
+	a, err := ValidateToken(first)
+	if err != nil {
+		t.Fatalf("ValidateToken() вернул ошибку: %v", err)
+	}
+	b, _ := ValidateToken(second)
+	if a.ID == "" || a.ID == b.ID {
+		t.Errorf("Ожидались разные непустые jti, получено %q и %q", a.ID, b.ID)
+	}
+}
+
+func TestValidateTokenRequiresJTI(t *testing.T) {
+	withConfig(t, DefaultConfig())
+
+	claims := &Claims{
+		UserID: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    DefaultIssuer,
+			Audience:  jwt.ClaimStrings{DefaultAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(DefaultSecret))
+
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("ValidateToken() должен отклонить токен без jti")
+	}
+}
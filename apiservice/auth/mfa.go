@@ -0,0 +1,23 @@
+package auth
+
+import "time"
+
+// MFATokenTTL сколько действует токен второго шага входа: за это время нужно ввести код из приложения
+const MFATokenTTL = 5 * time.Minute
+
+// mfaAudience аудитория токена второго шага. Она отличается от аудитории access-токенов,
+// поэтому ValidateToken его не примет и обратиться с ним к API нельзя
+func mfaAudience() string {
+	return config.Audience + "/mfa"
+}
+
+// GenerateMFAToken выпускает токен второго шага входа после проверки пароля пользователя
+// с включённой двухфакторной аутентификацией. Обменивается на access-токен вместе с кодом
+func GenerateMFAToken(userID int, username string) (string, error) {
+	return signToken(userID, username, mfaAudience(), MFATokenTTL)
+}
+
+// ValidateMFAToken проверяет токен второго шага так же, как ValidateToken проверяет access-токен
+func ValidateMFAToken(tokenString string) (*Claims, error) {
+	return parseToken(tokenString, mfaAudience())
+}
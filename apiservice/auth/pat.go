@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// PATPrefix начало персонального токена доступа. По нему AuthMiddleware отличает токен от JWT,
+// а сканеры секретов находят токен, случайно попавший в репозиторий
+const PATPrefix = "todo_pat_"
+
+// ErrPATInvalid персональный токен неизвестен, отозван или истёк
+var ErrPATInvalid = errors.New("personal access token not found, revoked or expired")
+
+// Области доступа персональных токенов. Право на запись включает право на чтение того же ресурса
+const (
+	ScopeTasksRead        = "tasks:read"
+	ScopeTasksWrite       = "tasks:write"
+	ScopeCollectionsRead  = "collections:read"
+	ScopeCollectionsWrite = "collections:write"
+	ScopeTagsRead         = "tags:read"
+	ScopeTagsWrite        = "tags:write"
+)
+
+// Scopes все области доступа, которые можно выдать токену
+var Scopes = []string{
+	ScopeTasksRead, ScopeTasksWrite,
+	ScopeCollectionsRead, ScopeCollectionsWrite,
+	ScopeTagsRead, ScopeTagsWrite,
+}
+
+// ValidScope проверяет, что scope — известная область доступа
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// HasScope проверяет, разрешает ли набор областей granted область required
+func HasScope(granted []string, required string) bool {
+	if slices.Contains(granted, required) {
+		return true
+	}
+	resource, ok := strings.CutSuffix(required, ":read")
+	return ok && slices.Contains(granted, resource+":write")
+}
+
+// NewPersonalAccessToken создаёт персональный токен доступа для пользователя и его хэш для db-service.
+// Сам токен показывается один раз и нигде не хранится
+func NewPersonalAccessToken() (token, hash string, err error) {
+	raw, _, err := NewRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = PATPrefix + raw
+	return token, HashPersonalAccessToken(token), nil
+}
+
+// IsPersonalAccessToken проверяет, похожа ли строка из заголовка Authorization на персональный токен
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PATPrefix)
+}
+
+// HashPersonalAccessToken хэш токена вместе с префиксом, считается так же, как у refresh-токена
+func HashPersonalAccessToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ персональных токенов доступа
+// ============================================================================
+
+func TestNewPersonalAccessToken(t *testing.T) {
+	token, hash, err := NewPersonalAccessToken()
+	if err != nil {
+		t.Fatalf("NewPersonalAccessToken() вернул ошибку: %v", err)
+	}
+
+	if !regexp.MustCompile(`^todo_pat_[A-Za-z0-9_-]{43}$`).MatchString(token) {
+		t.Errorf("Неправильный формат токена %q", token)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Error("IsPersonalAccessToken() не узнал новый токен")
+	}
+	if hash != HashPersonalAccessToken(token) {
+		t.Error("Хэш не совпадает с HashPersonalAccessToken(token)")
+	}
+
+	access, _ := GenerateToken(1, "testuser")
+	if IsPersonalAccessToken(access) {
+		t.Error("JWT не должен считаться персональным токеном")
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required string
+		want     bool
+	}{
+		{[]string{ScopeTasksRead}, ScopeTasksRead, true},
+		{[]string{ScopeTasksWrite}, ScopeTasksRead, true},
+		{[]string{ScopeTasksRead}, ScopeTasksWrite, false},
+		{[]string{ScopeTagsWrite}, ScopeTasksRead, false},
+		{[]string{ScopeCollectionsWrite}, ScopeCollectionsWrite, true},
+		{nil, ScopeTasksRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasScope(tt.granted, tt.required); got != tt.want {
+			t.Errorf("HasScope(%v, %q) = %v, ожидалось %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("%q должна быть известной областью", scope)
+		}
+	}
+	for _, scope := range []string{"", "tasks", "tasks:admin", "account:write"} {
+		if ValidScope(scope) {
+			t.Errorf("%q не должна быть известной областью", scope)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// refreshTokenBytes сколько случайных байт в refresh-токене
+const refreshTokenBytes = 32
+
+// newTokenID случайный jti access-токена
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewRefreshToken создаёт непрозрачный refresh-токен для клиента и его хэш для db-service.
+// Сам токен нигде не хранится
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken sha256 токена в hex. Токен случайный и длинный, поэтому соль и медленный хэш не нужны
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewPasswordResetToken создаёт одноразовый токен сброса пароля для письма и его хэш для db-service
+func NewPasswordResetToken() (token, hash string, err error) {
+	return NewRefreshToken()
+}
+
+// HashPasswordResetToken хэш токена сброса, считается так же, как у refresh-токена
+func HashPasswordResetToken(token string) string {
+	return HashRefreshToken(token)
+}
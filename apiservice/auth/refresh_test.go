@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"regexp"
+	"testing"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ REFRESH-ТОКЕНОВ
+// ============================================================================
+
+func TestNewRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken() вернул ошибку: %v", err)
+	}
+
+	if len(token) != 43 {
+		t.Errorf("Ожидался токен из 43 символов base64url, получено %q", token)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(hash) {
+		t.Errorf("Хэш должен быть sha256 в hex, получено %q", hash)
+	}
+	if hash != HashRefreshToken(token) {
+		t.Error("Хэш не совпадает с HashRefreshToken(token)")
+	}
+
+	other, _, _ := NewRefreshToken()
+	if other == token {
+		t.Error("NewRefreshToken() вернул одинаковые токены")
+	}
+}
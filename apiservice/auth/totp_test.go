@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"encoding/base32"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ TOTP
+// ============================================================================
+
+// rfcSecret ключ "12345678901234567890" из тестовых векторов RFC 6238 в base32
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Тестовые векторы SHA1 из приложения B RFC 6238, последние 6 цифр
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() вернул ошибку: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("t=%d: ожидался код %s, получен %s", tt.unix, tt.code, code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	prev, _ := TOTPCode(rfcSecret, step-1)
+	current, _ := TOTPCode(rfcSecret, step)
+	old, _ := TOTPCode(rfcSecret, step-3)
+
+	if got, ok := MatchTOTP(rfcSecret, current, now, 0); !ok || got != step {
+		t.Errorf("Текущий код должен подойти, получено %d, %v", got, ok)
+	}
+	// Часы телефона отстают на шаг
+	if got, ok := MatchTOTP(rfcSecret, prev, now, 0); !ok || got != step-1 {
+		t.Errorf("Код предыдущего шага должен подойти, получено %d, %v", got, ok)
+	}
+	if _, ok := MatchTOTP(rfcSecret, old, now, 0); ok {
+		t.Error("Код трёх шагов назад не должен подойти")
+	}
+	// Код уже принят
+	if _, ok := MatchTOTP(rfcSecret, current, now, step); ok {
+		t.Error("Повторный код не должен подойти")
+	}
+	if _, ok := MatchTOTP(rfcSecret, "12345", now, 0); ok {
+		t.Error("Код неправильной длины не должен подойти")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatalf("NewTOTPSecret() вернул ошибку: %v", err)
+	}
+	if !regexp.MustCompile(`^[A-Z2-7]{32}$`).MatchString(secret) {
+		t.Errorf("Ожидалось 32 символа base32 без выравнивания, получено %q", secret)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("TOTPCode() не принял новый секрет: %v", err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("JBSWY3DPEHPK3PXP", "To-Do List", "user 1")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/To-Do%20List:user%201?") {
+		t.Errorf("Неправильная метка: %s", uri)
+	}
+	for _, want := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=To-Do+List", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("В %s нет %s", uri, want)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ кодов восстановления и токена второго шага
+// ============================================================================
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes() вернул ошибку: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("Ожидалось %d кодов, получено %d и %d хэшей", RecoveryCodeCount, len(codes), len(hashes))
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`).MatchString(code) {
+			t.Errorf("Неправильный формат кода %q", code)
+		}
+		if hashes[i] != HashRecoveryCode(code) {
+			t.Errorf("Хэш %d не совпадает с HashRecoveryCode", i)
+		}
+		if seen[code] {
+			t.Errorf("Код %q повторяется", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := HashRecoveryCode("abcd-efgh-ijkl-mnop")
+	for _, code := range []string{"ABCD-EFGH-IJKL-MNOP", "abcdefghijklmnop", " abcd efgh ijkl mnop "} {
+		if HashRecoveryCode(code) != want {
+			t.Errorf("%q должен давать тот же хэш", code)
+		}
+	}
+}
+
+func TestMFATokenIsNotAccessToken(t *testing.T) {
+	token, err := GenerateMFAToken(1, "testuser")
+	if err != nil {
+		t.Fatalf("GenerateMFAToken() вернул ошибку: %v", err)
+	}
+
+	claims, err := ValidateMFAToken(token)
+	if err != nil || claims.UserID != 1 || claims.Username != "testuser" {
+		t.Errorf("ValidateMFAToken() = %+v, %v", claims, err)
+	}
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("Токен второго шага не должен проходить как access-токен")
+	}
+
+	access, _ := GenerateToken(1, "testuser")
+	if _, err := ValidateMFAToken(access); err == nil {
+		t.Error("Access-токен не должен проходить как токен второго шага")
+	}
+}
@@ -4,8 +4,22 @@ import (
 	models "apiservice/models"
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+)
+
+// Ошибки, которые db-service возвращает кодом ответа
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrBadRequest = errors.New("bad request")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
 )
 
 type DBClient struct {
@@ -20,19 +34,45 @@ func NewDBClient(baseURL string) *DBClient {
 	}
 }
 
+// checkStatus превращает неуспешный ответ db-service в ошибку
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	msg := strings.TrimSpace(string(body))
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, msg)
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrBadRequest, msg)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrConflict, msg)
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrForbidden, msg)
+	}
+	return fmt.Errorf("db-service returned %d: %s", resp.StatusCode, msg)
+}
+
 func (c *DBClient) CreateTask(task *models.CreateTaskRequest, userID int) (*models.Task, error) {
 	jsonData, err := json.Marshal(task)
 	if err != nil {
 		return nil, err
 	}
 
-	url := c.BaseURL + "/create?user_id=" + strconv.Itoa(userID)
+	url := c.BaseURL + "/create?user_id=" + strconv.Itoa(userID) + existingTagsOnlyParam(task.ExistingTagsOnly)
 	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
 	var createdTask models.Task
 	if err := json.NewDecoder(resp.Body).Decode(&createdTask); err != nil {
 		return nil, err
@@ -41,52 +81,141 @@ func (c *DBClient) CreateTask(task *models.CreateTaskRequest, userID int) (*mode
 	return &createdTask, nil
 }
 
-func (c *DBClient) GetAllTasks(userID int) ([]models.Task, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/get?user_id=" + strconv.Itoa(userID))
+// listParams собирает query-параметры списка задач вместе с сортировкой
+func listParams(userID int, opts models.ListOptions) url.Values {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	if opts.Sort != "" {
+		params.Set("sort", opts.Sort)
+	}
+	if opts.Order != "" {
+		params.Set("order", opts.Order)
+	}
+	setPageParams(params, opts.PageParams)
+	return params
+}
+
+func setPageParams(params url.Values, page models.PageParams) {
+	if page.Limit > 0 {
+		params.Set("limit", strconv.Itoa(page.Limit))
+	}
+	if page.Cursor != "" {
+		params.Set("cursor", page.Cursor)
+	}
+}
+
+// existingTagsOnlyParam продолжение query-строки, запрещающее db-service создавать теги задач
+func existingTagsOnlyParam(existingOnly bool) string {
+	if existingOnly {
+		return "&existing_tags_only=true"
+	}
+	return ""
+}
+
+// decodeList читает список db-сервиса: конверт при пагинации или голый массив в режиме совместимости
+func decodeList[T any](body io.Reader, page models.PageParams) (*models.Page[T], error) {
+	var result models.Page[T]
+	if page.Paginated() {
+		if err := json.NewDecoder(body).Decode(&result); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+
+	if err := json.NewDecoder(body).Decode(&result.Items); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
 
+// getTasks выполняет GET и декодирует страницу задач
+func (c *DBClient) getTasks(path string, params url.Values, page models.PageParams) (*models.Page[models.Task], error) {
+	resp, err := c.Client.Get(c.BaseURL + path + "?" + params.Encode())
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var tasks []models.Task
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+	if err := checkStatus(resp); err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	return decodeList[models.Task](resp.Body, page)
+}
+
+func (c *DBClient) GetAllTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
+	return c.getTasks("/get", listParams(userID, opts), opts.PageParams)
 }
 
-func (c *DBClient) GetCompleted(userID int) ([]models.Task, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/get?complete=true&user_id=" + strconv.Itoa(userID))
+func (c *DBClient) GetCompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	params.Set("complete", "true")
+	return c.getTasks("/get", params, opts.PageParams)
+}
 
-	if err != nil {
-		return nil, err
+func (c *DBClient) GetUncompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	params.Set("complete", "false")
+	return c.getTasks("/get", params, opts.PageParams)
+}
+
+func (c *DBClient) GetTasksByDue(userID int, due, tz string, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	params.Set("due", due)
+	if tz != "" {
+		params.Set("tz", tz)
 	}
-	defer resp.Body.Close()
+	return c.getTasks("/get", params, opts.PageParams)
+}
 
-	var tasks []models.Task
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
-		return nil, err
+// GetTasksByTags: matchAll=true — задачи со всеми тегами, иначе с любым из них
+func (c *DBClient) GetTasksByTags(userID int, tags []string, matchAll bool, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	params.Set("tag", strings.Join(tags, ","))
+	if matchAll {
+		params.Set("match", "all")
 	}
+	return c.getTasks("/get", params, opts.PageParams)
+}
 
-	return tasks, nil
+// GetAssignedTasks задачи, поручённые пользователю другими: ожидающие ответа и принятые
+func (c *DBClient) GetAssignedTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	params.Set("assigned", "me")
+	return c.getTasks("/get", params, opts.PageParams)
 }
 
-func (c *DBClient) GetUncompleted(userID int) ([]models.Task, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/get?complete=false&user_id=" + strconv.Itoa(userID))
+// SearchTasks полнотекстовый поиск по названию и описанию задач пользователя
+func (c *DBClient) SearchTasks(userID int, query string, opts models.SearchOptions) ([]models.SearchResult, error) {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	params.Set("q", query)
+	if opts.CollectionID != nil {
+		params.Set("collection_id", strconv.Itoa(*opts.CollectionID))
+	}
+	if opts.Complete != nil {
+		params.Set("complete", strconv.FormatBool(*opts.Complete))
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
 
+	resp, err := c.Client.Get(c.BaseURL + "/search?" + params.Encode())
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var tasks []models.Task
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var results []models.SearchResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	return results, nil
 }
 
 func (c *DBClient) DeleteTask(id, userID int) error {
@@ -108,10 +237,38 @@ func (c *DBClient) DeleteTask(id, userID int) error {
 	return nil
 }
 
-func (c *DBClient) CompleteTask(id, userID int) error {
+// CompleteTask отмечает задачу выполненной и возвращает следующую задачу серии, если она создана
+func (c *DBClient) CompleteTask(id, userID int) (*models.Task, error) {
 	idStr := strconv.Itoa(id)
 	url := c.BaseURL + "/complete/" + idStr + "?user_id=" + strconv.Itoa(userID)
 
+	req, err := http.NewRequest("PUT", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var result models.CompleteTaskResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return result.NextTask, nil
+}
+
+func (c *DBClient) ReopenTask(id, userID int) error {
+	idStr := strconv.Itoa(id)
+	url := c.BaseURL + "/uncomplete/" + idStr + "?user_id=" + strconv.Itoa(userID)
+
 	req, err := http.NewRequest("PUT", url, nil)
 	if err != nil {
 		return err
@@ -123,12 +280,43 @@ func (c *DBClient) CompleteTask(id, userID int) error {
 	}
 	defer resp.Body.Close()
 
-	return nil
+	return checkStatus(resp)
 }
 
-func (c *DBClient) GetTaskByID(id int) (*models.Task, error) {
-	idStr := strconv.Itoa(id)
-	url := c.BaseURL + "/getbyid/" + idStr
+func (c *DBClient) UpdateTask(id, userID int, task *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+	jsonData, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/update/" + strconv.Itoa(id) + "?user_id=" + strconv.Itoa(userID) + existingTagsOnlyParam(task.ExistingTagsOnly)
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var updated models.UpdateTaskResponse
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// GetTaskByID возвращает задачу пользователя. Чужая задача даёт ErrNotFound
+func (c *DBClient) GetTaskByID(id, userID int) (*models.Task, error) {
+	url := c.BaseURL + "/getbyid/" + strconv.Itoa(id) + "?user_id=" + strconv.Itoa(userID)
 
 	resp, err := c.Client.Get(url)
 	if err != nil {
@@ -136,6 +324,10 @@ func (c *DBClient) GetTaskByID(id int) (*models.Task, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
 	var task models.Task
 	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
 		return nil, err
@@ -144,15 +336,18 @@ func (c *DBClient) GetTaskByID(id int) (*models.Task, error) {
 	return &task, nil
 }
 
-func (c *DBClient) GetTaskByName(name string) (*models.Task, error) {
-	url := c.BaseURL + "/getbyname/" + name
-
-	resp, err := c.Client.Get(url)
+// GetTaskByName возвращает задачу пользователя по точному названию
+func (c *DBClient) GetTaskByName(name string, userID int) (*models.Task, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/getbyname/" + url.PathEscape(name) + "?user_id=" + strconv.Itoa(userID))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
 	var task models.Task
 	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
 		return nil, err
@@ -176,6 +371,10 @@ func (c *DBClient) CreateCollection(req *models.CreateCollectionRequest, userID
 	}
 	defer resp.Body.Close()
 
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
 	var collection models.Collection
 	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
 		return nil, err
@@ -184,19 +383,105 @@ func (c *DBClient) CreateCollection(req *models.CreateCollectionRequest, userID
 	return &collection, nil
 }
 
-func (c *DBClient) GetCollections(userID int) ([]models.Collection, error) {
-	resp, err := c.Client.Get(c.BaseURL + "/collections?user_id=" + strconv.Itoa(userID))
+func (c *DBClient) GetCollections(userID int, page models.PageParams) (*models.Page[models.Collection], error) {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	setPageParams(params, page)
+
+	resp, err := c.Client.Get(c.BaseURL + "/collections?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeList[models.Collection](resp.Body, page)
+}
+
+// MoveTasks переносит задачи в коллекцию и возвращает только те, у которых коллекция сменилась
+func (c *DBClient) MoveTasks(move *models.MoveTasksRequest, userID int) ([]models.TaskMove, error) {
+	jsonData, err := json.Marshal(move)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/tasks/move?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var moves []models.TaskMove
+	if err := json.NewDecoder(resp.Body).Decode(&moves); err != nil {
+		return nil, err
+	}
+
+	return moves, nil
+}
+
+// BulkTasks выполняет пакет операций над задачами; ошибки отдельных операций приходят в их результатах
+func (c *DBClient) BulkTasks(req *models.BulkTasksRequest, userID int) ([]models.BulkResult, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/tasks/bulk?user_id=" + strconv.Itoa(userID) + existingTagsOnlyParam(req.ExistingTagsOnly)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var results []models.BulkResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (c *DBClient) UpdateCollection(id, userID int, upd *models.UpdateCollectionRequest) (*models.Collection, error) {
+	jsonData, err := json.Marshal(upd)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/collections/" + strconv.Itoa(id) + "?user_id=" + strconv.Itoa(userID)
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var collections []models.Collection
-	if err := json.NewDecoder(resp.Body).Decode(&collections); err != nil {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
 		return nil, err
 	}
 
-	return collections, nil
+	return &collection, nil
 }
 
 func (c *DBClient) DeleteCollection(collectionID, userID int) error {
@@ -206,22 +491,1038 @@ func (c *DBClient) DeleteCollection(collectionID, userID int) error {
 		return err
 	}
 
-	_, err = c.Client.Do(req)
-	return err
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
 }
 
-func (c *DBClient) GetTasksByCollection(collectionID, userID int) ([]models.Task, error) {
-	url := c.BaseURL + "/collections/" + strconv.Itoa(collectionID) + "/tasks?user_id=" + strconv.Itoa(userID)
-	resp, err := c.Client.Get(url)
+// GetTasksByCollection задачи коллекции; recursive=true — вместе с задачами вложенных коллекций
+func (c *DBClient) GetTasksByCollection(collectionID, userID int, recursive bool, opts models.ListOptions) (*models.Page[models.Task], error) {
+	params := listParams(userID, opts)
+	if recursive {
+		params.Set("recursive", "true")
+	}
+	return c.getTasks("/collections/"+strconv.Itoa(collectionID)+"/tasks", params, opts.PageParams)
+}
+
+// MoveCollection вкладывает коллекцию в другую или поднимает на верхний уровень
+func (c *DBClient) MoveCollection(id, userID int, move *models.MoveCollectionRequest) (*models.Collection, error) {
+	jsonData, err := json.Marshal(move)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/collections/" + strconv.Itoa(id) + "/move?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// ReorderCollection ставит коллекцию перед или после соседней
+func (c *DBClient) ReorderCollection(id, userID int, req *models.ReorderRequest) (*models.Collection, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/collections/" + strconv.Itoa(id) + "/reorder?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+// Assignment methods
+
+// postTask выполняет POST /tasks/{id}/{action} и декодирует задачу из ответа
+func (c *DBClient) postTask(id, userID int, action string, body any) (*models.Task, error) {
+	var payload io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewBuffer(jsonData)
+	}
+
+	url := c.BaseURL + "/tasks/" + strconv.Itoa(id) + "/" + action + "?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+// AssignTask поручает задачу другому пользователю; поручение ждёт его ответа
+func (c *DBClient) AssignTask(id, userID int, req *models.AssignTaskRequest) (*models.Task, error) {
+	return c.postTask(id, userID, "assign", req)
+}
+
+func (c *DBClient) AcceptTask(id, userID int) (*models.Task, error) {
+	return c.postTask(id, userID, "accept", nil)
+}
+
+func (c *DBClient) DeclineTask(id, userID int) (*models.Task, error) {
+	return c.postTask(id, userID, "decline", nil)
+}
+
+// ReorderTask ставит задачу перед или после другой задачи того же списка
+func (c *DBClient) ReorderTask(id, userID int, req *models.ReorderRequest) (*models.Task, error) {
+	return c.postTask(id, userID, "reorder", req)
+}
+
+// Member methods
+
+// membersURL: /collections/{collectionID}/members{suffix}?user_id=
+func (c *DBClient) membersURL(collectionID, userID int, suffix string) string {
+	return c.BaseURL + "/collections/" + strconv.Itoa(collectionID) + "/members" + suffix + "?user_id=" + strconv.Itoa(userID)
+}
+
+func (c *DBClient) GetMembers(collectionID, userID int) ([]models.Member, error) {
+	resp, err := c.Client.Get(c.membersURL(collectionID, userID, ""))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var tasks []models.Task
-	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var members []models.Member
+	if err := json.NewDecoder(resp.Body).Decode(&members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (c *DBClient) AddMember(collectionID, userID int, req *models.AddMemberRequest) (*models.Member, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.membersURL(collectionID, userID, ""), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var member models.Member
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (c *DBClient) UpdateMember(collectionID, memberID, userID int, upd *models.UpdateMemberRequest) (*models.Member, error) {
+	jsonData, err := json.Marshal(upd)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("PATCH", c.membersURL(collectionID, userID, "/"+strconv.Itoa(memberID)), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var member models.Member
+	if err := json.NewDecoder(resp.Body).Decode(&member); err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (c *DBClient) RemoveMember(collectionID, memberID, userID int) error {
+	req, err := http.NewRequest("DELETE", c.membersURL(collectionID, userID, "/"+strconv.Itoa(memberID)), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// Trash methods
+
+// trashURL: /trash{suffix}?user_id=[&type=]
+func (c *DBClient) trashURL(suffix string, userID int, itemType string) string {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	if itemType != "" {
+		params.Set("type", itemType)
+	}
+	return c.BaseURL + "/trash" + suffix + "?" + params.Encode()
+}
+
+func (c *DBClient) GetTrash(userID int) (*models.Trash, error) {
+	resp, err := c.Client.Get(c.trashURL("", userID, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var trash models.Trash
+	if err := json.NewDecoder(resp.Body).Decode(&trash); err != nil {
+		return nil, err
+	}
+
+	return &trash, nil
+}
+
+func (c *DBClient) RestoreTask(id, userID int) (*models.Task, error) {
+	var task models.Task
+	if err := c.restore(id, userID, models.TrashTask, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *DBClient) RestoreCollection(id, userID int) (*models.Collection, error) {
+	var collection models.Collection
+	if err := c.restore(id, userID, models.TrashCollection, &collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// restore возвращает элемент из корзины и декодирует восстановленный объект в out
+func (c *DBClient) restore(id, userID int, itemType string, out interface{}) error {
+	resp, err := c.Client.Post(c.trashURL("/"+strconv.Itoa(id)+"/restore", userID, itemType), "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *DBClient) PurgeTask(id, userID int) error {
+	return c.purge(id, userID, models.TrashTask)
+}
+
+func (c *DBClient) PurgeCollection(id, userID int) error {
+	return c.purge(id, userID, models.TrashCollection)
+}
+
+func (c *DBClient) purge(id, userID int, itemType string) error {
+	req, err := http.NewRequest("DELETE", c.trashURL("/"+strconv.Itoa(id), userID, itemType), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+func (c *DBClient) EmptyTrash(userID int) (*models.PurgeResult, error) {
+	req, err := http.NewRequest("DELETE", c.trashURL("", userID, ""), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var purged models.PurgeResult
+	if err := json.NewDecoder(resp.Body).Decode(&purged); err != nil {
+		return nil, err
+	}
+
+	return &purged, nil
+}
+
+func (c *DBClient) CreateTag(req *models.CreateTagRequest, userID int) (*models.Tag, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/tags?user_id="+strconv.Itoa(userID), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var tag models.Tag
+	if err := json.NewDecoder(resp.Body).Decode(&tag); err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (c *DBClient) GetTags(userID int, page models.PageParams) (*models.Page[models.Tag], error) {
+	params := url.Values{}
+	params.Set("user_id", strconv.Itoa(userID))
+	setPageParams(params, page)
+
+	resp, err := c.Client.Get(c.BaseURL + "/tags?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeList[models.Tag](resp.Body, page)
+}
+
+func (c *DBClient) UpdateTag(id, userID int, upd *models.UpdateTagRequest) (*models.Tag, error) {
+	jsonData, err := json.Marshal(upd)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.BaseURL + "/tags/" + strconv.Itoa(id) + "?user_id=" + strconv.Itoa(userID)
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var tag models.Tag
+	if err := json.NewDecoder(resp.Body).Decode(&tag); err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (c *DBClient) DeleteTag(id, userID int) error {
+	url := c.BaseURL + "/tags/" + strconv.Itoa(id) + "?user_id=" + strconv.Itoa(userID)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// checklistURL: /tasks/{taskID}/checklist{suffix}?user_id=
+func (c *DBClient) checklistURL(taskID, userID int, suffix string) string {
+	return c.BaseURL + "/tasks/" + strconv.Itoa(taskID) + "/checklist" + suffix + "?user_id=" + strconv.Itoa(userID)
+}
+
+func (c *DBClient) GetChecklist(taskID, userID int) ([]models.ChecklistItem, error) {
+	resp, err := c.Client.Get(c.checklistURL(taskID, userID, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var items []models.ChecklistItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (c *DBClient) AddChecklistItem(taskID, userID int, req *models.AddChecklistItemRequest) (*models.ChecklistItem, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.checklistURL(taskID, userID, "")
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var item models.ChecklistItem
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func (c *DBClient) UpdateChecklistItem(taskID, itemID, userID int, upd *models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+	jsonData, err := json.Marshal(upd)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.checklistURL(taskID, userID, "/"+strconv.Itoa(itemID))
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var item models.ChecklistItem
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func (c *DBClient) DeleteChecklistItem(taskID, itemID, userID int) error {
+	url := c.checklistURL(taskID, userID, "/"+strconv.Itoa(itemID))
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+func (c *DBClient) ReorderChecklist(taskID, userID int, req *models.ReorderChecklistRequest) ([]models.ChecklistItem, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.checklistURL(taskID, userID, "/order")
+	httpReq, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var items []models.ChecklistItem
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// User and token methods
+
+func (c *DBClient) CreateUser(req *models.CreateUserRequest) (*models.User, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/user/create", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserByUsername возвращает пользователя вместе с хэшем пароля
+func (c *DBClient) GetUserByUsername(username string) (*models.User, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/user/" + url.PathEscape(username))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (c *DBClient) CreateRefreshToken(userID int, req *models.CreateRefreshTokenRequest) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	url := c.BaseURL + "/tokens?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// RotateRefreshToken обменивает refresh-токен и возвращает владельца. Если токен уже обменивался,
+// возвращает владельца отозванной цепочки вместе с ErrConflict; неизвестный или истёкший токен — ErrNotFound
+func (c *DBClient) RotateRefreshToken(req *models.RotateRefreshTokenRequest) (*models.User, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/tokens/rotate", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		var reuse models.RefreshTokenReuse
+		if err := json.NewDecoder(resp.Body).Decode(&reuse); err != nil {
+			return nil, err
+		}
+		return &models.User{ID: reuse.UserID, Username: reuse.Username}, fmt.Errorf("%w: %s", ErrConflict, reuse.Error)
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (c *DBClient) RevokeToken(userID int, req *models.RevokeTokenRequest) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	url := c.BaseURL + "/revocations?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// RevokeAllTokens отзывает все токены пользователя и возвращает момент отзыва
+func (c *DBClient) RevokeAllTokens(userID int) (time.Time, error) {
+	url := c.BaseURL + "/revocations/all?user_id=" + strconv.Itoa(userID)
+	resp, err := c.Client.Post(url, "application/json", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return time.Time{}, err
+	}
+
+	var revocation models.UserRevocation
+	if err := json.NewDecoder(resp.Body).Decode(&revocation); err != nil {
+		return time.Time{}, err
+	}
+
+	return revocation.RevokedBefore, nil
+}
+
+// GetRevocations действующие отзывы токенов для revocation.Cache
+func (c *DBClient) GetRevocations(since time.Time) (*models.Revocations, error) {
+	resp, err := c.Client.Get(c.BaseURL + "/revocations?since=" + url.QueryEscape(since.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var revocations models.Revocations
+	if err := json.NewDecoder(resp.Body).Decode(&revocations); err != nil {
+		return nil, err
+	}
+
+	return &revocations, nil
+}
+
+// ChangePassword сохраняет новый хэш пароля; db-service отзывает все токены пользователя и возвращает момент отзыва
+func (c *DBClient) ChangePassword(userID int, body *models.UpdatePasswordRequest) (time.Time, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	url := c.BaseURL + "/account/password?user_id=" + strconv.Itoa(userID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return time.Time{}, err
+	}
+
+	var revocation models.UserRevocation
+	if err := json.NewDecoder(resp.Body).Decode(&revocation); err != nil {
+		return time.Time{}, err
+	}
+
+	return revocation.RevokedBefore, nil
+}
+
+// DeleteUser удаляет пользователя со всеми его данными
+func (c *DBClient) DeleteUser(userID int) error {
+	req, err := http.NewRequest("DELETE", c.BaseURL+"/account?user_id="+strconv.Itoa(userID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// UpdateEmail меняет почту пользователя. ErrConflict — почта занята
+func (c *DBClient) UpdateEmail(userID int, email string) error {
+	jsonData, err := json.Marshal(models.UpdateEmailRequest{Email: email})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", c.BaseURL+"/account/email?user_id="+strconv.Itoa(userID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// CreatePasswordReset сохраняет хэш токена сброса и возвращает владельца почты. ErrNotFound — почта не зарегистрирована
+func (c *DBClient) CreatePasswordReset(req *models.CreatePasswordResetRequest) (*models.User, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/password-resets", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ConsumePasswordReset гасит токен сброса и сохраняет новый пароль. ErrNotFound — токен неизвестен, использован или истёк
+func (c *DBClient) ConsumePasswordReset(req *models.ConsumePasswordResetRequest) (*models.PasswordReset, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/password-resets/consume", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var reset models.PasswordReset
+	if err := json.NewDecoder(resp.Body).Decode(&reset); err != nil {
+		return nil, err
+	}
+
+	return &reset, nil
+}
+
+// totpURL адрес настройки двухфакторной аутентификации пользователя в db-service
+func (c *DBClient) totpURL(suffix string, userID int) string {
+	return c.BaseURL + "/account/totp" + suffix + "?user_id=" + strconv.Itoa(userID)
+}
+
+// GetTOTP возвращает настройку двухфакторной аутентификации. ErrNotFound — пользователь её не настраивал
+func (c *DBClient) GetTOTP(userID int) (*models.TOTP, error) {
+	resp, err := c.Client.Get(c.totpURL("", userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var totp models.TOTP
+	if err := json.NewDecoder(resp.Body).Decode(&totp); err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+// SetupTOTP сохраняет секрет неподтверждённой настройки. ErrConflict — двухфакторная аутентификация уже включена
+func (c *DBClient) SetupTOTP(userID int, secret string) error {
+	jsonData, err := json.Marshal(models.SetupTOTPRequest{Secret: secret})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PUT", c.totpURL("", userID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// EnableTOTP подтверждает настройку и сохраняет хэши кодов восстановления. ErrNotFound — нет неподтверждённой настройки
+func (c *DBClient) EnableTOTP(userID int, req *models.EnableTOTPRequest) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.totpURL("/enable", userID), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// UseTOTPStep отмечает код шага step принятым. ErrConflict — код этого шага уже использован
+func (c *DBClient) UseTOTPStep(userID int, step int64) error {
+	jsonData, err := json.Marshal(models.TOTPStepRequest{Step: step})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Post(c.totpURL("/step", userID), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// RecordTOTPFailure засчитывает неверный код
+func (c *DBClient) RecordTOTPFailure(userID int) error {
+	resp, err := c.Client.Post(c.totpURL("/failures", userID), "application/json", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// UseRecoveryCode гасит код восстановления по хэшу и возвращает число оставшихся.
+// ErrNotFound — код неизвестен или уже использован
+func (c *DBClient) UseRecoveryCode(userID int, codeHash string) (int, error) {
+	jsonData, err := json.Marshal(models.RecoveryCodeRequest{CodeHash: codeHash})
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Client.Post(c.totpURL("/recovery", userID), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return 0, err
+	}
+
+	var result models.RecoveryCodeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+
+	return result.RecoveryCodesLeft, nil
+}
+
+// DisableTOTP выключает двухфакторную аутентификацию. ErrNotFound — она не была настроена
+func (c *DBClient) DisableTOTP(userID int) error {
+	req, err := http.NewRequest("DELETE", c.totpURL("", userID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// accessTokensURL адрес персональных токенов доступа пользователя в db-service
+func (c *DBClient) accessTokensURL(suffix string, userID int) string {
+	return c.BaseURL + "/account/access-tokens" + suffix + "?user_id=" + strconv.Itoa(userID)
+}
+
+// CreateAccessToken сохраняет хэш нового персонального токена. ErrConflict — имя уже занято другим токеном пользователя
+func (c *DBClient) CreateAccessToken(userID int, req *models.StoreAccessTokenRequest) (*models.AccessToken, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.accessTokensURL("", userID), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var token models.AccessToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// ListAccessTokens возвращает персональные токены пользователя, новые первыми
+func (c *DBClient) ListAccessTokens(userID int) ([]models.AccessToken, error) {
+	resp, err := c.Client.Get(c.accessTokensURL("", userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var tokens []models.AccessToken
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteAccessToken отзывает персональный токен. ErrNotFound — токена нет или он чужой
+func (c *DBClient) DeleteAccessToken(id, userID int) error {
+	req, err := http.NewRequest("DELETE", c.accessTokensURL("/"+strconv.Itoa(id), userID), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkStatus(resp)
+}
+
+// AuthenticateAccessToken возвращает владельца и области доступа персонального токена по его хэшу.
+// ErrNotFound — токен неизвестен, отозван или истёк
+func (c *DBClient) AuthenticateAccessToken(tokenHash string) (*models.AccessTokenOwner, error) {
+	jsonData, err := json.Marshal(models.AuthenticateAccessTokenRequest{TokenHash: tokenHash})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Post(c.BaseURL+"/access-tokens/authenticate", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var owner models.AccessTokenOwner
+	if err := json.NewDecoder(resp.Body).Decode(&owner); err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	return &owner, nil
 }
@@ -3,6 +3,8 @@ package client
 import (
 	"apiservice/models"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -87,6 +89,26 @@ func TestCreateTaskSuccess(t *testing.T) {
 	}
 }
 
+func TestUpdateTaskExistingTagsOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("existing_tags_only") != "true" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["ExistingTagsOnly"]; ok {
+			t.Error("Флаг не должен уходить в теле запроса")
+		}
+		json.NewEncoder(w).Encode(models.UpdateTaskResponse{Task: models.Task{ID: 1}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.UpdateTask(1, 1, &models.UpdateTaskRequest{AddTags: []string{"new"}, ExistingTagsOnly: true}); err != nil {
+		t.Fatalf("UpdateTask() вернул ошибку: %v", err)
+	}
+}
+
 func TestCreateTaskInvalidResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusCreated)
@@ -124,10 +146,11 @@ func TestGetAllTasksSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	tasks, err := client.GetAllTasks(1)
+	page, err := client.GetAllTasks(1, models.ListOptions{})
 	if err != nil {
 		t.Fatalf("GetAllTasks() вернул ошибку: %v", err)
 	}
+	tasks := page.Items
 
 	if len(tasks) != 2 {
 		t.Errorf("Неправильное количество задач: получено %d, ожидается 2", len(tasks))
@@ -142,10 +165,11 @@ func TestGetAllTasksEmptyList(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	tasks, err := client.GetAllTasks(1)
+	page, err := client.GetAllTasks(1, models.ListOptions{})
 	if err != nil {
 		t.Fatalf("GetAllTasks() вернул ошибку: %v", err)
 	}
+	tasks := page.Items
 
 	if len(tasks) != 0 {
 		t.Errorf("Должен вернуть пустой список, получено %d задач", len(tasks))
@@ -173,10 +197,11 @@ func TestGetCompletedSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	tasks, err := client.GetCompleted(1)
+	page, err := client.GetCompleted(1, models.ListOptions{})
 	if err != nil {
 		t.Fatalf("GetCompleted() вернул ошибку: %v", err)
 	}
+	tasks := page.Items
 
 	if len(tasks) != 1 {
 		t.Errorf("Неправильное количество задач: получено %d, ожидается 1", len(tasks))
@@ -208,10 +233,11 @@ func TestGetUncompletedSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	tasks, err := client.GetUncompleted(1)
+	page, err := client.GetUncompleted(1, models.ListOptions{})
 	if err != nil {
 		t.Fatalf("GetUncompleted() вернул ошибку: %v", err)
 	}
+	tasks := page.Items
 
 	if len(tasks) != 1 {
 		t.Errorf("Неправильное количество задач: получено %d, ожидается 1", len(tasks))
@@ -270,7 +296,7 @@ func TestCompleteTaskSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	err := client.CompleteTask(1, 1)
+	_, err := client.CompleteTask(1, 1)
 	if err != nil {
 		t.Fatalf("CompleteTask() вернул ошибку: %v", err)
 	}
@@ -294,7 +320,7 @@ func TestGetTaskByIDSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	task, err := client.GetTaskByID(1)
+	task, err := client.GetTaskByID(1, 1)
 	if err != nil {
 		t.Fatalf("GetTaskByID() вернул ошибку: %v", err)
 	}
@@ -304,6 +330,25 @@ func TestGetTaskByIDSuccess(t *testing.T) {
 	}
 }
 
+func TestGetTaskByIDPassesUserID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/getbyid/1" || r.URL.Query().Get("user_id") != "2" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	task, err := client.GetTaskByID(1, 2)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+	if task != nil {
+		t.Errorf("Чужая задача не должна возвращаться, получено %+v", task)
+	}
+}
+
 // ============================================================================
 // ТЕСТЫ ДЛЯ GetTaskByName
 // ============================================================================
@@ -322,7 +367,7 @@ func TestGetTaskByNameSuccess(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	task, err := client.GetTaskByName("Test Task")
+	task, err := client.GetTaskByName("Test Task", 1)
 	if err != nil {
 		t.Fatalf("GetTaskByName() вернул ошибку: %v", err)
 	}
@@ -349,7 +394,7 @@ func TestCreateTaskNetworkError(t *testing.T) {
 func TestGetAllTasksNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	_, err := client.GetAllTasks(1)
+	_, err := client.GetAllTasks(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetAllTasks() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -411,16 +456,32 @@ func TestCompleteTaskWithNegativeID(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	err := client.CompleteTask(-1, 1)
+	_, err := client.CompleteTask(-1, 1)
 	if err != nil {
 		t.Fatalf("CompleteTask() вернул ошибку: %v", err)
 	}
 }
 
+func TestGetTaskByNameEscapesName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/getbyname/Buy milk?" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: path=%q query=%q", r.URL.Path, r.URL.RawQuery)
+		}
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.GetTaskByName("Buy milk?", 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
 func TestGetTaskByIDNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	_, err := client.GetTaskByID(1)
+	_, err := client.GetTaskByID(1, 1)
 	if err == nil {
 		t.Error("GetTaskByID() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -429,7 +490,7 @@ func TestGetTaskByIDNetworkError(t *testing.T) {
 func TestGetTaskByNameNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	_, err := client.GetTaskByName("test")
+	_, err := client.GetTaskByName("test", 1)
 	if err == nil {
 		t.Error("GetTaskByName() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -438,7 +499,7 @@ func TestGetTaskByNameNetworkError(t *testing.T) {
 func TestGetCompletedNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	_, err := client.GetCompleted(1)
+	_, err := client.GetCompleted(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetCompleted() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -447,7 +508,7 @@ func TestGetCompletedNetworkError(t *testing.T) {
 func TestGetUncompletedNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	_, err := client.GetUncompleted(1)
+	_, err := client.GetUncompleted(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetUncompleted() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -465,7 +526,7 @@ func TestDeleteTaskNetworkError(t *testing.T) {
 func TestCompleteTaskNetworkError(t *testing.T) {
 	client := NewDBClient("http://invalid-host:9999")
 
-	err := client.CompleteTask(1, 1)
+	_, err := client.CompleteTask(1, 1)
 	if err == nil {
 		t.Error("CompleteTask() должен вернуть ошибку при сетевой ошибке")
 	}
@@ -494,7 +555,7 @@ func TestGetAllTasksInvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	_, err := client.GetAllTasks(1)
+	_, err := client.GetAllTasks(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetAllTasks() должен вернуть ошибку при невалидном JSON")
 	}
@@ -507,7 +568,7 @@ func TestGetCompletedInvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	_, err := client.GetCompleted(1)
+	_, err := client.GetCompleted(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetCompleted() должен вернуть ошибку при невалидном JSON")
 	}
@@ -520,7 +581,7 @@ func TestGetUncompletedInvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	_, err := client.GetUncompleted(1)
+	_, err := client.GetUncompleted(1, models.ListOptions{})
 	if err == nil {
 		t.Error("GetUncompleted() должен вернуть ошибку при невалидном JSON")
 	}
@@ -533,7 +594,7 @@ func TestGetTaskByIDInvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	_, err := client.GetTaskByID(1)
+	_, err := client.GetTaskByID(1, 1)
 	if err == nil {
 		t.Error("GetTaskByID() должен вернуть ошибку при невалидном JSON")
 	}
@@ -546,7 +607,7 @@ func TestGetTaskByNameInvalidJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	_, err := client.GetTaskByName("test")
+	_, err := client.GetTaskByName("test", 1)
 	if err == nil {
 		t.Error("GetTaskByName() должен вернуть ошибку при невалидном JSON")
 	}
@@ -572,7 +633,7 @@ func TestCompleteTaskWithInvalidURL(t *testing.T) {
 		Client:  &http.Client{},
 	}
 
-	err := client.CompleteTask(1, 1)
+	_, err := client.CompleteTask(1, 1)
 	if err == nil {
 		t.Error("CompleteTask() должен вернуть ошибку при недопустимом URL")
 	}
@@ -623,8 +684,1496 @@ func TestCompleteTaskResponseClose(t *testing.T) {
 	defer server.Close()
 
 	client := NewDBClient(server.URL)
-	err := client.CompleteTask(1, 1)
+	_, err := client.CompleteTask(1, 1)
 	if err != nil {
 		t.Errorf("CompleteTask() вернул ошибку: %v", err)
 	}
 }
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ UpdateTask
+// ============================================================================
+
+func TestUpdateTaskSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Неправильный метод: получено %s, ожидается PATCH", r.Method)
+		}
+		if r.URL.Path != "/update/5" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Не удалось декодировать запрос: %v", err)
+		}
+		if _, ok := body["text"]; ok {
+			t.Error("Непереданное поле text не должно уходить в db-service")
+		}
+		if v, ok := body["collection_id"]; !ok || v != nil {
+			t.Error("collection_id: null должен передаваться явно")
+		}
+
+		json.NewEncoder(w).Encode(models.UpdateTaskResponse{
+			Task:    models.Task{ID: 5, Name: "Renamed"},
+			Changes: []models.FieldChange{{Field: "name", Old: "Old", New: "Renamed"}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	name := "Renamed"
+	req := &models.UpdateTaskRequest{
+		Name:         &name,
+		CollectionID: models.Optional[int]{Set: true},
+	}
+
+	resp, err := client.UpdateTask(5, 1, req)
+	if err != nil {
+		t.Fatalf("UpdateTask() вернул ошибку: %v", err)
+	}
+
+	if resp.Task.Name != "Renamed" || len(resp.Changes) != 1 {
+		t.Errorf("Неправильный ответ: %+v", resp)
+	}
+}
+
+func TestUpdateTaskNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	text := "x"
+
+	_, err := client.UpdateTask(5, 2, &models.UpdateTaskRequest{Text: &text})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+func TestUpdateTaskNetworkError(t *testing.T) {
+	client := NewDBClient("http://invalid-host-that-does-not-exist:9999")
+	text := "x"
+
+	_, err := client.UpdateTask(1, 1, &models.UpdateTaskRequest{Text: &text})
+	if err == nil {
+		t.Error("UpdateTask() должен вернуть ошибку при сетевой ошибке")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ReopenTask
+// ============================================================================
+
+func TestReopenTaskSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("Неправильный метод: получено %s, ожидается PUT", r.Method)
+		}
+		if r.URL.Path != "/uncomplete/3" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.ReopenTask(3, 1); err != nil {
+		t.Errorf("ReopenTask() вернул ошибку: %v", err)
+	}
+}
+
+func TestReopenTaskForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Failed to reopen task"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.ReopenTask(3, 1); err == nil {
+		t.Error("ReopenTask() должен вернуть ошибку при статусе 403")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetTasksByDue
+// ============================================================================
+
+func TestGetTasksByDueSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/get" || q.Get("due") != "week" || q.Get("user_id") != "7" || q.Get("tz") != "Europe/Moscow" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		dueAt := time.Now()
+		json.NewEncoder(w).Encode([]models.Task{{ID: 1, Name: "Soon", DueAt: &dueAt}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetTasksByDue(7, "week", "Europe/Moscow", models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetTasksByDue() вернул ошибку: %v", err)
+	}
+	tasks := page.Items
+	if len(tasks) != 1 || tasks[0].DueAt == nil {
+		t.Errorf("Неправильный ответ: %+v", tasks)
+	}
+}
+
+func TestGetTasksByDueBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Invalid tz"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.GetTasksByDue(1, "today", "Nowhere/City", models.ListOptions{})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+func TestGetCompletedWithSort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("complete") != "true" || q.Get("sort") != "priority" || q.Get("order") != "asc" || q.Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.Task{})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.GetCompleted(1, models.ListOptions{Sort: "priority", Order: "asc"}); err != nil {
+		t.Fatalf("GetCompleted() вернул ошибку: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ тегов
+// ============================================================================
+
+func TestGetTasksByTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("tag") != "work,home" || q.Get("match") != "all" || q.Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.Task{{ID: 1, Tags: []string{"home", "work"}}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetTasksByTags(1, []string{"work", "home"}, true, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetTasksByTags() вернул ошибку: %v", err)
+	}
+	tasks := page.Items
+	if len(tasks) != 1 || len(tasks[0].Tags) != 2 {
+		t.Errorf("Неправильный ответ: %+v", tasks)
+	}
+}
+
+func TestCreateTagConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tags" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.Path)
+		}
+		http.Error(w, `{"error": "Tag already exists"}`, http.StatusConflict)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.CreateTag(&models.CreateTagRequest{Name: "work"}, 1)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидалась ошибка ErrConflict, получено %v", err)
+	}
+}
+
+func TestUpdateTagSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/tags/3" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["name"]; ok {
+			t.Errorf("Незаданное поле name не должно отправляться: %v", body)
+		}
+		json.NewEncoder(w).Encode(models.Tag{ID: 3, Name: "work", Color: "#ff0000"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	color := "#ff0000"
+	tag, err := client.UpdateTag(3, 1, &models.UpdateTagRequest{Color: &color})
+	if err != nil {
+		t.Fatalf("UpdateTag() вернул ошибку: %v", err)
+	}
+	if tag.Color != "#ff0000" {
+		t.Errorf("Неправильный цвет: %s", tag.Color)
+	}
+}
+
+func TestDeleteTagNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Tag not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteTag(9, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ чек-листа
+// ============================================================================
+
+func TestReorderChecklistSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/tasks/3/checklist/order" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body models.ReorderChecklistRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.ItemIDs) != 2 || body.ItemIDs[0] != 5 {
+			t.Errorf("Неправильное тело запроса: %+v", body)
+		}
+		json.NewEncoder(w).Encode([]models.ChecklistItem{{ID: 5, Position: 0}, {ID: 4, Position: 1}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	items, err := client.ReorderChecklist(3, 1, &models.ReorderChecklistRequest{ItemIDs: []int{5, 4}})
+	if err != nil {
+		t.Fatalf("ReorderChecklist() вернул ошибку: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != 5 {
+		t.Errorf("Неправильный ответ: %+v", items)
+	}
+}
+
+func TestUpdateChecklistItemURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/tasks/3/checklist/9" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(models.ChecklistItem{ID: 9, TaskID: 3, Done: true})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	done := true
+	item, err := client.UpdateChecklistItem(3, 9, 1, &models.UpdateChecklistItemRequest{Done: &done})
+	if err != nil {
+		t.Fatalf("UpdateChecklistItem() вернул ошибку: %v", err)
+	}
+	if !item.Done {
+		t.Error("Пункт должен быть отмечен")
+	}
+}
+
+func TestDeleteChecklistItemNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Checklist item not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteChecklistItem(3, 9, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ повторяющихся задач
+// ============================================================================
+
+func TestCompleteTaskReturnsNextTask(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dueAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+		rule := "FREQ=WEEKLY;COUNT=2"
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.CompleteTaskResponse{
+			NextTask: &models.Task{ID: 2, Name: "Weekly", DueAt: &dueAt, Recurrence: &rule},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	next, err := client.CompleteTask(1, 1)
+	if err != nil {
+		t.Fatalf("CompleteTask() вернул ошибку: %v", err)
+	}
+	if next == nil || next.ID != 2 || next.Recurrence == nil || *next.Recurrence != "FREQ=WEEKLY;COUNT=2" {
+		t.Errorf("Ожидалась следующая задача с ID 2 и правилом повторения, получено %+v", next)
+	}
+}
+
+func TestCompleteTaskWithoutNextTask(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"next_task": null}`))
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	next, err := client.CompleteTask(1, 1)
+	if err != nil {
+		t.Fatalf("CompleteTask() вернул ошибку: %v", err)
+	}
+	if next != nil {
+		t.Errorf("Ожидался nil, получено %+v", next)
+	}
+}
+
+func TestCompleteTaskForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Failed to complete task"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.CompleteTask(1, 1); err == nil {
+		t.Error("CompleteTask() должен вернуть ошибку при статусе 403")
+	}
+}
+
+func TestCreateTaskInvalidRecurrence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Invalid recurrence rule"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	rule := "FREQ=HOURLY"
+	_, err := client.CreateTask(&models.CreateTaskRequest{Name: "Task", Recurrence: &rule}, 1)
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ пагинации
+// ============================================================================
+
+func TestGetAllTasksPaginated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("limit") != "2" || q.Get("cursor") != "abc" || q.Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Page[models.Task]{
+			Items:      []models.Task{{ID: 5}, {ID: 4}},
+			NextCursor: "def",
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetAllTasks(1, models.ListOptions{PageParams: models.PageParams{Limit: 2, Cursor: "abc"}})
+	if err != nil {
+		t.Fatalf("GetAllTasks() вернул ошибку: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor != "def" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestGetAllTasksLegacyListHasNoParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Has("limit") || q.Has("cursor") {
+			t.Errorf("Без пагинации limit/cursor не передаются: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.Task{{ID: 1}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetAllTasks(1, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetAllTasks() вернул ошибку: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestGetCollectionsPaginated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/collections" || r.URL.Query().Get("limit") != "10" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Page[models.Collection]{
+			Items:      []models.Collection{{ID: 1, Name: "Work"}},
+			NextCursor: "next",
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetCollections(1, models.PageParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetCollections() вернул ошибку: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "next" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestGetTagsPaginated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tags" || r.URL.Query().Get("cursor") != "abc" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Page[models.Tag]{
+			Items: []models.Tag{{ID: 2, Name: "work"}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetTags(1, models.PageParams{Cursor: "abc"})
+	if err != nil {
+		t.Fatalf("GetTags() вернул ошибку: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestGetAllTasksInvalidCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.GetAllTasks(1, models.ListOptions{PageParams: models.PageParams{Cursor: "garbage"}})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ поиска
+// ============================================================================
+
+func TestSearchTasksSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/search" || q.Get("q") != "quarterly rep" || q.Get("user_id") != "1" ||
+			q.Get("collection_id") != "3" || q.Get("complete") != "false" || q.Get("limit") != "5" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.SearchResult{{
+			Task:    models.Task{ID: 7, Name: "Quarterly report"},
+			Rank:    0.6,
+			Snippet: "<mark>Quarterly</mark> <mark>report</mark>",
+		}})
+	}))
+	defer server.Close()
+
+	collectionID := 3
+	complete := false
+
+	client := NewDBClient(server.URL)
+	results, err := client.SearchTasks(1, "quarterly rep", models.SearchOptions{CollectionID: &collectionID, Complete: &complete, Limit: 5})
+	if err != nil {
+		t.Fatalf("SearchTasks() вернул ошибку: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 7 || results[0].Rank != 0.6 || results[0].Snippet == "" {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+}
+
+func TestSearchTasksWithoutFilters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Has("collection_id") || q.Has("complete") || q.Has("limit") {
+			t.Errorf("Без фильтров параметры не передаются: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.SearchResult{})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	results, err := client.SearchTasks(1, "milk", models.SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchTasks() вернул ошибку: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Ожидался пустой список, получено %+v", results)
+	}
+}
+
+func TestSearchTasksBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "q must contain at least one word"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.SearchTasks(1, "!!!", models.SearchOptions{})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ корзины
+// ============================================================================
+
+func TestGetTrashSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/trash" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный URL: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Trash{
+			Tasks:       []models.TrashedTask{{Task: models.Task{ID: 5}}},
+			Collections: []models.TrashedCollection{{Collection: models.Collection{ID: 3}, TaskCount: 2}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	trash, err := client.GetTrash(1)
+	if err != nil {
+		t.Fatalf("GetTrash() вернул ошибку: %v", err)
+	}
+	if len(trash.Tasks) != 1 || len(trash.Collections) != 1 || trash.Collections[0].TaskCount != 2 {
+		t.Errorf("Неправильная корзина: %+v", trash)
+	}
+}
+
+func TestRestoreCollectionURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/trash/3/restore" || r.URL.Query().Get("type") != "collection" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Collection{ID: 3, Name: "Work"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	collection, err := client.RestoreCollection(3, 1)
+	if err != nil {
+		t.Fatalf("RestoreCollection() вернул ошибку: %v", err)
+	}
+	if collection.Name != "Work" {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestRestoreTaskNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Item not found in trash"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.RestoreTask(5, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+func TestPurgeTaskURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/trash/5" || r.URL.Query().Get("type") != "task" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.PurgeTask(5, 1); err != nil {
+		t.Errorf("PurgeTask() вернул ошибку: %v", err)
+	}
+}
+
+func TestEmptyTrashSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/trash" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.PurgeResult{Tasks: 3, Collections: 1})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	purged, err := client.EmptyTrash(1)
+	if err != nil {
+		t.Fatalf("EmptyTrash() вернул ошибку: %v", err)
+	}
+	if purged.Tasks != 3 || purged.Collections != 1 {
+		t.Errorf("Неправильный результат: %+v", purged)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ UpdateCollection
+// ============================================================================
+
+func TestUpdateCollectionSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/collections/3" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["icon"]; ok {
+			t.Errorf("Незаданное поле icon не должно отправляться: %v", body)
+		}
+		json.NewEncoder(w).Encode(models.Collection{ID: 3, Name: "Work", Color: "#ff8800", Icon: "📁"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	name, color := "Work", "#ff8800"
+	collection, err := client.UpdateCollection(3, 1, &models.UpdateCollectionRequest{Name: &name, Color: &color})
+	if err != nil {
+		t.Fatalf("UpdateCollection() вернул ошибку: %v", err)
+	}
+	if collection.Name != "Work" || collection.Color != "#ff8800" {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestUpdateCollectionNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	icon := "🏠"
+	if _, err := client.UpdateCollection(3, 2, &models.UpdateCollectionRequest{Icon: &icon}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ MoveTasks
+// ============================================================================
+
+func TestMoveTasksSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tasks/move" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if v, ok := body["collection_id"]; !ok || v != nil {
+			t.Errorf("collection_id: null должен передаваться явно: %v", body)
+		}
+		from := 3
+		json.NewEncoder(w).Encode([]models.TaskMove{{TaskID: 5, From: &from}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	moves, err := client.MoveTasks(&models.MoveTasksRequest{TaskIDs: []int{5}, CollectionID: models.Optional[int]{Set: true}}, 1)
+	if err != nil {
+		t.Fatalf("MoveTasks() вернул ошибку: %v", err)
+	}
+	if len(moves) != 1 || moves[0].TaskID != 5 || *moves[0].From != 3 || moves[0].To != nil {
+		t.Errorf("Неправильный результат: %+v", moves)
+	}
+}
+
+func TestMoveTasksForeignCollection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	target := 7
+	_, err := client.MoveTasks(&models.MoveTasksRequest{TaskIDs: []int{5}, CollectionID: models.Optional[int]{Set: true, Value: &target}}, 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+func TestBulkTasksSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tasks/bulk" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body struct {
+			Operations []map[string]interface{} `json:"operations"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Operations) != 2 {
+			t.Fatalf("Неправильное тело запроса: %+v", body)
+		}
+		if _, ok := body.Operations[0]["collection_id"]; ok {
+			t.Errorf("collection_id не должен передаваться для complete: %v", body.Operations[0])
+		}
+		if v, ok := body.Operations[1]["collection_id"]; !ok || v != nil {
+			t.Errorf("collection_id: null должен передаваться явно: %v", body.Operations[1])
+		}
+		json.NewEncoder(w).Encode([]models.BulkResult{
+			{Index: 0, Op: models.BulkComplete, TaskID: 1, Status: models.BulkStatusOK},
+			{Index: 1, Op: models.BulkMove, TaskID: 2, Status: models.BulkStatusError, Error: "task not found or access denied"},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	results, err := client.BulkTasks(&models.BulkTasksRequest{Operations: []models.BulkOperation{
+		{Op: models.BulkComplete, TaskID: 1},
+		{Op: models.BulkMove, TaskID: 2, CollectionID: models.Optional[int]{Set: true}},
+	}}, 1)
+	if err != nil {
+		t.Fatalf("BulkTasks() вернул ошибку: %v", err)
+	}
+	if len(results) != 2 || results[1].Status != models.BulkStatusError {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+}
+
+func TestBulkTasksBadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Invalid bulk operation"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.BulkTasks(&models.BulkTasksRequest{Operations: []models.BulkOperation{
+		{Op: models.BulkTag, TaskID: 1, AddTags: []string{","}},
+	}}, 1)
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ участников коллекции
+// ============================================================================
+
+func TestAddMemberSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/collections/3/members" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body models.AddMemberRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Username != "bob" || body.Role != models.RoleEditor {
+			t.Errorf("Неправильное тело запроса: %+v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.Member{CollectionID: 3, UserID: 2, Username: "bob", Role: models.RoleEditor})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	member, err := client.AddMember(3, 1, &models.AddMemberRequest{Username: "bob", Role: models.RoleEditor})
+	if err != nil {
+		t.Fatalf("AddMember() вернул ошибку: %v", err)
+	}
+	if member.UserID != 2 || member.Role != models.RoleEditor {
+		t.Errorf("Неправильный участник: %+v", member)
+	}
+}
+
+func TestAddMemberForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Only the owner can manage members"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.AddMember(3, 2, &models.AddMemberRequest{Username: "carol", Role: models.RoleViewer}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ошибка ErrForbidden, получено %v", err)
+	}
+}
+
+func TestUpdateMemberAndRemoveMemberURL(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		if r.Method == "PATCH" {
+			json.NewEncoder(w).Encode(models.Member{CollectionID: 3, UserID: 2, Role: models.RoleViewer})
+		}
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.UpdateMember(3, 2, 1, &models.UpdateMemberRequest{Role: models.RoleViewer}); err != nil {
+		t.Fatalf("UpdateMember() вернул ошибку: %v", err)
+	}
+	if err := client.RemoveMember(3, 2, 1); err != nil {
+		t.Fatalf("RemoveMember() вернул ошибку: %v", err)
+	}
+
+	want := []string{"PATCH /collections/3/members/2?user_id=1", "DELETE /collections/3/members/2?user_id=1"}
+	if len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("Ожидались запросы %v, получено %v", want, requests)
+	}
+}
+
+func TestDeleteCollectionForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Failed to delete collection"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteCollection(3, 2); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ошибка ErrForbidden, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ поручения задач
+// ============================================================================
+
+func TestAssignTaskSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tasks/5/assign" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var body models.AssignTaskRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Username != "bob" {
+			t.Errorf("Неправильное тело запроса: %+v", body)
+		}
+		assignee, status := 2, models.AssignmentPending
+		json.NewEncoder(w).Encode(models.Task{ID: 5, AssigneeID: &assignee, AssignmentStatus: &status})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	task, err := client.AssignTask(5, 1, &models.AssignTaskRequest{Username: "bob"})
+	if err != nil {
+		t.Fatalf("AssignTask() вернул ошибку: %v", err)
+	}
+	if task.AssigneeID == nil || *task.AssigneeID != 2 || *task.AssignmentStatus != models.AssignmentPending {
+		t.Errorf("Неправильная задача: %+v", task)
+	}
+}
+
+func TestAcceptAndDeclineTaskURL(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		json.NewEncoder(w).Encode(models.Task{ID: 5})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.AcceptTask(5, 2); err != nil {
+		t.Fatalf("AcceptTask() вернул ошибку: %v", err)
+	}
+	if _, err := client.DeclineTask(5, 2); err != nil {
+		t.Fatalf("DeclineTask() вернул ошибку: %v", err)
+	}
+
+	want := []string{"POST /tasks/5/accept?user_id=2", "POST /tasks/5/decline?user_id=2"}
+	if len(requests) != 2 || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("Ожидались запросы %v, получено %v", want, requests)
+	}
+}
+
+func TestAcceptTaskNoPendingAssignment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "No pending assignment for this task"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.AcceptTask(5, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+func TestGetAssignedTasksQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/get" || r.URL.Query().Get("assigned") != "me" || r.URL.Query().Get("user_id") != "2" {
+			t.Errorf("Неправильный запрос: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.Task{{ID: 5}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	page, err := client.GetAssignedTasks(2, models.ListOptions{})
+	if err != nil {
+		t.Fatalf("GetAssignedTasks() вернул ошибку: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != 5 {
+		t.Errorf("Неправильный список: %+v", page.Items)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ вложенных коллекций
+// ============================================================================
+
+func TestMoveCollectionSendsExplicitNull(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/collections/3/move" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"parent_id":null}` {
+			t.Errorf("Неправильное тело запроса: %s", body)
+		}
+		json.NewEncoder(w).Encode(models.Collection{ID: 3, Name: "Q1"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	collection, err := client.MoveCollection(3, 1, &models.MoveCollectionRequest{ParentID: models.Optional[int]{Set: true}})
+	if err != nil {
+		t.Fatalf("MoveCollection() вернул ошибку: %v", err)
+	}
+	if collection.ID != 3 || collection.ParentID != nil {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestMoveCollectionCycle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Cannot move a collection into itself or its subcollection"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	parent := 7
+	_, err := client.MoveCollection(3, 1, &models.MoveCollectionRequest{ParentID: models.Optional[int]{Set: true, Value: &parent}})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Ожидалась ошибка ErrBadRequest, получено %v", err)
+	}
+}
+
+func TestGetTasksByCollectionRecursiveQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/collections/3/tasks" || r.URL.Query().Get("recursive") != "true" {
+			t.Errorf("Неправильный запрос: %s", r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.Task{})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.GetTasksByCollection(3, 1, true, models.ListOptions{}); err != nil {
+		t.Fatalf("GetTasksByCollection() вернул ошибку: %v", err)
+	}
+}
+
+func TestCreateCollectionMissingParent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "Parent collection not found"}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	parent := 9
+	if _, err := client.CreateCollection(&models.CreateCollectionRequest{Name: "Q1", ParentID: &parent}, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ошибка ErrNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ручного порядка
+// ============================================================================
+
+func TestReorderTaskSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tasks/5/reorder" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"before":3}` {
+			t.Errorf("Неправильное тело запроса: %s", body)
+		}
+		json.NewEncoder(w).Encode(models.Task{ID: 5, Position: 1536})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	before := 3
+	task, err := client.ReorderTask(5, 1, &models.ReorderRequest{Before: &before})
+	if err != nil {
+		t.Fatalf("ReorderTask() вернул ошибку: %v", err)
+	}
+	if task.Position != 1536 {
+		t.Errorf("Неправильная задача: %+v", task)
+	}
+}
+
+func TestReorderCollectionForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/collections/4/reorder" {
+			t.Errorf("Неправильный запрос: %s", r.URL.String())
+		}
+		http.Error(w, `{"error": "Only the owner can reorder collections"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	after := 2
+	if _, err := client.ReorderCollection(4, 1, &models.ReorderRequest{After: &after}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ошибка ErrForbidden, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ пользователей и refresh-токенов
+// ============================================================================
+
+func TestCreateUserConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/user/create" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		http.Error(w, "Username already exists", http.StatusConflict)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	_, err := client.CreateUser(&models.CreateUserRequest{Username: "alice", PasswordHash: "hash"})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидалась ошибка ErrConflict, получено %v", err)
+	}
+}
+
+func TestGetUserByUsernameEscapesPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/user/a%2Fb" {
+			t.Errorf("Имя пользователя должно экранироваться, получено %s", r.URL.EscapedPath())
+		}
+		json.NewEncoder(w).Encode(models.User{ID: 1, Username: "a/b", PasswordHash: "hash"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	user, err := client.GetUserByUsername("a/b")
+	if err != nil {
+		t.Fatalf("GetUserByUsername() вернул ошибку: %v", err)
+	}
+	if user.PasswordHash != "hash" {
+		t.Errorf("Ожидался хэш пароля, получено %+v", user)
+	}
+}
+
+func TestCreateRefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/tokens" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var req models.CreateRefreshTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.TokenHash != "hash" || req.TTLSeconds != 60 {
+			t.Errorf("Неправильное тело запроса: %+v", req)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.CreateRefreshToken(1, &models.CreateRefreshTokenRequest{TokenHash: "hash", TTLSeconds: 60}); err != nil {
+		t.Errorf("CreateRefreshToken() вернул ошибку: %v", err)
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantErr error
+		wantID  int
+	}{
+		{"обмен", http.StatusOK, models.User{ID: 1, Username: "alice"}, nil, 1},
+		{"повторное использование", http.StatusConflict, models.RefreshTokenReuse{Error: "Refresh token reuse detected", UserID: 2, Username: "bob"}, ErrConflict, 2},
+		{"неизвестный токен", http.StatusNotFound, map[string]string{"error": "Refresh token not found, revoked or expired"}, ErrNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/tokens/rotate" {
+					t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+				}
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDBClient(server.URL)
+			user, err := client.RotateRefreshToken(&models.RotateRefreshTokenRequest{TokenHash: "old", NewTokenHash: "new", TTLSeconds: 60})
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ожидалась ошибка %v, получено %v", tt.wantErr, err)
+			}
+			if tt.wantID != 0 && (user == nil || user.ID != tt.wantID) {
+				t.Errorf("Ожидался пользователь %d, получено %+v", tt.wantID, user)
+			}
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	expiresAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/revocations" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var req models.RevokeTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.JTI != "jti" || !req.ExpiresAt.Equal(expiresAt) || req.RefreshTokenHash != "hash" {
+			t.Errorf("Неправильное тело запроса: %+v", req)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	err := client.RevokeToken(1, &models.RevokeTokenRequest{JTI: "jti", ExpiresAt: expiresAt, RefreshTokenHash: "hash"})
+	if err != nil {
+		t.Errorf("RevokeToken() вернул ошибку: %v", err)
+	}
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	revokedBefore := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/revocations/all" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.UserRevocation{UserID: 1, RevokedBefore: revokedBefore})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	got, err := client.RevokeAllTokens(1)
+	if err != nil {
+		t.Fatalf("RevokeAllTokens() вернул ошибку: %v", err)
+	}
+	if !got.Equal(revokedBefore) {
+		t.Errorf("Неправильный момент отзыва: получено %v, ожидается %v", got, revokedBefore)
+	}
+}
+
+func TestRevokeAllTokensNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if _, err := client.RevokeAllTokens(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestGetRevocations(t *testing.T) {
+	since := time.Date(2025, 1, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/revocations" || r.URL.Query().Get("since") != "2025-01-01T09:00:00Z" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		json.NewEncoder(w).Encode(models.Revocations{
+			Tokens: []models.RevokedToken{{JTI: "jti", ExpiresAt: since.Add(time.Hour)}},
+			Users:  []models.UserRevocation{{UserID: 2, RevokedBefore: since}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	revocations, err := client.GetRevocations(since)
+	if err != nil {
+		t.Fatalf("GetRevocations() вернул ошибку: %v", err)
+	}
+	if len(revocations.Tokens) != 1 || revocations.Tokens[0].JTI != "jti" ||
+		len(revocations.Users) != 1 || revocations.Users[0].UserID != 2 {
+		t.Errorf("Неправильный ответ: %+v", revocations)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	revokedBefore := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/account/password" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var req models.UpdatePasswordRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.PasswordHash != "hash" {
+			t.Errorf("Неправильное тело запроса: %+v", req)
+		}
+		json.NewEncoder(w).Encode(models.UserRevocation{UserID: 1, RevokedBefore: revokedBefore})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	got, err := client.ChangePassword(1, &models.UpdatePasswordRequest{PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("ChangePassword() вернул ошибку: %v", err)
+	}
+	if !got.Equal(revokedBefore) {
+		t.Errorf("Неправильный момент отзыва: получено %v, ожидается %v", got, revokedBefore)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/account" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteUser(1); err != nil {
+		t.Errorf("DeleteUser() вернул ошибку: %v", err)
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteUser(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestUpdateEmailConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/account/email" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var req models.UpdateEmailRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Email != "user@example.com" || req.Password != "" {
+			t.Errorf("Неправильное тело запроса: %+v", req)
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email already in use"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.UpdateEmail(1, "user@example.com"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидалась ErrConflict, получено %v", err)
+	}
+}
+
+func TestCreatePasswordReset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/password-resets" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		var req models.CreatePasswordResetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Email != "user@example.com" || req.TokenHash != "hash" || req.TTLSeconds != 3600 {
+			t.Errorf("Неправильное тело запроса: %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.User{ID: 1, Username: "alice"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	user, err := client.CreatePasswordReset(&models.CreatePasswordResetRequest{Email: "user@example.com", TokenHash: "hash", TTLSeconds: 3600})
+	if err != nil {
+		t.Fatalf("CreatePasswordReset() вернул ошибку: %v", err)
+	}
+	if user.ID != 1 || user.Username != "alice" {
+		t.Errorf("Неправильный пользователь: %+v", user)
+	}
+}
+
+func TestConsumePasswordReset(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantErr error
+	}{
+		{"сброс", http.StatusOK, models.PasswordReset{UserID: 1, Username: "alice"}, nil},
+		{"использованный токен", http.StatusNotFound, map[string]string{"error": "Password reset token not found, used or expired"}, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "POST" || r.URL.Path != "/password-resets/consume" {
+					t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+				}
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDBClient(server.URL)
+			reset, err := client.ConsumePasswordReset(&models.ConsumePasswordResetRequest{TokenHash: "hash", PasswordHash: "new"})
+			if tt.wantErr == nil && (err != nil || reset.UserID != 1) || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Ожидалась ошибка %v, получено %+v, %v", tt.wantErr, reset, err)
+			}
+		})
+	}
+}
+
+func TestGetTOTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantErr error
+	}{
+		{"включена", http.StatusOK, models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true, RecoveryCodesLeft: 10}, nil},
+		{"не настроена", http.StatusNotFound, map[string]string{"error": "Two-factor authentication is not set up"}, ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" || r.URL.Path != "/account/totp" || r.URL.Query().Get("user_id") != "1" {
+					t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+				}
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDBClient(server.URL)
+			totp, err := client.GetTOTP(1)
+			if tt.wantErr == nil && (err != nil || !totp.Enabled || totp.RecoveryCodesLeft != 10) || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Ожидалась ошибка %v, получено %+v, %v", tt.wantErr, totp, err)
+			}
+		})
+	}
+}
+
+func TestUseTOTPStepConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.TOTPStepRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != "POST" || r.URL.Path != "/account/totp/step" || req.Step != 100 {
+			t.Errorf("Неправильный запрос: %s %s %+v", r.Method, r.URL.String(), req)
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Code already used"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.UseTOTPStep(1, 100); !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидалась ErrConflict, получено %v", err)
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.RecoveryCodeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != "POST" || r.URL.Path != "/account/totp/recovery" || req.CodeHash != "hash" {
+			t.Errorf("Неправильный запрос: %s %s %+v", r.Method, r.URL.String(), req)
+		}
+		json.NewEncoder(w).Encode(models.RecoveryCodeResult{RecoveryCodesLeft: 9})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	left, err := client.UseRecoveryCode(1, "hash")
+	if err != nil || left != 9 {
+		t.Errorf("Ожидалось 9 оставшихся кодов, получено %d, %v", left, err)
+	}
+}
+
+func TestCreateAccessToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    interface{}
+		wantErr error
+	}{
+		{"создан", http.StatusCreated, models.AccessToken{ID: 7, Name: "ci", Scopes: []string{"tasks:read"}}, nil},
+		{"имя занято", http.StatusConflict, map[string]string{"error": "Access token name already in use"}, ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req models.StoreAccessTokenRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				if r.Method != "POST" || r.URL.Path != "/account/access-tokens" || r.URL.Query().Get("user_id") != "1" || req.TokenHash != "hash" {
+					t.Errorf("Неправильный запрос: %s %s %+v", r.Method, r.URL.String(), req)
+				}
+				w.WriteHeader(tt.status)
+				json.NewEncoder(w).Encode(tt.body)
+			}))
+			defer server.Close()
+
+			client := NewDBClient(server.URL)
+			token, err := client.CreateAccessToken(1, &models.StoreAccessTokenRequest{Name: "ci", TokenHash: "hash", Scopes: []string{"tasks:read"}})
+			if tt.wantErr == nil && (err != nil || token.ID != 7) || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Ожидалась ошибка %v, получено %+v, %v", tt.wantErr, token, err)
+			}
+		})
+	}
+}
+
+func TestListAccessTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/account/access-tokens" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		json.NewEncoder(w).Encode([]models.AccessToken{{ID: 7, Name: "ci"}, {ID: 6, Name: "backup"}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	tokens, err := client.ListAccessTokens(1)
+	if err != nil || len(tokens) != 2 || tokens[0].Name != "ci" {
+		t.Errorf("Неправильный ответ: %+v, %v", tokens, err)
+	}
+}
+
+func TestDeleteAccessTokenNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/account/access-tokens/7" || r.URL.Query().Get("user_id") != "1" {
+			t.Errorf("Неправильный запрос: %s %s", r.Method, r.URL.String())
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Access token not found"})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	if err := client.DeleteAccessToken(7, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.AuthenticateAccessTokenRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Method != "POST" || r.URL.Path != "/access-tokens/authenticate" || req.TokenHash != "hash" {
+			t.Errorf("Неправильный запрос: %s %s %+v", r.Method, r.URL.String(), req)
+		}
+		json.NewEncoder(w).Encode(models.AccessTokenOwner{TokenID: 7, UserID: 1, Username: "alice", Scopes: []string{"tasks:read"}})
+	}))
+	defer server.Close()
+
+	client := NewDBClient(server.URL)
+	owner, err := client.AuthenticateAccessToken("hash")
+	if err != nil || owner.UserID != 1 || owner.Username != "alice" || len(owner.Scopes) != 1 {
+		t.Errorf("Неправильный ответ: %+v, %v", owner, err)
+	}
+}
@@ -2,15 +2,103 @@ package handlers
 
 import (
 	"apiservice/auth"
+	"apiservice/client"
+	"apiservice/mailer"
+	"apiservice/middleware"
 	"apiservice/models"
-	"bytes"
+	"apiservice/revocation"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// passwordResetTTL сколько действует ссылка из письма для сброса пароля
+const passwordResetTTL = time.Hour
+
+// maxAccessTokenNameLength максимальная длина имени персонального токена, как в db-service
+const maxAccessTokenNameLength = 100
+
+// totpIssuer название сервиса в приложении-аутентификаторе
+const totpIssuer = "To-Do List"
+
+// Защита от перебора кодов: после totpMaxFailures неверных кодов подряд следующий принимается не раньше,
+// чем через totpLockout после последней неудачи. Коды восстановления блокировка не затрагивает
+const (
+	totpMaxFailures = 5
+	totpLockout     = 15 * time.Minute
 )
 
+// AuthHandlers регистрация, вход, обновление токенов, выход, сброс пароля, двухфакторная аутентификация
+// и персональные токены доступа
+type AuthHandlers struct {
+	DBClient      AuthClientInterface
+	EventProducer EventProducerInterface
+	// Revocations кэш отзывов этого экземпляра: выход действует здесь сразу, не дожидаясь обновления кэша
+	Revocations *revocation.Cache
+	// Mailer отправляет письма для сброса пароля; nil — сброс по почте отключён
+	Mailer mailer.Mailer
+	// ResetURL страница сброса пароля, к ней добавляется ?reset_token=; пустая — в письме только токен
+	ResetURL string
+}
+
+func NewAuthHandlers(dbClient AuthClientInterface, eventProducer EventProducerInterface, revocations *revocation.Cache, mail mailer.Mailer) *AuthHandlers {
+	return &AuthHandlers{
+		DBClient:      dbClient,
+		EventProducer: eventProducer,
+		Revocations:   revocations,
+		Mailer:        mail,
+	}
+}
+
+// refreshTTLSeconds срок жизни refresh-токена для db-service
+func refreshTTLSeconds() int64 {
+	return int64(auth.RefreshTTL() / time.Second)
+}
+
+// issueTokens выпускает access-токен и refresh-токен новой цепочки
+func (h *AuthHandlers) issueTokens(user *models.User) (*models.AuthResponse, error) {
+	token, err := auth.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.DBClient.CreateRefreshToken(user.ID, &models.CreateRefreshTokenRequest{
+		TokenHash:  hash,
+		TTLSeconds: refreshTTLSeconds(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return authResponse(user, token, refreshToken), nil
+}
+
+// authResponse ответ с парой токенов; expires_in — срок жизни access-токена в секундах
+func authResponse(user *models.User, token, refreshToken string) *models.AuthResponse {
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(auth.TTL() / time.Second),
+		Username:     user.Username,
+		UserID:       user.ID,
+	}
+}
+
 // Регистрируемся
-func Register(w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandlers) Register(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "error: Invalid JSON", http.StatusBadRequest)
@@ -33,6 +121,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Email != "" && !mailer.ValidAddress(req.Email) {
+		http.Error(w, "error: Invalid email", http.StatusBadRequest)
+		return
+	}
+
 	//Хэшируем
 	hashedPassword, err := auth.HashPassword(req.Password)
 	if err != nil {
@@ -41,48 +134,92 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Отправляем в db
-	createUserReq := map[string]string{
-		"username":      req.Username,
-		"password_hash": hashedPassword,
+	user, err := h.DBClient.CreateUser(&models.CreateUserRequest{
+		Username:     req.Username,
+		PasswordHash: hashedPassword,
+		Email:        req.Email,
+	})
+	if errors.Is(err, client.ErrConflict) {
+		if req.Email != "" {
+			http.Error(w, "Username or email already in use", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Username already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error: Failed to create user", http.StatusInternalServerError)
+		return
 	}
 
-	jsonData, err := json.Marshal(createUserReq)
+	//Наши токены
+	resp, err := h.issueTokens(user)
 	if err != nil {
-		http.Error(w, "error: Failed to marshal request", http.StatusInternalServerError)
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	resp, err := http.Post(
-		"http://db-service:8080/user/create",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	//Ответ
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Логинимся
+func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `Invalid request body`, http.StatusBadRequest)
+		return
+	}
+
+	//Валидируем
+	if req.Username == "" || req.Password == "" {
+		http.Error(w, "Username and Password are required", http.StatusBadRequest)
+		return
+	}
 
+	//Получаем юзера из db
+	user, err := h.DBClient.GetUserByUsername(req.Username)
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		http.Error(w, "error: Failed to create user", http.StatusInternalServerError)
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusConflict {
-		http.Error(w, "Username already exists", http.StatusConflict)
+	//Сверяем пароль
+	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 
-	if resp.StatusCode != http.StatusCreated {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	//С двухфакторной аутентификацией токены выдаст POST /login/mfa после проверки кода
+	totp, err := h.DBClient.GetTOTP(user.ID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
 		return
 	}
+	if totp != nil && totp.Enabled {
+		mfaToken, err := auth.GenerateMFAToken(user.ID, user.Username)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
 
-	//Получаем созданного юзера
-	var user models.User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		http.Error(w, "Failed to decode user data", http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int64(auth.MFATokenTTL / time.Second),
+		})
 		return
 	}
 
-	//Наш JWT
-	token, err := auth.GenerateToken(user.ID, user.Username)
+	//Наши токены
+	resp, err := h.issueTokens(user)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
@@ -90,70 +227,915 @@ func Register(w http.ResponseWriter, r *http.Request) {
 
 	//Ответ
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(models.AuthResponse{
-		Token:    token,
-		Username: user.Username,
-		UserID:   user.ID,
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов. Старый refresh-токен после этого недействителен;
+// если его предъявят ещё раз, db-service отзовёт всю цепочку, и выйти придётся на всех её устройствах
+func (h *AuthHandlers) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.DBClient.RotateRefreshToken(&models.RotateRefreshTokenRequest{
+		TokenHash:    auth.HashRefreshToken(req.RefreshToken),
+		NewTokenHash: hash,
+		TTLSeconds:   refreshTTLSeconds(),
 	})
+	switch {
+	case errors.Is(err, client.ErrConflict):
+		h.EventProducer.SendEvent(
+			user.ID,
+			user.Username,
+			"REFRESH_TOKEN_REUSE",
+			"Used refresh token presented again, token family revoked",
+			"ERROR",
+		)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	case errors.Is(err, client.ErrNotFound):
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authResponse(user, token, refreshToken))
 }
 
-// Логинимся
-func Login(w http.ResponseWriter, r *http.Request) {
-	var req models.LoginRequest
+// Logout отзывает текущий access-токен. Если в теле передан refresh_token, отзывается и его цепочка,
+// иначе refresh-токен этого входа продолжит выдавать новые access-токены
+func (h *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	revoke := &models.RevokeTokenRequest{
+		JTI:       claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if req.RefreshToken != "" {
+		revoke.RefreshTokenHash = auth.HashRefreshToken(req.RefreshToken)
+	}
+
+	if err := h.DBClient.RevokeToken(claims.UserID, revoke); err != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"LOGOUT",
+			"Failed to revoke token",
+			"ERROR",
+		)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	h.Revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+
+	details := "Access token revoked"
+	if req.RefreshToken != "" {
+		details = "Access token and refresh token family revoked"
+	}
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"LOGOUT",
+		details,
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// LogoutAll отзывает все access- и refresh-токены пользователя, включая текущий
+func (h *AuthHandlers) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	revokedBefore, err := h.DBClient.RevokeAllTokens(claims.UserID)
+	if err != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"LOGOUT_ALL",
+			"Failed to revoke tokens",
+			"ERROR",
+		)
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	h.Revocations.RevokeUser(claims.UserID, revokedBefore)
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"LOGOUT_ALL",
+		"All access and refresh tokens revoked",
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out on all devices"})
+}
+
+// checkAccountPassword сверяет пароль владельца токена перед изменением учётной записи.
+// При ошибке пишет ответ и возвращает false; неверный пароль пишется в события action со статусом ERROR
+func (h *AuthHandlers) checkAccountPassword(w http.ResponseWriter, claims *auth.Claims, password, action string) bool {
+	user, err := h.DBClient.GetUserByUsername(claims.Username)
+	// Другой id — учётную запись удалили, а имя занял новый пользователь
+	if errors.Is(err, client.ErrNotFound) || err == nil && user.ID != claims.UserID {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return false
+	}
+
+	// 403, а не 401: клиент не должен принимать неверный пароль за истёкший токен
+	if err := auth.CheckPassword(password, user.PasswordHash); err != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			action,
+			"Invalid password",
+			"ERROR",
+		)
+		http.Error(w, "Invalid password", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// ChangePassword меняет пароль после проверки старого и отзывает все токены пользователя, включая текущий
+func (h *AuthHandlers) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ChangePasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `Invalid request body`, http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	//Валидируем
-	if req.Username == "" || req.Password == "" {
-		http.Error(w, "Username and Password are required", http.StatusBadRequest)
+	if req.OldPassword == "" || req.NewPassword == "" {
+		http.Error(w, "old_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		http.Error(w, "Password must be at least 8 characters long", http.StatusBadRequest)
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		http.Error(w, "New password must differ from the old one", http.StatusBadRequest)
 		return
 	}
 
-	//Получаем юзера из db
-	resp, err := http.Get(fmt.Sprintf("http://db-service:8080/user/%s", req.Username))
+	if !h.checkAccountPassword(w, claims, req.OldPassword, "CHANGE_PASSWORD") {
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
 	if err != nil {
-		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	revokedBefore, err := h.DBClient.ChangePassword(claims.UserID, &models.UpdatePasswordRequest{PasswordHash: hashedPassword})
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"CHANGE_PASSWORD",
+			"Failed to change password",
+			"ERROR",
+		)
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
 		return
 	}
+	h.Revocations.RevokeUser(claims.UserID, revokedBefore)
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"CHANGE_PASSWORD",
+		"Password changed, all tokens revoked",
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed, log in again"})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		http.Error(w, `Failed to get user`, http.StatusInternalServerError)
+// DeleteAccount удаляет учётную запись после проверки пароля. Задачи, коллекции, теги и токены пользователя
+// удаляет db-service каскадом
+func (h *AuthHandlers) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		http.Error(w, "Failed to decode response", http.StatusInternalServerError)
+	var req models.DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
 		return
 	}
 
-	//Сверяем пароль
-	if err := auth.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+	if !h.checkAccountPassword(w, claims, req.Password, "DELETE_ACCOUNT") {
 		return
 	}
 
-	//Наш JWT
-	token, err := auth.GenerateToken(user.ID, user.Username)
+	err := h.DBClient.DeleteUser(claims.UserID)
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"DELETE_ACCOUNT",
+			"Failed to delete account",
+			"ERROR",
+		)
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+	// Отзывы в db-service удалены вместе с пользователем, поэтому токены отзываются только на этом экземпляре;
+	// на остальных они проходят проверку до истечения, но данных за ними уже нет
+	h.Revocations.RevokeUser(claims.UserID, time.Now())
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"DELETE_ACCOUNT",
+		"Account and all its data deleted",
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account deleted"})
+}
+
+// UpdateEmail меняет или, при пустом email, удаляет почту для сброса пароля. Требует текущий пароль:
+// иначе украденный токен позволил бы привязать свою почту и сбросить пароль
+func (h *AuthHandlers) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdateEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Email != "" && !mailer.ValidAddress(req.Email) {
+		http.Error(w, "Invalid email", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkAccountPassword(w, claims, req.Password, "CHANGE_EMAIL") {
+		return
+	}
+
+	err := h.DBClient.UpdateEmail(claims.UserID, req.Email)
+	switch {
+	case errors.Is(err, client.ErrConflict):
+		http.Error(w, "Email already in use", http.StatusConflict)
+		return
+	case errors.Is(err, client.ErrNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update email", http.StatusInternalServerError)
+		return
+	}
+
+	details := "Email changed"
+	if req.Email == "" {
+		details = "Email removed"
+	}
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"CHANGE_EMAIL",
+		details,
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email updated"})
+}
+
+// ForgotPassword отправляет на почту одноразовую ссылку для сброса пароля. Ответ не зависит от того,
+// зарегистрирована ли почта, чтобы по нему нельзя было проверять чужие адреса
+func (h *AuthHandlers) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !mailer.ValidAddress(req.Email) {
+		http.Error(w, "A valid email is required", http.StatusBadRequest)
+		return
+	}
+
+	if h.Mailer == nil {
+		http.Error(w, "Password reset is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	token, hash, err := auth.NewPasswordResetToken()
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	//Ответ
+	user, err := h.DBClient.CreatePasswordReset(&models.CreatePasswordResetRequest{
+		Email:      req.Email,
+		TokenHash:  hash,
+		TTLSeconds: int64(passwordResetTTL / time.Second),
+	})
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Failed to request password reset", http.StatusInternalServerError)
+		return
+	}
+
+	if user != nil {
+		if err := h.Mailer.Send(h.passwordResetMail(req.Email, user.Username, token)); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+			h.EventProducer.SendEvent(
+				user.ID,
+				user.Username,
+				"PASSWORD_RESET_REQUEST",
+				"Failed to send email",
+				"ERROR",
+			)
+		} else {
+			h.EventProducer.SendEvent(
+				user.ID,
+				user.Username,
+				"PASSWORD_RESET_REQUEST",
+				"Reset link sent",
+				"SUCCESS",
+			)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.AuthResponse{
-		Token:    token,
-		Username: user.Username,
-		UserID:   user.ID,
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// passwordResetMail письмо со ссылкой сброса; без ResetURL в письме только токен
+func (h *AuthHandlers) passwordResetMail(to, username, token string) mailer.Message {
+	instruction := "Код для сброса пароля: " + token
+	if link, err := url.Parse(h.ResetURL); h.ResetURL != "" && err == nil {
+		q := link.Query()
+		q.Set("reset_token", token)
+		link.RawQuery = q.Encode()
+		instruction = "Чтобы задать новый пароль, откройте ссылку:\n" + link.String()
+	}
+
+	return mailer.Message{
+		To:      to,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nКто-то запросил сброс пароля для вашей учётной записи.\n%s\n\n"+
+			"Ссылка действует %d мин. и срабатывает один раз. Если вы не запрашивали сброс, просто проигнорируйте это письмо.\n",
+			username, instruction, int(passwordResetTTL/time.Minute)),
+	}
+}
+
+// ResetPassword задаёт новый пароль по токену из письма и отзывает все токены пользователя
+func (h *AuthHandlers) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		http.Error(w, "token and new_password are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		http.Error(w, "Password must be at least 8 characters long", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	reset, err := h.DBClient.ConsumePasswordReset(&models.ConsumePasswordResetRequest{
+		TokenHash:    auth.HashPasswordResetToken(req.Token),
+		PasswordHash: hashedPassword,
+	})
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	h.Revocations.RevokeUser(reset.UserID, reset.RevokedBefore)
+
+	h.EventProducer.SendEvent(
+		reset.UserID,
+		reset.Username,
+		"PASSWORD_RESET",
+		"Password reset by email, all tokens revoked",
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset, log in with the new password"})
+}
+
+// checkSecondFactor проверяет код из приложения или код восстановления пользователя с включённой двухфакторной
+// аутентификацией. При ошибке пишет ответ со статусом failStatus и возвращает false; неверный код пишется в события
+// action со статусом ERROR. При успехе возвращает, чем подтверждён вход, для события вызывающего
+func (h *AuthHandlers) checkSecondFactor(w http.ResponseWriter, userID int, username string, totp *models.TOTP,
+	code, recoveryCode, action string, failStatus int) (string, bool) {
+	if recoveryCode != "" {
+		left, err := h.DBClient.UseRecoveryCode(userID, auth.HashRecoveryCode(recoveryCode))
+		if errors.Is(err, client.ErrNotFound) {
+			h.EventProducer.SendEvent(userID, username, action, "Invalid recovery code", "ERROR")
+			http.Error(w, "Invalid code", failStatus)
+			return "", false
+		}
+		if err != nil {
+			http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+			return "", false
+		}
+		return fmt.Sprintf("recovery code, %d left", left), true
+	}
+
+	if totp.Failures >= totpMaxFailures && totp.FailedAt != nil {
+		if wait := totpLockout - time.Since(*totp.FailedAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+			http.Error(w, "Too many invalid codes, try again later or use a recovery code", http.StatusTooManyRequests)
+			return "", false
+		}
+	}
+
+	step, ok := auth.MatchTOTP(totp.Secret, code, time.Now(), totp.LastStep)
+	if ok {
+		// ErrConflict — тот же код только что принят в параллельном запросе
+		err := h.DBClient.UseTOTPStep(userID, step)
+		if err != nil && !errors.Is(err, client.ErrConflict) {
+			http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+			return "", false
+		}
+		ok = err == nil
+	}
+	if !ok {
+		if err := h.DBClient.RecordTOTPFailure(userID); err != nil {
+			log.Printf("Failed to record invalid TOTP code for user %d: %v", userID, err)
+		}
+		h.EventProducer.SendEvent(userID, username, action, "Invalid code", "ERROR")
+		http.Error(w, "Invalid code", failStatus)
+		return "", false
+	}
+	return "authenticator code", true
+}
+
+// LoginMFA второй шаг входа: обменивает mfa_token из ответа POST /login и код на пару токенов
+func (h *AuthHandlers) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MFAToken == "" || (req.Code == "") == (req.RecoveryCode == "") {
+		http.Error(w, "mfa_token and either code or recovery_code are required", http.StatusBadRequest)
+		return
+	}
+
+	// Смена пароля или выход на всех устройствах после первого шага отменяют и его
+	claims, err := auth.ValidateMFAToken(req.MFAToken)
+	if err != nil || h.Revocations.IsRevoked(claims) {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+
+	totp, err := h.DBClient.GetTOTP(claims.UserID)
+	// Двухфакторную аутентификацию выключили или учётную запись удалили после первого шага
+	if errors.Is(err, client.ErrNotFound) || err == nil && !totp.Enabled {
+		http.Error(w, "Invalid or expired MFA token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+
+	method, ok := h.checkSecondFactor(w, claims.UserID, claims.Username, totp, req.Code, req.RecoveryCode, "LOGIN_MFA", http.StatusUnauthorized)
+	if !ok {
+		return
+	}
+
+	resp, err := h.issueTokens(&models.User{ID: claims.UserID, Username: claims.Username})
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"LOGIN_MFA",
+		"Logged in with "+method,
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// TOTPStatus сообщает, включена ли двухфакторная аутентификация и сколько осталось кодов восстановления
+func (h *AuthHandlers) TOTPStatus(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var status models.TOTPStatus
+	totp, err := h.DBClient.GetTOTP(claims.UserID)
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Failed to get two-factor authentication status", http.StatusInternalServerError)
+		return
+	}
+	if totp != nil && totp.Enabled {
+		status = models.TOTPStatus{Enabled: true, RecoveryCodesLeft: totp.RecoveryCodesLeft}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetupTOTP начинает включение двухфакторной аутентификации: создаёт секрет и возвращает его вместе со ссылкой
+// otpauth:// для QR-кода. Пока настройка не подтверждена кодом через EnableTOTP, вход не меняется;
+// повторный вызов заменяет секрет
+func (h *AuthHandlers) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.TOTPSetupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkAccountPassword(w, claims, req.Password, "ENABLE_2FA") {
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.DBClient.SetupTOTP(claims.UserID, secret)
+	if errors.Is(err, client.ErrConflict) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set up two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TOTPSetupResponse{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(secret, totpIssuer, claims.Username),
+	})
+}
+
+// EnableTOTP подтверждает настройку первым кодом из приложения, включает двухфакторную аутентификацию
+// и возвращает коды восстановления. Они показываются один раз, в db-service хранятся только их хэши
+func (h *AuthHandlers) EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.TOTPEnableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	totp, err := h.DBClient.GetTOTP(claims.UserID)
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Start two-factor authentication setup first", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if totp.Enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, ok := auth.MatchTOTP(totp.Secret, req.Code, time.Now(), 0)
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes(auth.RecoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.DBClient.EnableTOTP(claims.UserID, &models.EnableTOTPRequest{Step: step, RecoveryCodeHashes: hashes})
+	// Настройку заменили или подтвердили в параллельном запросе
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Start two-factor authentication setup first", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"ENABLE_2FA",
+		fmt.Sprintf("Two-factor authentication enabled, %d recovery codes issued", len(codes)),
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TOTPEnableResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP выключает двухфакторную аутентификацию. Требует пароль и код: одного украденного токена
+// или пароля для этого мало
+func (h *AuthHandlers) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.TOTPDisableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" || (req.Code == "") == (req.RecoveryCode == "") {
+		http.Error(w, "password and either code or recovery_code are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkAccountPassword(w, claims, req.Password, "DISABLE_2FA") {
+		return
+	}
+
+	totp, err := h.DBClient.GetTOTP(claims.UserID)
+	if errors.Is(err, client.ErrNotFound) || err == nil && !totp.Enabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	// 403, а не 401: как и с неверным паролем, клиент не должен принимать неверный код за истёкший токен
+	if _, ok := h.checkSecondFactor(w, claims.UserID, claims.Username, totp, req.Code, req.RecoveryCode, "DISABLE_2FA", http.StatusForbidden); !ok {
+		return
+	}
+
+	err = h.DBClient.DisableTOTP(claims.UserID)
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"DISABLE_2FA",
+		"Two-factor authentication disabled, recovery codes deleted",
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// AuthenticatePAT проверяет персональный токен доступа для middleware.AuthMiddleware
+func (h *AuthHandlers) AuthenticatePAT(token string) (*auth.Claims, []string, error) {
+	owner, err := h.DBClient.AuthenticateAccessToken(auth.HashPersonalAccessToken(token))
+	if errors.Is(err, client.ErrNotFound) {
+		return nil, nil, auth.ErrPATInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &auth.Claims{UserID: owner.UserID, Username: owner.Username}, owner.Scopes, nil
+}
+
+// ListAccessTokens возвращает персональные токены доступа пользователя без самих токенов
+func (h *AuthHandlers) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.DBClient.ListAccessTokens(claims.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get access tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// CreateAccessToken выпускает персональный токен доступа для скриптов и CI. Выпуск подтверждается паролем,
+// сам токен возвращается один раз, в db-service хранится только его хэш
+func (h *AuthHandlers) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Password == "" || req.Name == "" || len(req.Name) > maxAccessTokenNameLength || len(req.Scopes) == 0 {
+		http.Error(w, "password, name (at most 100 characters) and scopes are required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			http.Error(w, fmt.Sprintf("Unknown scope %q, expected one of: %s", scope, strings.Join(auth.Scopes, ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkAccountPassword(w, claims, req.Password, "CREATE_ACCESS_TOKEN") {
+		return
+	}
+
+	token, hash, err := auth.NewPersonalAccessToken()
+	if err != nil {
+		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
+		return
+	}
+
+	scopes := slices.Compact(slices.Sorted(slices.Values(req.Scopes)))
+	created, err := h.DBClient.CreateAccessToken(claims.UserID, &models.StoreAccessTokenRequest{
+		Name:      req.Name,
+		TokenHash: hash,
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
 	})
+	if errors.Is(err, client.ErrConflict) {
+		http.Error(w, "Access token with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create access token", http.StatusInternalServerError)
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"CREATE_ACCESS_TOKEN",
+		fmt.Sprintf("Access token created: id=%d, name=%s, scopes=%s", created.ID, created.Name, strings.Join(created.Scopes, ",")),
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CreateAccessTokenResponse{AccessToken: *created, Token: token})
+}
+
+// RevokeAccessToken отзывает персональный токен доступа; следующий запрос с ним получит 401
+func (h *AuthHandlers) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid access token ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.DBClient.DeleteAccessToken(id, claims.UserID)
+	if errors.Is(err, client.ErrNotFound) {
+		http.Error(w, "Access token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke access token", http.StatusInternalServerError)
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REVOKE_ACCESS_TOKEN",
+		fmt.Sprintf("Access token revoked: id=%d", id),
+		"SUCCESS",
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Access token revoked"})
 }
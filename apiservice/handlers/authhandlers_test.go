@@ -1,56 +1,94 @@
 package handlers
 
 import (
+	"apiservice/auth"
+	"apiservice/client"
+	"apiservice/mailer"
+	"apiservice/middleware"
+	"apiservice/models"
+	"apiservice/revocation"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 )
 
 // ============================================================================
 // ТЕСТЫ ДЛЯ REGISTER
 // ============================================================================
 
-// TestRegisterSuccess проверяет успешную регистрацию пользователя
+// TestRegisterSuccess проверяет успешную регистрацию пользователя и выдачу пары токенов
 func TestRegisterSuccess(t *testing.T) {
-	// Создаем mock DB service
-	mockDB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/user/create" && r.Method == "POST" {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusCreated)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"id":       1,
-				"username": "testuser",
-			})
-		}
-	}))
-	defer mockDB.Close()
-
-	// Временно заменяем URL DB service на mock
-	// В реальном проекте лучше использовать dependency injection
-	originalURL := "http://db-service:8080"
-	// Для теста придется модифицировать код или использовать DI
+	var storedHash string
+	mockDB := &MockDBClient{
+		CreateUserFunc: func(req *models.CreateUserRequest) (*models.User, error) {
+			if req.Username != "testuser" || auth.CheckPassword("password123", req.PasswordHash) != nil {
+				t.Errorf("Неправильный запрос создания пользователя: %+v", req)
+			}
+			return &models.User{ID: 1, Username: "testuser"}, nil
+		},
+		CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+			storedHash = req.TokenHash
+			if userID != 1 || req.TTLSeconds != int64(auth.DefaultRefreshTTL/time.Second) {
+				t.Errorf("Неправильный запрос сохранения refresh-токена: %d %+v", userID, req)
+			}
+			return nil
+		},
+	}
 
 	reqBody := `{"username":"testuser","password":"password123"}`
 	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(reqBody))
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Register(rr, req)
 
-	// Вызываем handler
-	Register(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Register() вернул неправильный статус: получено %v, ожидается %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var resp models.AuthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if resp.UserID != 1 || resp.Username != "testuser" || resp.ExpiresIn != int64(auth.DefaultTTL/time.Second) {
+		t.Errorf("Неправильный ответ: %+v", resp)
+	}
+	if _, err := auth.ValidateToken(resp.Token); err != nil {
+		t.Errorf("Register() вернул невалидный access-токен: %v", err)
+	}
+	// В db-service уходит только хэш, сам refresh-токен получает лишь клиент
+	if resp.RefreshToken == "" || storedHash != auth.HashRefreshToken(resp.RefreshToken) {
+		t.Errorf("Хэш refresh-токена не совпадает: %q, %q", resp.RefreshToken, storedHash)
+	}
+}
+
+// TestRegisterUsernameTaken проверяет ответ на занятое имя
+func TestRegisterUsernameTaken(t *testing.T) {
+	mockDB := &MockDBClient{
+		CreateUserFunc: func(req *models.CreateUserRequest) (*models.User, error) {
+			return nil, fmt.Errorf("%w: Username already exists", client.ErrConflict)
+		},
+	}
 
-	// Проверяем статус код
-	// Примечание: этот тест провалится, потому что handler обращается к реальному db-service
-	// Для полноценного тестирования нужен рефакторинг с dependency injection
+	reqBody := `{"username":"testuser","password":"password123"}`
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(reqBody))
 
-	t.Log("Статус код:", rr.Code)
-	t.Log("Ответ:", rr.Body.String())
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Register(rr, req)
 
-	// Для демонстрации структуры теста
-	// В продакшене нужно использовать mock или testcontainers
-	_ = originalURL
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusConflict)
+	}
 }
 
 // TestRegisterInvalidJSON проверяет регистрацию с невалидным JSON
@@ -60,7 +98,7 @@ func TestRegisterInvalidJSON(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Register(rr, req)
+	testAuthHandlers().Register(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -79,7 +117,7 @@ func TestRegisterEmptyUsername(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Register(rr, req)
+	testAuthHandlers().Register(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -98,7 +136,7 @@ func TestRegisterEmptyPassword(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Register(rr, req)
+	testAuthHandlers().Register(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -117,7 +155,7 @@ func TestRegisterShortUsername(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Register(rr, req)
+	testAuthHandlers().Register(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -136,7 +174,7 @@ func TestRegisterShortPassword(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Register(rr, req)
+	testAuthHandlers().Register(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -159,7 +197,7 @@ func TestLoginInvalidJSON(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Login(rr, req)
+	testAuthHandlers().Login(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Login() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -173,7 +211,7 @@ func TestLoginEmptyUsername(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Login(rr, req)
+	testAuthHandlers().Login(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Login() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -192,7 +230,7 @@ func TestLoginEmptyPassword(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	Login(rr, req)
+	testAuthHandlers().Login(rr, req)
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Login() вернул неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
@@ -221,7 +259,7 @@ func TestLoginMissingFields(t *testing.T) {
 			req.Header.Set("Content-Type", "application/json")
 
 			rr := httptest.NewRecorder()
-			Login(rr, req)
+			testAuthHandlers().Login(rr, req)
 
 			if status := rr.Code; status != http.StatusBadRequest {
 				t.Errorf("Login() вернул неправильный статус для %s: получено %v, ожидается %v",
@@ -231,10 +269,1200 @@ func TestLoginMissingFields(t *testing.T) {
 	}
 }
 
+// TestLoginSuccess проверяет вход и начало новой цепочки refresh-токенов
+func TestLoginSuccess(t *testing.T) {
+	hash, err := auth.HashPassword("password123")
+	if err != nil {
+		t.Fatalf("HashPassword() вернул ошибку: %v", err)
+	}
+
+	stored := 0
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: func(username string) (*models.User, error) {
+			return &models.User{ID: 7, Username: username, PasswordHash: hash}, nil
+		},
+		CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+			stored++
+			return nil
+		},
+	}
+
+	for _, tt := range []struct {
+		password string
+		status   int
+	}{
+		{"password123", http.StatusOK},
+		{"wrongpassword", http.StatusUnauthorized},
+	} {
+		reqBody := `{"username":"testuser","password":"` + tt.password + `"}`
+		req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(reqBody))
+
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Login(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Login() с паролем %q: получено %v, ожидается %v", tt.password, rr.Code, tt.status)
+		}
+	}
+
+	if stored != 1 {
+		t.Errorf("Refresh-токен должен сохраняться только при успешном входе, сохранено %d", stored)
+	}
+}
+
+// TestLoginUnknownUser проверяет, что неизвестное имя неотличимо от неверного пароля
+func TestLoginUnknownUser(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: func(username string) (*models.User, error) {
+			return nil, fmt.Errorf("%w: User not found", client.ErrNotFound)
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username":"ghost","password":"password123"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Login(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || !contains(rr.Body.String(), "Invalid username or password") {
+		t.Errorf("Login() вернул %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ REFRESH
+// ============================================================================
+
+// TestRefreshSuccess проверяет обмен refresh-токена на новую пару
+func TestRefreshSuccess(t *testing.T) {
+	var rotate *models.RotateRefreshTokenRequest
+	mockDB := &MockDBClient{
+		RotateRefreshTokenFunc: func(req *models.RotateRefreshTokenRequest) (*models.User, error) {
+			rotate = req
+			return &models.User{ID: 1, Username: "testuser"}, nil
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{"refresh_token":"old-token"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Refresh(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Refresh() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+
+	var resp models.AuthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if rotate.TokenHash != auth.HashRefreshToken("old-token") {
+		t.Errorf("В db-service должен уходить хэш предъявленного токена, получено %q", rotate.TokenHash)
+	}
+	if resp.RefreshToken == "old-token" || rotate.NewTokenHash != auth.HashRefreshToken(resp.RefreshToken) {
+		t.Errorf("Новый refresh-токен не соответствует сохранённому хэшу: %+v", resp)
+	}
+	if claims, err := auth.ValidateToken(resp.Token); err != nil || claims.UserID != 1 {
+		t.Errorf("Refresh() вернул невалидный access-токен: %v", err)
+	}
+}
+
+// TestRefreshReuse проверяет ответ и событие при повторном предъявлении токена
+func TestRefreshReuse(t *testing.T) {
+	mockDB := &MockDBClient{
+		RotateRefreshTokenFunc: func(req *models.RotateRefreshTokenRequest) (*models.User, error) {
+			return &models.User{ID: 1, Username: "testuser"}, fmt.Errorf("%w: Refresh token reuse detected", client.ErrConflict)
+		},
+	}
+	events := &MockEventProducer{}
+
+	req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(`{"refresh_token":"stolen"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, nil, nil).Refresh(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Refresh() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "REFRESH_TOKEN_REUSE" ||
+		events.Events[0].Status != "ERROR" || events.Events[0].UserID != 1 {
+		t.Errorf("Ожидалось событие REFRESH_TOKEN_REUSE, получено %+v", events.Events)
+	}
+}
+
+// TestRefreshInvalid проверяет отказ для пустого, неизвестного или истёкшего токена
+func TestRefreshInvalid(t *testing.T) {
+	mockDB := &MockDBClient{
+		RotateRefreshTokenFunc: func(req *models.RotateRefreshTokenRequest) (*models.User, error) {
+			return nil, fmt.Errorf("%w: Refresh token not found, revoked or expired", client.ErrNotFound)
+		},
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"refresh_token":`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+		{`{"refresh_token":"expired"}`, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/token/refresh", bytes.NewBufferString(tt.body))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Refresh(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Refresh(%s): получено %v, ожидается %v", tt.body, rr.Code, tt.status)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ LOGOUT И LOGOUT-ALL
+// ============================================================================
+
+// TestLogoutSuccess проверяет отзыв текущего access-токена и цепочки переданного refresh-токена
+func TestLogoutSuccess(t *testing.T) {
+	var revoked *models.RevokeTokenRequest
+	mockDB := &MockDBClient{
+		RevokeTokenFunc: func(userID int, req *models.RevokeTokenRequest) error {
+			if userID != 1 {
+				t.Errorf("Отзыв для чужого пользователя: %d", userID)
+			}
+			revoked = req
+			return nil
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+	claims := testClaims(t)
+
+	req := httptest.NewRequest("POST", "/logout", bytes.NewBufferString(`{"refresh_token":"refresh"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).Logout(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Logout() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if revoked == nil || revoked.JTI != claims.ID || !revoked.ExpiresAt.Equal(claims.ExpiresAt.Time) ||
+		revoked.RefreshTokenHash != auth.HashRefreshToken("refresh") {
+		t.Errorf("Неправильный запрос отзыва: %+v", revoked)
+	}
+	if !cache.IsRevoked(claims) {
+		t.Error("Токен должен сразу считаться отозванным на этом экземпляре")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "LOGOUT" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие LOGOUT, получено %+v", events.Events)
+	}
+}
+
+// TestLogoutWithoutBody проверяет выход без refresh-токена в теле
+func TestLogoutWithoutBody(t *testing.T) {
+	var revoked *models.RevokeTokenRequest
+	mockDB := &MockDBClient{
+		RevokeTokenFunc: func(userID int, req *models.RevokeTokenRequest) error {
+			revoked = req
+			return nil
+		},
+	}
+	claims := testClaims(t)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Logout(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Logout() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if revoked == nil || revoked.JTI != claims.ID || revoked.RefreshTokenHash != "" {
+		t.Errorf("Неправильный запрос отзыва: %+v", revoked)
+	}
+}
+
+// TestLogoutDBError проверяет, что при ошибке db-service токен не считается отозванным
+func TestLogoutDBError(t *testing.T) {
+	mockDB := &MockDBClient{
+		RevokeTokenFunc: func(userID int, req *models.RevokeTokenRequest) error {
+			return errors.New("connection refused")
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+	claims := testClaims(t)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).Logout(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Logout() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusInternalServerError)
+	}
+	if cache.IsRevoked(claims) {
+		t.Error("Токен не должен отзываться локально, если db-service не сохранил отзыв")
+	}
+	if len(events.Events) != 1 || events.Events[0].Status != "ERROR" {
+		t.Errorf("Ожидалось событие LOGOUT с ошибкой, получено %+v", events.Events)
+	}
+}
+
+// TestLogoutUnauthorized проверяет отказ без claims в контексте
+func TestLogoutUnauthorized(t *testing.T) {
+	for name, handle := range map[string]http.HandlerFunc{
+		"Logout":    testAuthHandlers().Logout,
+		"LogoutAll": testAuthHandlers().LogoutAll,
+	} {
+		rr := httptest.NewRecorder()
+		handle(rr, httptest.NewRequest("POST", "/logout", nil))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s() вернул неправильный статус: получено %v, ожидается %v", name, rr.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+// TestLogoutAllSuccess проверяет отзыв всех токенов пользователя
+func TestLogoutAllSuccess(t *testing.T) {
+	claims := testClaims(t)
+	mockDB := &MockDBClient{
+		RevokeAllTokensFunc: func(userID int) (time.Time, error) {
+			if userID != 1 {
+				t.Errorf("Отзыв для чужого пользователя: %d", userID)
+			}
+			return claims.IssuedAt.Time.Add(time.Second), nil
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+
+	req := httptest.NewRequest("POST", "/logout-all", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).LogoutAll(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("LogoutAll() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if !cache.IsRevoked(claims) {
+		t.Error("Токены пользователя должны сразу считаться отозванными на этом экземпляре")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "LOGOUT_ALL" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие LOGOUT_ALL, получено %+v", events.Events)
+	}
+}
+
+// TestLogoutAllDBError проверяет ответ при ошибке db-service
+func TestLogoutAllDBError(t *testing.T) {
+	mockDB := &MockDBClient{
+		RevokeAllTokensFunc: func(userID int) (time.Time, error) {
+			return time.Time{}, errors.New("connection refused")
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/logout-all", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, testClaims(t)))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).LogoutAll(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("LogoutAll() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ CHANGE PASSWORD И DELETE ACCOUNT
+// ============================================================================
+
+// TestChangePasswordSuccess проверяет смену пароля и отзыв всех токенов пользователя
+func TestChangePasswordSuccess(t *testing.T) {
+	claims := testClaims(t)
+	var newHash string
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "oldpassword"),
+		ChangePasswordFunc: func(userID int, req *models.UpdatePasswordRequest) (time.Time, error) {
+			if userID != 1 {
+				t.Errorf("Смена пароля чужого пользователя: %d", userID)
+			}
+			newHash = req.PasswordHash
+			return claims.IssuedAt.Time.Add(1500 * time.Millisecond), nil
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+
+	req := httptest.NewRequest("PUT", "/account/password", bytes.NewBufferString(`{"old_password":"oldpassword","new_password":"newpassword"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).ChangePassword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ChangePassword() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if auth.CheckPassword("newpassword", newHash) != nil {
+		t.Error("В db-service должен уходить хэш нового пароля")
+	}
+	if !cache.IsRevoked(claims) {
+		t.Error("Текущий токен должен считаться отозванным после смены пароля")
+	}
+	if cache.IsRevoked(reissued(claims, claims.IssuedAt.Time.Add(1700*time.Millisecond))) {
+		t.Error("Токен, выданный при входе в ту же секунду после смены пароля, должен действовать")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "CHANGE_PASSWORD" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие CHANGE_PASSWORD, получено %+v", events.Events)
+	}
+}
+
+// TestChangePasswordWrongPassword проверяет отказ при неверном старом пароле
+func TestChangePasswordWrongPassword(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "oldpassword"),
+		ChangePasswordFunc: func(userID int, req *models.UpdatePasswordRequest) (time.Time, error) {
+			t.Error("Пароль не должен меняться без проверки старого")
+			return time.Time{}, nil
+		},
+	}
+	events := &MockEventProducer{}
+
+	req := httptest.NewRequest("PUT", "/account/password", bytes.NewBufferString(`{"old_password":"wrongpassword","new_password":"newpassword"}`))
+	req = addAuthContext(req, 1, "testuser")
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, nil, nil).ChangePassword(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("ChangePassword() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusForbidden)
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "CHANGE_PASSWORD" || events.Events[0].Status != "ERROR" {
+		t.Errorf("Ожидалось событие CHANGE_PASSWORD с ошибкой, получено %+v", events.Events)
+	}
+}
+
+// TestChangePasswordValidation проверяет ошибки в теле запроса
+func TestChangePasswordValidation(t *testing.T) {
+	for _, body := range []string{
+		`{`,
+		`{"new_password":"newpassword"}`,
+		`{"old_password":"oldpassword","new_password":"short"}`,
+		`{"old_password":"oldpassword","new_password":"oldpassword"}`,
+	} {
+		req := addAuthContext(httptest.NewRequest("PUT", "/account/password", bytes.NewBufferString(body)), 1, "testuser")
+		rr := httptest.NewRecorder()
+		testAuthHandlers().ChangePassword(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("ChangePassword(%s): получено %v, ожидается %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestDeleteAccountSuccess проверяет удаление учётной записи и отзыв её токенов
+func TestDeleteAccountSuccess(t *testing.T) {
+	claims := testClaims(t)
+	deleted := false
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		DeleteUserFunc: func(userID int) error {
+			deleted = userID == 1
+			return nil
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+
+	req := httptest.NewRequest("DELETE", "/account", bytes.NewBufferString(`{"password":"password123"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).DeleteAccount(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("DeleteAccount() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if !deleted {
+		t.Error("Пользователь 1 должен быть удалён")
+	}
+	if !cache.IsRevoked(claims) {
+		t.Error("Токены удалённого пользователя должны считаться отозванными")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "DELETE_ACCOUNT" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие DELETE_ACCOUNT, получено %+v", events.Events)
+	}
+}
+
+// TestDeleteAccountRejected проверяет отказ без пароля, с неверным паролем и для пользователя, занявшего имя удалённого
+func TestDeleteAccountRejected(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		DeleteUserFunc: func(userID int) error {
+			t.Error("Учётная запись не должна удаляться")
+			return nil
+		},
+	}
+
+	tests := []struct {
+		userID int
+		body   string
+		status int
+	}{
+		{1, `{}`, http.StatusBadRequest},
+		{1, `{"password":"wrongpassword"}`, http.StatusForbidden},
+		{2, `{"password":"password123"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := addAuthContext(httptest.NewRequest("DELETE", "/account", bytes.NewBufferString(tt.body)), tt.userID, "testuser")
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).DeleteAccount(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("DeleteAccount(user %d, %s): получено %v, ожидается %v", tt.userID, tt.body, rr.Code, tt.status)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ СБРОСА ПАРОЛЯ И ПОЧТЫ
+// ============================================================================
+
+// TestForgotPasswordSendsMail проверяет, что письмо содержит токен, хэш которого сохранён в db-service
+func TestForgotPasswordSendsMail(t *testing.T) {
+	var stored *models.CreatePasswordResetRequest
+	mockDB := &MockDBClient{
+		CreatePasswordResetFunc: func(req *models.CreatePasswordResetRequest) (*models.User, error) {
+			stored = req
+			return &models.User{ID: 1, Username: "testuser"}, nil
+		},
+	}
+	events := &MockEventProducer{}
+	var mailbox bytes.Buffer
+	h := NewAuthHandlers(mockDB, events, nil, mailer.NewLogMailer(&mailbox, "todo@localhost"))
+	h.ResetURL = "http://localhost:8080/"
+
+	req := httptest.NewRequest("POST", "/password/forgot", bytes.NewBufferString(`{"email":"user@example.com"}`))
+	rr := httptest.NewRecorder()
+	h.ForgotPassword(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("ForgotPassword() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if stored == nil || stored.Email != "user@example.com" || stored.TTLSeconds != 3600 {
+		t.Fatalf("Неправильный запрос в db-service: %+v", stored)
+	}
+
+	mail := mailbox.String()
+	i := strings.Index(mail, "reset_token=")
+	if !strings.Contains(mail, "To: user@example.com") || i < 0 {
+		t.Fatalf("Письмо без ссылки сброса:\n%s", mail)
+	}
+	token := strings.Fields(mail[i+len("reset_token="):])[0]
+	if auth.HashPasswordResetToken(token) != stored.TokenHash {
+		t.Errorf("Токен в письме не соответствует сохранённому хэшу")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "PASSWORD_RESET_REQUEST" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие PASSWORD_RESET_REQUEST, получено %+v", events.Events)
+	}
+}
+
+// TestForgotPasswordUnknownEmail проверяет, что для незарегистрированной почты ответ тот же, а письма нет
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	mockDB := &MockDBClient{
+		CreatePasswordResetFunc: func(req *models.CreatePasswordResetRequest) (*models.User, error) {
+			return nil, fmt.Errorf("%w: User not found", client.ErrNotFound)
+		},
+	}
+	var mailbox bytes.Buffer
+
+	req := httptest.NewRequest("POST", "/password/forgot", bytes.NewBufferString(`{"email":"nobody@example.com"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, mailer.NewLogMailer(&mailbox, "todo@localhost")).ForgotPassword(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("ForgotPassword() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusAccepted)
+	}
+	if mailbox.Len() != 0 {
+		t.Errorf("Письмо не должно отправляться:\n%s", mailbox.String())
+	}
+}
+
+// TestForgotPasswordRejected проверяет неверную почту и отключённую отправку писем
+func TestForgotPasswordRejected(t *testing.T) {
+	var mailbox bytes.Buffer
+	withMailer := NewAuthHandlers(&MockDBClient{}, &MockEventProducer{}, nil, mailer.NewLogMailer(&mailbox, "todo@localhost"))
+
+	tests := []struct {
+		h      *AuthHandlers
+		body   string
+		status int
+	}{
+		{withMailer, `{`, http.StatusBadRequest},
+		{withMailer, `{"email":"not-an-email"}`, http.StatusBadRequest},
+		{testAuthHandlers(), `{"email":"user@example.com"}`, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/password/forgot", bytes.NewBufferString(tt.body))
+		rr := httptest.NewRecorder()
+		tt.h.ForgotPassword(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("ForgotPassword(%s): получено %v, ожидается %v", tt.body, rr.Code, tt.status)
+		}
+	}
+}
+
+// TestResetPasswordSuccess проверяет сброс пароля по токену и отзыв токенов пользователя
+func TestResetPasswordSuccess(t *testing.T) {
+	claims := testClaims(t)
+	var consumed *models.ConsumePasswordResetRequest
+	mockDB := &MockDBClient{
+		ConsumePasswordResetFunc: func(req *models.ConsumePasswordResetRequest) (*models.PasswordReset, error) {
+			consumed = req
+			return &models.PasswordReset{UserID: 1, Username: "testuser", RevokedBefore: claims.IssuedAt.Time.Add(1500 * time.Millisecond)}, nil
+		},
+	}
+	events := &MockEventProducer{}
+	cache := revocation.NewCache(nil, time.Hour, 0)
+
+	req := httptest.NewRequest("POST", "/password/reset", bytes.NewBufferString(`{"token":"reset-token","new_password":"newpassword"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, cache, nil).ResetPassword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ResetPassword() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if consumed.TokenHash != auth.HashPasswordResetToken("reset-token") || auth.CheckPassword("newpassword", consumed.PasswordHash) != nil {
+		t.Errorf("Неправильный запрос в db-service: %+v", consumed)
+	}
+	if !cache.IsRevoked(claims) {
+		t.Error("Токены пользователя должны считаться отозванными после сброса пароля")
+	}
+	if cache.IsRevoked(reissued(claims, claims.IssuedAt.Time.Add(1700*time.Millisecond))) {
+		t.Error("Токен, выданный при входе в ту же секунду после сброса пароля, должен действовать")
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "PASSWORD_RESET" || events.Events[0].UserID != 1 {
+		t.Errorf("Ожидалось событие PASSWORD_RESET, получено %+v", events.Events)
+	}
+}
+
+// TestResetPasswordRejected проверяет ошибки в теле запроса и недействительный токен
+func TestResetPasswordRejected(t *testing.T) {
+	mockDB := &MockDBClient{
+		ConsumePasswordResetFunc: func(req *models.ConsumePasswordResetRequest) (*models.PasswordReset, error) {
+			return nil, fmt.Errorf("%w: Password reset token not found, used or expired", client.ErrNotFound)
+		},
+	}
+
+	for _, body := range []string{
+		`{`,
+		`{"new_password":"newpassword"}`,
+		`{"token":"reset-token","new_password":"short"}`,
+		`{"token":"used-token","new_password":"newpassword"}`,
+	} {
+		req := httptest.NewRequest("POST", "/password/reset", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).ResetPassword(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("ResetPassword(%s): получено %v, ожидается %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// TestUpdateEmail проверяет смену почты с подтверждением паролем
+func TestUpdateEmail(t *testing.T) {
+	var updated string
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		UpdateEmailFunc: func(userID int, email string) error {
+			if email == "taken@example.com" {
+				return fmt.Errorf("%w: Email already in use", client.ErrConflict)
+			}
+			updated = email
+			return nil
+		},
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"email":"user@example.com","password":"password123"}`, http.StatusOK},
+		{`{"email":"not-an-email","password":"password123"}`, http.StatusBadRequest},
+		{`{"email":"user@example.com"}`, http.StatusBadRequest},
+		{`{"email":"user@example.com","password":"wrongpassword"}`, http.StatusForbidden},
+		{`{"email":"taken@example.com","password":"password123"}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		req := addAuthContext(httptest.NewRequest("PUT", "/account/email", bytes.NewBufferString(tt.body)), 1, "testuser")
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).UpdateEmail(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("UpdateEmail(%s): получено %v, ожидается %v", tt.body, rr.Code, tt.status)
+		}
+	}
+	if updated != "user@example.com" {
+		t.Errorf("Почта не обновлена: %q", updated)
+	}
+}
+
+// TestRegisterInvalidEmail проверяет отказ при неверной почте
+func TestRegisterInvalidEmail(t *testing.T) {
+	req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(`{"username":"testuser","password":"password123","email":"not-an-email"}`))
+	rr := httptest.NewRecorder()
+	testAuthHandlers().Register(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Register() вернул неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ДВУХФАКТОРНОЙ АУТЕНТИФИКАЦИИ
+// ============================================================================
+
+// testTOTPSecret секрет TOTP для тестов
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// currentTOTPCode код testTOTPSecret для текущего шага
+func currentTOTPCode(t *testing.T) (string, int64) {
+	t.Helper()
+	step := auth.TOTPStep(time.Now())
+	code, err := auth.TOTPCode(testTOTPSecret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode() вернул ошибку: %v", err)
+	}
+	return code, step
+}
+
+// enabledTOTP GetTOTPFunc пользователя с включённой двухфакторной аутентификацией
+func enabledTOTP(totp models.TOTP) func(int) (*models.TOTP, error) {
+	totp.Secret = testTOTPSecret
+	totp.Enabled = true
+	return func(userID int) (*models.TOTP, error) {
+		return &totp, nil
+	}
+}
+
+// TestLoginMFARequired проверяет, что при включённой двухфакторной аутентификации вместо токенов выдаётся mfa_token
+func TestLoginMFARequired(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		GetTOTPFunc:           enabledTOTP(models.TOTP{}),
+		CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+			t.Error("Refresh-токен не должен выдаваться до проверки кода")
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(`{"username":"testuser","password":"password123"}`))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).Login(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Login() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["mfa_required"] != true || resp["token"] != nil {
+		t.Fatalf("Ожидался mfa_required без токенов, получено %v", resp)
+	}
+
+	mfaToken, _ := resp["mfa_token"].(string)
+	if claims, err := auth.ValidateMFAToken(mfaToken); err != nil || claims.UserID != 1 {
+		t.Errorf("Неправильный mfa_token: %+v, %v", claims, err)
+	}
+	if _, err := auth.ValidateToken(mfaToken); err == nil {
+		t.Error("mfa_token не должен приниматься как access-токен")
+	}
+}
+
+// TestLoginMFASuccess проверяет обмен mfa_token и кода на пару токенов
+func TestLoginMFASuccess(t *testing.T) {
+	code, step := currentTOTPCode(t)
+	var usedStep int64
+	mockDB := &MockDBClient{
+		GetTOTPFunc: enabledTOTP(models.TOTP{}),
+		UseTOTPStepFunc: func(userID int, s int64) error {
+			usedStep = s
+			return nil
+		},
+		CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+			return nil
+		},
+	}
+	events := &MockEventProducer{}
+	mfaToken, _ := auth.GenerateMFAToken(1, "testuser")
+
+	body := `{"mfa_token":"` + mfaToken + `","code":"` + code + `"}`
+	req := httptest.NewRequest("POST", "/login/mfa", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, nil, nil).LoginMFA(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("LoginMFA() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	var resp models.AuthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.Token == "" || resp.RefreshToken == "" || resp.UserID != 1 {
+		t.Errorf("Неправильный ответ: %+v, %v", resp, err)
+	}
+	if usedStep != step {
+		t.Errorf("Ожидался принятый шаг %d, получен %d", step, usedStep)
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "LOGIN_MFA" || events.Events[0].Status != "SUCCESS" {
+		t.Errorf("Ожидалось событие LOGIN_MFA, получено %+v", events.Events)
+	}
+}
+
+// TestLoginMFARecoveryCode проверяет вход с кодом восстановления
+func TestLoginMFARecoveryCode(t *testing.T) {
+	var usedHash string
+	mockDB := &MockDBClient{
+		// Блокировка перебора не мешает кодам восстановления
+		GetTOTPFunc: enabledTOTP(models.TOTP{Failures: totpMaxFailures, FailedAt: &time.Time{}}),
+		UseRecoveryCodeFunc: func(userID int, hash string) (int, error) {
+			usedHash = hash
+			return 9, nil
+		},
+		CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+			return nil
+		},
+	}
+	events := &MockEventProducer{}
+	mfaToken, _ := auth.GenerateMFAToken(1, "testuser")
+
+	body := `{"mfa_token":"` + mfaToken + `","recovery_code":"ABCD-EFGH-IJKL-MNOP"}`
+	req := httptest.NewRequest("POST", "/login/mfa", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, nil, nil).LoginMFA(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("LoginMFA() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	if usedHash != auth.HashRecoveryCode("abcd-efgh-ijkl-mnop") {
+		t.Errorf("В db-service ушёл неправильный хэш: %s", usedHash)
+	}
+	if len(events.Events) != 1 || !strings.Contains(events.Events[0].Details, "9 left") {
+		t.Errorf("Ожидалось событие с числом оставшихся кодов, получено %+v", events.Events)
+	}
+}
+
+// TestLoginMFARejected проверяет неверный и повторный код, чужой токен и блокировку перебора
+func TestLoginMFARejected(t *testing.T) {
+	code, _ := currentTOTPCode(t)
+	mfaToken, _ := auth.GenerateMFAToken(1, "testuser")
+	accessToken, _ := auth.GenerateToken(1, "testuser")
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	recent := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		totp     models.TOTP
+		stepErr  error
+		token    string
+		code     string
+		status   int
+		failures int
+	}{
+		{"неверный код", models.TOTP{}, nil, mfaToken, wrong, http.StatusUnauthorized, 1},
+		{"код уже принят параллельно", models.TOTP{}, client.ErrConflict, mfaToken, code, http.StatusUnauthorized, 1},
+		{"access-токен вместо mfa_token", models.TOTP{}, nil, accessToken, code, http.StatusUnauthorized, 0},
+		{"перебор", models.TOTP{Failures: totpMaxFailures, FailedAt: &recent}, nil, mfaToken, code, http.StatusTooManyRequests, 0},
+	}
+
+	for _, tt := range tests {
+		failures := 0
+		mockDB := &MockDBClient{
+			GetTOTPFunc: enabledTOTP(tt.totp),
+			UseTOTPStepFunc: func(userID int, step int64) error {
+				return tt.stepErr
+			},
+			RecordTOTPFailureFunc: func(userID int) error {
+				failures++
+				return nil
+			},
+			CreateRefreshTokenFunc: func(userID int, req *models.CreateRefreshTokenRequest) error {
+				t.Errorf("%s: токены не должны выдаваться", tt.name)
+				return nil
+			},
+		}
+
+		body := `{"mfa_token":"` + tt.token + `","code":"` + tt.code + `"}`
+		req := httptest.NewRequest("POST", "/login/mfa", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).LoginMFA(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: получено %v, ожидается %v", tt.name, rr.Code, tt.status)
+		}
+		if failures != tt.failures {
+			t.Errorf("%s: засчитано неудач %d, ожидалось %d", tt.name, failures, tt.failures)
+		}
+	}
+}
+
+// TestSetupAndEnableTOTP проверяет включение: секрет и ссылка для QR-кода, подтверждение кодом, коды восстановления
+func TestSetupAndEnableTOTP(t *testing.T) {
+	claims := testClaims(t)
+	var pending models.TOTP
+	var enabled *models.EnableTOTPRequest
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		SetupTOTPFunc: func(userID int, secret string) error {
+			pending = models.TOTP{Secret: secret}
+			return nil
+		},
+		GetTOTPFunc: func(userID int) (*models.TOTP, error) {
+			return &pending, nil
+		},
+		EnableTOTPFunc: func(userID int, req *models.EnableTOTPRequest) error {
+			enabled = req
+			return nil
+		},
+	}
+	events := &MockEventProducer{}
+	h := NewAuthHandlers(mockDB, events, nil, nil)
+
+	req := httptest.NewRequest("POST", "/account/2fa/setup", bytes.NewBufferString(`{"password":"password123"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	h.SetupTOTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("SetupTOTP() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	var setup models.TOTPSetupResponse
+	json.NewDecoder(rr.Body).Decode(&setup)
+	if setup.Secret == "" || setup.Secret != pending.Secret || !strings.HasPrefix(setup.OtpauthURI, "otpauth://totp/") {
+		t.Fatalf("Неправильный ответ: %+v", setup)
+	}
+
+	code, err := auth.TOTPCode(setup.Secret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode() вернул ошибку: %v", err)
+	}
+	req = httptest.NewRequest("POST", "/account/2fa/enable", bytes.NewBufferString(`{"code":"`+code+`"}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr = httptest.NewRecorder()
+	h.EnableTOTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("EnableTOTP() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	var resp models.TOTPEnableResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.RecoveryCodes) != auth.RecoveryCodeCount || enabled == nil || len(enabled.RecoveryCodeHashes) != auth.RecoveryCodeCount {
+		t.Fatalf("Ожидалось %d кодов восстановления, получено %+v и %+v", auth.RecoveryCodeCount, resp, enabled)
+	}
+	for i, code := range resp.RecoveryCodes {
+		if enabled.RecoveryCodeHashes[i] != auth.HashRecoveryCode(code) {
+			t.Errorf("В db-service должен уходить хэш кода %d, а не сам код", i)
+		}
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "ENABLE_2FA" {
+		t.Errorf("Ожидалось событие ENABLE_2FA, получено %+v", events.Events)
+	}
+}
+
+// TestEnableTOTPRejected проверяет неверный код подтверждения и уже включённую аутентификацию
+func TestEnableTOTPRejected(t *testing.T) {
+	claims := testClaims(t)
+	code, _ := currentTOTPCode(t)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	tests := []struct {
+		name   string
+		totp   *models.TOTP
+		code   string
+		status int
+	}{
+		{"неверный код", &models.TOTP{Secret: testTOTPSecret}, wrong, http.StatusBadRequest},
+		{"уже включена", &models.TOTP{Secret: testTOTPSecret, Enabled: true}, code, http.StatusConflict},
+		{"без настройки", nil, code, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		mockDB := &MockDBClient{
+			GetTOTPFunc: func(userID int) (*models.TOTP, error) {
+				if tt.totp == nil {
+					return nil, client.ErrNotFound
+				}
+				return tt.totp, nil
+			},
+			EnableTOTPFunc: func(userID int, req *models.EnableTOTPRequest) error {
+				t.Errorf("%s: настройка не должна подтверждаться", tt.name)
+				return nil
+			},
+		}
+
+		req := httptest.NewRequest("POST", "/account/2fa/enable", bytes.NewBufferString(`{"code":"`+tt.code+`"}`))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).EnableTOTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: получено %v, ожидается %v", tt.name, rr.Code, tt.status)
+		}
+	}
+}
+
+// TestDisableTOTP проверяет, что выключение требует пароль и код
+func TestDisableTOTP(t *testing.T) {
+	claims := testClaims(t)
+	code, _ := currentTOTPCode(t)
+	disabled := 0
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		GetTOTPFunc:           enabledTOTP(models.TOTP{}),
+		UseTOTPStepFunc: func(userID int, step int64) error {
+			return nil
+		},
+		RecordTOTPFailureFunc: func(userID int) error {
+			return nil
+		},
+		DisableTOTPFunc: func(userID int) error {
+			disabled++
+			return nil
+		},
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"password":"password123"}`, http.StatusBadRequest},
+		{`{"password":"wrongpassword","code":"` + code + `"}`, http.StatusForbidden},
+		{`{"password":"password123","code":"` + wrong + `"}`, http.StatusForbidden},
+		{`{"password":"password123","code":"` + code + `"}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest("DELETE", "/account/2fa", bytes.NewBufferString(tt.body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).DisableTOTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("DisableTOTP(%s): получено %v, ожидается %v", tt.body, rr.Code, tt.status)
+		}
+	}
+
+	if disabled != 1 {
+		t.Errorf("Двухфакторная аутентификация должна выключаться один раз, выключена %d", disabled)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ персональных токенов доступа
+// ============================================================================
+
+// TestCreateAccessToken проверяет, что токен возвращается один раз, а в db-service уходит только его хэш
+func TestCreateAccessToken(t *testing.T) {
+	claims := testClaims(t)
+	var stored *models.StoreAccessTokenRequest
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		CreateAccessTokenFunc: func(userID int, req *models.StoreAccessTokenRequest) (*models.AccessToken, error) {
+			stored = req
+			return &models.AccessToken{ID: 7, Name: req.Name, Scopes: req.Scopes, CreatedAt: time.Now(), ExpiresAt: req.ExpiresAt}, nil
+		},
+	}
+	events := &MockEventProducer{}
+
+	body := `{"password":"password123","name":" ci ","scopes":["tasks:write","tasks:read","tasks:write"],"expires_at":"` +
+		time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest("POST", "/account/tokens", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, events, nil, nil).CreateAccessToken(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("CreateAccessToken() вернул неправильный статус: получено %v: %s", rr.Code, rr.Body.String())
+	}
+	var resp models.CreateAccessTokenResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if !auth.IsPersonalAccessToken(resp.Token) || resp.ID != 7 || resp.Name != "ci" || resp.ExpiresAt == nil {
+		t.Fatalf("Неправильный ответ: %+v", resp)
+	}
+	if stored.TokenHash != auth.HashPersonalAccessToken(resp.Token) {
+		t.Error("В db-service должен уходить хэш токена, а не сам токен")
+	}
+	if strings.Join(stored.Scopes, ",") != "tasks:read,tasks:write" {
+		t.Errorf("Области должны сохраняться без повторов, получено %v", stored.Scopes)
+	}
+	if len(events.Events) != 1 || events.Events[0].Action != "CREATE_ACCESS_TOKEN" || strings.Contains(events.Events[0].Details, resp.Token) {
+		t.Errorf("Ожидалось событие CREATE_ACCESS_TOKEN без самого токена, получено %+v", events.Events)
+	}
+}
+
+// TestCreateAccessTokenRejected проверяет тело запроса, пароль и занятое имя
+func TestCreateAccessTokenRejected(t *testing.T) {
+	claims := testClaims(t)
+	mockDB := &MockDBClient{
+		GetUserByUsernameFunc: accountUser(t, "password123"),
+		CreateAccessTokenFunc: func(userID int, req *models.StoreAccessTokenRequest) (*models.AccessToken, error) {
+			return nil, client.ErrConflict
+		},
+	}
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"без пароля", `{"name":"ci","scopes":["tasks:read"]}`, http.StatusBadRequest},
+		{"без имени", `{"password":"password123","name":"  ","scopes":["tasks:read"]}`, http.StatusBadRequest},
+		{"без областей", `{"password":"password123","name":"ci","scopes":[]}`, http.StatusBadRequest},
+		{"неизвестная область", `{"password":"password123","name":"ci","scopes":["account:write"]}`, http.StatusBadRequest},
+		{"срок в прошлом", `{"password":"password123","name":"ci","scopes":["tasks:read"],"expires_at":"` + past + `"}`, http.StatusBadRequest},
+		{"неверный пароль", `{"password":"wrongpassword","name":"ci","scopes":["tasks:read"]}`, http.StatusForbidden},
+		{"имя занято", `{"password":"password123","name":"ci","scopes":["tasks:read"]}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/account/tokens", bytes.NewBufferString(tt.body))
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).CreateAccessToken(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: получено %v, ожидается %v", tt.name, rr.Code, tt.status)
+		}
+	}
+}
+
+func TestListAccessTokens(t *testing.T) {
+	mockDB := &MockDBClient{
+		ListAccessTokensFunc: func(userID int) ([]models.AccessToken, error) {
+			if userID != 1 {
+				t.Errorf("Ожидался пользователь 1, получен %d", userID)
+			}
+			return []models.AccessToken{{ID: 7, Name: "ci", Scopes: []string{"tasks:read"}}}, nil
+		},
+	}
+
+	req := httptest.NewRequest("GET", "/account/tokens", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, testClaims(t)))
+	rr := httptest.NewRecorder()
+	NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil).ListAccessTokens(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListAccessTokens() вернул неправильный статус: получено %v", rr.Code)
+	}
+	var tokens []models.AccessToken
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil || len(tokens) != 1 || tokens[0].Name != "ci" {
+		t.Errorf("Неправильный ответ: %+v, %v", tokens, err)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		err    error
+		status int
+	}{
+		{"отозван", "7", nil, http.StatusOK},
+		{"чужой токен", "8", client.ErrNotFound, http.StatusNotFound},
+		{"неправильный id", "abc", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		events := &MockEventProducer{}
+		mockDB := &MockDBClient{
+			DeleteAccessTokenFunc: func(id, userID int) error {
+				return tt.err
+			},
+		}
+
+		req := httptest.NewRequest("DELETE", "/account/tokens/"+tt.id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, testClaims(t)))
+		rr := httptest.NewRecorder()
+		NewAuthHandlers(mockDB, events, nil, nil).RevokeAccessToken(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: получено %v, ожидается %v", tt.name, rr.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && (len(events.Events) != 1 || events.Events[0].Action != "REVOKE_ACCESS_TOKEN") {
+			t.Errorf("%s: ожидалось событие REVOKE_ACCESS_TOKEN, получено %+v", tt.name, events.Events)
+		}
+	}
+}
+
+// TestAuthenticatePAT проверяет, что db-service получает хэш токена, а неизвестный токен даёт auth.ErrPATInvalid
+func TestAuthenticatePAT(t *testing.T) {
+	token, hash, _ := auth.NewPersonalAccessToken()
+	mockDB := &MockDBClient{
+		AuthenticateAccessTokenFunc: func(tokenHash string) (*models.AccessTokenOwner, error) {
+			if tokenHash != hash {
+				return nil, client.ErrNotFound
+			}
+			return &models.AccessTokenOwner{TokenID: 7, UserID: 1, Username: "testuser", Scopes: []string{"tasks:read"}}, nil
+		},
+	}
+	h := NewAuthHandlers(mockDB, &MockEventProducer{}, nil, nil)
+
+	claims, scopes, err := h.AuthenticatePAT(token)
+	if err != nil || claims.UserID != 1 || claims.Username != "testuser" || len(scopes) != 1 {
+		t.Errorf("AuthenticatePAT() = %+v, %v, %v", claims, scopes, err)
+	}
+
+	if _, _, err := h.AuthenticatePAT(auth.PATPrefix + "unknown"); !errors.Is(err, auth.ErrPATInvalid) {
+		t.Errorf("Ожидалась auth.ErrPATInvalid, получено %v", err)
+	}
+}
+
 // ============================================================================
 // ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ
 // ============================================================================
 
+// testAuthHandlers AuthHandlers для проверок запроса, до которых db-service не вызывается
+func testAuthHandlers() *AuthHandlers {
+	return NewAuthHandlers(&MockDBClient{}, &MockEventProducer{}, nil, nil)
+}
+
+// testClaims claims настоящего токена пользователя 1: с jti, iat и exp, как после AuthMiddleware
+func testClaims(t *testing.T) *auth.Claims {
+	t.Helper()
+	token, err := auth.GenerateToken(1, "testuser")
+	if err != nil {
+		t.Fatalf("GenerateToken() вернул ошибку: %v", err)
+	}
+	claims, err := auth.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() вернул ошибку: %v", err)
+	}
+	return claims
+}
+
+// reissued claims того же пользователя с новым jti, выданные в issuedAt; iat, как и в настоящем токене, с точностью до микросекунды
+func reissued(claims *auth.Claims, issuedAt time.Time) *auth.Claims {
+	fresh := *claims
+	fresh.ID = claims.ID + "-reissued"
+	fresh.IssuedAt = jwt.NewNumericDate(issuedAt)
+	return &fresh
+}
+
+// accountUser GetUserByUsernameFunc, возвращающий пользователя 1 с паролем password
+func accountUser(t *testing.T, password string) func(string) (*models.User, error) {
+	t.Helper()
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword() вернул ошибку: %v", err)
+	}
+	return func(username string) (*models.User, error) {
+		return &models.User{ID: 1, Username: username, PasswordHash: hash}, nil
+	}
+}
+
 // contains проверяет, содержит ли строка подстроку
 func contains(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 &&
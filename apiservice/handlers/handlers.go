@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"apiservice/auth"
+	"apiservice/client"
 	"apiservice/middleware"
 	"apiservice/models"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -41,9 +46,20 @@ func (h *TaskHandlers) HandleCreateTask(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.StartAt != nil && req.DueAt != nil && req.StartAt.After(*req.DueAt) {
+		http.Error(w, `error: start_at must not be after due_at`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Priority != "" && !models.ValidPriorities[req.Priority] {
+		http.Error(w, `error: priority must be one of none, low, medium, high, urgent`, http.StatusBadRequest)
+		return
+	}
+
+	req.ExistingTagsOnly = !canCreateTags(r)
 	task, err := h.DBClient.CreateTask(&req, claims.UserID)
 	if err != nil {
-		http.Error(w, `error: Failed to create task`, http.StatusInternalServerError)
+		http.Error(w, `error: Failed to create task`, dbErrorStatus(err))
 		h.EventProducer.SendEvent(
 			claims.UserID,
 			claims.Username,
@@ -56,7 +72,7 @@ func (h *TaskHandlers) HandleCreateTask(w http.ResponseWriter, r *http.Request)
 		claims.UserID,
 		claims.Username,
 		"CREATE_TASK",
-		fmt.Sprintf("Task created: id=%d, name=%s", task.ID, task.Name), "SUCCESS")
+		fmt.Sprintf("Task created: id=%d, name=%s%s", task.ID, task.Name, inCollection(task.CollectionID)), "SUCCESS")
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -70,15 +86,18 @@ func (h *TaskHandlers) HandleGetAllTasks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tasks, err := h.DBClient.GetAllTasks(claims.UserID)
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetAllTasks(claims.UserID, opts)
 	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	writeList(w, tasks, opts.PageParams)
 }
 
 func (h *TaskHandlers) HandleDeleteTask(w http.ResponseWriter, r *http.Request) {
@@ -131,7 +150,7 @@ func (h *TaskHandlers) HandleCompleteTask(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = h.DBClient.CompleteTask(id, claims.UserID)
+	next, err := h.DBClient.CompleteTask(id, claims.UserID)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to complete task"}`, http.StatusInternalServerError)
 		h.EventProducer.SendEvent(
@@ -148,187 +167,1745 @@ func (h *TaskHandlers) HandleCompleteTask(w http.ResponseWriter, r *http.Request
 		"COMPLETE_TASK",
 		fmt.Sprintf("Task completed: id=%d", id), "SUCCESS")
 
+	if next != nil {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"RECUR_TASK",
+			fmt.Sprintf("Task recurred: id=%d, next_id=%d, due_at=%s", id, next.ID, formatDueAt(next.DueAt)), "SUCCESS")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Task completed successfully",
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "Task completed successfully",
+		"next_task": next,
 	})
 }
 
-func (h *TaskHandlers) HandleGetCompletedTasks(w http.ResponseWriter, r *http.Request) {
+// formatDueAt форматирует срок для события; задача без срока помечается как none
+func formatDueAt(t *time.Time) string {
+	if t == nil {
+		return "none"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (h *TaskHandlers) HandleReopenTask(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	tasks, err := h.DBClient.GetCompleted(claims.UserID)
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.DBClient.ReopenTask(id, claims.UserID)
 	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to reopen task"}`, http.StatusInternalServerError)
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"REOPEN_TASK",
+			fmt.Sprintf("Failed to reopen task: id=%d", id), "ERROR")
 		return
 	}
 
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REOPEN_TASK",
+		fmt.Sprintf("Task reopened: id=%d", id), "SUCCESS")
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Task reopened successfully",
+	})
 }
 
-func (h *TaskHandlers) HandleGetUncompletedTasks(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandlers) HandleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	tasks, err := h.DBClient.GetUncompleted(claims.UserID)
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if req.IsEmpty() {
+		http.Error(w, `error: No fields to update`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			http.Error(w, `error: Name cannot be empty`, http.StatusBadRequest)
+			return
+		}
+		if !models.ValidLength(name, models.MaxTaskNameLength) {
+			http.Error(w, `error: name must be at most 255 characters`, http.StatusBadRequest)
+			return
+		}
+		req.Name = &name
+	}
+
+	if req.StartAt.Value != nil && req.DueAt.Value != nil && req.StartAt.Value.After(*req.DueAt.Value) {
+		http.Error(w, `error: start_at must not be after due_at`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Priority != nil && !models.ValidPriorities[*req.Priority] {
+		http.Error(w, `error: priority must be one of none, low, medium, high, urgent`, http.StatusBadRequest)
+		return
+	}
+
+	req.ExistingTagsOnly = !canCreateTags(r)
+	updated, err := h.DBClient.UpdateTask(id, claims.UserID, &req)
 	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to update task"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"UPDATE_TASK",
+			fmt.Sprintf("Failed to update task: id=%d", id), "ERROR")
 		return
 	}
 
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"UPDATE_TASK",
+		fmt.Sprintf("Task updated: id=%d%s, %s", id, inCollection(updated.Task.CollectionID), formatChanges(updated.Changes)), "SUCCESS")
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(updated.Task)
 }
 
-func (h *TaskHandlers) HandleGetTasksByID(w http.ResponseWriter, r *http.Request) {
+// HandleMoveTask переносит одну задачу: POST /tasks/{id}/move {"collection_id": 3}
+func (h *TaskHandlers) HandleMoveTask(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, `error: Invalid task ID`, http.StatusBadRequest)
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	tasks, err := h.DBClient.GetTaskByID(id)
-	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+	var req models.MoveTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
 		return
 	}
+	req.TaskIDs = []int{id}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	h.moveTasks(w, claims, &req)
 }
 
-func (h *TaskHandlers) HandleGetTasksByName(w http.ResponseWriter, r *http.Request) {
-	name := mux.Vars(r)["name"]
+// HandleMoveTasks переносит несколько задач: POST /tasks/move {"task_ids": [1, 2], "collection_id": 3}
+func (h *TaskHandlers) HandleMoveTasks(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.MoveTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if len(req.TaskIDs) == 0 || len(req.TaskIDs) > models.MaxMoveTasks {
+		http.Error(w, `error: task_ids must contain 1-100 task IDs`, http.StatusBadRequest)
+		return
+	}
+
+	h.moveTasks(w, claims, &req)
+}
+
+// moveTasks общая часть переноса: отправляет MOVE_TASK на каждую задачу, сменившую коллекцию
+func (h *TaskHandlers) moveTasks(w http.ResponseWriter, claims *auth.Claims, req *models.MoveTasksRequest) {
+	if !req.CollectionID.Set {
+		http.Error(w, `error: collection_id is required (null removes tasks from their collection)`, http.StatusBadRequest)
+		return
+	}
 
-	tasks, err := h.DBClient.GetTaskByName(name)
+	moves, err := h.DBClient.MoveTasks(req, claims.UserID)
 	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to move tasks"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"MOVE_TASK",
+			fmt.Sprintf("Failed to move tasks: ids=%v, to=%s", req.TaskIDs, collectionLabel(req.CollectionID.Value)), "ERROR")
 		return
 	}
 
+	for _, move := range moves {
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"MOVE_TASK",
+			fmt.Sprintf("Task moved: id=%d, from=%s, to=%s", move.TaskID, collectionLabel(move.From), collectionLabel(move.To)), "SUCCESS")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(moves)
 }
 
-// Collection handlers
-
-func (h *TaskHandlers) HandleCreateCollection(w http.ResponseWriter, r *http.Request) {
+// HandleBulkTasks выполняет пакет операций одним запросом к db-сервису:
+// POST /tasks/bulk {"operations": [{"op": "complete", "task_id": 1}, {"op": "move", "task_id": 2, "collection_id": 3}]}.
+// Отправляет событие на каждую операцию, как одиночные ручки, и одно сводное BULK_TASKS
+func (h *TaskHandlers) HandleBulkTasks(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	var req models.CreateCollectionRequest
+	var req models.BulkTasksRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, `error: Name is required`, http.StatusBadRequest)
+	if len(req.Operations) == 0 || len(req.Operations) > models.MaxBulkOperations {
+		http.Error(w, `error: operations must contain 1-100 items`, http.StatusBadRequest)
 		return
 	}
+	for _, op := range req.Operations {
+		if !models.ValidBulkOps[op.Op] {
+			http.Error(w, `error: op must be one of complete, reopen, delete, move, tag`, http.StatusBadRequest)
+			return
+		}
+		if op.TaskID <= 0 {
+			http.Error(w, `error: task_id is required for every operation`, http.StatusBadRequest)
+			return
+		}
+		if op.Op == models.BulkMove && !op.CollectionID.Set {
+			http.Error(w, `error: collection_id is required for move (null removes the task from its collection)`, http.StatusBadRequest)
+			return
+		}
+		if op.Op == models.BulkTag && len(op.AddTags) == 0 && len(op.RemoveTags) == 0 {
+			http.Error(w, `error: add_tags or remove_tags is required for tag`, http.StatusBadRequest)
+			return
+		}
+	}
 
-	collection, err := h.DBClient.CreateCollection(&req, claims.UserID)
+	req.ExistingTagsOnly = !canCreateTags(r)
+	results, err := h.DBClient.BulkTasks(&req, claims.UserID)
 	if err != nil {
-		http.Error(w, `error: Failed to create collection`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to run bulk operations"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"BULK_TASKS",
+			fmt.Sprintf("Failed to run bulk operations: total=%d", len(req.Operations)), "ERROR")
 		return
 	}
 
+	failed := 0
+	for _, res := range results {
+		if res.Status != models.BulkStatusOK {
+			failed++
+		}
+		if res.Index >= 0 && res.Index < len(req.Operations) {
+			h.sendBulkEvent(claims, req.Operations[res.Index], res)
+		}
+	}
+
 	h.EventProducer.SendEvent(
 		claims.UserID,
 		claims.Username,
-		"CREATE_COLLECTION",
-		fmt.Sprintf("Collection created: id=%d, name=%s", collection.ID, collection.Name), "SUCCESS")
+		"BULK_TASKS",
+		fmt.Sprintf("Bulk operations: total=%d, succeeded=%d, failed=%d", len(results), len(results)-failed, failed), "SUCCESS")
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(collection)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
 }
 
-func (h *TaskHandlers) HandleGetCollections(w http.ResponseWriter, r *http.Request) {
+// sendBulkEvent отправляет на операцию пакета то же событие, что и одиночная ручка
+func (h *TaskHandlers) sendBulkEvent(claims *auth.Claims, op models.BulkOperation, res models.BulkResult) {
+	send := func(action, details string) {
+		status := "SUCCESS"
+		if res.Status != models.BulkStatusOK {
+			status = "ERROR"
+		}
+		h.EventProducer.SendEvent(claims.UserID, claims.Username, action, details, status)
+	}
+	ok := res.Status == models.BulkStatusOK
+
+	switch op.Op {
+	case models.BulkComplete:
+		if !ok {
+			send("COMPLETE_TASK", fmt.Sprintf("Failed to complete task: id=%d", res.TaskID))
+			return
+		}
+		send("COMPLETE_TASK", fmt.Sprintf("Task completed: id=%d", res.TaskID))
+		if res.NextTask != nil {
+			send("RECUR_TASK", fmt.Sprintf("Task recurred: id=%d, next_id=%d, due_at=%s", res.TaskID, res.NextTask.ID, formatDueAt(res.NextTask.DueAt)))
+		}
+	case models.BulkReopen:
+		if !ok {
+			send("REOPEN_TASK", fmt.Sprintf("Failed to reopen task: id=%d", res.TaskID))
+			return
+		}
+		send("REOPEN_TASK", fmt.Sprintf("Task reopened: id=%d", res.TaskID))
+	case models.BulkDelete:
+		if !ok {
+			send("DELETE_TASK", fmt.Sprintf("Failed to delete task: id=%d", res.TaskID))
+			return
+		}
+		send("DELETE_TASK", fmt.Sprintf("Task deleted: id=%d", res.TaskID))
+	case models.BulkMove:
+		if !ok {
+			send("MOVE_TASK", fmt.Sprintf("Failed to move tasks: ids=[%d], to=%s", res.TaskID, collectionLabel(op.CollectionID.Value)))
+			return
+		}
+		// Задача уже была в этой коллекции: как и в /tasks/move, события нет
+		if res.Move != nil {
+			send("MOVE_TASK", fmt.Sprintf("Task moved: id=%d, from=%s, to=%s", res.TaskID, collectionLabel(res.Move.From), collectionLabel(res.Move.To)))
+		}
+	case models.BulkTag:
+		if !ok {
+			send("UPDATE_TASK", fmt.Sprintf("Failed to update task: id=%d", res.TaskID))
+			return
+		}
+		send("UPDATE_TASK", fmt.Sprintf("Task updated: id=%d, %s", res.TaskID, formatChanges(res.Changes)))
+	}
+}
+
+// collectionLabel ID коллекции для событий; none — задача без коллекции
+func collectionLabel(id *int) string {
+	if id == nil {
+		return "none"
+	}
+	return strconv.Itoa(*id)
+}
+
+// inCollection дописывает к событию коллекцию задачи, чтобы в общих списках было видно, где прошло изменение
+func inCollection(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return ", collection=" + strconv.Itoa(*id)
+}
+
+// canCreateTags может ли вызывающий создавать теги, назначая их задачам: с JWT может всегда,
+// с персональным токеном — только при tags:write. Без него задачам назначаются лишь существующие теги
+func canCreateTags(r *http.Request) bool {
+	scopes, ok := middleware.GetScopesFromContext(r)
+	return !ok || auth.HasScope(scopes, auth.ScopeTagsWrite)
+}
+
+// dbErrorStatus подбирает HTTP-статус по ошибке клиента db-service
+func dbErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, client.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, client.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, client.ErrForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// formatChanges собирает построчный diff для события: name: "old" -> "new"
+func formatChanges(changes []models.FieldChange) string {
+	if len(changes) == 0 {
+		return "no changes"
+	}
+
+	parts := make([]string, 0, len(changes))
+	for _, c := range changes {
+		parts = append(parts, fmt.Sprintf("%s: %s -> %s", c.Field, formatValue(c.Old), formatValue(c.New)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(val)
+	}
+	return fmt.Sprint(v)
+}
+
+func (h *TaskHandlers) HandleGetCompletedTasks(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetCompleted(claims.UserID, opts)
+	if err != nil {
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams)
+}
+
+func (h *TaskHandlers) HandleGetUncompletedTasks(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	collections, err := h.DBClient.GetCollections(claims.UserID)
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetUncompleted(claims.UserID, opts)
 	if err != nil {
-		http.Error(w, `error: Failed to get collections`, http.StatusInternalServerError)
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
 		return
 	}
 
+	writeList(w, tasks, opts.PageParams)
+}
+
+// parseListOptions читает ?sort=&order= и параметры страницы; при ошибке сам отвечает 400
+func parseListOptions(w http.ResponseWriter, r *http.Request) (models.ListOptions, bool) {
+	opts := models.ListOptions{
+		Sort:  r.URL.Query().Get("sort"),
+		Order: r.URL.Query().Get("order"),
+	}
+
+	if opts.Sort != "" && !models.ValidSortFields[opts.Sort] {
+		http.Error(w, `error: sort must be one of position, priority, due_at, name, create_time, complete_at`, http.StatusBadRequest)
+		return opts, false
+	}
+	if opts.Order != "" && opts.Order != "asc" && opts.Order != "desc" {
+		http.Error(w, `error: order must be asc or desc`, http.StatusBadRequest)
+		return opts, false
+	}
+
+	page, ok := parsePageParams(w, r)
+	if !ok {
+		return opts, false
+	}
+	if page.Paginated() && opts.Sort != "" && opts.Sort != "create_time" && opts.Sort != "position" {
+		http.Error(w, `error: pagination supports only sort=position or sort=create_time`, http.StatusBadRequest)
+		return opts, false
+	}
+	opts.PageParams = page
+
+	return opts, true
+}
+
+// parsePageParams читает ?limit=&cursor=; при ошибке сам отвечает 400.
+// Курсор проверяет db-сервис, здесь он передаётся как есть
+func parsePageParams(w http.ResponseWriter, r *http.Request) (models.PageParams, bool) {
+	page := models.PageParams{Cursor: r.URL.Query().Get("cursor")}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > models.MaxPageLimit {
+			http.Error(w, fmt.Sprintf("error: limit must be between 1 and %d", models.MaxPageLimit), http.StatusBadRequest)
+			return page, false
+		}
+		page.Limit = n
+	}
+
+	return page, true
+}
+
+// writeList отдаёт список: при пагинации конвертом {items, next_cursor}, иначе голым
+// массивом — в этом режиме совместимости работает текущий фронтенд
+func writeList[T any](w http.ResponseWriter, page *models.Page[T], params models.PageParams) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(collections)
+	if !params.Paginated() {
+		json.NewEncoder(w).Encode(page.Items)
+		return
+	}
+	json.NewEncoder(w).Encode(page)
 }
 
-func (h *TaskHandlers) HandleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+// HandleGetTasksByDue: /get?due=today|week|overdue[&tz=Europe/Moscow]
+func (h *TaskHandlers) HandleGetTasksByDue(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+	due := r.URL.Query().Get("due")
+	if due != "today" && due != "week" && due != "overdue" {
+		http.Error(w, `error: due must be one of today, week, overdue`, http.StatusBadRequest)
 		return
 	}
 
-	err = h.DBClient.DeleteCollection(id, claims.UserID)
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetTasksByDue(claims.UserID, due, r.URL.Query().Get("tz"), opts)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to delete collection"}`, http.StatusInternalServerError)
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
 		return
 	}
 
-	h.EventProducer.SendEvent(
-		claims.UserID,
-		claims.Username,
-		"DELETE_COLLECTION",
-		fmt.Sprintf("Collection deleted: id=%d", id), "SUCCESS")
+	writeList(w, tasks, opts.PageParams)
+}
 
-	w.WriteHeader(http.StatusOK)
+// HandleGetTasksByTag: /get?tag=work,home[&match=all|any]
+func (h *TaskHandlers) HandleGetTasksByTag(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	tags := strings.Split(r.URL.Query().Get("tag"), ",")
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			http.Error(w, `error: tag must be a comma-separated list of tag names`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	match := r.URL.Query().Get("match")
+	if match != "" && match != "any" && match != "all" {
+		http.Error(w, `error: match must be any or all`, http.StatusBadRequest)
+		return
+	}
+
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetTasksByTags(claims.UserID, tags, match == "all", opts)
+	if err != nil {
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams)
 }
 
-func (h *TaskHandlers) HandleGetTasksByCollection(w http.ResponseWriter, r *http.Request) {
+// HandleGetAssignedTasks: /get?assigned=me — задачи, поручённые пользователю, включая ожидающие ответа
+func (h *TaskHandlers) HandleGetAssignedTasks(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
 		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.DBClient.GetAssignedTasks(claims.UserID, opts)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams)
+}
+
+// HandleSearchTasks: /search?q=...[&collection_id=3][&complete=true|false][&limit=20]
+func (h *TaskHandlers) HandleSearchTasks(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
 		return
 	}
 
-	tasks, err := h.DBClient.GetTasksByCollection(id, claims.UserID)
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, `error: q is required`, http.StatusBadRequest)
+		return
+	}
+
+	var opts models.SearchOptions
+
+	if v := r.URL.Query().Get("collection_id"); v != "" {
+		collectionID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, `error: Invalid collection_id`, http.StatusBadRequest)
+			return
+		}
+		opts.CollectionID = &collectionID
+	}
+
+	if v := r.URL.Query().Get("complete"); v != "" {
+		complete, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, `error: complete must be true or false`, http.StatusBadRequest)
+			return
+		}
+		opts.Complete = &complete
+	}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxSearchLimit {
+			http.Error(w, fmt.Sprintf("error: limit must be between 1 and %d", models.MaxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		opts.Limit = limit
+	}
+
+	results, err := h.DBClient.SearchTasks(claims.UserID, q, opts)
 	if err != nil {
-		http.Error(w, `error: Failed to get tasks`, http.StatusInternalServerError)
+		http.Error(w, `error: Failed to search tasks`, dbErrorStatus(err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	json.NewEncoder(w).Encode(results)
+}
+
+func (h *TaskHandlers) HandleGetTasksByID(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `error: Invalid task ID`, http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.DBClient.GetTaskByID(id, claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to get task`, dbErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandlers) HandleGetTasksByName(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+
+	task, err := h.DBClient.GetTaskByName(name, claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to get task`, dbErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// Assignment handlers
+
+// HandleAssignTask поручает задачу пользователю по имени: POST /tasks/{id}/assign {"username": "bob"}.
+// Поручение ждёт ответа исполнителя; повторный вызов переназначает задачу
+func (h *TaskHandlers) HandleAssignTask(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		http.Error(w, `error: username is required`, http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.DBClient.AssignTask(id, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to assign task"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"ASSIGN_TASK",
+			fmt.Sprintf("Failed to assign task: id=%d, assignee=%s", id, req.Username), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"ASSIGN_TASK",
+		fmt.Sprintf("Task assigned: id=%d, assignee=%s%s", task.ID, req.Username, inCollection(task.CollectionID)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// HandleReorderTask ставит задачу перед или после другой задачи того же списка: POST /tasks/{id}/reorder
+func (h *TaskHandlers) HandleReorderTask(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	req, ok := decodeReorder(w, r, id)
+	if !ok {
+		return
+	}
+
+	task, err := h.DBClient.ReorderTask(id, claims.UserID, req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reorder task"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"REORDER_TASK",
+			fmt.Sprintf("Failed to reorder task: id=%d, %s", id, reorderLabel(req)), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REORDER_TASK",
+		fmt.Sprintf("Task reordered: id=%d, %s%s", task.ID, reorderLabel(req), inCollection(task.CollectionID)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// decodeReorder читает тело reorder-запроса и проверяет, что задан ровно один сосед и это не сам элемент id;
+// при ошибке сам отвечает 400
+func decodeReorder(w http.ResponseWriter, r *http.Request, id int) (*models.ReorderRequest, bool) {
+	var req models.ReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return nil, false
+	}
+
+	if (req.Before == nil) == (req.After == nil) {
+		http.Error(w, `error: Exactly one of before or after is required`, http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Before != nil && *req.Before == id || req.After != nil && *req.After == id {
+		http.Error(w, `error: Cannot place an item next to itself`, http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// reorderLabel сосед из reorder-запроса для событий: "before=N" или "after=N"
+func reorderLabel(req *models.ReorderRequest) string {
+	if req.Before != nil {
+		return "before=" + strconv.Itoa(*req.Before)
+	}
+	return "after=" + strconv.Itoa(*req.After)
+}
+
+// HandleAcceptTask исполнитель принимает поручение: POST /tasks/{id}/accept
+func (h *TaskHandlers) HandleAcceptTask(w http.ResponseWriter, r *http.Request) {
+	h.respondToAssignment(w, r, true)
+}
+
+// HandleDeclineTask исполнитель отклоняет поручение: POST /tasks/{id}/decline
+func (h *TaskHandlers) HandleDeclineTask(w http.ResponseWriter, r *http.Request) {
+	h.respondToAssignment(w, r, false)
+}
+
+// respondToAssignment общая часть ответа на поручение: ACCEPT_TASK или DECLINE_TASK
+func (h *TaskHandlers) respondToAssignment(w http.ResponseWriter, r *http.Request, accept bool) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	action, verb, respond := "ACCEPT_TASK", "accepted", h.DBClient.AcceptTask
+	if !accept {
+		action, verb, respond = "DECLINE_TASK", "declined", h.DBClient.DeclineTask
+	}
+
+	task, err := respond(id, claims.UserID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to respond to assignment"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		action,
+		fmt.Sprintf("Task %s: id=%d%s", verb, task.ID, inCollection(task.CollectionID)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// Collection handlers
+
+func (h *TaskHandlers) HandleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		http.Error(w, `error: Name is required`, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.DBClient.CreateCollection(&req, claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to create collection`, dbErrorStatus(err))
+		return
+	}
+
+	details := fmt.Sprintf("Collection created: id=%d, name=%s", collection.ID, collection.Name)
+	if collection.ParentID != nil {
+		details += fmt.Sprintf(", parent=%d", *collection.ParentID)
+	}
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"CREATE_COLLECTION",
+		details, "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collection)
+}
+
+func (h *TaskHandlers) HandleGetCollections(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	page, ok := parsePageParams(w, r)
+	if !ok {
+		return
+	}
+
+	collections, err := h.DBClient.GetCollections(claims.UserID, page)
+	if err != nil {
+		http.Error(w, `error: Failed to get collections`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, collections, page)
+}
+
+func (h *TaskHandlers) HandleUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == nil && req.Color == nil && req.Icon == nil {
+		http.Error(w, `error: No fields to update`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil && !models.ValidLength(strings.TrimSpace(*req.Name), models.MaxCollectionNameLength) {
+		http.Error(w, `error: name must be 1-100 characters`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Color != nil && !models.IsValidColor(*req.Color) {
+		http.Error(w, `error: color must be a hex value like #2564cf`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Icon != nil && !models.ValidLength(*req.Icon, models.MaxCollectionIconLength) {
+		http.Error(w, `error: icon must be 1-50 characters`, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.DBClient.UpdateCollection(id, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update collection"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"UPDATE_COLLECTION",
+		fmt.Sprintf("Collection updated: id=%d, name=%s, color=%s, icon=%s", collection.ID, collection.Name, collection.Color, collection.Icon), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+func (h *TaskHandlers) HandleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.DBClient.DeleteCollection(id, claims.UserID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete collection"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"DELETE_COLLECTION",
+		fmt.Sprintf("Collection deleted: id=%d", id), "SUCCESS")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleMoveCollection вкладывает коллекцию в другую: POST /collections/{id}/move {"parent_id": 3}.
+// Переносить можно только свои коллекции и только в свои; null поднимает коллекцию на верхний уровень
+func (h *TaskHandlers) HandleMoveCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.MoveCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if !req.ParentID.Set {
+		http.Error(w, `error: parent_id is required`, http.StatusBadRequest)
+		return
+	}
+
+	if req.ParentID.Value != nil && *req.ParentID.Value == id {
+		http.Error(w, `error: Cannot move a collection into itself`, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.DBClient.MoveCollection(id, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to move collection"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"MOVE_COLLECTION",
+			fmt.Sprintf("Failed to move collection: id=%d, parent=%s", id, collectionLabel(req.ParentID.Value)), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"MOVE_COLLECTION",
+		fmt.Sprintf("Collection moved: id=%d, parent=%s", collection.ID, collectionLabel(collection.ParentID)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// HandleReorderCollection ставит коллекцию перед или после соседней: POST /collections/{id}/reorder
+func (h *TaskHandlers) HandleReorderCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	req, ok := decodeReorder(w, r, id)
+	if !ok {
+		return
+	}
+
+	collection, err := h.DBClient.ReorderCollection(id, claims.UserID, req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reorder collection"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"REORDER_COLLECTION",
+			fmt.Sprintf("Failed to reorder collection: id=%d, %s", id, reorderLabel(req)), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REORDER_COLLECTION",
+		fmt.Sprintf("Collection reordered: id=%d, %s", collection.ID, reorderLabel(req)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+func (h *TaskHandlers) HandleGetTasksByCollection(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts, ok := parseListOptions(w, r)
+	if !ok {
+		return
+	}
+
+	recursive := false
+	if v := r.URL.Query().Get("recursive"); v != "" {
+		if recursive, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, `error: recursive must be true or false`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tasks, err := h.DBClient.GetTasksByCollection(id, claims.UserID, recursive, opts)
+	if err != nil {
+		http.Error(w, `error: Failed to get tasks`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams)
+}
+
+// Member handlers
+
+func (h *TaskHandlers) HandleGetMembers(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.DBClient.GetMembers(id, claims.UserID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get members"}`, dbErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+// HandleAddMember приглашает пользователя в коллекцию по имени. Приглашать может только владелец
+func (h *TaskHandlers) HandleAddMember(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		http.Error(w, `error: username is required`, http.StatusBadRequest)
+		return
+	}
+
+	if !models.IsValidInviteRole(req.Role) {
+		http.Error(w, `error: role must be editor or viewer`, http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.DBClient.AddMember(id, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to add member"}`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"ADD_MEMBER",
+			fmt.Sprintf("Failed to add member: collection=%d, username=%s", id, req.Username), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"ADD_MEMBER",
+		fmt.Sprintf("Member added: collection=%d, user_id=%d, username=%s, role=%s", id, member.UserID, member.Username, member.Role), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *TaskHandlers) HandleUpdateMember(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid member ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if !models.IsValidInviteRole(req.Role) {
+		http.Error(w, `error: role must be editor or viewer`, http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.DBClient.UpdateMember(id, memberID, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update member"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"UPDATE_MEMBER",
+		fmt.Sprintf("Member role changed: collection=%d, user_id=%d, username=%s, role=%s", id, member.UserID, member.Username, member.Role), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(member)
+}
+
+// HandleRemoveMember исключает участника; участник может выйти сам, указав свой user id
+func (h *TaskHandlers) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid member ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DBClient.RemoveMember(id, memberID, claims.UserID); err != nil {
+		http.Error(w, `{"error": "Failed to remove member"}`, dbErrorStatus(err))
+		return
+	}
+
+	details := fmt.Sprintf("Member removed: collection=%d, user_id=%d", id, memberID)
+	if memberID == claims.UserID {
+		details = fmt.Sprintf("Member left: collection=%d, user_id=%d", id, memberID)
+	}
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REMOVE_MEMBER",
+		details, "SUCCESS")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Tag handlers
+
+func (h *TaskHandlers) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, `error: Name is required`, http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.DBClient.CreateTag(&req, claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to create tag`, dbErrorStatus(err))
+		h.EventProducer.SendEvent(
+			claims.UserID,
+			claims.Username,
+			"CREATE_TAG",
+			fmt.Sprintf("Failed to create tag: name=%s", req.Name), "ERROR")
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"CREATE_TAG",
+		fmt.Sprintf("Tag created: id=%d, name=%s", tag.ID, tag.Name), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *TaskHandlers) HandleGetTags(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	page, ok := parsePageParams(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := h.DBClient.GetTags(claims.UserID, page)
+	if err != nil {
+		http.Error(w, `error: Failed to get tags`, dbErrorStatus(err))
+		return
+	}
+
+	writeList(w, tags, page)
+}
+
+func (h *TaskHandlers) HandleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == nil && req.Color == nil {
+		http.Error(w, `error: No fields to update`, http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.DBClient.UpdateTag(id, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update tag"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"UPDATE_TAG",
+		fmt.Sprintf("Tag updated: id=%d, name=%s, color=%s", tag.ID, tag.Name, tag.Color), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *TaskHandlers) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DBClient.DeleteTag(id, claims.UserID); err != nil {
+		http.Error(w, `{"error": "Failed to delete tag"}`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"DELETE_TAG",
+		fmt.Sprintf("Tag deleted: id=%d", id), "SUCCESS")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Checklist handlers
+
+// checklistIDs читает {id} задачи и, если есть, {itemId} пункта из пути
+func checklistIDs(r *http.Request) (taskID, itemID int, err error) {
+	vars := mux.Vars(r)
+	taskID, err = strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, err
+	}
+	if itemStr, ok := vars["itemId"]; ok {
+		itemID, err = strconv.Atoi(itemStr)
+	}
+	return taskID, itemID, err
+}
+
+func (h *TaskHandlers) HandleGetChecklist(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	taskID, _, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.DBClient.GetChecklist(taskID, claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to get checklist`, dbErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+func (h *TaskHandlers) HandleAddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	taskID, _, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AddChecklistItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, `error: Text is required`, http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.DBClient.AddChecklistItem(taskID, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `error: Failed to add checklist item`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"ADD_CHECKLIST_ITEM",
+		fmt.Sprintf("Checklist item added: task_id=%d, item_id=%d", taskID, item.ID), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+func (h *TaskHandlers) HandleUpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	taskID, itemID, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task or item ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateChecklistItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Text == nil && req.Done == nil {
+		http.Error(w, `error: No fields to update`, http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.DBClient.UpdateChecklistItem(taskID, itemID, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `error: Failed to update checklist item`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"UPDATE_CHECKLIST_ITEM",
+		fmt.Sprintf("Checklist item updated: task_id=%d, item_id=%d, done=%t", taskID, item.ID, item.Done), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(item)
+}
+
+func (h *TaskHandlers) HandleDeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	taskID, itemID, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task or item ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DBClient.DeleteChecklistItem(taskID, itemID, claims.UserID); err != nil {
+		http.Error(w, `error: Failed to delete checklist item`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"DELETE_CHECKLIST_ITEM",
+		fmt.Sprintf("Checklist item deleted: task_id=%d, item_id=%d", taskID, itemID), "SUCCESS")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TaskHandlers) HandleReorderChecklist(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	taskID, _, err := checklistIDs(r)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.ReorderChecklistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `error: Invalid JSON`, http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.DBClient.ReorderChecklist(taskID, claims.UserID, &req)
+	if err != nil {
+		http.Error(w, `error: Failed to reorder checklist`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"REORDER_CHECKLIST",
+		fmt.Sprintf("Checklist reordered: task_id=%d, items=%d", taskID, len(items)), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+// Trash handlers
+
+// trashItemType читает ?type= элемента корзины: task (по умолчанию) или collection
+func trashItemType(r *http.Request) (string, bool) {
+	switch t := r.URL.Query().Get("type"); t {
+	case "", models.TrashTask:
+		return models.TrashTask, true
+	case models.TrashCollection:
+		return t, true
+	}
+	return "", false
+}
+
+func (h *TaskHandlers) HandleGetTrash(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	trash, err := h.DBClient.GetTrash(claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to get trash`, dbErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trash)
+}
+
+// HandleRestoreFromTrash: POST /trash/{id}/restore[?type=collection]
+func (h *TaskHandlers) HandleRestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	itemType, ok := trashItemType(r)
+	if !ok {
+		http.Error(w, `error: type must be task or collection`, http.StatusBadRequest)
+		return
+	}
+
+	var restored interface{}
+	action := "RESTORE_TASK"
+	details := fmt.Sprintf("Task restored from trash: id=%d", id)
+	if itemType == models.TrashCollection {
+		action = "RESTORE_COLLECTION"
+		details = fmt.Sprintf("Collection restored from trash: id=%d", id)
+		restored, err = h.DBClient.RestoreCollection(id, claims.UserID)
+	} else {
+		restored, err = h.DBClient.RestoreTask(id, claims.UserID)
+	}
+	if err != nil {
+		http.Error(w, `error: Failed to restore item`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(claims.UserID, claims.Username, action, details, "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(restored)
+}
+
+// HandlePurgeFromTrash: DELETE /trash/{id}[?type=collection] — окончательное удаление
+func (h *TaskHandlers) HandlePurgeFromTrash(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	itemType, ok := trashItemType(r)
+	if !ok {
+		http.Error(w, `error: type must be task or collection`, http.StatusBadRequest)
+		return
+	}
+
+	action := "PURGE_TASK"
+	details := fmt.Sprintf("Task permanently deleted: id=%d", id)
+	if itemType == models.TrashCollection {
+		action = "PURGE_COLLECTION"
+		details = fmt.Sprintf("Collection permanently deleted: id=%d", id)
+		err = h.DBClient.PurgeCollection(id, claims.UserID)
+	} else {
+		err = h.DBClient.PurgeTask(id, claims.UserID)
+	}
+	if err != nil {
+		http.Error(w, `error: Failed to purge item`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(claims.UserID, claims.Username, action, details, "SUCCESS")
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TaskHandlers) HandleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, `error: Unauthorized`, http.StatusUnauthorized)
+		return
+	}
+
+	purged, err := h.DBClient.EmptyTrash(claims.UserID)
+	if err != nil {
+		http.Error(w, `error: Failed to empty trash`, dbErrorStatus(err))
+		return
+	}
+
+	h.EventProducer.SendEvent(
+		claims.UserID,
+		claims.Username,
+		"EMPTY_TRASH",
+		fmt.Sprintf("Trash emptied: tasks=%d, collections=%d", purged.Tasks, purged.Collections), "SUCCESS")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(purged)
 }
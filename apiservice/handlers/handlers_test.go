@@ -2,15 +2,19 @@ package handlers
 
 import (
 	"apiservice/auth"
+	"apiservice/client"
 	"apiservice/middleware"
 	"apiservice/models"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -43,17 +47,85 @@ func TestNewTaskHandlers(t *testing.T) {
 // MockDBClient для тестирования handlers
 type MockDBClient struct {
 	CreateTaskFunc           func(*models.CreateTaskRequest, int) (*models.Task, error)
-	GetAllTasksFunc          func(int) ([]models.Task, error)
+	GetAllTasksFunc          func(int, models.ListOptions) ([]models.Task, error)
 	DeleteTaskFunc           func(int, int) error
-	CompleteTaskFunc         func(int, int) error
-	GetCompletedFunc         func(int) ([]models.Task, error)
-	GetUncompletedFunc       func(int) ([]models.Task, error)
-	GetTaskByIDFunc          func(int) (*models.Task, error)
-	GetTaskByNameFunc        func(string) (*models.Task, error)
+	CompleteTaskFunc         func(int, int) (*models.Task, error)
+	ReopenTaskFunc           func(int, int) error
+	UpdateTaskFunc           func(int, int, *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error)
+	GetCompletedFunc         func(int, models.ListOptions) ([]models.Task, error)
+	GetUncompletedFunc       func(int, models.ListOptions) ([]models.Task, error)
+	GetTasksByDueFunc        func(int, string, string, models.ListOptions) ([]models.Task, error)
+	GetTaskByIDFunc          func(int, int) (*models.Task, error)
+	GetTaskByNameFunc        func(string, int) (*models.Task, error)
 	CreateCollectionFunc     func(*models.CreateCollectionRequest, int) (*models.Collection, error)
 	GetCollectionsFunc       func(int) ([]models.Collection, error)
+	MoveTasksFunc            func(*models.MoveTasksRequest, int) ([]models.TaskMove, error)
+	BulkTasksFunc            func(*models.BulkTasksRequest, int) ([]models.BulkResult, error)
+	UpdateCollectionFunc     func(int, int, *models.UpdateCollectionRequest) (*models.Collection, error)
 	DeleteCollectionFunc     func(int, int) error
-	GetTasksByCollectionFunc func(int, int) ([]models.Task, error)
+	GetMembersFunc           func(int, int) ([]models.Member, error)
+	AddMemberFunc            func(int, int, *models.AddMemberRequest) (*models.Member, error)
+	UpdateMemberFunc         func(int, int, int, *models.UpdateMemberRequest) (*models.Member, error)
+	RemoveMemberFunc         func(int, int, int) error
+	GetTasksByCollectionFunc func(int, int, bool, models.ListOptions) ([]models.Task, error)
+	MoveCollectionFunc       func(int, int, *models.MoveCollectionRequest) (*models.Collection, error)
+	ReorderCollectionFunc    func(int, int, *models.ReorderRequest) (*models.Collection, error)
+	GetTasksByTagsFunc       func(int, []string, bool, models.ListOptions) ([]models.Task, error)
+	GetAssignedTasksFunc     func(int, models.ListOptions) ([]models.Task, error)
+	AssignTaskFunc           func(int, int, *models.AssignTaskRequest) (*models.Task, error)
+	AcceptTaskFunc           func(int, int) (*models.Task, error)
+	DeclineTaskFunc          func(int, int) (*models.Task, error)
+	ReorderTaskFunc          func(int, int, *models.ReorderRequest) (*models.Task, error)
+	SearchTasksFunc          func(int, string, models.SearchOptions) ([]models.SearchResult, error)
+	GetTrashFunc             func(int) (*models.Trash, error)
+	RestoreTaskFunc          func(int, int) (*models.Task, error)
+	RestoreCollectionFunc    func(int, int) (*models.Collection, error)
+	PurgeTaskFunc            func(int, int) error
+	PurgeCollectionFunc      func(int, int) error
+	EmptyTrashFunc           func(int) (*models.PurgeResult, error)
+	CreateTagFunc            func(*models.CreateTagRequest, int) (*models.Tag, error)
+	GetTagsFunc              func(int) ([]models.Tag, error)
+	UpdateTagFunc            func(int, int, *models.UpdateTagRequest) (*models.Tag, error)
+	DeleteTagFunc            func(int, int) error
+	GetChecklistFunc         func(int, int) ([]models.ChecklistItem, error)
+	AddChecklistItemFunc     func(int, int, *models.AddChecklistItemRequest) (*models.ChecklistItem, error)
+	UpdateChecklistItemFunc  func(int, int, int, *models.UpdateChecklistItemRequest) (*models.ChecklistItem, error)
+	DeleteChecklistItemFunc  func(int, int, int) error
+	ReorderChecklistFunc     func(int, int, *models.ReorderChecklistRequest) ([]models.ChecklistItem, error)
+	CreateUserFunc           func(*models.CreateUserRequest) (*models.User, error)
+	GetUserByUsernameFunc    func(string) (*models.User, error)
+	CreateRefreshTokenFunc   func(int, *models.CreateRefreshTokenRequest) error
+	RotateRefreshTokenFunc   func(*models.RotateRefreshTokenRequest) (*models.User, error)
+	RevokeTokenFunc          func(int, *models.RevokeTokenRequest) error
+	RevokeAllTokensFunc      func(int) (time.Time, error)
+	ChangePasswordFunc       func(int, *models.UpdatePasswordRequest) (time.Time, error)
+	DeleteUserFunc           func(int) error
+	UpdateEmailFunc          func(int, string) error
+	CreatePasswordResetFunc  func(*models.CreatePasswordResetRequest) (*models.User, error)
+	ConsumePasswordResetFunc func(*models.ConsumePasswordResetRequest) (*models.PasswordReset, error)
+	GetTOTPFunc              func(int) (*models.TOTP, error)
+	SetupTOTPFunc            func(int, string) error
+	EnableTOTPFunc           func(int, *models.EnableTOTPRequest) error
+	UseTOTPStepFunc          func(int, int64) error
+	RecordTOTPFailureFunc    func(int) error
+	UseRecoveryCodeFunc      func(int, string) (int, error)
+	DisableTOTPFunc          func(int) error
+
+	CreateAccessTokenFunc       func(int, *models.StoreAccessTokenRequest) (*models.AccessToken, error)
+	ListAccessTokensFunc        func(int) ([]models.AccessToken, error)
+	DeleteAccessTokenFunc       func(int, int) error
+	AuthenticateAccessTokenFunc func(string) (*models.AccessTokenOwner, error)
+
+	// NextCursor подставляется в страницы, которые собирают списочные методы мока
+	NextCursor string
+}
+
+// mockPage заворачивает результат XxxFunc в страницу с NextCursor мока
+func mockPage[T any](m *MockDBClient, items []T, err error) (*models.Page[T], error) {
+	if err != nil {
+		return nil, err
+	}
+	return &models.Page[T]{Items: items, NextCursor: m.NextCursor}, nil
 }
 
 func (m *MockDBClient) CreateTask(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
@@ -63,9 +135,10 @@ func (m *MockDBClient) CreateTask(req *models.CreateTaskRequest, userID int) (*m
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetAllTasks(userID int) ([]models.Task, error) {
+func (m *MockDBClient) GetAllTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
 	if m.GetAllTasksFunc != nil {
-		return m.GetAllTasksFunc(userID)
+		items, err := m.GetAllTasksFunc(userID, opts)
+		return mockPage(m, items, err)
 	}
 	return nil, errors.New("not implemented")
 }
@@ -77,37 +150,61 @@ func (m *MockDBClient) DeleteTask(taskID, userID int) error {
 	return errors.New("not implemented")
 }
 
-func (m *MockDBClient) CompleteTask(taskID, userID int) error {
+func (m *MockDBClient) CompleteTask(taskID, userID int) (*models.Task, error) {
 	if m.CompleteTaskFunc != nil {
 		return m.CompleteTaskFunc(taskID, userID)
 	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ReopenTask(taskID, userID int) error {
+	if m.ReopenTaskFunc != nil {
+		return m.ReopenTaskFunc(taskID, userID)
+	}
 	return errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetCompleted(userID int) ([]models.Task, error) {
+func (m *MockDBClient) UpdateTask(taskID, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+	if m.UpdateTaskFunc != nil {
+		return m.UpdateTaskFunc(taskID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetCompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
 	if m.GetCompletedFunc != nil {
-		return m.GetCompletedFunc(userID)
+		items, err := m.GetCompletedFunc(userID, opts)
+		return mockPage(m, items, err)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetUncompleted(userID int) ([]models.Task, error) {
+func (m *MockDBClient) GetUncompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
 	if m.GetUncompletedFunc != nil {
-		return m.GetUncompletedFunc(userID)
+		items, err := m.GetUncompletedFunc(userID, opts)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetTasksByDue(userID int, due, tz string, opts models.ListOptions) (*models.Page[models.Task], error) {
+	if m.GetTasksByDueFunc != nil {
+		items, err := m.GetTasksByDueFunc(userID, due, tz, opts)
+		return mockPage(m, items, err)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetTaskByID(id int) (*models.Task, error) {
+func (m *MockDBClient) GetTaskByID(id, userID int) (*models.Task, error) {
 	if m.GetTaskByIDFunc != nil {
-		return m.GetTaskByIDFunc(id)
+		return m.GetTaskByIDFunc(id, userID)
 	}
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetTaskByName(name string) (*models.Task, error) {
+func (m *MockDBClient) GetTaskByName(name string, userID int) (*models.Task, error) {
 	if m.GetTaskByNameFunc != nil {
-		return m.GetTaskByNameFunc(name)
+		return m.GetTaskByNameFunc(name, userID)
 	}
 	return nil, errors.New("not implemented")
 }
@@ -119,13 +216,63 @@ func (m *MockDBClient) CreateCollection(req *models.CreateCollectionRequest, use
 	return nil, errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetCollections(userID int) ([]models.Collection, error) {
+func (m *MockDBClient) GetCollections(userID int, page models.PageParams) (*models.Page[models.Collection], error) {
 	if m.GetCollectionsFunc != nil {
-		return m.GetCollectionsFunc(userID)
+		items, err := m.GetCollectionsFunc(userID)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) MoveTasks(req *models.MoveTasksRequest, userID int) ([]models.TaskMove, error) {
+	if m.MoveTasksFunc != nil {
+		return m.MoveTasksFunc(req, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) BulkTasks(req *models.BulkTasksRequest, userID int) ([]models.BulkResult, error) {
+	if m.BulkTasksFunc != nil {
+		return m.BulkTasksFunc(req, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) UpdateCollection(id, userID int, req *models.UpdateCollectionRequest) (*models.Collection, error) {
+	if m.UpdateCollectionFunc != nil {
+		return m.UpdateCollectionFunc(id, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetMembers(collectionID, userID int) ([]models.Member, error) {
+	if m.GetMembersFunc != nil {
+		return m.GetMembersFunc(collectionID, userID)
 	}
 	return nil, errors.New("not implemented")
 }
 
+func (m *MockDBClient) AddMember(collectionID, userID int, req *models.AddMemberRequest) (*models.Member, error) {
+	if m.AddMemberFunc != nil {
+		return m.AddMemberFunc(collectionID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) UpdateMember(collectionID, memberID, userID int, req *models.UpdateMemberRequest) (*models.Member, error) {
+	if m.UpdateMemberFunc != nil {
+		return m.UpdateMemberFunc(collectionID, memberID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) RemoveMember(collectionID, memberID, userID int) error {
+	if m.RemoveMemberFunc != nil {
+		return m.RemoveMemberFunc(collectionID, memberID, userID)
+	}
+	return errors.New("not implemented")
+}
+
 func (m *MockDBClient) DeleteCollection(collectionID, userID int) error {
 	if m.DeleteCollectionFunc != nil {
 		return m.DeleteCollectionFunc(collectionID, userID)
@@ -133,9 +280,336 @@ func (m *MockDBClient) DeleteCollection(collectionID, userID int) error {
 	return errors.New("not implemented")
 }
 
-func (m *MockDBClient) GetTasksByCollection(collectionID, userID int) ([]models.Task, error) {
+func (m *MockDBClient) GetTasksByCollection(collectionID, userID int, recursive bool, opts models.ListOptions) (*models.Page[models.Task], error) {
 	if m.GetTasksByCollectionFunc != nil {
-		return m.GetTasksByCollectionFunc(collectionID, userID)
+		items, err := m.GetTasksByCollectionFunc(collectionID, userID, recursive, opts)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetTrash(userID int) (*models.Trash, error) {
+	if m.GetTrashFunc != nil {
+		return m.GetTrashFunc(userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) RestoreTask(id, userID int) (*models.Task, error) {
+	if m.RestoreTaskFunc != nil {
+		return m.RestoreTaskFunc(id, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) RestoreCollection(id, userID int) (*models.Collection, error) {
+	if m.RestoreCollectionFunc != nil {
+		return m.RestoreCollectionFunc(id, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) PurgeTask(id, userID int) error {
+	if m.PurgeTaskFunc != nil {
+		return m.PurgeTaskFunc(id, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) PurgeCollection(id, userID int) error {
+	if m.PurgeCollectionFunc != nil {
+		return m.PurgeCollectionFunc(id, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) EmptyTrash(userID int) (*models.PurgeResult, error) {
+	if m.EmptyTrashFunc != nil {
+		return m.EmptyTrashFunc(userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) SearchTasks(userID int, query string, opts models.SearchOptions) ([]models.SearchResult, error) {
+	if m.SearchTasksFunc != nil {
+		return m.SearchTasksFunc(userID, query, opts)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetTasksByTags(userID int, tags []string, matchAll bool, opts models.ListOptions) (*models.Page[models.Task], error) {
+	if m.GetTasksByTagsFunc != nil {
+		items, err := m.GetTasksByTagsFunc(userID, tags, matchAll, opts)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetAssignedTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error) {
+	if m.GetAssignedTasksFunc != nil {
+		items, err := m.GetAssignedTasksFunc(userID, opts)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) AssignTask(taskID, userID int, req *models.AssignTaskRequest) (*models.Task, error) {
+	if m.AssignTaskFunc != nil {
+		return m.AssignTaskFunc(taskID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) AcceptTask(taskID, userID int) (*models.Task, error) {
+	if m.AcceptTaskFunc != nil {
+		return m.AcceptTaskFunc(taskID, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DeclineTask(taskID, userID int) (*models.Task, error) {
+	if m.DeclineTaskFunc != nil {
+		return m.DeclineTaskFunc(taskID, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) MoveCollection(id, userID int, req *models.MoveCollectionRequest) (*models.Collection, error) {
+	if m.MoveCollectionFunc != nil {
+		return m.MoveCollectionFunc(id, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ReorderCollection(id, userID int, req *models.ReorderRequest) (*models.Collection, error) {
+	if m.ReorderCollectionFunc != nil {
+		return m.ReorderCollectionFunc(id, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ReorderTask(taskID, userID int, req *models.ReorderRequest) (*models.Task, error) {
+	if m.ReorderTaskFunc != nil {
+		return m.ReorderTaskFunc(taskID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) CreateTag(req *models.CreateTagRequest, userID int) (*models.Tag, error) {
+	if m.CreateTagFunc != nil {
+		return m.CreateTagFunc(req, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetTags(userID int, page models.PageParams) (*models.Page[models.Tag], error) {
+	if m.GetTagsFunc != nil {
+		items, err := m.GetTagsFunc(userID)
+		return mockPage(m, items, err)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) UpdateTag(id, userID int, req *models.UpdateTagRequest) (*models.Tag, error) {
+	if m.UpdateTagFunc != nil {
+		return m.UpdateTagFunc(id, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DeleteTag(id, userID int) error {
+	if m.DeleteTagFunc != nil {
+		return m.DeleteTagFunc(id, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetChecklist(taskID, userID int) ([]models.ChecklistItem, error) {
+	if m.GetChecklistFunc != nil {
+		return m.GetChecklistFunc(taskID, userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) AddChecklistItem(taskID, userID int, req *models.AddChecklistItemRequest) (*models.ChecklistItem, error) {
+	if m.AddChecklistItemFunc != nil {
+		return m.AddChecklistItemFunc(taskID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) UpdateChecklistItem(taskID, itemID, userID int, req *models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+	if m.UpdateChecklistItemFunc != nil {
+		return m.UpdateChecklistItemFunc(taskID, itemID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DeleteChecklistItem(taskID, itemID, userID int) error {
+	if m.DeleteChecklistItemFunc != nil {
+		return m.DeleteChecklistItemFunc(taskID, itemID, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) ReorderChecklist(taskID, userID int, req *models.ReorderChecklistRequest) ([]models.ChecklistItem, error) {
+	if m.ReorderChecklistFunc != nil {
+		return m.ReorderChecklistFunc(taskID, userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) CreateUser(req *models.CreateUserRequest) (*models.User, error) {
+	if m.CreateUserFunc != nil {
+		return m.CreateUserFunc(req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) GetUserByUsername(username string) (*models.User, error) {
+	if m.GetUserByUsernameFunc != nil {
+		return m.GetUserByUsernameFunc(username)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) CreateRefreshToken(userID int, req *models.CreateRefreshTokenRequest) error {
+	if m.CreateRefreshTokenFunc != nil {
+		return m.CreateRefreshTokenFunc(userID, req)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) RotateRefreshToken(req *models.RotateRefreshTokenRequest) (*models.User, error) {
+	if m.RotateRefreshTokenFunc != nil {
+		return m.RotateRefreshTokenFunc(req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) RevokeToken(userID int, req *models.RevokeTokenRequest) error {
+	if m.RevokeTokenFunc != nil {
+		return m.RevokeTokenFunc(userID, req)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) RevokeAllTokens(userID int) (time.Time, error) {
+	if m.RevokeAllTokensFunc != nil {
+		return m.RevokeAllTokensFunc(userID)
+	}
+	return time.Time{}, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ChangePassword(userID int, req *models.UpdatePasswordRequest) (time.Time, error) {
+	if m.ChangePasswordFunc != nil {
+		return m.ChangePasswordFunc(userID, req)
+	}
+	return time.Time{}, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DeleteUser(userID int) error {
+	if m.DeleteUserFunc != nil {
+		return m.DeleteUserFunc(userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) UpdateEmail(userID int, email string) error {
+	if m.UpdateEmailFunc != nil {
+		return m.UpdateEmailFunc(userID, email)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) CreatePasswordReset(req *models.CreatePasswordResetRequest) (*models.User, error) {
+	if m.CreatePasswordResetFunc != nil {
+		return m.CreatePasswordResetFunc(req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ConsumePasswordReset(req *models.ConsumePasswordResetRequest) (*models.PasswordReset, error) {
+	if m.ConsumePasswordResetFunc != nil {
+		return m.ConsumePasswordResetFunc(req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+// GetTOTP без GetTOTPFunc отвечает как для пользователя без двухфакторной аутентификации
+func (m *MockDBClient) GetTOTP(userID int) (*models.TOTP, error) {
+	if m.GetTOTPFunc != nil {
+		return m.GetTOTPFunc(userID)
+	}
+	return nil, client.ErrNotFound
+}
+
+func (m *MockDBClient) SetupTOTP(userID int, secret string) error {
+	if m.SetupTOTPFunc != nil {
+		return m.SetupTOTPFunc(userID, secret)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) EnableTOTP(userID int, req *models.EnableTOTPRequest) error {
+	if m.EnableTOTPFunc != nil {
+		return m.EnableTOTPFunc(userID, req)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) UseTOTPStep(userID int, step int64) error {
+	if m.UseTOTPStepFunc != nil {
+		return m.UseTOTPStepFunc(userID, step)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) RecordTOTPFailure(userID int) error {
+	if m.RecordTOTPFailureFunc != nil {
+		return m.RecordTOTPFailureFunc(userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) UseRecoveryCode(userID int, codeHash string) (int, error) {
+	if m.UseRecoveryCodeFunc != nil {
+		return m.UseRecoveryCodeFunc(userID, codeHash)
+	}
+	return 0, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DisableTOTP(userID int) error {
+	if m.DisableTOTPFunc != nil {
+		return m.DisableTOTPFunc(userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) CreateAccessToken(userID int, req *models.StoreAccessTokenRequest) (*models.AccessToken, error) {
+	if m.CreateAccessTokenFunc != nil {
+		return m.CreateAccessTokenFunc(userID, req)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) ListAccessTokens(userID int) ([]models.AccessToken, error) {
+	if m.ListAccessTokensFunc != nil {
+		return m.ListAccessTokensFunc(userID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockDBClient) DeleteAccessToken(id, userID int) error {
+	if m.DeleteAccessTokenFunc != nil {
+		return m.DeleteAccessTokenFunc(id, userID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockDBClient) AuthenticateAccessToken(tokenHash string) (*models.AccessTokenOwner, error) {
+	if m.AuthenticateAccessTokenFunc != nil {
+		return m.AuthenticateAccessTokenFunc(tokenHash)
 	}
 	return nil, errors.New("not implemented")
 }
@@ -443,6 +917,7 @@ func TestHandleGetTasksByIDInvalidID(t *testing.T) {
 
 	req := httptest.NewRequest("GET", "/task/invalid", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "invalid"})
+	req = addAuthContext(req, 1, "testuser")
 
 	rr := httptest.NewRecorder()
 	handler.HandleGetTasksByID(rr, req)
@@ -750,6 +1225,45 @@ func TestHandleCreateTaskWithMockSuccess(t *testing.T) {
 	}
 }
 
+// TestHandleCreateTaskExistingTagsOnly персональный токен без tags:write не может создавать теги через задачу
+func TestHandleCreateTaskExistingTagsOnly(t *testing.T) {
+	tests := []struct {
+		name         string
+		scopes       []string
+		existingOnly bool
+	}{
+		{"JWT", nil, false},
+		{"токен без tags:write", []string{auth.ScopeTasksWrite}, true},
+		{"токен с tags:write", []string{auth.ScopeTasksWrite, auth.ScopeTagsWrite}, false},
+	}
+
+	for _, tt := range tests {
+		var got *models.CreateTaskRequest
+		mockDB := &MockDBClient{
+			CreateTaskFunc: func(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
+				got = req
+				return &models.Task{ID: 1, Name: req.Name, Tags: req.Tags}, nil
+			},
+		}
+		handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+		req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(`{"name":"Task","tags":["new"]}`))
+		req = addAuthContext(req, 1, "testuser")
+		if tt.scopes != nil {
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ScopesContextKey, tt.scopes))
+		}
+		rr := httptest.NewRecorder()
+		handler.HandleCreateTask(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("%s: неправильный статус %d", tt.name, rr.Code)
+		}
+		if got.ExistingTagsOnly != tt.existingOnly {
+			t.Errorf("%s: ExistingTagsOnly = %v, ожидалось %v", tt.name, got.ExistingTagsOnly, tt.existingOnly)
+		}
+	}
+}
+
 func TestHandleCreateTaskWithMockDBError(t *testing.T) {
 	mockDB := &MockDBClient{
 		CreateTaskFunc: func(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
@@ -785,7 +1299,7 @@ func TestHandleCreateTaskWithMockDBError(t *testing.T) {
 
 func TestHandleGetAllTasksWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetAllTasksFunc: func(userID int) ([]models.Task, error) {
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Task 1", Complete: false},
 				{ID: 2, Name: "Task 2", Complete: true},
@@ -820,7 +1334,7 @@ func TestHandleGetAllTasksWithMockSuccess(t *testing.T) {
 
 func TestHandleGetAllTasksWithMockError(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetAllTasksFunc: func(userID int) ([]models.Task, error) {
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return nil, errors.New("database error")
 		},
 	}
@@ -907,8 +1421,8 @@ func TestHandleDeleteTaskWithMockError(t *testing.T) {
 
 func TestHandleCompleteTaskWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		CompleteTaskFunc: func(taskID, userID int) error {
-			return nil
+		CompleteTaskFunc: func(taskID, userID int) (*models.Task, error) {
+			return nil, nil
 		},
 	}
 
@@ -939,8 +1453,8 @@ func TestHandleCompleteTaskWithMockSuccess(t *testing.T) {
 
 func TestHandleCompleteTaskWithMockError(t *testing.T) {
 	mockDB := &MockDBClient{
-		CompleteTaskFunc: func(taskID, userID int) error {
-			return errors.New("database error")
+		CompleteTaskFunc: func(taskID, userID int) (*models.Task, error) {
+			return nil, errors.New("database error")
 		},
 	}
 
@@ -971,7 +1485,7 @@ func TestHandleCompleteTaskWithMockError(t *testing.T) {
 
 func TestHandleGetCompletedTasksWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetCompletedFunc: func(userID int) ([]models.Task, error) {
+		GetCompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Completed Task", Complete: true},
 			}, nil
@@ -1005,7 +1519,7 @@ func TestHandleGetCompletedTasksWithMockSuccess(t *testing.T) {
 
 func TestHandleGetUncompletedTasksWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetUncompletedFunc: func(userID int) ([]models.Task, error) {
+		GetUncompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Uncompleted Task", Complete: false},
 			}, nil
@@ -1039,7 +1553,7 @@ func TestHandleGetUncompletedTasksWithMockSuccess(t *testing.T) {
 
 func TestHandleGetTasksByIDWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetTaskByIDFunc: func(id int) (*models.Task, error) {
+		GetTaskByIDFunc: func(id, userID int) (*models.Task, error) {
 			return &models.Task{
 				ID:       id,
 				Name:     "Test Task",
@@ -1056,6 +1570,8 @@ func TestHandleGetTasksByIDWithMockSuccess(t *testing.T) {
 	req := httptest.NewRequest("GET", "/task/1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
+	req = addAuthContext(req, 1, "testuser")
+
 	rr := httptest.NewRecorder()
 	handler.HandleGetTasksByID(rr, req)
 
@@ -1075,7 +1591,7 @@ func TestHandleGetTasksByIDWithMockSuccess(t *testing.T) {
 
 func TestHandleGetTasksByNameWithMockSuccess(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetTaskByNameFunc: func(name string) (*models.Task, error) {
+		GetTaskByNameFunc: func(name string, userID int) (*models.Task, error) {
 			return &models.Task{
 				ID:       1,
 				Name:     name,
@@ -1092,6 +1608,8 @@ func TestHandleGetTasksByNameWithMockSuccess(t *testing.T) {
 	req := httptest.NewRequest("GET", "/task/name/TestTask", nil)
 	req = mux.SetURLVars(req, map[string]string{"name": "TestTask"})
 
+	req = addAuthContext(req, 1, "testuser")
+
 	rr := httptest.NewRecorder()
 	handler.HandleGetTasksByName(rr, req)
 
@@ -1111,7 +1629,7 @@ func TestHandleGetTasksByNameWithMockSuccess(t *testing.T) {
 
 func TestHandleGetTasksByIDWithMockError(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetTaskByIDFunc: func(id int) (*models.Task, error) {
+		GetTaskByIDFunc: func(id, userID int) (*models.Task, error) {
 			return nil, errors.New("database error")
 		},
 	}
@@ -1124,6 +1642,8 @@ func TestHandleGetTasksByIDWithMockError(t *testing.T) {
 	req := httptest.NewRequest("GET", "/task/1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
+	req = addAuthContext(req, 1, "testuser")
+
 	rr := httptest.NewRecorder()
 	handler.HandleGetTasksByID(rr, req)
 
@@ -1132,20 +1652,104 @@ func TestHandleGetTasksByIDWithMockError(t *testing.T) {
 	}
 }
 
-func TestHandleGetTasksByNameWithMockError(t *testing.T) {
-	mockDB := &MockDBClient{
-		GetTaskByNameFunc: func(name string) (*models.Task, error) {
-			return nil, errors.New("database error")
+// ownedTasks имитирует db-service: задача отдаётся только владельцу, иначе ErrNotFound
+func ownedTasks() *MockDBClient {
+	const ownerID = 1
+	task := models.Task{ID: 1, Name: "Secret"}
+	return &MockDBClient{
+		GetTaskByIDFunc: func(id, userID int) (*models.Task, error) {
+			if id != task.ID || userID != ownerID {
+				return nil, client.ErrNotFound
+			}
+			return &task, nil
+		},
+		GetTaskByNameFunc: func(name string, userID int) (*models.Task, error) {
+			if name != task.Name || userID != ownerID {
+				return nil, client.ErrNotFound
+			}
+			return &task, nil
 		},
 	}
+}
 
-	handler := &TaskHandlers{
-		DBClient:      mockDB,
-		EventProducer: &MockEventProducer{},
-	}
+func TestHandleGetTasksByIDOtherUser(t *testing.T) {
+	handler := NewTaskHandlers(ownedTasks(), &MockEventProducer{})
 
-	req := httptest.NewRequest("GET", "/task/name/TestTask", nil)
-	req = mux.SetURLVars(req, map[string]string{"name": "TestTask"})
+	req := httptest.NewRequest("GET", "/getbyid/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = addAuthContext(req, 2, "intruder")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByID(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+	if strings.Contains(rr.Body.String(), "Secret") {
+		t.Error("Ответ не должен содержать данные чужой задачи")
+	}
+}
+
+func TestHandleGetTasksByNameOtherUser(t *testing.T) {
+	handler := NewTaskHandlers(ownedTasks(), &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/getbyname/Secret", nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "Secret"})
+	req = addAuthContext(req, 2, "intruder")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByName(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleGetTasksByIDOwner(t *testing.T) {
+	handler := NewTaskHandlers(ownedTasks(), &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/getbyid/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = addAuthContext(req, 1, "owner")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByID(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestHandleGetTasksByIDUnauthorized(t *testing.T) {
+	handler := NewTaskHandlers(ownedTasks(), &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/getbyid/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByID(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleGetTasksByNameWithMockError(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetTaskByNameFunc: func(name string, userID int) (*models.Task, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	handler := &TaskHandlers{
+		DBClient:      mockDB,
+		EventProducer: &MockEventProducer{},
+	}
+
+	req := httptest.NewRequest("GET", "/task/name/TestTask", nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "TestTask"})
+
+	req = addAuthContext(req, 1, "testuser")
 
 	rr := httptest.NewRecorder()
 	handler.HandleGetTasksByName(rr, req)
@@ -1157,7 +1761,7 @@ func TestHandleGetTasksByNameWithMockError(t *testing.T) {
 
 func TestHandleGetCompletedTasksWithMockError(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetCompletedFunc: func(userID int) ([]models.Task, error) {
+		GetCompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return nil, errors.New("database error")
 		},
 	}
@@ -1180,7 +1784,7 @@ func TestHandleGetCompletedTasksWithMockError(t *testing.T) {
 
 func TestHandleGetUncompletedTasksWithMockError(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetUncompletedFunc: func(userID int) ([]models.Task, error) {
+		GetUncompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return nil, errors.New("database error")
 		},
 	}
@@ -1240,7 +1844,7 @@ func TestHandleCreateTaskKafkaErrorIgnored(t *testing.T) {
 
 func TestHandleGetAllTasksEmptyList(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetAllTasksFunc: func(userID int) ([]models.Task, error) {
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{}, nil
 		},
 	}
@@ -1303,11 +1907,11 @@ func TestHandleDeleteTaskWithZeroIDMock(t *testing.T) {
 
 func TestHandleCompleteTaskWithZeroIDMock(t *testing.T) {
 	mockDB := &MockDBClient{
-		CompleteTaskFunc: func(taskID, userID int) error {
+		CompleteTaskFunc: func(taskID, userID int) (*models.Task, error) {
 			if taskID == 0 {
-				return errors.New("invalid task ID")
+				return nil, errors.New("invalid task ID")
 			}
-			return nil
+			return nil, nil
 		},
 	}
 
@@ -1373,7 +1977,7 @@ func TestHandleCreateTaskWithEmptyText(t *testing.T) {
 
 func TestHandleGetAllTasksWithMultipleTasks(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetAllTasksFunc: func(userID int) ([]models.Task, error) {
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Task 1", Complete: false},
 				{ID: 2, Name: "Task 2", Complete: true},
@@ -1411,7 +2015,7 @@ func TestHandleGetAllTasksWithMultipleTasks(t *testing.T) {
 
 func TestHandleGetCompletedTasksMultiple(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetCompletedFunc: func(userID int) ([]models.Task, error) {
+		GetCompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Completed 1", Complete: true},
 				{ID: 2, Name: "Completed 2", Complete: true},
@@ -1453,7 +2057,7 @@ func TestHandleGetCompletedTasksMultiple(t *testing.T) {
 
 func TestHandleGetUncompletedTasksMultiple(t *testing.T) {
 	mockDB := &MockDBClient{
-		GetUncompletedFunc: func(userID int) ([]models.Task, error) {
+		GetUncompletedFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
 			return []models.Task{
 				{ID: 1, Name: "Uncompleted 1", Complete: false},
 				{ID: 2, Name: "Uncompleted 2", Complete: false},
@@ -1542,3 +2146,2013 @@ func TestHandleDeleteTaskWithDifferentUsers(t *testing.T) {
 		t.Errorf("DeleteTask должен быть вызван 2 раза, вызван %d раз", deleteCallCount)
 	}
 }
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleUpdateTask
+// ============================================================================
+
+func TestHandleUpdateTaskSuccess(t *testing.T) {
+	var gotReq *models.UpdateTaskRequest
+	mockDB := &MockDBClient{
+		UpdateTaskFunc: func(taskID, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+			gotReq = req
+			if taskID != 5 || userID != 1 {
+				t.Errorf("Неправильные аргументы: taskID=%d, userID=%d", taskID, userID)
+			}
+			return &models.UpdateTaskResponse{
+				Task: models.Task{ID: 5, Name: "New name"},
+				Changes: []models.FieldChange{
+					{Field: "name", Old: "Old name", New: "New name"},
+					{Field: "collection_id", Old: nil, New: float64(3)},
+				},
+			}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PATCH", "/tasks/5", bytes.NewBufferString(`{"name":"New name","collection_id":3}`))
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	if gotReq == nil || gotReq.Text != nil || !gotReq.CollectionID.Set {
+		t.Error("В DBClient должны уйти только переданные поля")
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if task.Name != "New name" {
+		t.Errorf("Неправильное имя: %s", task.Name)
+	}
+
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "UPDATE_TASK" {
+		t.Fatal("Должно быть отправлено событие UPDATE_TASK")
+	}
+	expected := `Task updated: id=5, name: "Old name" -> "New name", collection_id: null -> 3`
+	if mockKafka.Events[0].Details != expected {
+		t.Errorf("Неправильные детали события: %s", mockKafka.Events[0].Details)
+	}
+}
+
+func TestHandleUpdateTaskEmptyBody(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("PATCH", "/tasks/5", bytes.NewBufferString(`{}`))
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleUpdateTaskEmptyName(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, name := range []string{"", "   ", strings.Repeat("я", models.MaxTaskNameLength+1)} {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		req := httptest.NewRequest("PATCH", "/tasks/5", bytes.NewReader(body))
+		req = addAuthContext(req, 1, "testuser")
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+		rr := httptest.NewRecorder()
+		handler.HandleUpdateTask(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("name из %d символов: получено %v, ожидается %v", len([]rune(name)), rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleUpdateTaskTrimsName(t *testing.T) {
+	var gotName string
+	mockDB := &MockDBClient{
+		UpdateTaskFunc: func(taskID, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+			gotName = *req.Name
+			return &models.UpdateTaskResponse{Task: models.Task{ID: 5, Name: *req.Name}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("PATCH", "/tasks/5", bytes.NewBufferString(`{"name":"  New name  "}`))
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if gotName != "New name" {
+		t.Errorf("В DBClient должно уйти обрезанное имя, получено %q", gotName)
+	}
+}
+
+func TestHandleUpdateTaskNotFound(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateTaskFunc: func(taskID, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PUT", "/tasks/5", bytes.NewBufferString(`{"text":"x"}`))
+	req = addAuthContext(req, 2, "other")
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+		t.Error("Должно быть отправлено событие об ошибке")
+	}
+}
+
+func TestHandleUpdateTaskUnauthorized(t *testing.T) {
+	handler := &TaskHandlers{}
+
+	req := httptest.NewRequest("PATCH", "/tasks/5", bytes.NewBufferString(`{"name":"x"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleReopenTask
+// ============================================================================
+
+func TestHandleReopenTaskWithMockSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		ReopenTaskFunc: func(taskID, userID int) error {
+			return nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PUT", "/uncomplete/1", nil)
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleReopenTask(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", status, http.StatusOK)
+	}
+
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "REOPEN_TASK" || mockKafka.Events[0].Status != "SUCCESS" {
+		t.Error("Должно быть отправлено событие REOPEN_TASK")
+	}
+}
+
+func TestHandleReopenTaskWithMockError(t *testing.T) {
+	mockDB := &MockDBClient{
+		ReopenTaskFunc: func(taskID, userID int) error {
+			return errors.New("task not completed")
+		},
+	}
+	mockKafka := &MockEventProducer{}
+
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PUT", "/uncomplete/1", nil)
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleReopenTask(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", status, http.StatusInternalServerError)
+	}
+
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+		t.Error("Должно быть отправлено событие об ошибке")
+	}
+}
+
+func TestHandleReopenTaskInvalidID(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("PUT", "/uncomplete/abc", nil)
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "abc"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleReopenTask(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", status, http.StatusBadRequest)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleGetTasksByDue
+// ============================================================================
+
+func TestHandleGetTasksByDueSuccess(t *testing.T) {
+	dueAt := time.Now()
+	mockDB := &MockDBClient{
+		GetTasksByDueFunc: func(userID int, due, tz string, opts models.ListOptions) ([]models.Task, error) {
+			if due != "overdue" || tz != "Europe/Moscow" {
+				t.Errorf("Неправильные параметры: due=%s, tz=%s", due, tz)
+			}
+			return []models.Task{{ID: 1, Name: "Late", DueAt: &dueAt}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?due=overdue&tz=Europe/Moscow", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByDue(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var tasks []models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].DueAt == nil {
+		t.Errorf("Ожидалась 1 задача со сроком, получено %+v", tasks)
+	}
+}
+
+func TestHandleGetTasksByDueInvalidMode(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?due=yesterday", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByDue(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleGetTasksByDueInvalidTZ(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetTasksByDueFunc: func(userID int, due, tz string, opts models.ListOptions) ([]models.Task, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?due=today&tz=Mars/Base", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByDue(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleCreateTaskStartAfterDue(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	body := `{"name":"Task","start_at":"2025-01-02T00:00:00Z","due_at":"2025-01-01T00:00:00Z"}`
+	req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(body))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ приоритетов и сортировки
+// ============================================================================
+
+func TestHandleGetAllTasksPassesSort(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
+			if opts.Sort != "priority" || opts.Order != "desc" {
+				t.Errorf("Неправильные параметры сортировки: %+v", opts)
+			}
+			return []models.Task{{ID: 1, Name: "Urgent", Priority: "urgent"}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?sort=priority&order=desc", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetAllTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestHandleGetAllTasksInvalidSort(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, query := range []string{"sort=password", "order=random"} {
+		req := httptest.NewRequest("GET", "/get?"+query, nil)
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleGetAllTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус: получено %v, ожидается %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleCreateTaskInvalidPriority(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(`{"name":"Task","priority":"critical"}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleUpdateTaskPriorityOnly(t *testing.T) {
+	producer := &MockEventProducer{}
+	mockDB := &MockDBClient{
+		UpdateTaskFunc: func(id, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+			if req.Priority == nil || *req.Priority != "high" {
+				t.Errorf("Ожидался приоритет high, получено %v", req.Priority)
+			}
+			return &models.UpdateTaskResponse{
+				Task:    models.Task{ID: id, Name: "Task", Priority: "high"},
+				Changes: []models.FieldChange{{Field: "priority", Old: "none", New: "high"}},
+			}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, producer)
+
+	req := httptest.NewRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"priority":"high"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(producer.Events) != 1 || !strings.Contains(producer.Events[0].Details, `priority: "none" -> "high"`) {
+		t.Errorf("Неправильное событие: %+v", producer.Events)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ тегов
+// ============================================================================
+
+func TestHandleGetTasksByTagAll(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetTasksByTagsFunc: func(userID int, tags []string, matchAll bool, opts models.ListOptions) ([]models.Task, error) {
+			if len(tags) != 2 || tags[0] != "work" || tags[1] != "home" || !matchAll {
+				t.Errorf("Неправильные параметры: tags=%v, matchAll=%v", tags, matchAll)
+			}
+			return []models.Task{{ID: 1, Name: "Task", Tags: []string{"home", "work"}}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?tag=work,home&match=all", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByTag(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestHandleGetTasksByTagInvalid(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, query := range []string{"tag=", "tag=work,,home", "tag=work&match=most"} {
+		req := httptest.NewRequest("GET", "/get?"+query, nil)
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleGetTasksByTag(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус: получено %v, ожидается %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleCreateTagSuccess(t *testing.T) {
+	producer := &MockEventProducer{}
+	mockDB := &MockDBClient{
+		CreateTagFunc: func(req *models.CreateTagRequest, userID int) (*models.Tag, error) {
+			return &models.Tag{ID: 3, Name: req.Name, Color: "#808080"}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, producer)
+
+	req := httptest.NewRequest("POST", "/tags", bytes.NewBufferString(`{"name":"work"}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTag(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusCreated)
+	}
+	if len(producer.Events) != 1 || producer.Events[0].Action != "CREATE_TAG" {
+		t.Errorf("Ожидалось событие CREATE_TAG, получено %+v", producer.Events)
+	}
+}
+
+func TestHandleCreateTagConflict(t *testing.T) {
+	mockDB := &MockDBClient{
+		CreateTagFunc: func(req *models.CreateTagRequest, userID int) (*models.Tag, error) {
+			return nil, client.ErrConflict
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/tags", bytes.NewBufferString(`{"name":"work"}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTag(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestHandleDeleteTagNotFound(t *testing.T) {
+	mockDB := &MockDBClient{
+		DeleteTagFunc: func(id, userID int) error {
+			return client.ErrNotFound
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("DELETE", "/tags/9", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "9"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleDeleteTag(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleUpdateTaskTagsOnly(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateTaskFunc: func(id, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error) {
+			if len(req.AddTags) != 1 || req.AddTags[0] != "work" {
+				t.Errorf("Неправильные add_tags: %v", req.AddTags)
+			}
+			return &models.UpdateTaskResponse{Task: models.Task{ID: id, Tags: []string{"work"}}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("PATCH", "/tasks/1", bytes.NewBufferString(`{"add_tags":["work"]}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ чек-листа
+// ============================================================================
+
+func TestHandleAddChecklistItemSuccess(t *testing.T) {
+	producer := &MockEventProducer{}
+	mockDB := &MockDBClient{
+		AddChecklistItemFunc: func(taskID, userID int, req *models.AddChecklistItemRequest) (*models.ChecklistItem, error) {
+			if taskID != 3 || req.Text != "Шаг" {
+				t.Errorf("Неправильные параметры: taskID=%d, text=%s", taskID, req.Text)
+			}
+			return &models.ChecklistItem{ID: 7, TaskID: taskID, Text: req.Text}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, producer)
+
+	req := httptest.NewRequest("POST", "/tasks/3/checklist", bytes.NewBufferString(`{"text":"Шаг"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleAddChecklistItem(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusCreated)
+	}
+	if len(producer.Events) != 1 || producer.Events[0].Action != "ADD_CHECKLIST_ITEM" {
+		t.Errorf("Ожидалось событие ADD_CHECKLIST_ITEM, получено %+v", producer.Events)
+	}
+}
+
+func TestHandleAddChecklistItemEmptyText(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/tasks/3/checklist", bytes.NewBufferString(`{"text":" "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleAddChecklistItem(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleUpdateChecklistItemNotFound(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateChecklistItemFunc: func(taskID, itemID, userID int, req *models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("PATCH", "/tasks/3/checklist/9", bytes.NewBufferString(`{"done":true}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "itemId": "9"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateChecklistItem(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleReorderChecklistBadRequest(t *testing.T) {
+	mockDB := &MockDBClient{
+		ReorderChecklistFunc: func(taskID, userID int, req *models.ReorderChecklistRequest) ([]models.ChecklistItem, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("PUT", "/tasks/3/checklist/order", bytes.NewBufferString(`{"item_ids":[1]}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleReorderChecklist(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ повторяющихся задач
+// ============================================================================
+
+func TestHandleCompleteTaskRecurring(t *testing.T) {
+	dueAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	mockDB := &MockDBClient{
+		CompleteTaskFunc: func(taskID, userID int) (*models.Task, error) {
+			return &models.Task{ID: 8, Name: "Weekly", DueAt: &dueAt}, nil
+		},
+	}
+	producer := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, producer)
+
+	req := httptest.NewRequest("PUT", "/complete/7", nil)
+	req = addAuthContext(req, 1, "testuser")
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleCompleteTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var response struct {
+		NextTask *models.Task `json:"next_task"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if response.NextTask == nil || response.NextTask.ID != 8 {
+		t.Errorf("Ожидалась следующая задача с ID 8, получено %+v", response.NextTask)
+	}
+
+	if len(producer.Events) != 2 || producer.Events[1].Action != "RECUR_TASK" {
+		t.Fatalf("Ожидались события COMPLETE_TASK и RECUR_TASK, получено %+v", producer.Events)
+	}
+	want := "Task recurred: id=7, next_id=8, due_at=2025-03-10T09:00:00Z"
+	if producer.Events[1].Details != want {
+		t.Errorf("Неправильные детали события: получено %q, ожидается %q", producer.Events[1].Details, want)
+	}
+}
+
+func TestHandleCreateTaskInvalidRecurrence(t *testing.T) {
+	mockDB := &MockDBClient{
+		CreateTaskFunc: func(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(`{"name":"Task","recurrence":"FREQ=HOURLY"}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTask(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ пагинации
+// ============================================================================
+
+func TestHandleGetAllTasksPaginated(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
+			if opts.Limit != 2 || opts.Cursor != "abc" {
+				t.Errorf("Неправильные параметры страницы: %+v", opts.PageParams)
+			}
+			return []models.Task{{ID: 5}, {ID: 4}}, nil
+		},
+		NextCursor: "def",
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/tasks?limit=2&cursor=abc", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetAllTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var page models.Page[models.Task]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor != "def" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestHandleGetAllTasksLegacyArray(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
+			return []models.Task{{ID: 1}}, nil
+		},
+		NextCursor: "ignored",
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetAllTasks(rr, req)
+
+	var tasks []models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+		t.Fatalf("Без limit/cursor ожидался голый массив: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Ожидалась 1 задача, получено %d", len(tasks))
+	}
+}
+
+func TestHandleGetAllTasksInvalidPagination(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, query := range []string{"limit=0", "limit=x", "limit=201", "limit=10&sort=due_at", "cursor=abc&sort=name"} {
+		req := httptest.NewRequest("GET", "/tasks?"+query, nil)
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleGetAllTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус: получено %v, ожидается %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleGetAllTasksInvalidCursor(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetAllTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/tasks?cursor=garbage", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetAllTasks(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleGetCollectionsPaginated(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetCollectionsFunc: func(userID int) ([]models.Collection, error) {
+			return []models.Collection{{ID: 1, Name: "Work"}}, nil
+		},
+		NextCursor: "next",
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/collections?limit=1", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetCollections(rr, req)
+
+	var page models.Page[models.Collection]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "next" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+func TestHandleGetTagsPaginated(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetTagsFunc: func(userID int) ([]models.Tag, error) {
+			return []models.Tag{{ID: 1, Name: "home"}}, nil
+		},
+		NextCursor: "next",
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/tags?limit=1", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTags(rr, req)
+
+	var page models.Page[models.Tag]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != "next" {
+		t.Errorf("Неправильная страница: %+v", page)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ поиска
+// ============================================================================
+
+func TestHandleSearchTasksSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		SearchTasksFunc: func(userID int, query string, opts models.SearchOptions) ([]models.SearchResult, error) {
+			if userID != 1 || query != "report" {
+				t.Errorf("Неправильные аргументы: userID=%d, query=%q", userID, query)
+			}
+			if opts.CollectionID == nil || *opts.CollectionID != 3 || opts.Complete == nil || *opts.Complete || opts.Limit != 5 {
+				t.Errorf("Неправильные фильтры: %+v", opts)
+			}
+			return []models.SearchResult{{Task: models.Task{ID: 7}, Rank: 0.6, Snippet: "<mark>report</mark>"}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/search?q=report&collection_id=3&complete=false&limit=5", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleSearchTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var results []models.SearchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 7 || results[0].Snippet != "<mark>report</mark>" {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+}
+
+func TestHandleSearchTasksInvalidParams(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, query := range []string{"", "q=%20%20", "q=a&collection_id=x", "q=a&complete=maybe", "q=a&limit=0", "q=a&limit=101"} {
+		req := httptest.NewRequest("GET", "/search?"+query, nil)
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleSearchTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус: получено %v, ожидается %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleSearchTasksEmptyQueryFromDB(t *testing.T) {
+	mockDB := &MockDBClient{
+		SearchTasksFunc: func(userID int, query string, opts models.SearchOptions) ([]models.SearchResult, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/search?q=!!!", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleSearchTasks(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleSearchTasksUnauthorized(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/search?q=report", nil)
+	rr := httptest.NewRecorder()
+	handler.HandleSearchTasks(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ корзины
+// ============================================================================
+
+func TestHandleGetTrash(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetTrashFunc: func(userID int) (*models.Trash, error) {
+			return &models.Trash{
+				Tasks:       []models.TrashedTask{{Task: models.Task{ID: 5, Name: "Old task"}}},
+				Collections: []models.TrashedCollection{},
+			}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/trash", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var trash models.Trash
+	if err := json.NewDecoder(rr.Body).Decode(&trash); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(trash.Tasks) != 1 || trash.Tasks[0].ID != 5 {
+		t.Errorf("Неправильная корзина: %+v", trash)
+	}
+}
+
+func TestHandleRestoreTaskFromTrash(t *testing.T) {
+	mockDB := &MockDBClient{
+		RestoreTaskFunc: func(id, userID int) (*models.Task, error) {
+			if id != 5 || userID != 1 {
+				t.Errorf("Неправильные аргументы: id=%d, userID=%d", id, userID)
+			}
+			return &models.Task{ID: 5}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/trash/5/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleRestoreFromTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "RESTORE_TASK" {
+		t.Errorf("Ожидалось событие RESTORE_TASK, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleRestoreCollectionFromTrash(t *testing.T) {
+	mockDB := &MockDBClient{
+		RestoreCollectionFunc: func(id, userID int) (*models.Collection, error) {
+			return &models.Collection{ID: id, Name: "Work"}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/trash/3/restore?type=collection", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleRestoreFromTrash(rr, req)
+
+	var collection models.Collection
+	if err := json.NewDecoder(rr.Body).Decode(&collection); err != nil || collection.ID != 3 {
+		t.Errorf("Ожидалась коллекция 3, получено %+v (%v)", collection, err)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "RESTORE_COLLECTION" {
+		t.Errorf("Ожидалось событие RESTORE_COLLECTION, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleRestoreFromTrashNotFound(t *testing.T) {
+	mockDB := &MockDBClient{
+		RestoreTaskFunc: func(id, userID int) (*models.Task, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/trash/5/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 2, "intruder")
+
+	rr := httptest.NewRecorder()
+	handler.HandleRestoreFromTrash(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+	if len(mockKafka.Events) != 0 {
+		t.Errorf("При ошибке событие не отправляется, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandlePurgeFromTrashInvalidType(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("DELETE", "/trash/5?type=tag", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandlePurgeFromTrash(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlePurgeCollectionFromTrash(t *testing.T) {
+	purged := false
+	mockDB := &MockDBClient{
+		PurgeCollectionFunc: func(id, userID int) error {
+			purged = id == 3 && userID == 1
+			return nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("DELETE", "/trash/3?type=collection", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandlePurgeFromTrash(rr, req)
+
+	if rr.Code != http.StatusOK || !purged {
+		t.Errorf("Коллекция должна быть удалена окончательно, статус %v", rr.Code)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "PURGE_COLLECTION" {
+		t.Errorf("Ожидалось событие PURGE_COLLECTION, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleEmptyTrash(t *testing.T) {
+	mockDB := &MockDBClient{
+		EmptyTrashFunc: func(userID int) (*models.PurgeResult, error) {
+			return &models.PurgeResult{Tasks: 3, Collections: 1}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("DELETE", "/trash", nil)
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleEmptyTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Details != "Trash emptied: tasks=3, collections=1" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleUpdateCollection
+// ============================================================================
+
+func TestHandleUpdateCollectionSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateCollectionFunc: func(id, userID int, req *models.UpdateCollectionRequest) (*models.Collection, error) {
+			if id != 3 || userID != 1 || req.Color == nil || *req.Color != "#FF8800" {
+				t.Errorf("Неправильные аргументы: id=%d, userID=%d, req=%+v", id, userID, req)
+			}
+			return &models.Collection{ID: 3, Name: "Work", Color: "#FF8800", Icon: "📁"}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PATCH", "/collections/3", bytes.NewBufferString(`{"color":"#FF8800"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateCollection(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "UPDATE_COLLECTION" {
+		t.Errorf("Ожидалось событие UPDATE_COLLECTION, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleUpdateCollectionValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	bodies := []string{
+		`{}`,
+		`{"color":"blue"}`,
+		`{"color":"#2564cf0"}`,
+		`{"name":"  "}`,
+		`{"name":"` + strings.Repeat("я", 101) + `"}`,
+		`{"icon":""}`,
+		`{"icon":"` + strings.Repeat("🏠", 51) + `"}`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest("PATCH", "/collections/3", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleUpdateCollection(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус %v, ожидается %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleUpdateCollectionNotFound(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateCollectionFunc: func(id, userID int, req *models.UpdateCollectionRequest) (*models.Collection, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PATCH", "/collections/3", bytes.NewBufferString(`{"name":"Work"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 2, "intruder")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateCollection(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+	if len(mockKafka.Events) != 0 {
+		t.Errorf("При ошибке событие не отправляется, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleUpdateCollectionUnauthorized(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("PATCH", "/collections/3", bytes.NewBufferString(`{"name":"Work"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateCollection(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ переноса задач
+// ============================================================================
+
+func TestHandleMoveTaskSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		MoveTasksFunc: func(req *models.MoveTasksRequest, userID int) ([]models.TaskMove, error) {
+			if len(req.TaskIDs) != 1 || req.TaskIDs[0] != 5 || *req.CollectionID.Value != 3 {
+				t.Errorf("Неправильный запрос: %+v", req)
+			}
+			return []models.TaskMove{{TaskID: 5, To: req.CollectionID.Value}}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/5/move", bytes.NewBufferString(`{"collection_id":3}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleMoveTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "MOVE_TASK" ||
+		mockKafka.Events[0].Details != "Task moved: id=5, from=none, to=3" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleMoveTasksBulk(t *testing.T) {
+	from := 3
+	mockDB := &MockDBClient{
+		MoveTasksFunc: func(req *models.MoveTasksRequest, userID int) ([]models.TaskMove, error) {
+			if req.CollectionID.Value != nil {
+				t.Errorf("Ожидался перенос без коллекции, получено %v", *req.CollectionID.Value)
+			}
+			// Задача 2 уже без коллекции и не переносится
+			return []models.TaskMove{{TaskID: 1, From: &from}, {TaskID: 4, From: &from}}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/move", bytes.NewBufferString(`{"task_ids":[1,2,4],"collection_id":null}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleMoveTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 2 || mockKafka.Events[1].Details != "Task moved: id=4, from=3, to=none" {
+		t.Errorf("Неправильные события: %+v", mockKafka.Events)
+	}
+
+	var moves []models.TaskMove
+	if err := json.NewDecoder(rr.Body).Decode(&moves); err != nil || len(moves) != 2 {
+		t.Errorf("Неправильный ответ: %+v (%v)", moves, err)
+	}
+}
+
+func TestHandleMoveTasksValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	bodies := []string{
+		`{"task_ids":[1]}`,
+		`{"task_ids":[],"collection_id":3}`,
+		`{"collection_id":3}`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest("POST", "/tasks/move", bytes.NewBufferString(body))
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleMoveTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус %v, ожидается %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleMoveTaskForeignCollection(t *testing.T) {
+	mockDB := &MockDBClient{
+		MoveTasksFunc: func(req *models.MoveTasksRequest, userID int) ([]models.TaskMove, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/5/move", bytes.NewBufferString(`{"collection_id":7}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleMoveTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+		t.Errorf("Ожидалось событие с ошибкой, получено %+v", mockKafka.Events)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ пакетных операций
+// ============================================================================
+
+func TestHandleBulkTasksSuccess(t *testing.T) {
+	from := 3
+	due := time.Date(2026, 1, 8, 9, 0, 0, 0, time.UTC)
+	mockDB := &MockDBClient{
+		BulkTasksFunc: func(req *models.BulkTasksRequest, userID int) ([]models.BulkResult, error) {
+			if userID != 1 || len(req.Operations) != 5 {
+				t.Errorf("Неправильный запрос: %+v", req)
+			}
+			return []models.BulkResult{
+				{Index: 0, Op: models.BulkComplete, TaskID: 1, Status: models.BulkStatusOK, NextTask: &models.Task{ID: 10, DueAt: &due}},
+				{Index: 1, Op: models.BulkReopen, TaskID: 2, Status: models.BulkStatusError, Error: "task not completed, not found, or access denied"},
+				{Index: 2, Op: models.BulkDelete, TaskID: 3, Status: models.BulkStatusOK},
+				{Index: 3, Op: models.BulkMove, TaskID: 4, Status: models.BulkStatusOK, Move: &models.TaskMove{TaskID: 4, From: &from}},
+				{Index: 4, Op: models.BulkTag, TaskID: 5, Status: models.BulkStatusOK,
+					Changes: []models.FieldChange{{Field: "tags", Old: []interface{}{}, New: []interface{}{"work"}}}},
+			}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	body := `{"operations":[
+		{"op":"complete","task_id":1},
+		{"op":"reopen","task_id":2},
+		{"op":"delete","task_id":3},
+		{"op":"move","task_id":4,"collection_id":null},
+		{"op":"tag","task_id":5,"add_tags":["work"]}]}`
+	req := httptest.NewRequest("POST", "/tasks/bulk", bytes.NewBufferString(body))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleBulkTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	expected := []struct{ action, details, status string }{
+		{"COMPLETE_TASK", "Task completed: id=1", "SUCCESS"},
+		{"RECUR_TASK", "Task recurred: id=1, next_id=10, due_at=2026-01-08T09:00:00Z", "SUCCESS"},
+		{"REOPEN_TASK", "Failed to reopen task: id=2", "ERROR"},
+		{"DELETE_TASK", "Task deleted: id=3", "SUCCESS"},
+		{"MOVE_TASK", "Task moved: id=4, from=3, to=none", "SUCCESS"},
+		{"UPDATE_TASK", "Task updated: id=5, tags: [] -> [work]", "SUCCESS"},
+		{"BULK_TASKS", "Bulk operations: total=5, succeeded=4, failed=1", "SUCCESS"},
+	}
+	if len(mockKafka.Events) != len(expected) {
+		t.Fatalf("Ожидалось %d событий, получено %+v", len(expected), mockKafka.Events)
+	}
+	for i, e := range expected {
+		got := mockKafka.Events[i]
+		if got.Action != e.action || got.Details != e.details || got.Status != e.status {
+			t.Errorf("Событие %d: получено %+v, ожидается %+v", i, got, e)
+		}
+	}
+
+	var results []models.BulkResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil || len(results) != 5 {
+		t.Errorf("Неправильный ответ: %+v (%v)", results, err)
+	}
+}
+
+func TestHandleBulkTasksValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	bodies := []string{
+		`{"operations":[]}`,
+		`{"operations":[{"op":"archive","task_id":1}]}`,
+		`{"operations":[{"op":"complete"}]}`,
+		`{"operations":[{"op":"move","task_id":1}]}`,
+		`{"operations":[{"op":"tag","task_id":1}]}`,
+		`not json`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest("POST", "/tasks/bulk", bytes.NewBufferString(body))
+		req = addAuthContext(req, 1, "testuser")
+
+		rr := httptest.NewRecorder()
+		handler.HandleBulkTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: неправильный статус %v, ожидается %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleBulkTasksDBError(t *testing.T) {
+	mockDB := &MockDBClient{
+		BulkTasksFunc: func(req *models.BulkTasksRequest, userID int) ([]models.BulkResult, error) {
+			return nil, client.ErrBadRequest
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/bulk", bytes.NewBufferString(`{"operations":[{"op":"tag","task_id":1,"add_tags":[","]}]}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleBulkTasks(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusBadRequest)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "BULK_TASKS" || mockKafka.Events[0].Status != "ERROR" {
+		t.Errorf("Ожидалось событие с ошибкой, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleBulkTasksUnauthorized(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/tasks/bulk", bytes.NewBufferString(`{"operations":[{"op":"complete","task_id":1}]}`))
+	rr := httptest.NewRecorder()
+	handler.HandleBulkTasks(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandleCreateTaskForeignCollection(t *testing.T) {
+	mockDB := &MockDBClient{
+		CreateTaskFunc: func(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
+			return nil, client.ErrNotFound
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(`{"name":"Task","collection_id":7}`))
+	req = addAuthContext(req, 1, "testuser")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ участников коллекции
+// ============================================================================
+
+func TestHandleAddMemberSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		AddMemberFunc: func(collectionID, userID int, req *models.AddMemberRequest) (*models.Member, error) {
+			if collectionID != 3 || userID != 1 || req.Username != "bob" || req.Role != models.RoleEditor {
+				t.Errorf("Неправильные аргументы: collection=%d, userID=%d, req=%+v", collectionID, userID, req)
+			}
+			return &models.Member{CollectionID: 3, UserID: 2, Username: "bob", Role: models.RoleEditor}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/collections/3/members", bytes.NewBufferString(`{"username":" bob ","role":"editor"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleAddMember(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusCreated)
+	}
+	want := "Member added: collection=3, user_id=2, username=bob, role=editor"
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "ADD_MEMBER" || mockKafka.Events[0].Details != want {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleAddMemberValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, body := range []string{`{"username":"bob","role":"owner"}`, `{"username":"  ","role":"viewer"}`, `{"username":"bob"}`, `not json`} {
+		req := httptest.NewRequest("POST", "/collections/3/members", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleAddMember(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получено %v", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleAddMemberErrorStatus(t *testing.T) {
+	cases := map[error]int{
+		fmt.Errorf("%w: only owner", client.ErrForbidden): http.StatusForbidden,
+		fmt.Errorf("%w: user", client.ErrNotFound):        http.StatusNotFound,
+		fmt.Errorf("%w: member", client.ErrConflict):      http.StatusConflict,
+	}
+
+	for dbErr, want := range cases {
+		mockKafka := &MockEventProducer{}
+		handler := NewTaskHandlers(&MockDBClient{
+			AddMemberFunc: func(int, int, *models.AddMemberRequest) (*models.Member, error) {
+				return nil, dbErr
+			},
+		}, mockKafka)
+
+		req := httptest.NewRequest("POST", "/collections/3/members", bytes.NewBufferString(`{"username":"bob","role":"viewer"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		req = addAuthContext(req, 2, "bob")
+
+		rr := httptest.NewRecorder()
+		handler.HandleAddMember(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%v: ожидался статус %d, получено %d", dbErr, want, rr.Code)
+		}
+		if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+			t.Errorf("%v: ожидалось событие с ошибкой, получено %+v", dbErr, mockKafka.Events)
+		}
+	}
+}
+
+func TestHandleUpdateMember(t *testing.T) {
+	mockDB := &MockDBClient{
+		UpdateMemberFunc: func(collectionID, memberID, userID int, req *models.UpdateMemberRequest) (*models.Member, error) {
+			if collectionID != 3 || memberID != 2 || userID != 1 {
+				t.Errorf("Неправильные аргументы: collection=%d, member=%d, userID=%d", collectionID, memberID, userID)
+			}
+			return &models.Member{CollectionID: 3, UserID: 2, Username: "bob", Role: req.Role}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("PATCH", "/collections/3/members/2", bytes.NewBufferString(`{"role":"viewer"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "userId": "2"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleUpdateMember(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "UPDATE_MEMBER" {
+		t.Errorf("Ожидалось событие UPDATE_MEMBER, получено %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleRemoveMemberLeave(t *testing.T) {
+	mockDB := &MockDBClient{
+		RemoveMemberFunc: func(collectionID, memberID, userID int) error {
+			return nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("DELETE", "/collections/3/members/2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "userId": "2"})
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleRemoveMember(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Details != "Member left: collection=3, user_id=2" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleDeleteCollectionForbidden(t *testing.T) {
+	mockDB := &MockDBClient{
+		DeleteCollectionFunc: func(collectionID, userID int) error {
+			return fmt.Errorf("%w: editor", client.ErrForbidden)
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("DELETE", "/collections/3", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleDeleteCollection(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusForbidden)
+	}
+	if len(mockKafka.Events) != 0 {
+		t.Errorf("Событие не должно отправляться: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleCreateTaskEventInSharedCollection(t *testing.T) {
+	collectionID := 3
+	mockDB := &MockDBClient{
+		CreateTaskFunc: func(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
+			return &models.Task{ID: 7, Name: req.Name, CollectionID: &collectionID}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/create", bytes.NewBufferString(`{"name":"Milk","collection_id":3}`))
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateTask(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusCreated)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Details != "Task created: id=7, name=Milk, collection=3" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ поручения задач
+// ============================================================================
+
+func TestHandleAssignTaskSuccess(t *testing.T) {
+	collectionID := 3
+	mockDB := &MockDBClient{
+		AssignTaskFunc: func(taskID, userID int, req *models.AssignTaskRequest) (*models.Task, error) {
+			if taskID != 5 || userID != 1 || req.Username != "bob" {
+				t.Errorf("Неправильные аргументы: taskID=%d, userID=%d, req=%+v", taskID, userID, req)
+			}
+			assignee, status := 2, models.AssignmentPending
+			return &models.Task{ID: 5, CollectionID: &collectionID, AssigneeID: &assignee, AssignmentStatus: &status}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/5/assign", bytes.NewBufferString(`{"username":" bob "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleAssignTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	want := "Task assigned: id=5, assignee=bob, collection=3"
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "ASSIGN_TASK" || mockKafka.Events[0].Details != want {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleAssignTaskValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, body := range []string{`{"username":"  "}`, `{}`, `not json`} {
+		req := httptest.NewRequest("POST", "/tasks/5/assign", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleAssignTask(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получено %v", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleAssignTaskErrorStatus(t *testing.T) {
+	cases := map[error]int{
+		fmt.Errorf("%w: user", client.ErrNotFound):   http.StatusNotFound,
+		fmt.Errorf("%w: self", client.ErrBadRequest): http.StatusBadRequest,
+		errors.New("connection refused"):             http.StatusInternalServerError,
+	}
+
+	for dbErr, want := range cases {
+		mockDB := &MockDBClient{
+			AssignTaskFunc: func(int, int, *models.AssignTaskRequest) (*models.Task, error) {
+				return nil, dbErr
+			},
+		}
+		mockKafka := &MockEventProducer{}
+		handler := NewTaskHandlers(mockDB, mockKafka)
+
+		req := httptest.NewRequest("POST", "/tasks/5/assign", bytes.NewBufferString(`{"username":"bob"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleAssignTask(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%v: ожидался статус %d, получено %d", dbErr, want, rr.Code)
+		}
+		if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+			t.Errorf("%v: ожидалось событие об ошибке, получено %+v", dbErr, mockKafka.Events)
+		}
+	}
+}
+
+func TestHandleAcceptAndDeclineTask(t *testing.T) {
+	respond := func(taskID, userID int) (*models.Task, error) {
+		if taskID != 5 || userID != 2 {
+			t.Errorf("Неправильные аргументы: taskID=%d, userID=%d", taskID, userID)
+		}
+		return &models.Task{ID: 5}, nil
+	}
+	mockDB := &MockDBClient{AcceptTaskFunc: respond, DeclineTaskFunc: respond}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	for _, h := range []http.HandlerFunc{handler.HandleAcceptTask, handler.HandleDeclineTask} {
+		req := httptest.NewRequest("POST", "/tasks/5/accept", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		req = addAuthContext(req, 2, "bob")
+
+		rr := httptest.NewRecorder()
+		h(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+		}
+	}
+
+	if len(mockKafka.Events) != 2 ||
+		mockKafka.Events[0].Action != "ACCEPT_TASK" || mockKafka.Events[0].Details != "Task accepted: id=5" ||
+		mockKafka.Events[1].Action != "DECLINE_TASK" || mockKafka.Events[1].Details != "Task declined: id=5" {
+		t.Errorf("Неправильные события: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleAcceptTaskNoPendingAssignment(t *testing.T) {
+	mockDB := &MockDBClient{
+		AcceptTaskFunc: func(int, int) (*models.Task, error) {
+			return nil, fmt.Errorf("%w: no pending assignment", client.ErrNotFound)
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/5/accept", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleAcceptTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusNotFound)
+	}
+	if len(mockKafka.Events) != 0 {
+		t.Errorf("Событие не должно отправляться: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleGetAssignedTasks(t *testing.T) {
+	mockDB := &MockDBClient{
+		GetAssignedTasksFunc: func(userID int, opts models.ListOptions) ([]models.Task, error) {
+			if userID != 2 {
+				t.Errorf("Неправильный userID: %d", userID)
+			}
+			return []models.Task{{ID: 5}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/get?assigned=me", nil)
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetAssignedTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	var tasks []models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != 5 {
+		t.Errorf("Неправильный список: %+v", tasks)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ вложенных коллекций
+// ============================================================================
+
+func TestHandleMoveCollectionSuccess(t *testing.T) {
+	mockDB := &MockDBClient{
+		MoveCollectionFunc: func(id, userID int, req *models.MoveCollectionRequest) (*models.Collection, error) {
+			if id != 3 || userID != 1 || req.ParentID.Value == nil || *req.ParentID.Value != 2 {
+				t.Errorf("Неправильные аргументы: id=%d, userID=%d, req=%+v", id, userID, req)
+			}
+			return &models.Collection{ID: 3, ParentID: req.ParentID.Value, Name: "Q1"}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/collections/3/move", bytes.NewBufferString(`{"parent_id":2}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleMoveCollection(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "MOVE_COLLECTION" || mockKafka.Events[0].Details != "Collection moved: id=3, parent=2" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleMoveCollectionToTopLevel(t *testing.T) {
+	mockDB := &MockDBClient{
+		MoveCollectionFunc: func(id, userID int, req *models.MoveCollectionRequest) (*models.Collection, error) {
+			if !req.ParentID.Set || req.ParentID.Value != nil {
+				t.Errorf("Ожидался явный null, получено %+v", req.ParentID)
+			}
+			return &models.Collection{ID: 3, Name: "Q1"}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/collections/3/move", bytes.NewBufferString(`{"parent_id":null}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleMoveCollection(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Details != "Collection moved: id=3, parent=none" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleMoveCollectionValidation(t *testing.T) {
+	handler := NewTaskHandlers(&MockDBClient{}, &MockEventProducer{})
+
+	for _, body := range []string{`{}`, `{"parent_id":3}`, `not json`} {
+		req := httptest.NewRequest("POST", "/collections/3/move", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleMoveCollection(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получено %v", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleMoveCollectionErrorStatus(t *testing.T) {
+	cases := map[error]int{
+		fmt.Errorf("%w: cycle", client.ErrBadRequest):    http.StatusBadRequest,
+		fmt.Errorf("%w: shared", client.ErrForbidden):    http.StatusForbidden,
+		fmt.Errorf("%w: collection", client.ErrNotFound): http.StatusNotFound,
+	}
+
+	for dbErr, want := range cases {
+		mockDB := &MockDBClient{
+			MoveCollectionFunc: func(int, int, *models.MoveCollectionRequest) (*models.Collection, error) {
+				return nil, dbErr
+			},
+		}
+		mockKafka := &MockEventProducer{}
+		handler := NewTaskHandlers(mockDB, mockKafka)
+
+		req := httptest.NewRequest("POST", "/collections/3/move", bytes.NewBufferString(`{"parent_id":7}`))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleMoveCollection(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%v: ожидался статус %d, получено %d", dbErr, want, rr.Code)
+		}
+		if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" {
+			t.Errorf("%v: ожидалось событие об ошибке, получено %+v", dbErr, mockKafka.Events)
+		}
+	}
+}
+
+func TestHandleGetTasksByCollectionRecursive(t *testing.T) {
+	var gotRecursive bool
+	mockDB := &MockDBClient{
+		GetTasksByCollectionFunc: func(collectionID, userID int, recursive bool, opts models.ListOptions) ([]models.Task, error) {
+			gotRecursive = recursive
+			return []models.Task{{ID: 5}}, nil
+		},
+	}
+	handler := NewTaskHandlers(mockDB, &MockEventProducer{})
+
+	req := httptest.NewRequest("GET", "/collections/3/tasks?recursive=true", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleGetTasksByCollection(rr, req)
+
+	if rr.Code != http.StatusOK || !gotRecursive {
+		t.Errorf("Ожидался рекурсивный список: статус %d, recursive=%v", rr.Code, gotRecursive)
+	}
+
+	req = httptest.NewRequest("GET", "/collections/3/tasks?recursive=maybe", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr = httptest.NewRecorder()
+	handler.HandleGetTasksByCollection(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался статус 400, получено %d", rr.Code)
+	}
+}
+
+func TestHandleCreateCollectionInParentEvent(t *testing.T) {
+	parent := 2
+	mockDB := &MockDBClient{
+		CreateCollectionFunc: func(req *models.CreateCollectionRequest, userID int) (*models.Collection, error) {
+			if req.ParentID == nil || *req.ParentID != 2 {
+				t.Errorf("Ожидался parent_id=2, получено %+v", req)
+			}
+			return &models.Collection{ID: 3, ParentID: &parent, Name: "Q1"}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/collections", bytes.NewBufferString(`{"name":"Q1","parent_id":2}`))
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleCreateCollection(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusCreated)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Details != "Collection created: id=3, name=Q1, parent=2" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ручного порядка
+// ============================================================================
+
+func TestHandleReorderTaskSuccess(t *testing.T) {
+	collectionID := 4
+	mockDB := &MockDBClient{
+		ReorderTaskFunc: func(taskID, userID int, req *models.ReorderRequest) (*models.Task, error) {
+			if taskID != 5 || userID != 1 || req.After == nil || *req.After != 3 || req.Before != nil {
+				t.Errorf("Неправильные аргументы: taskID=%d, userID=%d, req=%+v", taskID, userID, req)
+			}
+			return &models.Task{ID: 5, CollectionID: &collectionID, Position: 2560}, nil
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/tasks/5/reorder", bytes.NewBufferString(`{"after":3}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	req = addAuthContext(req, 1, "alice")
+
+	rr := httptest.NewRecorder()
+	handler.HandleReorderTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Action != "REORDER_TASK" || mockKafka.Events[0].Details != "Task reordered: id=5, after=3, collection=4" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
+
+func TestHandleReorderTaskValidation(t *testing.T) {
+	for _, body := range []string{`not json`, `{}`, `{"before":1,"after":2}`, `{"before":5}`} {
+		mockKafka := &MockEventProducer{}
+		handler := NewTaskHandlers(&MockDBClient{}, mockKafka)
+
+		req := httptest.NewRequest("POST", "/tasks/5/reorder", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "5"})
+		req = addAuthContext(req, 1, "alice")
+
+		rr := httptest.NewRecorder()
+		handler.HandleReorderTask(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался статус 400, получено %v", body, rr.Code)
+		}
+		if len(mockKafka.Events) != 0 {
+			t.Errorf("%s: при ошибке валидации события не отправляются, получено %+v", body, mockKafka.Events)
+		}
+	}
+}
+
+func TestHandleReorderCollectionForbidden(t *testing.T) {
+	mockDB := &MockDBClient{
+		ReorderCollectionFunc: func(id, userID int, req *models.ReorderRequest) (*models.Collection, error) {
+			return nil, client.ErrForbidden
+		},
+	}
+	mockKafka := &MockEventProducer{}
+	handler := NewTaskHandlers(mockDB, mockKafka)
+
+	req := httptest.NewRequest("POST", "/collections/3/reorder", bytes.NewBufferString(`{"before":2}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	req = addAuthContext(req, 2, "bob")
+
+	rr := httptest.NewRecorder()
+	handler.HandleReorderCollection(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Неправильный статус: получено %v, ожидается %v", rr.Code, http.StatusForbidden)
+	}
+	if len(mockKafka.Events) != 1 || mockKafka.Events[0].Status != "ERROR" || mockKafka.Events[0].Details != "Failed to reorder collection: id=3, before=2" {
+		t.Errorf("Неправильное событие: %+v", mockKafka.Events)
+	}
+}
@@ -1,24 +1,88 @@
 package handlers
 
-import "apiservice/models"
+import (
+	"apiservice/models"
+	"time"
+)
 
 // DBClientInterface определяет методы клиента БД
 type DBClientInterface interface {
 	CreateTask(req *models.CreateTaskRequest, userID int) (*models.Task, error)
-	GetAllTasks(userID int) ([]models.Task, error)
+	GetAllTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error)
 	DeleteTask(taskID, userID int) error
-	CompleteTask(taskID, userID int) error
-	GetCompleted(userID int) ([]models.Task, error)
-	GetUncompleted(userID int) ([]models.Task, error)
-	GetTaskByID(id int) (*models.Task, error)
-	GetTaskByName(name string) (*models.Task, error)
+	CompleteTask(taskID, userID int) (*models.Task, error)
+	ReopenTask(taskID, userID int) error
+	UpdateTask(taskID, userID int, req *models.UpdateTaskRequest) (*models.UpdateTaskResponse, error)
+	GetCompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error)
+	GetUncompleted(userID int, opts models.ListOptions) (*models.Page[models.Task], error)
+	GetTasksByDue(userID int, due, tz string, opts models.ListOptions) (*models.Page[models.Task], error)
+	GetTaskByID(id, userID int) (*models.Task, error)
+	GetTaskByName(name string, userID int) (*models.Task, error)
 	CreateCollection(req *models.CreateCollectionRequest, userID int) (*models.Collection, error)
-	GetCollections(userID int) ([]models.Collection, error)
+	GetCollections(userID int, page models.PageParams) (*models.Page[models.Collection], error)
+	MoveTasks(req *models.MoveTasksRequest, userID int) ([]models.TaskMove, error)
+	BulkTasks(req *models.BulkTasksRequest, userID int) ([]models.BulkResult, error)
+	UpdateCollection(id, userID int, req *models.UpdateCollectionRequest) (*models.Collection, error)
+	MoveCollection(id, userID int, req *models.MoveCollectionRequest) (*models.Collection, error)
+	ReorderCollection(id, userID int, req *models.ReorderRequest) (*models.Collection, error)
 	DeleteCollection(collectionID, userID int) error
-	GetTasksByCollection(collectionID, userID int) ([]models.Task, error)
+	GetMembers(collectionID, userID int) ([]models.Member, error)
+	AddMember(collectionID, userID int, req *models.AddMemberRequest) (*models.Member, error)
+	UpdateMember(collectionID, memberID, userID int, req *models.UpdateMemberRequest) (*models.Member, error)
+	RemoveMember(collectionID, memberID, userID int) error
+	GetTrash(userID int) (*models.Trash, error)
+	RestoreTask(id, userID int) (*models.Task, error)
+	RestoreCollection(id, userID int) (*models.Collection, error)
+	PurgeTask(id, userID int) error
+	PurgeCollection(id, userID int) error
+	EmptyTrash(userID int) (*models.PurgeResult, error)
+	GetTasksByCollection(collectionID, userID int, recursive bool, opts models.ListOptions) (*models.Page[models.Task], error)
+	GetAssignedTasks(userID int, opts models.ListOptions) (*models.Page[models.Task], error)
+	AssignTask(taskID, userID int, req *models.AssignTaskRequest) (*models.Task, error)
+	AcceptTask(taskID, userID int) (*models.Task, error)
+	DeclineTask(taskID, userID int) (*models.Task, error)
+	ReorderTask(taskID, userID int, req *models.ReorderRequest) (*models.Task, error)
+	SearchTasks(userID int, query string, opts models.SearchOptions) ([]models.SearchResult, error)
+	GetTasksByTags(userID int, tags []string, matchAll bool, opts models.ListOptions) (*models.Page[models.Task], error)
+	CreateTag(req *models.CreateTagRequest, userID int) (*models.Tag, error)
+	GetTags(userID int, page models.PageParams) (*models.Page[models.Tag], error)
+	UpdateTag(id, userID int, req *models.UpdateTagRequest) (*models.Tag, error)
+	DeleteTag(id, userID int) error
+	GetChecklist(taskID, userID int) ([]models.ChecklistItem, error)
+	AddChecklistItem(taskID, userID int, req *models.AddChecklistItemRequest) (*models.ChecklistItem, error)
+	UpdateChecklistItem(taskID, itemID, userID int, req *models.UpdateChecklistItemRequest) (*models.ChecklistItem, error)
+	DeleteChecklistItem(taskID, itemID, userID int) error
+	ReorderChecklist(taskID, userID int, req *models.ReorderChecklistRequest) ([]models.ChecklistItem, error)
 }
 
 // EventProducerInterface определяет методы продюсера Kafka
 type EventProducerInterface interface {
 	SendEvent(userID int, username, action, details, status string) error
 }
+
+// AuthClientInterface определяет методы клиента БД для регистрации, входа, refresh-токенов, выхода,
+// управления учётной записью, сброса пароля, двухфакторной аутентификации и персональных токенов доступа
+type AuthClientInterface interface {
+	CreateUser(req *models.CreateUserRequest) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	CreateRefreshToken(userID int, req *models.CreateRefreshTokenRequest) error
+	RotateRefreshToken(req *models.RotateRefreshTokenRequest) (*models.User, error)
+	RevokeToken(userID int, req *models.RevokeTokenRequest) error
+	RevokeAllTokens(userID int) (time.Time, error)
+	ChangePassword(userID int, req *models.UpdatePasswordRequest) (time.Time, error)
+	DeleteUser(userID int) error
+	UpdateEmail(userID int, email string) error
+	CreatePasswordReset(req *models.CreatePasswordResetRequest) (*models.User, error)
+	ConsumePasswordReset(req *models.ConsumePasswordResetRequest) (*models.PasswordReset, error)
+	GetTOTP(userID int) (*models.TOTP, error)
+	SetupTOTP(userID int, secret string) error
+	EnableTOTP(userID int, req *models.EnableTOTPRequest) error
+	UseTOTPStep(userID int, step int64) error
+	RecordTOTPFailure(userID int) error
+	UseRecoveryCode(userID int, codeHash string) (int, error)
+	DisableTOTP(userID int) error
+	CreateAccessToken(userID int, req *models.StoreAccessTokenRequest) (*models.AccessToken, error)
+	ListAccessTokens(userID int) ([]models.AccessToken, error)
+	DeleteAccessToken(id, userID int) error
+	AuthenticateAccessToken(tokenHash string) (*models.AccessTokenOwner, error)
+}
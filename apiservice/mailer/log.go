@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogMailer вместо отправки пишет письма целиком в Out: в файл или stdout при локальной разработке, в буфер в тестах
+type LogMailer struct {
+	mu   sync.Mutex
+	out  io.Writer
+	from string
+}
+
+func NewLogMailer(out io.Writer, from string) *LogMailer {
+	return &LogMailer{out: out, from: from}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Письма из параллельных запросов не должны перемешиваться
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := fmt.Fprintf(m.out, "---- mail to %s ----\n", msg.To); err != nil {
+		return err
+	}
+	_, err = m.out.Write(data)
+	return err
+}
@@ -0,0 +1,100 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFrom адрес отправителя, если MAIL_FROM не задан
+const DefaultFrom = "todo@localhost"
+
+// Message письмо в виде простого текста
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма. Реализации: SMTPMailer для настоящей почты и LogMailer для разработки и тестов
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// ValidAddress проверяет, что строка — голый адрес вида user@example.com, без имени и угловых скобок
+func ValidAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// FromEnv выбирает реализацию по переменным окружения: SMTP_HOST (и SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD) —
+// SMTPMailer; иначе MAIL_FILE — LogMailer, дописывающий письма в файл; иначе в dev-режиме — LogMailer в stdout.
+// Вне dev-режима без настроек возвращает nil: письма с токенами не должны попадать в логи
+func FromEnv(dev bool) (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = DefaultFrom
+	}
+	if !ValidAddress(from) {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q", from)
+	}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil || p <= 0 || p > 65535 {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", v)
+			}
+			port = p
+		}
+		return NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	}
+
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("open MAIL_FILE: %w", err)
+		}
+		return NewLogMailer(f, from), nil
+	}
+
+	if dev {
+		return NewLogMailer(os.Stdout, from), nil
+	}
+	return nil, nil
+}
+
+// format собирает письмо в формате RFC 5322 с телом в UTF-8
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	if !ValidAddress(msg.To) {
+		return nil, fmt.Errorf("invalid recipient %q", msg.To)
+	}
+	// Перевод строки в заголовке позволил бы дописать свои заголовки
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("subject must not contain line breaks")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	if !strings.HasSuffix(body, "\n") {
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,214 @@
+package mailer
+
+import (
+	"bytes"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ format
+// ============================================================================
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, err := format("todo@localhost", Message{To: "user@example.com", Subject: "Сброс пароля", Body: "line1\nline2"}, date)
+	if err != nil {
+		t.Fatalf("format() вернул ошибку: %v", err)
+	}
+
+	msg := string(data)
+	for _, want := range []string{
+		"From: todo@localhost\r\n",
+		"To: user@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Date: Sat, 01 Mar 2025 12:00:00 +0000\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+		"\r\n\r\nline1\r\nline2\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("В письме нет %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	tests := []Message{
+		{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hi"},
+		{To: "User <user@example.com>", Subject: "Hi"},
+		{To: "user@example.com", Subject: "Hi\r\nBcc: other@example.com"},
+	}
+
+	for _, msg := range tests {
+		if _, err := format("todo@localhost", msg, time.Now()); err == nil {
+			t.Errorf("format() должен отклонить %+v", msg)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ LogMailer и SMTPMailer
+// ============================================================================
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf, "todo@localhost")
+
+	if err := m.Send(Message{To: "user@example.com", Subject: "Hi", Body: "token: abc"}); err != nil {
+		t.Fatalf("Send() вернул ошибку: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "---- mail to user@example.com ----") || !strings.Contains(out, "token: abc") {
+		t.Errorf("Письмо не записано целиком:\n%s", out)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+
+	m := NewSMTPMailer(host, p, "", "", "todo@localhost")
+	if err := m.Send(Message{To: "user@example.com", Subject: "Hi", Body: "token: abc"}); err != nil {
+		t.Fatalf("Send() вернул ошибку: %v", err)
+	}
+
+	select {
+	case mail := <-received:
+		if mail.from != "<todo@localhost>" || mail.to != "<user@example.com>" || !strings.Contains(mail.data, "token: abc") {
+			t.Errorf("Сервер получил неправильное письмо: %+v", mail)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Сервер не получил письмо")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ FromEnv
+// ============================================================================
+
+// clearMailEnv сбрасывает переменные окружения почты, чтобы на тест не влияло окружение запуска
+func clearMailEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"MAIL_FROM", "MAIL_FILE", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	clearMailEnv(t)
+
+	if m, err := FromEnv(false); err != nil || m != nil {
+		t.Errorf("Без настроек вне dev-режима ожидался nil, получено %v, %v", m, err)
+	}
+	if m, err := FromEnv(true); err != nil || m == nil {
+		t.Errorf("В dev-режиме ожидался LogMailer, получено %v, %v", m, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mail.log")
+	t.Setenv("MAIL_FILE", path)
+	m, err := FromEnv(false)
+	if err != nil {
+		t.Fatalf("FromEnv() вернул ошибку: %v", err)
+	}
+	if err := m.Send(Message{To: "user@example.com", Subject: "Hi", Body: "token: abc"}); err != nil {
+		t.Fatalf("Send() вернул ошибку: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "token: abc") {
+		t.Errorf("Письмо не записано в MAIL_FILE: %q", data)
+	}
+
+	t.Setenv("SMTP_HOST", "mailpit")
+	t.Setenv("SMTP_PORT", "1025")
+	m, err = FromEnv(false)
+	if smtpMailer, ok := m.(*SMTPMailer); err != nil || !ok || smtpMailer.Addr != "mailpit:1025" || smtpMailer.Auth != nil {
+		t.Errorf("SMTP_HOST должен выбирать SMTPMailer без авторизации, получено %+v, %v", m, err)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	tests := []map[string]string{
+		{"MAIL_FROM": "Todo <todo@localhost>"},
+		{"SMTP_HOST": "mailpit", "SMTP_PORT": "smtp"},
+		{"MAIL_FILE": filepath.Join(os.TempDir(), "missing-dir", "mail.log")},
+	}
+
+	for _, env := range tests {
+		clearMailEnv(t)
+		for k, v := range env {
+			t.Setenv(k, v)
+		}
+		if _, err := FromEnv(true); err == nil {
+			t.Errorf("%v: ожидалась ошибка конфигурации", env)
+		}
+	}
+}
+
+// ============================================================================
+// ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ
+// ============================================================================
+
+type receivedMail struct {
+	from, to, data string
+}
+
+// fakeSMTPServer принимает одно письмо по минимальному подмножеству SMTP без расширений
+func fakeSMTPServer(t *testing.T) (string, <-chan receivedMail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть порт: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan receivedMail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var mail receivedMail
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch {
+			case cmd == "EHLO" || cmd == "HELO":
+				tp.PrintfLine("250 localhost")
+			case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+				mail.from = line[len("MAIL FROM:"):]
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+				mail.to = line[len("RCPT TO:"):]
+				tp.PrintfLine("250 OK")
+			case cmd == "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				tp.PrintfLine("250 OK")
+				received <- mail
+			case cmd == "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
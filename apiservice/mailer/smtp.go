@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer отправляет письма через SMTP-сервер. STARTTLS включается, если сервер его предлагает
+type SMTPMailer struct {
+	Addr string
+	From string
+	// Auth nil — без авторизации, как у локальных заглушек вроде MailHog и Mailpit
+	Auth smtp.Auth
+}
+
+// NewSMTPMailer создаёт SMTPMailer; пустой username отключает авторизацию.
+// smtp.PlainAuth передаёт пароль только по TLS или на localhost
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		Addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		From: from,
+	}
+	if username != "" {
+		m.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := format(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{msg.To}, data)
+}
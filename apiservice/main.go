@@ -1,18 +1,28 @@
 package main
 
 import (
+	"apiservice/auth"
 	"apiservice/client"
 	"apiservice/handlers"
 	"apiservice/kafka"
+	"apiservice/mailer"
 	"apiservice/middleware"
+	"apiservice/revocation"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 func main() {
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatal("Invalid JWT configuration: ", err)
+	}
+	auth.Configure(authConfig)
+
 	dbClient := client.NewDBClient("http://db-service:8080")
 
 	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
@@ -32,25 +42,67 @@ func main() {
 		}
 	}()
 
+	// Кэш отзывов: выход на другом экземпляре становится виден здесь не позже чем через интервал обновления
+	revocationInterval := revocation.DefaultRefreshInterval
+	if v := os.Getenv("REVOCATION_REFRESH_INTERVAL"); v != "" {
+		revocationInterval, err = time.ParseDuration(v)
+		if err != nil || revocationInterval <= 0 {
+			log.Fatalf("Invalid REVOCATION_REFRESH_INTERVAL %q: must be a positive duration such as 30s", v)
+		}
+	}
+	revocations := revocation.NewCache(dbClient, auth.TTL(), auth.Leeway())
+	if err := revocations.Refresh(); err != nil {
+		log.Printf("Warning: Failed to load token revocations: %v. Retrying every %v.", err, revocationInterval)
+	}
+	go revocations.Run(revocationInterval)
+	middleware.SetRevocationChecker(revocations)
+
+	mail, err := mailer.FromEnv(auth.IsDevMode())
+	if err != nil {
+		log.Fatal("Invalid mail configuration: ", err)
+	}
+	if mail == nil {
+		log.Println("Warning: SMTP_HOST and MAIL_FILE are not set, password reset by email is disabled")
+	}
+
 	taskHandlers := handlers.NewTaskHandlers(dbClient, eventProducer)
+	authHandlers := handlers.NewAuthHandlers(dbClient, eventProducer, revocations, mail)
+	authHandlers.ResetURL = os.Getenv("PASSWORD_RESET_URL")
+	middleware.SetPATAuthenticator(authHandlers)
 
 	//Без JWT
 	router := mux.NewRouter()
 
 	router.Use(corsMiddleware)
 
-	router.HandleFunc("/register", handlers.Register).Methods("POST", "OPTIONS")
-	router.HandleFunc("/login", handlers.Login).Methods("POST", "OPTIONS")
+	router.HandleFunc("/register", authHandlers.Register).Methods("POST", "OPTIONS")
+	router.HandleFunc("/login", authHandlers.Login).Methods("POST", "OPTIONS")
+	router.HandleFunc("/login/mfa", authHandlers.LoginMFA).Methods("POST", "OPTIONS")
+	router.HandleFunc("/token/refresh", authHandlers.Refresh).Methods("POST", "OPTIONS")
+	router.HandleFunc("/password/forgot", authHandlers.ForgotPassword).Methods("POST", "OPTIONS")
+	router.HandleFunc("/password/reset", authHandlers.ResetPassword).Methods("POST", "OPTIONS")
 
-	//C JWT
+	//C JWT или персональным токеном. Каждый маршрут ниже оборачивается либо в RequireScope с областями,
+	//которые нужны персональному токену, либо в SessionOnly: учётной записью и самими токенами управляют только из сессии
 	protected := router.PathPrefix("/").Subrouter()
 	protected.Use(middleware.AuthMiddleware)
 
+	session := middleware.SessionOnly
+	tasksRead := middleware.RequireScope(auth.ScopeTasksRead)
+	tasksWrite := middleware.RequireScope(auth.ScopeTasksWrite)
+	collectionsRead := middleware.RequireScope(auth.ScopeCollectionsRead)
+	collectionsWrite := middleware.RequireScope(auth.ScopeCollectionsWrite)
+	tagsRead := middleware.RequireScope(auth.ScopeTagsRead)
+	tagsWrite := middleware.RequireScope(auth.ScopeTagsWrite)
+	// В корзине и задачи, и коллекции
+	trashRead := middleware.RequireScope(auth.ScopeTasksRead, auth.ScopeCollectionsRead)
+	trashWrite := middleware.RequireScope(auth.ScopeTasksWrite, auth.ScopeCollectionsWrite)
+
 	// Enable CORS
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 			if r.Method == "OPTIONS" {
@@ -62,21 +114,73 @@ func main() {
 		})
 	})
 
-	protected.Path("/create").Methods("POST", "OPTIONS").HandlerFunc(taskHandlers.HandleCreateTask)
-	protected.Path("/get").Methods("GET", "OPTIONS").Queries("complete", "true").HandlerFunc(taskHandlers.HandleGetCompletedTasks)
-	protected.Path("/get").Methods("GET", "OPTIONS").Queries("complete", "false").HandlerFunc(taskHandlers.HandleGetUncompletedTasks)
-	protected.Path("/get").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetAllTasks)
-	protected.Path("/tasks").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetAllTasks)
-	protected.Path("/delete/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(taskHandlers.HandleDeleteTask)
-	protected.Path("/complete/{id}").Methods("PUT", "POST", "OPTIONS").HandlerFunc(taskHandlers.HandleCompleteTask)
-	protected.Path("/getbyid/{id}").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetTasksByID)
-	protected.Path("/getbyname/{name}").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetTasksByName)
+	protected.Path("/logout").Methods("POST", "OPTIONS").HandlerFunc(session(authHandlers.Logout))
+	protected.Path("/logout-all").Methods("POST", "OPTIONS").HandlerFunc(session(authHandlers.LogoutAll))
+	protected.Path("/account/password").Methods("PUT", "OPTIONS").HandlerFunc(session(authHandlers.ChangePassword))
+	protected.Path("/account").Methods("DELETE", "OPTIONS").HandlerFunc(session(authHandlers.DeleteAccount))
+	protected.Path("/account/email").Methods("PUT", "OPTIONS").HandlerFunc(session(authHandlers.UpdateEmail))
+	protected.Path("/account/2fa").Methods("GET", "OPTIONS").HandlerFunc(session(authHandlers.TOTPStatus))
+	protected.Path("/account/2fa").Methods("DELETE", "OPTIONS").HandlerFunc(session(authHandlers.DisableTOTP))
+	protected.Path("/account/2fa/setup").Methods("POST", "OPTIONS").HandlerFunc(session(authHandlers.SetupTOTP))
+	protected.Path("/account/2fa/enable").Methods("POST", "OPTIONS").HandlerFunc(session(authHandlers.EnableTOTP))
+	protected.Path("/account/tokens").Methods("GET", "OPTIONS").HandlerFunc(session(authHandlers.ListAccessTokens))
+	protected.Path("/account/tokens").Methods("POST", "OPTIONS").HandlerFunc(session(authHandlers.CreateAccessToken))
+	protected.Path("/account/tokens/{id:[0-9]+}").Methods("DELETE", "OPTIONS").HandlerFunc(session(authHandlers.RevokeAccessToken))
+
+	protected.Path("/create").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleCreateTask))
+	protected.Path("/get").Methods("GET", "OPTIONS").Queries("complete", "true").HandlerFunc(tasksRead(taskHandlers.HandleGetCompletedTasks))
+	protected.Path("/get").Methods("GET", "OPTIONS").Queries("complete", "false").HandlerFunc(tasksRead(taskHandlers.HandleGetUncompletedTasks))
+	protected.Path("/get").Methods("GET", "OPTIONS").Queries("due", "{due}").HandlerFunc(tasksRead(taskHandlers.HandleGetTasksByDue))
+	protected.Path("/get").Methods("GET", "OPTIONS").Queries("tag", "{tag}").HandlerFunc(tasksRead(taskHandlers.HandleGetTasksByTag))
+	protected.Path("/get").Methods("GET", "OPTIONS").Queries("assigned", "me").HandlerFunc(tasksRead(taskHandlers.HandleGetAssignedTasks))
+	protected.Path("/get").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetAllTasks))
+	protected.Path("/tasks").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetAllTasks))
+	protected.Path("/delete/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleDeleteTask))
+	protected.Path("/complete/{id}").Methods("PUT", "POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleCompleteTask))
+	protected.Path("/uncomplete/{id}").Methods("PUT", "POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleReopenTask))
+	protected.Path("/tasks/move").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleMoveTasks))
+	protected.Path("/tasks/bulk").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleBulkTasks))
+	protected.Path("/tasks/{id:[0-9]+}/move").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleMoveTask))
+	protected.Path("/tasks/{id:[0-9]+}/assign").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleAssignTask))
+	protected.Path("/tasks/{id:[0-9]+}/accept").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleAcceptTask))
+	protected.Path("/tasks/{id:[0-9]+}/decline").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleDeclineTask))
+	protected.Path("/tasks/{id:[0-9]+}/reorder").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleReorderTask))
+	protected.Path("/tasks/{id}").Methods("PUT", "PATCH", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleUpdateTask))
+	protected.Path("/getbyid/{id}").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetTasksByID))
+	protected.Path("/getbyname/{name}").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetTasksByName))
+	protected.Path("/search").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleSearchTasks))
+
+	// Checklist routes
+	protected.Path("/tasks/{id}/checklist").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetChecklist))
+	protected.Path("/tasks/{id}/checklist").Methods("POST", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleAddChecklistItem))
+	protected.Path("/tasks/{id}/checklist/order").Methods("PUT", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleReorderChecklist))
+	protected.Path("/tasks/{id}/checklist/{itemId:[0-9]+}").Methods("PUT", "PATCH", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleUpdateChecklistItem))
+	protected.Path("/tasks/{id}/checklist/{itemId:[0-9]+}").Methods("DELETE", "OPTIONS").HandlerFunc(tasksWrite(taskHandlers.HandleDeleteChecklistItem))
 
 	// Collection routes
-	protected.Path("/collections").Methods("POST", "OPTIONS").HandlerFunc(taskHandlers.HandleCreateCollection)
-	protected.Path("/collections").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetCollections)
-	protected.Path("/collections/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(taskHandlers.HandleDeleteCollection)
-	protected.Path("/collections/{id}/tasks").Methods("GET", "OPTIONS").HandlerFunc(taskHandlers.HandleGetTasksByCollection)
+	protected.Path("/collections").Methods("POST", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleCreateCollection))
+	protected.Path("/collections").Methods("GET", "OPTIONS").HandlerFunc(collectionsRead(taskHandlers.HandleGetCollections))
+	protected.Path("/collections/{id}").Methods("PATCH", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleUpdateCollection))
+	protected.Path("/collections/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleDeleteCollection))
+	protected.Path("/collections/{id}/move").Methods("POST", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleMoveCollection))
+	protected.Path("/collections/{id}/reorder").Methods("POST", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleReorderCollection))
+	protected.Path("/collections/{id}/tasks").Methods("GET", "OPTIONS").HandlerFunc(tasksRead(taskHandlers.HandleGetTasksByCollection))
+	protected.Path("/collections/{id}/members").Methods("GET", "OPTIONS").HandlerFunc(collectionsRead(taskHandlers.HandleGetMembers))
+	protected.Path("/collections/{id}/members").Methods("POST", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleAddMember))
+	protected.Path("/collections/{id}/members/{userId:[0-9]+}").Methods("PATCH", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleUpdateMember))
+	protected.Path("/collections/{id}/members/{userId:[0-9]+}").Methods("DELETE", "OPTIONS").HandlerFunc(collectionsWrite(taskHandlers.HandleRemoveMember))
+
+	// Trash routes
+	protected.Path("/trash").Methods("GET", "OPTIONS").HandlerFunc(trashRead(taskHandlers.HandleGetTrash))
+	protected.Path("/trash").Methods("DELETE", "OPTIONS").HandlerFunc(trashWrite(taskHandlers.HandleEmptyTrash))
+	protected.Path("/trash/{id}/restore").Methods("POST", "OPTIONS").HandlerFunc(trashWrite(taskHandlers.HandleRestoreFromTrash))
+	protected.Path("/trash/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(trashWrite(taskHandlers.HandlePurgeFromTrash))
+
+	// Tag routes
+	protected.Path("/tags").Methods("POST", "OPTIONS").HandlerFunc(tagsWrite(taskHandlers.HandleCreateTag))
+	protected.Path("/tags").Methods("GET", "OPTIONS").HandlerFunc(tagsRead(taskHandlers.HandleGetTags))
+	protected.Path("/tags/{id}").Methods("PUT", "PATCH", "OPTIONS").HandlerFunc(tagsWrite(taskHandlers.HandleUpdateTag))
+	protected.Path("/tags/{id}").Methods("DELETE", "OPTIONS").HandlerFunc(tagsWrite(taskHandlers.HandleDeleteTag))
 
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -93,7 +197,7 @@ func main() {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
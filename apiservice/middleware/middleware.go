@@ -3,6 +3,7 @@ package middleware
 import (
     "apiservice/auth"
     "context"
+    "errors"
     "net/http"
     "strings"
 )
@@ -11,6 +12,36 @@ type contextKey string
 
 const UserContextKey = contextKey("user")
 
+// RevocationChecker сообщает, отозван ли токен
+type RevocationChecker interface {
+    IsRevoked(claims *auth.Claims) bool
+}
+
+// revocations проверка отзыва токенов; пока SetRevocationChecker не вызван, отзыв не проверяется
+var revocations RevocationChecker
+
+// SetRevocationChecker задаёт проверку отзыва для AuthMiddleware. Вызывается один раз при старте
+func SetRevocationChecker(c RevocationChecker) {
+    revocations = c
+}
+
+// ScopesContextKey области доступа персонального токена. У запроса с JWT их в контексте нет: ему доступно всё
+const ScopesContextKey = contextKey("scopes")
+
+// PATAuthenticator проверяет персональный токен доступа и возвращает его владельца и области доступа.
+// Неизвестный, отозванный или истёкший токен — auth.ErrPATInvalid
+type PATAuthenticator interface {
+    AuthenticatePAT(token string) (*auth.Claims, []string, error)
+}
+
+// pats проверка персональных токенов; пока SetPATAuthenticator не вызван, они не принимаются
+var pats PATAuthenticator
+
+// SetPATAuthenticator задаёт проверку персональных токенов для AuthMiddleware. Вызывается один раз при старте
+func SetPATAuthenticator(a PATAuthenticator) {
+    pats = a
+}
+
 func AuthMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         authHeader := r.Header.Get("Authorization")
@@ -27,12 +58,39 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
         tokenString := parts[1]
 
+        // Персональный токен проверяет db-service; отзыв выходом и сменой пароля его не касается
+        if auth.IsPersonalAccessToken(tokenString) {
+            if pats == nil {
+                http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+                return
+            }
+            claims, scopes, err := pats.AuthenticatePAT(tokenString)
+            if errors.Is(err, auth.ErrPATInvalid) {
+                http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+                return
+            }
+            if err != nil {
+                http.Error(w, "Failed to verify access token", http.StatusServiceUnavailable)
+                return
+            }
+
+            ctx := context.WithValue(r.Context(), UserContextKey, claims)
+            ctx = context.WithValue(ctx, ScopesContextKey, scopes)
+            next.ServeHTTP(w, r.WithContext(ctx))
+            return
+        }
+
         claims, err := auth.ValidateToken(tokenString)
         if err != nil {
             http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
             return
         }
 
+        if revocations != nil && revocations.IsRevoked(claims) {
+            http.Error(w, "Token has been revoked", http.StatusUnauthorized)
+            return
+        }
+
         ctx := context.WithValue(r.Context(), UserContextKey, claims)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
@@ -44,4 +102,40 @@ func GetUserFromContext(r *http.Request) *auth.Claims {
         return nil
     }
     return claims
-}
\ No newline at end of file
+}
+
+// GetScopesFromContext области доступа персонального токена; ok == false — запрос с JWT, ему доступно всё
+func GetScopesFromContext(r *http.Request) (scopes []string, ok bool) {
+    scopes, ok = r.Context().Value(ScopesContextKey).([]string)
+    return scopes, ok
+}
+
+// RequireScope пропускает запрос с персональным токеном, только если у токена есть все области scopes.
+// Запрос с JWT пропускается всегда. Оборачивает обработчик маршрута за AuthMiddleware
+func RequireScope(scopes ...string) func(http.HandlerFunc) http.HandlerFunc {
+    return func(next http.HandlerFunc) http.HandlerFunc {
+        return func(w http.ResponseWriter, r *http.Request) {
+            if granted, ok := GetScopesFromContext(r); ok {
+                for _, scope := range scopes {
+                    if !auth.HasScope(granted, scope) {
+                        http.Error(w, "Token lacks required scope: "+scope, http.StatusForbidden)
+                        return
+                    }
+                }
+            }
+            next(w, r)
+        }
+    }
+}
+
+// SessionOnly не пускает персональные токены: учётной записью, входом и самими токенами
+// управляют только из сессии с JWT
+func SessionOnly(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if _, ok := GetScopesFromContext(r); ok {
+            http.Error(w, "Personal access tokens cannot be used for this endpoint", http.StatusForbidden)
+            return
+        }
+        next(w, r)
+    }
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"apiservice/auth"
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -64,6 +65,41 @@ func TestAuthMiddlewareSuccess(t *testing.T) {
 	}
 }
 
+// revokedJTI отзывает один jti
+type revokedJTI string
+
+func (r revokedJTI) IsRevoked(claims *auth.Claims) bool {
+	return claims.ID == string(r)
+}
+
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
+	revoked, _ := auth.GenerateToken(1, "testuser")
+	active, _ := auth.GenerateToken(1, "testuser")
+	claims, err := auth.ValidateToken(revoked)
+	if err != nil {
+		t.Fatalf("Не удалось проверить токен: %v", err)
+	}
+
+	SetRevocationChecker(revokedJTI(claims.ID))
+	t.Cleanup(func() { SetRevocationChecker(nil) })
+
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for token, want := range map[string]int{revoked: http.StatusUnauthorized, active: http.StatusOK} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("AuthMiddleware() вернул неправильный статус: получено %v, ожидается %v", rr.Code, want)
+		}
+	}
+}
+
 func TestAuthMiddlewareMissingHeader(t *testing.T) {
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Next handler не должен был быть вызван")
@@ -270,3 +306,134 @@ func TestAuthMiddlewareChain(t *testing.T) {
 		t.Errorf("Неправильный ответ: получено %q, ожидается %q", rr.Body.String(), "success")
 	}
 }
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ персональных токенов и областей доступа
+// ============================================================================
+
+// patStub принимает один персональный токен с областями scopes
+type patStub struct {
+	token  string
+	scopes []string
+	err    error
+}
+
+func (p patStub) AuthenticatePAT(token string) (*auth.Claims, []string, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	if token != p.token {
+		return nil, nil, auth.ErrPATInvalid
+	}
+	return &auth.Claims{UserID: 1, Username: "testuser"}, p.scopes, nil
+}
+
+func TestAuthMiddlewarePersonalAccessToken(t *testing.T) {
+	SetPATAuthenticator(patStub{token: "todo_pat_valid", scopes: []string{auth.ScopeTasksRead}})
+	t.Cleanup(func() { SetPATAuthenticator(nil) })
+
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := GetUserFromContext(r)
+		scopes, ok := GetScopesFromContext(r)
+		if claims == nil || claims.UserID != 1 || !ok || len(scopes) != 1 {
+			t.Errorf("Неправильный контекст: %+v, %v, %v", claims, scopes, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for token, want := range map[string]int{"todo_pat_valid": http.StatusOK, "todo_pat_revoked": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Errorf("%s: получено %v, ожидается %v", token, rr.Code, want)
+		}
+	}
+}
+
+func TestAuthMiddlewarePersonalAccessTokenErrors(t *testing.T) {
+	handler := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler не должен был быть вызван")
+	}))
+
+	tests := []struct {
+		name string
+		stub PATAuthenticator
+		want int
+	}{
+		{"проверка не настроена", nil, http.StatusUnauthorized},
+		{"db-service недоступен", patStub{err: errors.New("connection refused")}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPATAuthenticator(tt.stub)
+			t.Cleanup(func() { SetPATAuthenticator(nil) })
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer todo_pat_valid")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Получено %v, ожидается %v", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+// withScopes запрос с персональным токеном, у которого области scopes
+func withScopes(r *http.Request, scopes ...string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ScopesContextKey, scopes))
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := RequireScope(auth.ScopeTasksRead, auth.ScopeCollectionsRead)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"JWT", httptest.NewRequest("GET", "/test", nil), http.StatusOK},
+		{"все области", withScopes(httptest.NewRequest("GET", "/test", nil), auth.ScopeTasksRead, auth.ScopeCollectionsRead), http.StatusOK},
+		{"запись включает чтение", withScopes(httptest.NewRequest("GET", "/test", nil), auth.ScopeTasksWrite, auth.ScopeCollectionsWrite), http.StatusOK},
+		{"не хватает области", withScopes(httptest.NewRequest("GET", "/test", nil), auth.ScopeTasksRead), http.StatusForbidden},
+		{"без областей", withScopes(httptest.NewRequest("GET", "/test", nil)), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler(rr, tt.req)
+
+			if rr.Code != tt.want {
+				t.Errorf("Получено %v, ожидается %v", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionOnly(t *testing.T) {
+	handler := SessionOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/logout", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("JWT: получено %v, ожидается %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	handler(rr, withScopes(httptest.NewRequest("POST", "/logout", nil), auth.Scopes...))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Персональный токен: получено %v, ожидается %v", rr.Code, http.StatusForbidden)
+	}
+}
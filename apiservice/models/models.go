@@ -1,37 +1,415 @@
 package models
 
 import (
+	"encoding/json"
+	"regexp"
 	"time"
+	"unicode/utf8"
 )
 
 type Task struct {
-	ID           int        `json:"id"`
-	CollectionID *int       `json:"collection_id"`
+	ID               int               `json:"id"`
+	CollectionID     *int              `json:"collection_id"`
+	Name             string            `json:"name"`
+	Text             string            `json:"text"`
+	CreateTime       time.Time         `json:"create_time"`
+	Complete         bool              `json:"complete"`
+	CompleteAt       *time.Time        `json:"complete_at"`
+	DueAt            *time.Time        `json:"due_at"`
+	StartAt          *time.Time        `json:"start_at"`
+	Priority         string            `json:"priority"`
+	Recurrence       *string           `json:"recurrence"`
+	Tags             []string          `json:"tags"`
+	Progress         ChecklistProgress `json:"progress"`
+	AssigneeID       *int              `json:"assignee_id,omitempty"`
+	AssignmentStatus *string           `json:"assignment_status,omitempty"`
+	Position         int64             `json:"position"`
+}
+
+// ChecklistProgress сводка по чек-листу: отмечено done из total
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type ChecklistItem struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	Text      string    `json:"text"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AddChecklistItemRequest struct {
+	Text string `json:"text"`
+}
+
+type UpdateChecklistItemRequest struct {
+	Text *string `json:"text,omitempty"`
+	Done *bool   `json:"done,omitempty"`
+}
+
+type ReorderChecklistRequest struct {
+	ItemIDs []int `json:"item_ids"`
+}
+
+// ValidPriorities допустимые значения приоритета задачи
+var ValidPriorities = map[string]bool{
+	"none":   true,
+	"low":    true,
+	"medium": true,
+	"high":   true,
+	"urgent": true,
+}
+
+// ListOptions параметры сортировки и страницы списков задач (?sort=&order=&limit=&cursor=)
+type ListOptions struct {
+	Sort  string
+	Order string
+	PageParams
+}
+
+// MaxPageLimit верхняя граница ?limit=, совпадает с db-сервисом
+const MaxPageLimit = 200
+
+// PageParams параметры keyset-пагинации. Cursor непрозрачен для api-сервиса и
+// передаётся в db-сервис как есть. Нулевое значение — список целиком, как раньше
+type PageParams struct {
+	Limit  int
+	Cursor string
+}
+
+// Paginated сообщает, запрошена ли постраничная выдача
+func (p PageParams) Paginated() bool {
+	return p.Limit > 0 || p.Cursor != ""
+}
+
+// Page страница списка. NextCursor пуст на последней странице
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// MaxSearchLimit верхняя граница ?limit= для поиска, совпадает с db-сервисом
+const MaxSearchLimit = 100
+
+// SearchOptions фильтры поиска задач. nil — фильтр не применяется
+type SearchOptions struct {
+	CollectionID *int
+	Complete     *bool
+	Limit        int
+}
+
+// SearchResult найденная задача с релевантностью и фрагментом,
+// где совпадения обёрнуты в <mark>...</mark>
+type SearchResult struct {
+	Task
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// ValidSortFields поля, по которым db-сервис умеет сортировать. position — ручной порядок, он же по умолчанию
+var ValidSortFields = map[string]bool{
+	"position":    true,
+	"priority":    true,
+	"due_at":      true,
+	"name":        true,
+	"create_time": true,
+	"complete_at": true,
+}
+
+type CreateTaskRequest struct {
 	Name         string     `json:"name"`
 	Text         string     `json:"text"`
-	CreateTime   time.Time  `json:"create_time"`
-	Complete     bool       `json:"complete"`
-	CompleteAt   *time.Time `json:"complete_at"`
+	CollectionID *int       `json:"collection_id"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	StartAt      *time.Time `json:"start_at,omitempty"`
+	Priority     string     `json:"priority,omitempty"`
+	Recurrence   *string    `json:"recurrence,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	// ExistingTagsOnly задаётся обработчиком, а не телом: db-service не создаст недостающие теги
+	ExistingTagsOnly bool `json:"-"`
 }
 
-type CreateTaskRequest struct {
-	Name         string `json:"name"`
-	Text         string `json:"text"`
-	CollectionID *int   `json:"collection_id"`
+// Optional различает отсутствующее поле и явный null в JSON
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
+
+// UpdateTaskRequest частичное обновление: меняются только переданные поля
+type UpdateTaskRequest struct {
+	Name         *string             `json:"name,omitempty"`
+	Text         *string             `json:"text,omitempty"`
+	CollectionID Optional[int]       `json:"collection_id,omitzero"`
+	DueAt        Optional[time.Time] `json:"due_at,omitzero"`
+	StartAt      Optional[time.Time] `json:"start_at,omitzero"`
+	Priority     *string             `json:"priority,omitempty"`
+	Recurrence   Optional[string]    `json:"recurrence,omitzero"`
+	AddTags      []string            `json:"add_tags,omitempty"`
+	RemoveTags   []string            `json:"remove_tags,omitempty"`
+	// ExistingTagsOnly задаётся обработчиком, а не телом: db-service не создаст недостающие теги
+	ExistingTagsOnly bool `json:"-"`
+}
+
+// MaxMoveTasks сколько задач можно перенести одним запросом, совпадает с db-сервисом
+const MaxMoveTasks = 100
+
+// MoveTasksRequest перенос задач в коллекцию. collection_id обязателен; null — убрать из коллекции.
+// В POST /tasks/{id}/move task_ids не передаётся
+type MoveTasksRequest struct {
+	TaskIDs      []int         `json:"task_ids"`
+	CollectionID Optional[int] `json:"collection_id"`
+}
+
+// TaskMove перенесённая задача: откуда и куда. nil — без коллекции
+type TaskMove struct {
+	TaskID int  `json:"task_id"`
+	From   *int `json:"from_collection_id"`
+	To     *int `json:"to_collection_id"`
+}
+
+// MaxBulkOperations сколько операций можно передать в POST /tasks/bulk, совпадает с db-сервисом
+const MaxBulkOperations = 100
+
+// Виды пакетных операций
+const (
+	BulkComplete = "complete"
+	BulkReopen   = "reopen"
+	BulkDelete   = "delete"
+	BulkMove     = "move"
+	BulkTag      = "tag"
+)
+
+// ValidBulkOps допустимые значения op в пакетной операции
+var ValidBulkOps = map[string]bool{
+	BulkComplete: true,
+	BulkReopen:   true,
+	BulkDelete:   true,
+	BulkMove:     true,
+	BulkTag:      true,
+}
+
+// BulkOperation одна операция пакета. collection_id обязателен для move (null — убрать из коллекции),
+// add_tags/remove_tags — для tag
+type BulkOperation struct {
+	Op           string        `json:"op"`
+	TaskID       int           `json:"task_id"`
+	CollectionID Optional[int] `json:"collection_id,omitzero"`
+	AddTags      []string      `json:"add_tags,omitempty"`
+	RemoveTags   []string      `json:"remove_tags,omitempty"`
+}
+
+// BulkTasksRequest тело POST /tasks/bulk: операции выполняются по порядку в одной транзакции db-сервиса
+type BulkTasksRequest struct {
+	Operations []BulkOperation `json:"operations"`
+	// ExistingTagsOnly задаётся обработчиком, а не телом: db-service не создаст недостающие теги
+	ExistingTagsOnly bool `json:"-"`
+}
+
+// BulkResult результат одной операции пакета: status ok или error с текстом ошибки.
+// next_task — следующая задача серии после complete, move — перенос, если коллекция сменилась,
+// changes — изменение тегов после tag
+type BulkResult struct {
+	Index    int           `json:"index"`
+	Op       string        `json:"op"`
+	TaskID   int           `json:"task_id"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	NextTask *Task         `json:"next_task,omitempty"`
+	Move     *TaskMove     `json:"move,omitempty"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// Статусы результата пакетной операции
+const (
+	BulkStatusOK    = "ok"
+	BulkStatusError = "error"
+)
+
+// IsEmpty сообщает, что в запросе нет ни одного поля для обновления
+func (r *UpdateTaskRequest) IsEmpty() bool {
+	return r.Name == nil && r.Text == nil && !r.CollectionID.Set && !r.DueAt.Set && !r.StartAt.Set && r.Priority == nil &&
+		!r.Recurrence.Set && len(r.AddTags) == 0 && len(r.RemoveTags) == 0
+}
+
+// CompleteTaskResponse ответ db-service на выполнение задачи:
+// NextTask заполнен, если у задачи было правило повторения и серия продолжается
+type CompleteTaskResponse struct {
+	NextTask *Task `json:"next_task"`
+}
+
+// FieldChange одно изменённое поле задачи
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type UpdateTaskResponse struct {
+	Task    Task          `json:"task"`
+	Changes []FieldChange `json:"changes"`
 }
 
 type Collection struct {
 	ID        int       `json:"id"`
+	ParentID  *int      `json:"parent_id"`
 	Name      string    `json:"name"`
 	Color     string    `json:"color"`
 	Icon      string    `json:"icon"`
 	CreatedAt time.Time `json:"created_at"`
+	Position  int64     `json:"position"`
+	Role      string    `json:"role,omitempty"`
+}
+
+// Роли участников коллекции. owner один — создатель коллекции
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// IsValidInviteRole роль, которую владелец может выдать участнику
+func IsValidInviteRole(role string) bool {
+	return role == RoleEditor || role == RoleViewer
+}
+
+// Member участник коллекции
+type Member struct {
+	CollectionID int       `json:"collection_id"`
+	UserID       int       `json:"user_id"`
+	Username     string    `json:"username"`
+	Role         string    `json:"role"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+// AddMemberRequest приглашение пользователя в коллекцию (POST /collections/{id}/members)
+type AddMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// UpdateMemberRequest смена роли участника (PATCH /collections/{id}/members/{userId})
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// Статусы поручения задачи
+const (
+	AssignmentPending  = "pending"
+	AssignmentAccepted = "accepted"
+	AssignmentDeclined = "declined"
+)
+
+// AssignTaskRequest поручение задачи другому пользователю (POST /tasks/{id}/assign)
+type AssignTaskRequest struct {
+	Username string `json:"username"`
+}
+
+// Типы элементов корзины (?type=)
+const (
+	TrashTask       = "task"
+	TrashCollection = "collection"
+)
+
+// TrashedTask задача в корзине
+type TrashedTask struct {
+	Task
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TrashedCollection коллекция в корзине; TaskCount — задачи, удалённые вместе с ней
+type TrashedCollection struct {
+	Collection
+	DeletedAt time.Time `json:"deleted_at"`
+	TaskCount int       `json:"task_count"`
+}
+
+type Trash struct {
+	Tasks       []TrashedTask       `json:"tasks"`
+	Collections []TrashedCollection `json:"collections"`
+}
+
+// PurgeResult сколько элементов корзины стёрто окончательно
+type PurgeResult struct {
+	Tasks       int64 `json:"tasks"`
+	Collections int64 `json:"collections"`
 }
 
 type CreateCollectionRequest struct {
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+	ParentID *int   `json:"parent_id,omitempty"`
+}
+
+// MoveCollectionRequest вложение коллекции в другую (POST /collections/{id}/move). parent_id обязателен;
+// null — поднять коллекцию на верхний уровень
+type MoveCollectionRequest struct {
+	ParentID Optional[int] `json:"parent_id"`
+}
+
+// ReorderRequest перестановка задачи или коллекции (POST /tasks/{id}/reorder, /collections/{id}/reorder):
+// встать перед before или после after. Задаётся ровно одно поле
+type ReorderRequest struct {
+	Before *int `json:"before,omitempty"`
+	After  *int `json:"after,omitempty"`
+}
+
+// UpdateCollectionRequest частичное обновление коллекции (PATCH /collections/{id})
+type UpdateCollectionRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+	Icon  *string `json:"icon,omitempty"`
+}
+
+// Ограничения совпадают с колонками collections.name и collections.icon в db-сервисе
+const (
+	MaxCollectionNameLength = 100
+	MaxCollectionIconLength = 50
+)
+
+// MaxTaskNameLength совпадает с колонкой tasks.name в db-сервисе
+const MaxTaskNameLength = 255
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidColor цвет в формате #RRGGBB
+func IsValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// ValidLength непустая строка не длиннее max символов
+func ValidLength(s string, max int) bool {
+	n := utf8.RuneCountInString(s)
+	return n > 0 && n <= max
+}
+
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateTagRequest struct {
 	Name  string `json:"name"`
-	Color string `json:"color"`
-	Icon  string `json:"icon"`
+	Color string `json:"color,omitempty"`
+}
+
+type UpdateTagRequest struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
 }
 
 type User struct {
@@ -44,6 +422,8 @@ type User struct {
 type RegisterRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Email необязателен, нужен для сброса пароля
+	Email string `json:"email,omitempty"`
 }
 
 type LoginRequest struct {
@@ -51,8 +431,257 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// CreateUserRequest тело POST /user/create в db-service
+type CreateUserRequest struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Email        string `json:"email,omitempty"`
+}
+
+// AuthResponse ответ входа, регистрации и обновления токенов. token — короткоживущий access-токен,
+// expires_in — его срок жизни в секундах; refresh_token обменивается на новую пару через POST /token/refresh
 type AuthResponse struct {
-	Token    string `json:"token"`
-	Username string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Username     string `json:"username"`
+	UserID       int    `json:"user_id"`
+}
+
+// RefreshRequest тело POST /token/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CreateRefreshTokenRequest тело POST /tokens в db-service: хэш токена нового входа
+type CreateRefreshTokenRequest struct {
+	TokenHash  string `json:"token_hash"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// RotateRefreshTokenRequest тело POST /tokens/rotate в db-service
+type RotateRefreshTokenRequest struct {
+	TokenHash    string `json:"token_hash"`
+	NewTokenHash string `json:"new_token_hash"`
+	TTLSeconds   int64  `json:"ttl_seconds"`
+}
+
+// ChangePasswordRequest тело PUT /account/password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// DeleteAccountRequest тело DELETE /account: удаление подтверждается паролем
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// UpdatePasswordRequest тело PUT /account/password в db-service
+type UpdatePasswordRequest struct {
+	PasswordHash string `json:"password_hash"`
+}
+
+// UpdateEmailRequest тело PUT /account/email; пустой email удаляет почту. В db-service уходит только email
+type UpdateEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+}
+
+// ForgotPasswordRequest тело POST /password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest тело POST /password/reset: токен из письма и новый пароль
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// CreatePasswordResetRequest тело POST /password-resets в db-service
+type CreatePasswordResetRequest struct {
+	Email      string `json:"email"`
+	TokenHash  string `json:"token_hash"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// ConsumePasswordResetRequest тело POST /password-resets/consume в db-service
+type ConsumePasswordResetRequest struct {
+	TokenHash    string `json:"token_hash"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// PasswordReset ответ db-service на сброс пароля: чей пароль сброшен и с какого момента отозваны его токены
+type PasswordReset struct {
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// MFAChallengeResponse ответ POST /login для пользователя с двухфакторной аутентификацией: вместо токенов —
+// mfa_token, который вместе с кодом обменивается на пару токенов через POST /login/mfa
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// LoginMFARequest тело POST /login/mfa: код из приложения или один из кодов восстановления
+type LoginMFARequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPSetupRequest тело POST /account/2fa/setup: настройка подтверждается паролем
+type TOTPSetupRequest struct {
+	Password string `json:"password"`
+}
+
+// TOTPSetupResponse секрет для ручного ввода и ссылка otpauth:// для QR-кода
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// TOTPEnableRequest тело POST /account/2fa/enable: первый код из приложения
+type TOTPEnableRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPEnableResponse коды восстановления; показываются один раз
+type TOTPEnableResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TOTPDisableRequest тело DELETE /account/2fa: пароль и код из приложения или код восстановления
+type TOTPDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPStatus ответ GET /account/2fa
+type TOTPStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TOTP настройка двухфакторной аутентификации из db-service
+type TOTP struct {
+	Secret            string     `json:"secret"`
+	Enabled           bool       `json:"enabled"`
+	LastStep          int64      `json:"last_step"`
+	Failures          int        `json:"failures"`
+	FailedAt          *time.Time `json:"failed_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// SetupTOTPRequest тело PUT /account/totp в db-service
+type SetupTOTPRequest struct {
+	Secret string `json:"secret"`
+}
+
+// EnableTOTPRequest тело POST /account/totp/enable в db-service
+type EnableTOTPRequest struct {
+	Step               int64    `json:"step"`
+	RecoveryCodeHashes []string `json:"recovery_code_hashes"`
+}
+
+// TOTPStepRequest тело POST /account/totp/step в db-service
+type TOTPStepRequest struct {
+	Step int64 `json:"step"`
+}
+
+// RecoveryCodeRequest тело POST /account/totp/recovery в db-service
+type RecoveryCodeRequest struct {
+	CodeHash string `json:"code_hash"`
+}
+
+// RecoveryCodeResult ответ db-service на использованный код восстановления
+type RecoveryCodeResult struct {
+	RecoveryCodesLeft int `json:"recovery_codes_left"`
+}
+
+// CreateAccessTokenRequest тело POST /account/tokens: выпуск подтверждается паролем, без expires_at токен бессрочный
+type CreateAccessTokenRequest struct {
+	Password  string     `json:"password"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AccessToken персональный токен доступа без самого токена
+type AccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateAccessTokenResponse ответ POST /account/tokens; сам токен показывается один раз
+type CreateAccessTokenResponse struct {
+	AccessToken
+	Token string `json:"token"`
+}
+
+// StoreAccessTokenRequest тело POST /account/access-tokens в db-service
+type StoreAccessTokenRequest struct {
+	Name      string     `json:"name"`
+	TokenHash string     `json:"token_hash"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AuthenticateAccessTokenRequest тело POST /access-tokens/authenticate в db-service
+type AuthenticateAccessTokenRequest struct {
+	TokenHash string `json:"token_hash"`
+}
+
+// AccessTokenOwner ответ db-service на проверку персонального токена: чей он и что разрешает
+type AccessTokenOwner struct {
+	TokenID  int      `json:"token_id"`
+	UserID   int      `json:"user_id"`
+	Username string   `json:"username"`
+	Scopes   []string `json:"scopes"`
+}
+
+// LogoutRequest тело POST /logout; refresh_token необязателен, с ним отзывается и цепочка refresh-токенов
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RevokeTokenRequest тело POST /revocations в db-service
+type RevokeTokenRequest struct {
+	JTI              string    `json:"jti"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshTokenHash string    `json:"refresh_token_hash,omitempty"`
+}
+
+// RevokedToken отозванный access-токен
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserRevocation все токены пользователя с iat не позже revoked_before недействительны
+type UserRevocation struct {
+	UserID        int       `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// Revocations список действующих отзывов из db-service
+type Revocations struct {
+	Tokens []RevokedToken   `json:"tokens"`
+	Users  []UserRevocation `json:"users"`
+}
+
+// RefreshTokenReuse ответ db-service 409: чья цепочка refresh-токенов отозвана после повторного предъявления
+type RefreshTokenReuse struct {
+	Error    string `json:"error"`
 	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
 }
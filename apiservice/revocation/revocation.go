@@ -0,0 +1,134 @@
+package revocation
+
+import (
+	"apiservice/auth"
+	"apiservice/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshInterval как часто кэш перечитывает отзывы из db-service. Отзыв, сделанный через другой
+// экземпляр apiservice, начинает действовать здесь не позже чем через этот интервал
+const DefaultRefreshInterval = 30 * time.Second
+
+// Source откуда кэш берёт отзывы; since отсекает отзывы всех токенов пользователя, которые уже не нужны
+type Source interface {
+	GetRevocations(since time.Time) (*models.Revocations, error)
+}
+
+// Cache отозванные access-токены в памяти. Отзывы только добавляются и живут до истечения токенов,
+// которых касаются, поэтому обновление сливает новые записи с уже известными
+type Cache struct {
+	source Source
+	// ttl и leeway срок жизни access-токена и допуск часов, с которыми его проверяет auth.ValidateToken
+	ttl    time.Duration
+	leeway time.Duration
+
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[int]time.Time
+}
+
+func NewCache(source Source, ttl, leeway time.Duration) *Cache {
+	return &Cache{
+		source: source,
+		ttl:    ttl,
+		leeway: leeway,
+		tokens: make(map[string]time.Time),
+		users:  make(map[int]time.Time),
+	}
+}
+
+// RevokeToken отмечает токен отозванным до expiresAt, не дожидаясь обновления. nil-кэш ничего не делает
+func (c *Cache) RevokeToken(jti string, expiresAt time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[jti] = expiresAt
+}
+
+// RevokeUser отмечает отозванными все токены пользователя, выданные не позже before. nil-кэш ничего не делает
+func (c *Cache) RevokeUser(userID int, before time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if before.After(c.users[userID]) {
+		c.users[userID] = before
+	}
+}
+
+// IsRevoked сообщает, отозван ли токен по jti или выходом пользователя на всех устройствах. nil-кэш ничего не отзывает
+func (c *Cache) IsRevoked(claims *auth.Claims) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, ok := c.tokens[claims.ID]; ok {
+		return true
+	}
+	before, ok := c.users[claims.UserID]
+	if !ok {
+		return false
+	}
+	// iat выдаётся с точностью до микросекунды (см. auth), поэтому токен, выданный в ту же секунду, но после отзыва,
+	// действует, а выданный до отзыва — нет
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(before)
+}
+
+// Refresh перечитывает отзывы из source и забывает те, что касаются уже истёкших токенов
+func (c *Cache) Refresh() error {
+	now := time.Now()
+	// Токены, выданные раньше since, истекли даже с учётом допуска
+	since := now.Add(-c.ttl - c.leeway)
+
+	revocations, err := c.source.GetRevocations(since)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range revocations.Tokens {
+		c.tokens[t.JTI] = t.ExpiresAt
+	}
+	for _, u := range revocations.Users {
+		if u.RevokedBefore.After(c.users[u.UserID]) {
+			c.users[u.UserID] = u.RevokedBefore
+		}
+	}
+
+	// Истёкший токен отклонит проверка exp, запись об отзыве больше не нужна. Допуск часов учитываем,
+	// чтобы не забыть отзыв, пока ValidateToken ещё принимает токен
+	for jti, exp := range c.tokens {
+		if exp.Add(c.leeway).Before(now) {
+			delete(c.tokens, jti)
+		}
+	}
+	for userID, before := range c.users {
+		if before.Before(since) {
+			delete(c.users, userID)
+		}
+	}
+	return nil
+}
+
+// Run раз в interval обновляет кэш. Ошибка только логируется: до следующего обновления
+// действуют уже известные отзывы
+func (c *Cache) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := c.Refresh(); err != nil {
+			log.Printf("Failed to refresh token revocations: %v", err)
+		}
+	}
+}
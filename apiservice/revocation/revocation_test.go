@@ -0,0 +1,133 @@
+package revocation
+
+import (
+	"apiservice/auth"
+	"apiservice/models"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeSource отдаёт заданные отзывы и запоминает since
+type fakeSource struct {
+	revocations *models.Revocations
+	err         error
+	since       time.Time
+}
+
+func (f *fakeSource) GetRevocations(since time.Time) (*models.Revocations, error) {
+	f.since = since
+	return f.revocations, f.err
+}
+
+// claimsAt claims токена пользователя с jti, выданного в issuedAt
+func claimsAt(userID int, jti string, issuedAt time.Time) *auth.Claims {
+	return &auth.Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ Cache
+// ============================================================================
+
+func TestCacheRevokeToken(t *testing.T) {
+	cache := NewCache(&fakeSource{}, 15*time.Minute, 30*time.Second)
+	cache.RevokeToken("jti-1", time.Now().Add(time.Minute))
+
+	if !cache.IsRevoked(claimsAt(1, "jti-1", time.Now())) {
+		t.Error("Отозванный jti должен отклоняться")
+	}
+	if cache.IsRevoked(claimsAt(1, "jti-2", time.Now())) {
+		t.Error("Другой токен того же пользователя не отозван")
+	}
+}
+
+func TestCacheRevokeUser(t *testing.T) {
+	cache := NewCache(&fakeSource{}, 15*time.Minute, 30*time.Second)
+	cutoff := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+	cache.RevokeUser(1, cutoff)
+
+	tests := []struct {
+		name    string
+		claims  *auth.Claims
+		revoked bool
+	}{
+		{"выдан раньше", claimsAt(1, "a", cutoff.Add(-time.Minute)), true},
+		{"выдан в ту же секунду до отзыва", claimsAt(1, "b", cutoff.Add(-200*time.Millisecond)), true},
+		{"выдан в момент отзыва", claimsAt(1, "f", cutoff), true},
+		{"выдан в ту же секунду после отзыва", claimsAt(1, "g", cutoff.Add(200*time.Millisecond)), false},
+		{"выдан позже", claimsAt(1, "c", cutoff.Add(time.Second)), false},
+		{"другой пользователь", claimsAt(2, "d", cutoff.Add(-time.Minute)), false},
+	}
+	for _, tt := range tests {
+		if got := cache.IsRevoked(tt.claims); got != tt.revoked {
+			t.Errorf("%s: IsRevoked = %v, ожидалось %v", tt.name, got, tt.revoked)
+		}
+	}
+
+	// Более ранний отзыв не отменяет поздний
+	cache.RevokeUser(1, cutoff.Add(-time.Hour))
+	if !cache.IsRevoked(claimsAt(1, "e", cutoff.Add(-time.Minute))) {
+		t.Error("Ранний отзыв не должен сдвигать границу назад")
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	now := time.Now()
+	source := &fakeSource{revocations: &models.Revocations{
+		Tokens: []models.RevokedToken{{JTI: "remote", ExpiresAt: now.Add(time.Minute)}},
+		Users:  []models.UserRevocation{{UserID: 2, RevokedBefore: now}},
+	}}
+	cache := NewCache(source, 15*time.Minute, 30*time.Second)
+
+	// Запись, сделанная этим экземпляром, переживает обновление, даже если db-service её ещё не вернул
+	cache.RevokeToken("local", now.Add(time.Minute))
+	// Отзыв истёкшего токена забывается
+	cache.RevokeToken("expired", now.Add(-time.Hour))
+
+	if err := cache.Refresh(); err != nil {
+		t.Fatalf("Refresh() вернул ошибку: %v", err)
+	}
+
+	wantSince := now.Add(-15*time.Minute - 30*time.Second)
+	if d := source.since.Sub(wantSince); d < -time.Second || d > time.Second {
+		t.Errorf("Неправильный since: %v, ожидалось около %v", source.since, wantSince)
+	}
+	if !cache.IsRevoked(claimsAt(1, "remote", now)) || !cache.IsRevoked(claimsAt(1, "local", now)) {
+		t.Error("Отзывы из db-service и локальные должны действовать вместе")
+	}
+	if !cache.IsRevoked(claimsAt(2, "x", now.Add(-time.Minute))) {
+		t.Error("Отзыв всех токенов пользователя из db-service не применён")
+	}
+	if _, ok := cache.tokens["expired"]; ok {
+		t.Error("Отзыв истёкшего токена должен удаляться")
+	}
+}
+
+func TestCacheRefreshErrorKeepsRevocations(t *testing.T) {
+	cache := NewCache(&fakeSource{err: errors.New("connection refused")}, 15*time.Minute, 30*time.Second)
+	cache.RevokeToken("jti-1", time.Now().Add(time.Minute))
+
+	if err := cache.Refresh(); err == nil {
+		t.Error("Ожидалась ошибка обновления")
+	}
+	if !cache.IsRevoked(claimsAt(1, "jti-1", time.Now())) {
+		t.Error("При ошибке обновления известные отзывы должны сохраняться")
+	}
+}
+
+func TestNilCacheRevokeIsNoop(t *testing.T) {
+	var cache *Cache
+	cache.RevokeToken("jti-1", time.Now())
+	cache.RevokeUser(1, time.Now())
+	if cache.IsRevoked(claimsAt(1, "jti-1", time.Now())) {
+		t.Error("nil-кэш не должен считать токены отозванными")
+	}
+}
@@ -4,7 +4,10 @@ import (
     "database/sql"
     "dbservice/models"
     "encoding/json"
+    "errors"
     "net/http"
+    "strconv"
+    "time"
 
 	"github.com/gorilla/mux"
 )
@@ -37,14 +40,28 @@ func CreateUser(db *sql.DB) http.HandlerFunc{
 			return 
 		}
 
+		//Почта необязательна, но занятой быть не может
+		if req.Email != "" {
+			err = db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))`, req.Email).Scan(&exists)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if exists {
+				http.Error(w, "Email already in use", http.StatusConflict)
+				return
+			}
+		}
+
 		//Создаём пользователя
 		var user models.User
 		 err = db.QueryRow(
-            `INSERT INTO users (username, password_hash)
-			VALUES ($1, $2)
+            `INSERT INTO users (username, password_hash, email)
+			VALUES ($1, $2, NULLIF($3, ''))
 			RETURNING id, username, created_at`,
             req.Username,
             req.PasswordHash,
+            req.Email,
         ).Scan(&user.ID, &user.Username, &user.CreatedAt)
 
 		if err != nil {
@@ -123,4 +140,691 @@ func GetUserByID(db *sql.DB) http.HandlerFunc {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(user)
 	}
-}
\ No newline at end of file
+}
+// Refresh-токены
+
+// HandleCreateRefreshToken сохраняет хэш refresh-токена нового входа
+func (h *TaskHandlers) HandleCreateRefreshToken(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateRefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.TokenHash) || req.TTLSeconds <= 0 {
+		http.Error(w, `{"error": "token_hash must be a sha256 hex digest and ttl_seconds must be positive"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.CreateRefreshToken(userID, req.TokenHash, time.Duration(req.TTLSeconds)*time.Second); err != nil {
+		http.Error(w, `{"error": "Failed to store refresh token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRotateRefreshToken обменивает refresh-токен на новый и возвращает владельца.
+// 404 — токен неизвестен, отозван или истёк; 409 — токен уже обменивался, цепочка отозвана
+func (h *TaskHandlers) HandleRotateRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RotateRefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.TokenHash) || !models.ValidTokenHash(req.NewTokenHash) || req.TTLSeconds <= 0 {
+		http.Error(w, `{"error": "token_hash and new_token_hash must be sha256 hex digests and ttl_seconds must be positive"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Repo.RotateRefreshToken(req.TokenHash, req.NewTokenHash, time.Duration(req.TTLSeconds)*time.Second)
+	switch {
+	case errors.Is(err, models.ErrRefreshTokenReused):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.RefreshTokenReuse{
+			Error:    "Refresh token reuse detected",
+			UserID:   user.ID,
+			Username: user.Username,
+		})
+		return
+	case errors.Is(err, models.ErrRefreshTokenInvalid):
+		http.Error(w, `{"error": "Refresh token not found, revoked or expired"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, `{"error": "Failed to rotate refresh token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// Отзыв access-токенов
+
+// HandleRevokeToken отзывает access-токен по jti и, если передан хэш, цепочку refresh-токенов этого входа
+func (h *TaskHandlers) HandleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.RevokeTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.JTI == "" || len(req.JTI) > 64 || req.ExpiresAt.IsZero() {
+		http.Error(w, `{"error": "jti and expires_at are required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RefreshTokenHash != "" && !models.ValidTokenHash(req.RefreshTokenHash) {
+		http.Error(w, `{"error": "refresh_token_hash must be a sha256 hex digest"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.RevokeToken(userID, req.JTI, req.ExpiresAt, req.RefreshTokenHash); err != nil {
+		http.Error(w, `{"error": "Failed to revoke token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRevokeAllTokens отзывает все токены пользователя и возвращает момент отзыва
+func (h *TaskHandlers) HandleRevokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	revokedAt, err := h.Repo.RevokeAllTokens(userID)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to revoke tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserRevocation{UserID: userID, RevokedBefore: revokedAt})
+}
+
+// HandleGetRevocations список действующих отзывов для кэша apiservice. since (RFC 3339) отсекает
+// старые отзывы всех токенов пользователя; без since возвращаются все
+func (h *TaskHandlers) HandleGetRevocations(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, `{"error": "since must be an RFC 3339 timestamp"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	revocations, err := h.Repo.GetRevocations(since)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get revocations"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revocations)
+}
+
+// Учётная запись
+
+// HandleChangePassword сохраняет новый хэш пароля и отзывает все токены пользователя.
+// Старый пароль проверяет apiservice; в ответе — момент отзыва для его кэша
+func (h *TaskHandlers) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdatePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.PasswordHash == "" {
+		http.Error(w, `{"error": "password_hash is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	revokedAt, err := h.Repo.ChangePassword(userID, req.PasswordHash)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to change password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.UserRevocation{UserID: userID, RevokedBefore: revokedAt})
+}
+
+// HandleDeleteAccount удаляет пользователя вместе со всеми его данными
+func (h *TaskHandlers) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteUser(userID)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete account"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Сброс пароля
+
+// HandleCreatePasswordReset сохраняет хэш токена сброса для владельца почты и возвращает его, чтобы apiservice
+// отправил письмо. 404 — почта не зарегистрирована
+func (h *TaskHandlers) HandleCreatePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" || !models.ValidTokenHash(req.TokenHash) || req.TTLSeconds <= 0 {
+		http.Error(w, `{"error": "email is required, token_hash must be a sha256 hex digest and ttl_seconds must be positive"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Repo.CreatePasswordResetToken(req.Email, req.TokenHash, time.Duration(req.TTLSeconds)*time.Second)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to store password reset token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// HandleResetPassword гасит токен сброса и сохраняет новый хэш пароля.
+// 404 — токен неизвестен, уже использован или истёк
+func (h *TaskHandlers) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.TokenHash) || req.PasswordHash == "" {
+		http.Error(w, `{"error": "token_hash must be a sha256 hex digest and password_hash is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	reset, err := h.Repo.ResetPassword(req.TokenHash, req.PasswordHash)
+	if errors.Is(err, models.ErrResetTokenInvalid) {
+		http.Error(w, `{"error": "Password reset token not found, used or expired"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reset password"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reset)
+}
+
+// HandleUpdateEmail меняет почту пользователя; пустая строка её удаляет. 409 — почта занята
+func (h *TaskHandlers) HandleUpdateEmail(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.UpdateEmail(userID, req.Email)
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrEmailTaken):
+		http.Error(w, `{"error": "Email already in use"}`, http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, `{"error": "Failed to update email"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Двухфакторная аутентификация
+
+// HandleGetTOTP возвращает настройку TOTP пользователя. 404 — настройки нет
+func (h *TaskHandlers) HandleGetTOTP(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	totp, err := h.Repo.GetTOTP(userID)
+	if errors.Is(err, models.ErrTOTPNotFound) {
+		http.Error(w, `{"error": "Two-factor authentication is not set up"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(totp)
+}
+
+// HandleSetupTOTP сохраняет секрет неподтверждённой настройки. 409 — двухфакторная аутентификация уже включена
+func (h *TaskHandlers) HandleSetupTOTP(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.SetupTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Secret == "" || len(req.Secret) > 64 {
+		http.Error(w, `{"error": "secret is required and must be at most 64 characters"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.SetupTOTP(userID, req.Secret)
+	if errors.Is(err, models.ErrTOTPEnabled) {
+		http.Error(w, `{"error": "Two-factor authentication is already enabled"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to set up two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleEnableTOTP подтверждает настройку и сохраняет хэши кодов восстановления. 404 — нет неподтверждённой настройки
+func (h *TaskHandlers) HandleEnableTOTP(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.EnableTOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Step <= 0 || len(req.RecoveryCodeHashes) == 0 {
+		http.Error(w, `{"error": "step and recovery_code_hashes are required"}`, http.StatusBadRequest)
+		return
+	}
+	for _, hash := range req.RecoveryCodeHashes {
+		if !models.ValidTokenHash(hash) {
+			http.Error(w, `{"error": "recovery_code_hashes must be sha256 hex digests"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = h.Repo.EnableTOTP(userID, req.Step, req.RecoveryCodeHashes)
+	if errors.Is(err, models.ErrTOTPNotFound) {
+		http.Error(w, `{"error": "No pending two-factor authentication setup"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to enable two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUseTOTPStep принимает код шага step при входе. 409 — код этого или более позднего шага уже принят
+func (h *TaskHandlers) HandleUseTOTPStep(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.TOTPStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Step <= 0 {
+		http.Error(w, `{"error": "step is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.UseTOTPStep(userID, req.Step)
+	if errors.Is(err, models.ErrTOTPStepUsed) {
+		http.Error(w, `{"error": "Code already used"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to use code"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRecordTOTPFailure засчитывает неверный код
+func (h *TaskHandlers) HandleRecordTOTPFailure(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.RecordTOTPFailure(userID)
+	if errors.Is(err, models.ErrTOTPNotFound) {
+		http.Error(w, `{"error": "Two-factor authentication is not set up"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to record failure"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUseRecoveryCode гасит код восстановления. 404 — код неизвестен или уже использован
+func (h *TaskHandlers) HandleUseRecoveryCode(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.RecoveryCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.CodeHash) {
+		http.Error(w, `{"error": "code_hash must be a sha256 hex digest"}`, http.StatusBadRequest)
+		return
+	}
+
+	left, err := h.Repo.UseRecoveryCode(userID, req.CodeHash)
+	if errors.Is(err, models.ErrRecoveryCodeInvalid) {
+		http.Error(w, `{"error": "Recovery code not found or already used"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to use recovery code"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodeResult{RecoveryCodesLeft: left})
+}
+
+// HandleDisableTOTP выключает двухфакторную аутентификацию и удаляет коды восстановления
+func (h *TaskHandlers) HandleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DisableTOTP(userID)
+	if errors.Is(err, models.ErrTOTPNotFound) {
+		http.Error(w, `{"error": "Two-factor authentication is not set up"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to disable two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Персональные токены доступа
+
+// HandleListAccessTokens возвращает токены пользователя без их хэшей
+func (h *TaskHandlers) HandleListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.Repo.ListAccessTokens(userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get access tokens"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// HandleCreateAccessToken сохраняет хэш нового токена. 409 — у пользователя уже есть токен с таким именем
+func (h *TaskHandlers) HandleCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Name) > models.MaxAccessTokenNameLength || len(req.Scopes) == 0 {
+		http.Error(w, `{"error": "name is required and must be at most 100 characters, scopes are required"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.TokenHash) {
+		http.Error(w, `{"error": "token_hash must be a sha256 hex digest"}`, http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.Repo.CreateAccessToken(userID, &req)
+	if errors.Is(err, models.ErrAccessTokenNameTaken) {
+		http.Error(w, `{"error": "Access token name already in use"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to create access token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+// HandleDeleteAccessToken отзывает токен пользователя. 404 — токена нет или он чужой
+func (h *TaskHandlers) HandleDeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid access token ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteAccessToken(id, userID)
+	if errors.Is(err, models.ErrAccessTokenNotFound) {
+		http.Error(w, `{"error": "Access token not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete access token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAuthenticateAccessToken возвращает владельца и области доступа токена по его хэшу.
+// 404 — токен неизвестен, отозван или истёк
+func (h *TaskHandlers) HandleAuthenticateAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req models.AuthenticateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidTokenHash(req.TokenHash) {
+		http.Error(w, `{"error": "token_hash must be a sha256 hex digest"}`, http.StatusBadRequest)
+		return
+	}
+
+	owner, err := h.Repo.AuthenticateAccessToken(req.TokenHash)
+	if errors.Is(err, models.ErrAccessTokenInvalid) {
+		http.Error(w, `{"error": "Access token not found or expired"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to authenticate access token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owner)
+}
@@ -7,11 +7,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 // ============================================================================
@@ -33,7 +35,7 @@ func TestCreateUserSuccess(t *testing.T) {
 
 	now := time.Now()
 	mock.ExpectQuery(`INSERT INTO users`).
-		WithArgs("testuser", "hash123").
+		WithArgs("testuser", "hash123", "").
 		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}).
 			AddRow(1, "testuser", now))
 
@@ -78,6 +80,67 @@ func TestCreateUserInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestCreateUserWithEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	handler := CreateUser(db)
+
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE username = \$1\)`).
+		WithArgs("testuser").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM users WHERE LOWER\(email\) = LOWER\(\$1\)\)`).
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`INSERT INTO users \(username, password_hash, email\)\s+VALUES \(\$1, \$2, NULLIF\(\$3, ''\)\)`).
+		WithArgs("testuser", "hash123", "user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}).
+			AddRow(1, "testuser", time.Now()))
+
+	body := `{"username":"testuser","password_hash":"hash123","email":"user@example.com"}`
+	req := httptest.NewRequest("POST", "/user/create", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handler(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Ожидался код 201, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCreateUserEmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	handler := CreateUser(db)
+
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("testuser").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs("User@Example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	body := `{"username":"testuser","password_hash":"hash123","email":"User@Example.com"}`
+	req := httptest.NewRequest("POST", "/user/create", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handler(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+}
+
 func TestCreateUserEmptyUsername(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -398,3 +461,929 @@ func TestGetUserByIDDBError(t *testing.T) {
 		t.Errorf("Ожидался код 500, получен %d", rr.Code)
 	}
 }
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ refresh-токенов
+// ============================================================================
+
+func TestHandleCreateRefreshToken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	mock.ExpectExec(`INSERT INTO refresh_tokens`).
+		WithArgs(1, hash, int64(60)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	body := `{"token_hash":"` + hash + `","ttl_seconds":60}`
+	req := httptest.NewRequest("POST", "/tokens?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreateRefreshToken(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleCreateRefreshTokenInvalid(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	for _, tt := range []struct{ url, body string }{
+		{"/tokens", `{"token_hash":"` + hash + `","ttl_seconds":60}`},
+		{"/tokens?user_id=1", `{"token_hash":"plain-token","ttl_seconds":60}`},
+		{"/tokens?user_id=1", `{"token_hash":"` + hash + `","ttl_seconds":0}`},
+	} {
+		req := httptest.NewRequest("POST", tt.url, bytes.NewBufferString(tt.body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleCreateRefreshToken(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: ожидался код 400, получен %d", tt.url, tt.body, rr.Code)
+		}
+	}
+}
+
+func TestHandleRotateRefreshToken(t *testing.T) {
+	oldHash, newHash := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	body := `{"token_hash":"` + oldHash + `","new_token_hash":"` + newHash + `","ttl_seconds":60}`
+	rows := func(used bool) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "family_id", "used", "revoked", "expired", "user_id", "username", "created_at"}).
+			AddRow(10, "family-1", used, false, false, 1, "alice", time.Now())
+	}
+
+	t.Run("обмен", func(t *testing.T) {
+		repo, mock, db := setupMockRepo(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).WithArgs(oldHash).WillReturnRows(rows(false))
+		mock.ExpectExec(`UPDATE refresh_tokens SET used_at`).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`INSERT INTO refresh_tokens`).WithArgs(1, "family-1", newHash, int64(60)).WillReturnResult(sqlmock.NewResult(11, 1))
+		mock.ExpectCommit()
+
+		rr := httptest.NewRecorder()
+		NewTaskHandlers(repo).HandleRotateRefreshToken(rr, httptest.NewRequest("POST", "/tokens/rotate", bytes.NewBufferString(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+		}
+		var user models.User
+		if err := json.NewDecoder(rr.Body).Decode(&user); err != nil || user.ID != 1 {
+			t.Errorf("Неправильный ответ: %+v, %v", user, err)
+		}
+	})
+
+	t.Run("повторное использование", func(t *testing.T) {
+		repo, mock, db := setupMockRepo(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).WithArgs(oldHash).WillReturnRows(rows(true))
+		mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at`).WithArgs("family-1").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		rr := httptest.NewRecorder()
+		NewTaskHandlers(repo).HandleRotateRefreshToken(rr, httptest.NewRequest("POST", "/tokens/rotate", bytes.NewBufferString(body)))
+
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("Ожидался код 409, получен %d: %s", rr.Code, rr.Body.String())
+		}
+		var reuse models.RefreshTokenReuse
+		if err := json.NewDecoder(rr.Body).Decode(&reuse); err != nil || reuse.UserID != 1 || reuse.Username != "alice" {
+			t.Errorf("Неправильный ответ: %+v, %v", reuse, err)
+		}
+	})
+
+	t.Run("неизвестный токен", func(t *testing.T) {
+		repo, mock, db := setupMockRepo(t)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).WithArgs(oldHash).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		rr := httptest.NewRecorder()
+		NewTaskHandlers(repo).HandleRotateRefreshToken(rr, httptest.NewRequest("POST", "/tokens/rotate", bytes.NewBufferString(body)))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Ожидался код 404, получен %d", rr.Code)
+		}
+	})
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ отзыва токенов
+// ============================================================================
+
+func TestHandleRevokeToken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_tokens`).
+		WithArgs("jti-1", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"jti":"jti-1","expires_at":"2030-01-01T00:00:00Z"}`
+	req := httptest.NewRequest("POST", "/revocations?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRevokeToken(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleRevokeTokenInvalid(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{
+		`{"expires_at":"2030-01-01T00:00:00Z"}`,
+		`{"jti":"jti-1"}`,
+		`{"jti":"jti-1","expires_at":"2030-01-01T00:00:00Z","refresh_token_hash":"plain"}`,
+	} {
+		req := httptest.NewRequest("POST", "/revocations?user_id=1", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleRevokeToken(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleRevokeAllTokens(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET tokens_revoked_at`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/revocations/all?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRevokeAllTokens(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var revocation models.UserRevocation
+	if err := json.NewDecoder(rr.Body).Decode(&revocation); err != nil || !revocation.RevokedBefore.Equal(now) {
+		t.Errorf("Неправильный ответ: %+v, %v", revocation, err)
+	}
+}
+
+func TestHandleGetRevocations(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	since := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT jti, expires_at FROM revoked_tokens`).
+		WillReturnRows(sqlmock.NewRows([]string{"jti", "expires_at"}))
+	mock.ExpectQuery(`SELECT id, tokens_revoked_at FROM users`).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tokens_revoked_at"}))
+
+	req := httptest.NewRequest("GET", "/revocations?since=2025-03-01T12:00:00Z", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetRevocations(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	// Пустые списки отдаются массивами, а не null
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"tokens":[],"users":[]}` {
+		t.Errorf("Неправильный ответ: %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/revocations?since=yesterday", nil)
+	rr = httptest.NewRecorder()
+	handlers.HandleGetRevocations(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400 для неверного since, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleChangePassword и HandleDeleteAccount
+// ============================================================================
+
+func TestHandleChangePassword(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET password_hash`).
+		WithArgs(1, "new-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("PUT", "/account/password?user_id=1", bytes.NewBufferString(`{"password_hash":"new-hash"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleChangePassword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var revocation models.UserRevocation
+	if err := json.NewDecoder(rr.Body).Decode(&revocation); err != nil || !revocation.RevokedBefore.Equal(now) {
+		t.Errorf("Неправильный ответ: %+v, %v", revocation, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleChangePasswordInvalid(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	tests := []struct {
+		url  string
+		body string
+	}{
+		{"/account/password", `{"password_hash":"new-hash"}`},
+		{"/account/password?user_id=abc", `{"password_hash":"new-hash"}`},
+		{"/account/password?user_id=1", `{"password_hash":""}`},
+		{"/account/password?user_id=1", `{`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", tt.url, bytes.NewBufferString(tt.body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleChangePassword(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: ожидался код 400, получен %d", tt.url, tt.body, rr.Code)
+		}
+	}
+}
+
+func TestHandleDeleteAccount(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("DELETE", "/account?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDeleteAccount(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleDeleteAccountNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(99).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("DELETE", "/account?user_id=99", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDeleteAccount(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ сброса пароля и HandleUpdateEmail
+// ============================================================================
+
+func TestHandleCreatePasswordReset(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, username, created_at FROM users`).
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}).AddRow(1, "testuser", time.Now()))
+	mock.ExpectExec(`DELETE FROM password_reset_tokens`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO password_reset_tokens`).
+		WithArgs(1, hash, int64(3600)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"email":"user@example.com","token_hash":"` + hash + `","ttl_seconds":3600}`
+	req := httptest.NewRequest("POST", "/password-resets", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreatePasswordReset(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Ожидался код 201, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var user models.User
+	if err := json.NewDecoder(rr.Body).Decode(&user); err != nil || user.ID != 1 || user.Username != "testuser" {
+		t.Errorf("Неправильный ответ: %+v, %v", user, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleCreatePasswordResetUnknownEmail(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, username, created_at FROM users`).
+		WithArgs("nobody@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}))
+	mock.ExpectRollback()
+
+	body := `{"email":"nobody@example.com","token_hash":"` + strings.Repeat("a", 64) + `","ttl_seconds":3600}`
+	req := httptest.NewRequest("POST", "/password-resets", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreatePasswordReset(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleResetPassword(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens t SET used_at = NOW\(\)`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "testuser"))
+	mock.ExpectQuery(`UPDATE users SET password_hash`).
+		WithArgs(1, "new-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"token_hash":"` + hash + `","password_hash":"new-hash"}`
+	req := httptest.NewRequest("POST", "/password-resets/consume", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleResetPassword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var reset models.PasswordReset
+	if err := json.NewDecoder(rr.Body).Decode(&reset); err != nil || reset.UserID != 1 || !reset.RevokedBefore.Equal(now) {
+		t.Errorf("Неправильный ответ: %+v, %v", reset, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleResetPasswordInvalid(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	for _, body := range []string{
+		`{"token_hash":"plain","password_hash":"new-hash"}`,
+		`{"token_hash":"` + hash + `"}`,
+	} {
+		req := httptest.NewRequest("POST", "/password-resets/consume", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleResetPassword(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+
+	// Использованный или истёкший токен
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/password-resets/consume", bytes.NewBufferString(`{"token_hash":"`+hash+`","password_hash":"new-hash"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleResetPassword(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUpdateEmail(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET email = NULLIF\(\$2, ''\) WHERE id = \$1`).
+		WithArgs(1, "user@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM password_reset_tokens`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("PUT", "/account/email?user_id=1", bytes.NewBufferString(`{"email":"user@example.com"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateEmail(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleUpdateEmailTaken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET email`).
+		WithArgs(1, "taken@example.com").
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PUT", "/account/email?user_id=1", bytes.NewBufferString(`{"email":"taken@example.com"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateEmail(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ двухфакторной аутентификации
+// ============================================================================
+
+func TestHandleGetTOTP(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`FROM user_totp t`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled", "last_step", "failures", "failed_at", "left"}).
+			AddRow("JBSWY3DPEHPK3PXP", true, int64(100), 0, nil, 10))
+
+	req := httptest.NewRequest("GET", "/account/totp?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTOTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var totp models.TOTP
+	if err := json.NewDecoder(rr.Body).Decode(&totp); err != nil || !totp.Enabled || totp.RecoveryCodesLeft != 10 {
+		t.Errorf("Неправильный ответ: %+v, %v", totp, err)
+	}
+
+	mock.ExpectQuery(`FROM user_totp t`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled", "last_step", "failures", "failed_at", "left"}))
+
+	req = httptest.NewRequest("GET", "/account/totp?user_id=2", nil)
+	rr = httptest.NewRecorder()
+
+	handlers.HandleGetTOTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleSetupTOTPConflict(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`INSERT INTO user_totp`).
+		WithArgs(1, "JBSWY3DPEHPK3PXP").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("PUT", "/account/totp?user_id=1", bytes.NewBufferString(`{"secret":"JBSWY3DPEHPK3PXP"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleSetupTOTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+}
+
+func TestHandleEnableTOTP(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	// Коды восстановления должны быть хэшами, а не самими кодами
+	req := httptest.NewRequest("POST", "/account/totp/enable?user_id=1", bytes.NewBufferString(`{"step":100,"recovery_code_hashes":["abcd-efgh"]}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleEnableTOTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE user_totp SET enabled_at = NOW\(\)`).
+		WithArgs(1, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO recovery_codes`).
+		WithArgs(1, pq.Array([]string{hash})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req = httptest.NewRequest("POST", "/account/totp/enable?user_id=1", bytes.NewBufferString(`{"step":100,"recovery_code_hashes":["`+hash+`"]}`))
+	rr = httptest.NewRecorder()
+
+	handlers.HandleEnableTOTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleUseTOTPStepReplay(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`UPDATE user_totp SET last_step = \$2`).
+		WithArgs(1, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("POST", "/account/totp/step?user_id=1", bytes.NewBufferString(`{"step":100}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUseTOTPStep(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUseRecoveryCode(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE recovery_codes SET used_at = NOW\(\)`).
+		WithArgs(1, hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE user_totp SET failures = 0`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recovery_codes`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/account/totp/recovery?user_id=1", bytes.NewBufferString(`{"code_hash":"`+hash+`"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUseRecoveryCode(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var result models.RecoveryCodeResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil || result.RecoveryCodesLeft != 4 {
+		t.Errorf("Неправильный ответ: %+v, %v", result, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE recovery_codes SET used_at = NOW\(\)`).
+		WithArgs(1, hash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	req = httptest.NewRequest("POST", "/account/totp/recovery?user_id=1", bytes.NewBufferString(`{"code_hash":"`+hash+`"}`))
+	rr = httptest.NewRecorder()
+
+	handlers.HandleUseRecoveryCode(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleDisableTOTP(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM user_totp`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("DELETE", "/account/totp?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDisableTOTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ персональных токенов доступа
+// ============================================================================
+
+func TestHandleCreateAccessToken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+	now := time.Now()
+
+	mock.ExpectQuery(`INSERT INTO personal_access_tokens`).
+		WithArgs(1, "ci", hash, pq.Array([]string{"tasks:read"}), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at"}).
+			AddRow(7, "ci", "{tasks:read}", now, nil))
+
+	body := `{"name":"ci","token_hash":"` + hash + `","scopes":["tasks:read"]}`
+	req := httptest.NewRequest("POST", "/account/access-tokens?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreateAccessToken(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Ожидался код 201, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var token models.AccessToken
+	if err := json.NewDecoder(rr.Body).Decode(&token); err != nil || token.ID != 7 || token.ExpiresAt != nil {
+		t.Errorf("Неправильный ответ: %+v, %v", token, err)
+	}
+
+	mock.ExpectQuery(`INSERT INTO personal_access_tokens`).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	req = httptest.NewRequest("POST", "/account/access-tokens?user_id=1", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+
+	handlers.HandleCreateAccessToken(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleCreateAccessTokenValidation(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{"нет user_id", "/account/access-tokens", `{"name":"ci","token_hash":"` + hash + `","scopes":["tasks:read"]}`},
+		{"нет имени", "/account/access-tokens?user_id=1", `{"token_hash":"` + hash + `","scopes":["tasks:read"]}`},
+		{"длинное имя", "/account/access-tokens?user_id=1", `{"name":"` + strings.Repeat("x", 101) + `","token_hash":"` + hash + `","scopes":["tasks:read"]}`},
+		{"нет областей", "/account/access-tokens?user_id=1", `{"name":"ci","token_hash":"` + hash + `","scopes":[]}`},
+		{"не хэш", "/account/access-tokens?user_id=1", `{"name":"ci","token_hash":"todo_pat_secret","scopes":["tasks:read"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+
+			handlers.HandleCreateAccessToken(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Ожидался код 400, получен %d", rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleListAccessTokens(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	now := time.Now()
+
+	mock.ExpectQuery(`FROM personal_access_tokens`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at"}).
+			AddRow(7, "ci", "{tasks:read}", now, nil, now))
+
+	req := httptest.NewRequest("GET", "/account/access-tokens?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleListAccessTokens(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "hash") {
+		t.Errorf("В ответе не должно быть хэша токена: %s", rr.Body.String())
+	}
+	var tokens []models.AccessToken
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil || len(tokens) != 1 || tokens[0].Name != "ci" {
+		t.Errorf("Неправильный ответ: %+v, %v", tokens, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleDeleteAccessToken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`DELETE FROM personal_access_tokens`).
+		WithArgs(7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("DELETE", "/account/access-tokens/7?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "7"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDeleteAccessToken(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Ожидался код 204, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	mock.ExpectExec(`DELETE FROM personal_access_tokens`).
+		WithArgs(8, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req = httptest.NewRequest("DELETE", "/account/access-tokens/8?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "8"})
+	rr = httptest.NewRecorder()
+
+	handlers.HandleDeleteAccessToken(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleAuthenticateAccessToken(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	hash := strings.Repeat("a", 64)
+
+	mock.ExpectQuery(`UPDATE personal_access_tokens t SET last_used_at`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "scopes"}).
+			AddRow(7, 1, "testuser", "{tasks:read,tags:write}"))
+
+	req := httptest.NewRequest("POST", "/access-tokens/authenticate", bytes.NewBufferString(`{"token_hash":"`+hash+`"}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAuthenticateAccessToken(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	var owner models.AccessTokenOwner
+	if err := json.NewDecoder(rr.Body).Decode(&owner); err != nil || owner.UserID != 1 || len(owner.Scopes) != 2 {
+		t.Errorf("Неправильный ответ: %+v, %v", owner, err)
+	}
+
+	mock.ExpectQuery(`UPDATE personal_access_tokens t SET last_used_at`).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "scopes"}))
+
+	req = httptest.NewRequest("POST", "/access-tokens/authenticate", bytes.NewBufferString(`{"token_hash":"`+hash+`"}`))
+	rr = httptest.NewRecorder()
+
+	handlers.HandleAuthenticateAccessToken(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -3,12 +3,113 @@ package handlers
 import (
 	"dbservice/models"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 )
 
+// taskSortWhitelist допустимые значения параметра sort для списков задач
+var taskSortWhitelist = map[string]bool{
+	models.SortPosition:   true,
+	models.SortPriority:   true,
+	models.SortDueAt:      true,
+	models.SortName:       true,
+	models.SortCreateTime: true,
+	models.SortCompleteAt: true,
+}
+
+// parseListOptions читает sort/order и параметры страницы, проверяет sort по белому списку.
+// Ручной порядок без явного order идёт по возрастанию, остальные поля — в порядке defaultOrder
+func parseListOptions(r *http.Request, defaultSort, defaultOrder string) (models.ListOptions, error) {
+	opts := models.ListOptions{Sort: defaultSort, Order: defaultOrder}
+
+	sort := r.URL.Query().Get("sort")
+	if sort != "" {
+		if !taskSortWhitelist[sort] {
+			return opts, fmt.Errorf("unsupported sort field %q", sort)
+		}
+		opts.Sort = sort
+	}
+	if opts.Sort == models.SortPosition {
+		opts.Order = "asc"
+	}
+
+	if order := r.URL.Query().Get("order"); order != "" {
+		if order != "asc" && order != "desc" {
+			return opts, fmt.Errorf("order must be asc or desc")
+		}
+		opts.Order = order
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		return opts, err
+	}
+	if page.Paginated() && sort != "" && sort != models.SortCreateTime && sort != models.SortPosition {
+		return opts, fmt.Errorf("pagination supports only sort=position or sort=create_time")
+	}
+	if err := page.CheckSort(opts.Sort == models.SortPosition); err != nil {
+		return opts, err
+	}
+	opts.PageParams = page
+
+	return opts, nil
+}
+
+// parsePage читает limit/cursor. Без обоих параметров пагинация выключена и список
+// отдаётся целиком голым массивом, как раньше — на это рассчитывает текущий фронтенд
+func parsePage(r *http.Request) (models.PageParams, error) {
+	var page models.PageParams
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > models.MaxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", models.MaxPageLimit)
+		}
+		page.Limit = n
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := models.DecodeCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+		if page.Limit == 0 {
+			page.Limit = models.DefaultPageLimit
+		}
+	}
+
+	return page, nil
+}
+
+// writeList отдаёт список: при пагинации конвертом {items, next_cursor}, иначе голым массивом
+func writeList[T any](w http.ResponseWriter, items []T, page models.PageParams, cursor func(T) models.Cursor) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if !page.Paginated() {
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+	json.NewEncoder(w).Encode(models.NewPage(items, page.Limit, cursor))
+}
+
+// existingTagsOnly читает ?existing_tags_only=: apiservice ставит true, когда вызывающему нельзя создавать теги.
+// Без параметра недостающие теги создаются, как раньше
+func existingTagsOnly(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("existing_tags_only")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
 type TaskHandlers struct {
 	Repo *models.TaskRepository
 }
@@ -30,10 +131,21 @@ func (h *TaskHandlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existingOnly, err := existingTagsOnly(r)
+	if err != nil {
+		http.Error(w, `{"error": "existing_tags_only must be true or false"}`, http.StatusBadRequest)
+		return
+	}
+
 	var task struct {
-		Name         string `json:"name"`
-		Text         string `json:"text"`
-		CollectionID *int   `json:"collection_id"`
+		Name         string     `json:"name"`
+		Text         string     `json:"text"`
+		CollectionID *int       `json:"collection_id"`
+		DueAt        *time.Time `json:"due_at"`
+		StartAt      *time.Time `json:"start_at"`
+		Priority     string     `json:"priority"`
+		Recurrence   *string    `json:"recurrence"`
+		Tags         []string   `json:"tags"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
@@ -46,14 +158,57 @@ func (h *TaskHandlers) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if task.StartAt != nil && task.DueAt != nil && task.StartAt.After(*task.DueAt) {
+		http.Error(w, `{"error": "start_at must not be after due_at"}`, http.StatusBadRequest)
+		return
+	}
+
+	if task.Priority == "" {
+		task.Priority = models.PriorityNone
+	}
+	if !models.IsValidPriority(task.Priority) {
+		http.Error(w, `{"error": "Invalid priority"}`, http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.NormalizeTagNames(task.Tags)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+		return
+	}
+
+	recurrence, err := normalizeRecurrence(task.Recurrence)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid recurrence rule"}`, http.StatusBadRequest)
+		return
+	}
+
 	taskToCreate := &models.Task{
 		UserID:       userID,
 		Name:         task.Name,
 		Text:         task.Text,
 		CollectionID: task.CollectionID,
+		DueAt:        task.DueAt,
+		StartAt:      task.StartAt,
+		Priority:     task.Priority,
+		Recurrence:   recurrence,
+		Tags:         tags,
 	}
 
-	if err := h.Repo.CreateTask(taskToCreate); err != nil {
+	err = h.Repo.CreateTask(taskToCreate, existingOnly)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Not allowed for this collection role"}`, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrTagCreationDenied) {
+		http.Error(w, `{"error": "Creating tags is not allowed"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
 		http.Error(w, `{"error": "Failed to create task"}`, http.StatusInternalServerError)
 		return
 	}
@@ -76,16 +231,20 @@ func (h *TaskHandlers) HandleGetAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := h.Repo.GetAllTasksByUser(userID)
+	opts, err := parseListOptions(r, models.SortCreateTime, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetAllTasksByUser(userID, opts)
 
 	if err != nil {
 		http.Error(w, `{"error": "Failed to get tasks"}`, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
 }
 
 func (h *TaskHandlers) HandleGetCompleted(w http.ResponseWriter, r *http.Request) {
@@ -101,16 +260,20 @@ func (h *TaskHandlers) HandleGetCompleted(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tasks, err := h.Repo.GetCompletedTasksByUser(userID)
+	opts, err := parseListOptions(r, models.SortCreateTime, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetCompletedTasksByUser(userID, opts)
 
 	if err != nil {
 		http.Error(w, `{"error": "Failed to get tasks"}`, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
 }
 
 func (h *TaskHandlers) HandleGetUncompleted(w http.ResponseWriter, r *http.Request) {
@@ -126,28 +289,103 @@ func (h *TaskHandlers) HandleGetUncompleted(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	tasks, err := h.Repo.GetUncompletedTasksByUser(userID)
+	opts, err := parseListOptions(r, models.SortCreateTime, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetUncompletedTasksByUser(userID, opts)
 
 	if err != nil {
 		http.Error(w, `{"error": "Failed to get tasks"}`, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
+}
+
+// HandleGetByDue отдаёт задачи по сроку: due=today|week|overdue, tz — IANA-зона для границ дня
+func (h *TaskHandlers) HandleGetByDue(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid tz"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts, err := parseListOptions(r, models.SortDueAt, "asc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var tasks []models.Task
+	switch r.URL.Query().Get("due") {
+	case "today":
+		tasks, err = h.Repo.GetTasksDueBetween(userID, startOfDay, startOfDay.AddDate(0, 0, 1), opts)
+	case "week":
+		tasks, err = h.Repo.GetTasksDueBetween(userID, startOfDay, startOfDay.AddDate(0, 0, 7), opts)
+	case "overdue":
+		tasks, err = h.Repo.GetOverdueTasksByUser(userID, now, opts)
+	default:
+		http.Error(w, `{"error": "due must be one of today, week, overdue"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get tasks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
 }
 
 func (h *TaskHandlers) HandleGetByID(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)["id"]
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
 
-	id, err := strconv.Atoi(vars)
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	task, _ := h.Repo.GetTaskByID(id)
+	task, err := h.Repo.GetTaskByUser(id, userID)
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch task"}`, http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -155,16 +393,35 @@ func (h *TaskHandlers) HandleGetByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *TaskHandlers) HandleGetByName(w http.ResponseWriter, r *http.Request) {
-	name := mux.Vars(r)["name"]
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
 
-	task, _ := h.Repo.GetIDByName(name)
+	task, err := h.Repo.GetTaskByNameByUser(mux.Vars(r)["name"], userID)
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch task"}`, http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(task)
 }
 
-func (h *TaskHandlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
+// HandleSearch: /search?q=...[&collection_id=3][&complete=true|false][&limit=20]
+func (h *TaskHandlers) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -177,24 +434,50 @@ func (h *TaskHandlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)["id"]
+	var opts models.SearchOptions
 
-	id, err := strconv.Atoi(vars)
-	if err != nil {
-		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
-		return
+	if v := r.URL.Query().Get("collection_id"); v != "" {
+		collectionID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, `{"error": "Invalid collection_id"}`, http.StatusBadRequest)
+			return
+		}
+		opts.CollectionID = &collectionID
 	}
 
-	err = h.Repo.DeleteTaskByUser(id, userID)
+	if v := r.URL.Query().Get("complete"); v != "" {
+		complete, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, `{"error": "complete must be true or false"}`, http.StatusBadRequest)
+			return
+		}
+		opts.Complete = &complete
+	}
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit < 1 || opts.Limit > models.MaxSearchLimit {
+			http.Error(w, `{"error": "Invalid limit"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := h.Repo.SearchTasks(userID, r.URL.Query().Get("q"), opts)
+	if errors.Is(err, models.ErrEmptySearchQuery) {
+		http.Error(w, `{"error": "q must contain at least one word"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, `{"error": "Failed to delete task"}`, http.StatusForbidden)
+		http.Error(w, `{"error": "Failed to search tasks"}`, http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
 }
 
-func (h *TaskHandlers) HandleComplete(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -215,18 +498,16 @@ func (h *TaskHandlers) HandleComplete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.Repo.CompleteTaskByUser(id, userID)
+	err = h.Repo.DeleteTaskByUser(id, userID)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to complete task"}`, http.StatusForbidden)
+		http.Error(w, `{"error": "Failed to delete task"}`, http.StatusForbidden)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// Collection handlers
-
-func (h *TaskHandlers) HandleCreateCollection(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandlers) HandleComplete(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -239,36 +520,42 @@ func (h *TaskHandlers) HandleCreateCollection(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	var collection models.Collection
-	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
-		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
-		return
-	}
+	vars := mux.Vars(r)["id"]
 
-	if collection.Name == "" {
-		http.Error(w, `{"error": "Collection name is required"}`, http.StatusBadRequest)
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	if collection.Color == "" {
-		collection.Color = "#2564cf"
-	}
-	if collection.Icon == "" {
-		collection.Icon = "📁"
-	}
-
-	collection.UserID = userID
-
-	if err := h.Repo.CreateCollection(&collection); err != nil {
-		http.Error(w, `{"error": "Failed to create collection"}`, http.StatusInternalServerError)
+	next, err := h.Repo.CompleteTaskByUser(id, userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to complete task"}`, http.StatusForbidden)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collection)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"next_task": next,
+	})
 }
 
-func (h *TaskHandlers) HandleGetCollections(w http.ResponseWriter, r *http.Request) {
+// normalizeRecurrence проверяет правило повторения и приводит его к каноничному виду.
+// Пустая строка означает отсутствие повторения
+func normalizeRecurrence(rule *string) (*string, error) {
+	if rule == nil || strings.TrimSpace(*rule) == "" {
+		return nil, nil
+	}
+	parsed, err := models.ParseRRule(*rule)
+	if err != nil {
+		return nil, err
+	}
+	canonical := parsed.String()
+	return &canonical, nil
+}
+
+func (h *TaskHandlers) HandleReopen(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -281,17 +568,24 @@ func (h *TaskHandlers) HandleGetCollections(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	collections, err := h.Repo.GetCollectionsByUser(userID)
+	vars := mux.Vars(r)["id"]
+
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.ReopenTaskByUser(id, userID)
 	if err != nil {
-		http.Error(w, `{"error": "Failed to fetch collections"}`, http.StatusInternalServerError)
+		http.Error(w, `{"error": "Failed to reopen task"}`, http.StatusForbidden)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collections)
+	w.WriteHeader(http.StatusOK)
 }
 
-func (h *TaskHandlers) HandleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandlers) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -305,21 +599,91 @@ func (h *TaskHandlers) HandleDeleteCollection(w http.ResponseWriter, r *http.Req
 	}
 
 	vars := mux.Vars(r)["id"]
+
 	id, err := strconv.Atoi(vars)
 	if err != nil {
-		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
 		return
 	}
 
-	if err := h.Repo.DeleteCollectionByUser(id, userID); err != nil {
-		http.Error(w, `{"error": "Failed to delete collection"}`, http.StatusForbidden)
+	var upd models.TaskUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.ExistingTagsOnly, err = existingTagsOnly(r); err != nil {
+		http.Error(w, `{"error": "existing_tags_only must be true or false"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.Name != nil {
+		name := strings.TrimSpace(*upd.Name)
+		if name == "" {
+			http.Error(w, `{"error": "Task name cannot be empty"}`, http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(name) > models.MaxTaskNameLength {
+			http.Error(w, `{"error": "Task name must be at most 255 characters"}`, http.StatusBadRequest)
+			return
+		}
+		upd.Name = &name
+	}
+
+	if upd.Priority != nil && !models.IsValidPriority(*upd.Priority) {
+		http.Error(w, `{"error": "Invalid priority"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.AddTags, err = models.NormalizeTagNames(upd.AddTags); err != nil {
+		http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+		return
+	}
+	if upd.RemoveTags, err = models.NormalizeTagNames(upd.RemoveTags); err != nil {
+		http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.Recurrence.Value, err = normalizeRecurrence(upd.Recurrence.Value); err != nil {
+		http.Error(w, `{"error": "Invalid recurrence rule"}`, http.StatusBadRequest)
 		return
 	}
 
+	task, changes, err := h.Repo.UpdateTaskByUser(id, userID, upd)
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrInvalidSchedule) {
+		http.Error(w, `{"error": "start_at must not be after due_at"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Not allowed for this collection role"}`, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrTagCreationDenied) {
+		http.Error(w, `{"error": "Creating tags is not allowed"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update task"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task":    task,
+		"changes": changes,
+	})
 }
 
-func (h *TaskHandlers) HandleGetTasksByCollection(w http.ResponseWriter, r *http.Request) {
+func (h *TaskHandlers) HandleMoveTasks(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.URL.Query().Get("user_id")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
@@ -332,19 +696,1308 @@ func (h *TaskHandlers) HandleGetTasksByCollection(w http.ResponseWriter, r *http
 		return
 	}
 
-	vars := mux.Vars(r)["id"]
-	collectionID, err := strconv.Atoi(vars)
-	if err != nil {
-		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+	var req models.MoveTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
 		return
 	}
 
-	tasks, err := h.Repo.GetTasksByCollection(userID, collectionID)
-	if err != nil {
-		http.Error(w, `{"error": "Failed to fetch tasks"}`, http.StatusInternalServerError)
+	if !req.CollectionID.Set {
+		http.Error(w, `{"error": "collection_id is required"}`, http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	moves, err := h.Repo.MoveTasksByUser(req.TaskIDs, userID, req.CollectionID.Value)
+	if errors.Is(err, models.ErrNoTasksToMove) {
+		http.Error(w, `{"error": "task_ids must contain 1-100 task IDs"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Not allowed for this collection role"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to move tasks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moves)
+}
+
+// HandleBulkTasks: POST /tasks/bulk {"operations": [{"op": "complete", "task_id": 1}, ...]}.
+// Ошибки отдельных операций возвращаются в их результатах, статус ответа при этом 200
+func (h *TaskHandlers) HandleBulkTasks(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	existingOnly, err := existingTagsOnly(r)
+	if err != nil {
+		http.Error(w, `{"error": "existing_tags_only must be true or false"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.BulkTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = req.Validate()
+	if errors.Is(err, models.ErrNoBulkOperations) {
+		http.Error(w, `{"error": "operations must contain 1-100 items"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Invalid bulk operation"}`, http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.Repo.BulkTasksByUser(req.Operations, userID, existingOnly)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to run bulk operations"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// Assignment handlers
+
+// HandleGetAssigned: /get?assigned=me — задачи, поручённые пользователю
+func (h *TaskHandlers) HandleGetAssigned(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseListOptions(r, models.SortCreateTime, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetAssignedTasks(userID, opts)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get tasks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
+}
+
+// HandleAssignTask: POST /tasks/{id}/assign {"username": "bob"}
+func (h *TaskHandlers) HandleAssignTask(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.AssignTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		http.Error(w, `{"error": "username is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.Repo.AssignTaskByUser(id, userID, req.Username)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrSelfAssign) {
+		http.Error(w, `{"error": "Cannot assign a task to yourself"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to assign task"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+func (h *TaskHandlers) HandleAcceptTask(w http.ResponseWriter, r *http.Request) {
+	h.respondToAssignment(w, r, true)
+}
+
+func (h *TaskHandlers) HandleDeclineTask(w http.ResponseWriter, r *http.Request) {
+	h.respondToAssignment(w, r, false)
+}
+
+// respondToAssignment общий обработчик accept/decline
+func (h *TaskHandlers) respondToAssignment(w http.ResponseWriter, r *http.Request, accept bool) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.Repo.RespondToAssignment(id, userID, accept)
+	if errors.Is(err, models.ErrAssignmentNotFound) {
+		http.Error(w, `{"error": "No pending assignment for this task"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to respond to assignment"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// HandleReorderTask ставит задачу перед или после другой задачи того же списка
+func (h *TaskHandlers) HandleReorderTask(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	anchorID, after, ok := decodeReorder(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := h.Repo.ReorderTaskByUser(id, userID, anchorID, after)
+	if errors.Is(err, models.ErrReorderAnchor) {
+		http.Error(w, `{"error": "before/after must reference another task of the same list"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reorder task"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// decodeReorder читает тело reorder-запроса; при ошибке сам отвечает 400
+func decodeReorder(w http.ResponseWriter, r *http.Request) (anchorID int, after bool, ok bool) {
+	var req models.ReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return 0, false, false
+	}
+
+	anchorID, after, err := req.Anchor()
+	if err != nil {
+		http.Error(w, `{"error": "Exactly one of before or after is required"}`, http.StatusBadRequest)
+		return 0, false, false
+	}
+	return anchorID, after, true
+}
+
+// Collection handlers
+
+func (h *TaskHandlers) HandleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(r.Body).Decode(&collection); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if collection.Name == "" {
+		http.Error(w, `{"error": "Collection name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if collection.Color == "" {
+		collection.Color = "#2564cf"
+	}
+	if collection.Icon == "" {
+		collection.Icon = "📁"
+	}
+
+	collection.UserID = userID
+
+	err = h.Repo.CreateCollection(&collection)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Parent collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Only the owner can add subcollections"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to create collection"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+func (h *TaskHandlers) HandleGetCollections(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err == nil {
+		err = page.CheckSort(true)
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Invalid pagination parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	collections, err := h.Repo.GetCollectionsByUser(userID, page)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch collections"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, collections, page, models.CollectionCursor)
+}
+
+func (h *TaskHandlers) HandleUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var upd models.CollectionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := upd.Validate(); err != nil {
+		http.Error(w, `{"error": "Invalid collection name, color or icon"}`, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.Repo.UpdateCollectionByUser(id, userID, upd)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Not allowed for this collection role"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update collection"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+// HandleMoveCollection: POST /collections/{id}/move {"parent_id": 3}; null — на верхний уровень
+func (h *TaskHandlers) HandleMoveCollection(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.MoveCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !req.ParentID.Set {
+		http.Error(w, `{"error": "parent_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := h.Repo.MoveCollectionByUser(id, userID, req.ParentID.Value)
+	if errors.Is(err, models.ErrCollectionCycle) {
+		http.Error(w, `{"error": "Cannot move a collection into itself or its subcollection"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Only the owner can move collections"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to move collection"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+// HandleReorderCollection ставит коллекцию перед или после соседней коллекции
+func (h *TaskHandlers) HandleReorderCollection(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	anchorID, after, ok := decodeReorder(w, r)
+	if !ok {
+		return
+	}
+
+	collection, err := h.Repo.ReorderCollectionByUser(id, userID, anchorID, after)
+	if errors.Is(err, models.ErrReorderAnchor) {
+		http.Error(w, `{"error": "before/after must reference a sibling collection"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Only the owner can reorder collections"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reorder collection"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collection)
+}
+
+func (h *TaskHandlers) HandleDeleteCollection(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.DeleteCollectionByUser(id, userID); err != nil {
+		http.Error(w, `{"error": "Failed to delete collection"}`, http.StatusForbidden)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TaskHandlers) HandleGetTasksByCollection(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)["id"]
+	collectionID, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseListOptions(r, models.SortPosition, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	recursive := false
+	if v := r.URL.Query().Get("recursive"); v != "" {
+		if recursive, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, `{"error": "recursive must be true or false"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tasks, err := h.Repo.GetTasksByCollection(userID, collectionID, recursive, opts)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch tasks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
+}
+
+// HandleGetByTags: /get?tag=work,home[&match=all|any]
+func (h *TaskHandlers) HandleGetByTags(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	names, err := models.NormalizeTagNames(strings.Split(r.URL.Query().Get("tag"), ","))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+		return
+	}
+
+	match := r.URL.Query().Get("match")
+	if match == "" {
+		match = "any"
+	}
+	if match != "any" && match != "all" {
+		http.Error(w, `{"error": "match must be any or all"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseListOptions(r, models.SortCreateTime, "desc")
+	if err != nil {
+		http.Error(w, `{"error": "Invalid list parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.Repo.GetTasksByTags(userID, names, match == "all", opts)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch tasks"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, tasks, opts.PageParams, opts.CursorOf)
+}
+
+// Tag handlers
+
+func (h *TaskHandlers) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	var tag models.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	names, err := models.NormalizeTagNames([]string{tag.Name})
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+		return
+	}
+	tag.Name = names[0]
+
+	if tag.Color == "" {
+		tag.Color = "#808080"
+	}
+	if !models.IsValidColor(tag.Color) {
+		http.Error(w, `{"error": "Invalid tag color"}`, http.StatusBadRequest)
+		return
+	}
+
+	tag.UserID = userID
+
+	err = h.Repo.CreateTag(&tag)
+	if errors.Is(err, models.ErrTagExists) {
+		http.Error(w, `{"error": "Tag already exists"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to create tag"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *TaskHandlers) HandleGetTags(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePage(r)
+	if err == nil {
+		err = page.CheckByName()
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Invalid pagination parameters"}`, http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.Repo.GetTagsByUser(userID, page)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch tags"}`, http.StatusInternalServerError)
+		return
+	}
+
+	writeList(w, tags, page, models.TagCursor)
+}
+
+func (h *TaskHandlers) HandleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var upd models.TagUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.Name != nil {
+		names, err := models.NormalizeTagNames([]string{*upd.Name})
+		if err != nil {
+			http.Error(w, `{"error": "Invalid tag name"}`, http.StatusBadRequest)
+			return
+		}
+		upd.Name = &names[0]
+	}
+	if upd.Color != nil && !models.IsValidColor(*upd.Color) {
+		http.Error(w, `{"error": "Invalid tag color"}`, http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Repo.UpdateTagByUser(id, userID, upd)
+	if errors.Is(err, models.ErrTagNotFound) {
+		http.Error(w, `{"error": "Tag not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrTagExists) {
+		http.Error(w, `{"error": "Tag already exists"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update tag"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+func (h *TaskHandlers) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)["id"]
+	id, err := strconv.Atoi(vars)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid tag ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteTagByUser(id, userID)
+	if errors.Is(err, models.ErrTagNotFound) {
+		http.Error(w, `{"error": "Tag not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete tag"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Checklist handlers
+
+// validChecklistText проверяет текст пункта: не пустой и помещается в колонку
+func validChecklistText(text string) bool {
+	text = strings.TrimSpace(text)
+	return text != "" && len(text) <= models.MaxChecklistTextLength
+}
+
+func (h *TaskHandlers) HandleGetChecklist(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.Repo.GetChecklist(taskID, userID)
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch checklist"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func (h *TaskHandlers) HandleAddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !validChecklistText(req.Text) {
+		http.Error(w, `{"error": "Invalid checklist item text"}`, http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.Repo.AddChecklistItem(taskID, userID, strings.TrimSpace(req.Text))
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to add checklist item"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+func (h *TaskHandlers) HandleUpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+	itemID, err := strconv.Atoi(vars["itemId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid checklist item ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var upd models.ChecklistItemUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if upd.Text != nil {
+		if !validChecklistText(*upd.Text) {
+			http.Error(w, `{"error": "Invalid checklist item text"}`, http.StatusBadRequest)
+			return
+		}
+		text := strings.TrimSpace(*upd.Text)
+		upd.Text = &text
+	}
+
+	item, err := h.Repo.UpdateChecklistItem(taskID, itemID, userID, upd)
+	if errors.Is(err, models.ErrChecklistItemNotFound) {
+		http.Error(w, `{"error": "Checklist item not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update checklist item"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func (h *TaskHandlers) HandleDeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+	itemID, err := strconv.Atoi(vars["itemId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid checklist item ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteChecklistItem(taskID, itemID, userID)
+	if errors.Is(err, models.ErrChecklistItemNotFound) {
+		http.Error(w, `{"error": "Checklist item not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to delete checklist item"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TaskHandlers) HandleReorderChecklist(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid task ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ItemIDs []int `json:"item_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.Repo.ReorderChecklist(taskID, userID, req.ItemIDs)
+	if errors.Is(err, models.ErrTaskNotFound) {
+		http.Error(w, `{"error": "Task not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrChecklistOrder) {
+		http.Error(w, `{"error": "item_ids must list every checklist item exactly once"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to reorder checklist"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// Trash handlers
+
+// trashItemType читает ?type= элемента корзины: task (по умолчанию) или collection
+func trashItemType(r *http.Request) (string, bool) {
+	switch t := r.URL.Query().Get("type"); t {
+	case "", models.TrashTask:
+		return models.TrashTask, true
+	case models.TrashCollection:
+		return t, true
+	}
+	return "", false
+}
+
+func (h *TaskHandlers) HandleGetTrash(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	trash, err := h.Repo.GetTrashByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to fetch trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trash)
+}
+
+// HandleRestoreFromTrash: /trash/{id}/restore[?type=collection]. Отдаёт восстановленную задачу или коллекцию
+func (h *TaskHandlers) HandleRestoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	itemType, ok := trashItemType(r)
+	if !ok {
+		http.Error(w, `{"error": "type must be task or collection"}`, http.StatusBadRequest)
+		return
+	}
+
+	var restored interface{}
+	if itemType == models.TrashCollection {
+		restored, err = h.Repo.RestoreCollectionByUser(id, userID)
+	} else {
+		restored, err = h.Repo.RestoreTaskByUser(id, userID)
+	}
+	if errors.Is(err, models.ErrTaskNotFound) || errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Item not found in trash"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to restore item"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(restored)
+}
+
+// HandlePurgeFromTrash: DELETE /trash/{id}[?type=collection] — окончательное удаление
+func (h *TaskHandlers) HandlePurgeFromTrash(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	itemType, ok := trashItemType(r)
+	if !ok {
+		http.Error(w, `{"error": "type must be task or collection"}`, http.StatusBadRequest)
+		return
+	}
+
+	if itemType == models.TrashCollection {
+		err = h.Repo.PurgeCollectionByUser(id, userID)
+	} else {
+		err = h.Repo.PurgeTaskByUser(id, userID)
+	}
+	if errors.Is(err, models.ErrTaskNotFound) || errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Item not found in trash"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to purge item"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TaskHandlers) HandleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return
+	}
+
+	purged, err := h.Repo.EmptyTrashByUser(userID)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to empty trash"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(purged)
+}
+
+// Member handlers
+
+// collectionAndUser читает user_id из запроса и id коллекции из пути
+func collectionAndUser(w http.ResponseWriter, r *http.Request) (collectionID, userID int, ok bool) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		http.Error(w, `{"error": "user_id is required"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user_id"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	collectionID, err = strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid collection ID"}`, http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return collectionID, userID, true
+}
+
+func (h *TaskHandlers) HandleGetMembers(w http.ResponseWriter, r *http.Request) {
+	collectionID, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.Repo.GetMembers(collectionID, userID)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to get members"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// HandleAddMember: POST /collections/{id}/members {"username": "bob", "role": "editor"}
+func (h *TaskHandlers) HandleAddMember(w http.ResponseWriter, r *http.Request) {
+	collectionID, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || !models.IsValidInviteRole(req.Role) {
+		http.Error(w, `{"error": "username and role (editor or viewer) are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.Repo.AddMember(collectionID, userID, req.Username, req.Role)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Only the owner can manage members"}`, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrMemberExists) {
+		http.Error(w, `{"error": "User is already a member"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to add member"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(member)
+}
+
+func (h *TaskHandlers) HandleUpdateMember(w http.ResponseWriter, r *http.Request) {
+	collectionID, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid member ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if !models.IsValidInviteRole(req.Role) {
+		http.Error(w, `{"error": "role must be editor or viewer"}`, http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.Repo.UpdateMemberRole(collectionID, userID, memberID, req.Role)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Only the owner can manage members"}`, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrMemberNotFound) {
+		http.Error(w, `{"error": "Member not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to update member"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// HandleRemoveMember: DELETE /collections/{id}/members/{userId}. Участник может выйти сам, указав свой id
+func (h *TaskHandlers) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	collectionID, userID, ok := collectionAndUser(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, `{"error": "Invalid member ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.RemoveMember(collectionID, userID, memberID)
+	if errors.Is(err, models.ErrCollectionNotFound) {
+		http.Error(w, `{"error": "Collection not found"}`, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		http.Error(w, `{"error": "Not allowed to remove this member"}`, http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrMemberNotFound) {
+		http.Error(w, `{"error": "Member not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to remove member"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
 }
@@ -7,11 +7,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 // ============================================================================
@@ -27,6 +30,22 @@ func setupMockRepo(t *testing.T) (*models.TaskRepository, sqlmock.Sqlmock, *sql.
 	return repo, mock, db
 }
 
+// taskRows собирает строки sqlmock в порядке колонок задачи в репозитории
+func taskRows(tasks ...models.Task) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "collection_id", "name", "text", "complete", "create_time", "complete_at", "due_at", "start_at", "priority", "recurrence", "tags", "progress_done", "progress_total", "assignee_id", "assignment_status", "position"})
+	for _, t := range tasks {
+		rows.AddRow(t.ID, t.UserID, t.CollectionID, t.Name, t.Text, t.Complete, t.CreateTime, t.CompleteAt, t.DueAt, t.StartAt, priorityOrNone(t.Priority), t.Recurrence, "{"+strings.Join(t.Tags, ",")+"}", t.Progress.Done, t.Progress.Total, t.AssigneeID, t.AssignmentStatus, t.Position)
+	}
+	return rows
+}
+
+func priorityOrNone(p string) string {
+	if p == "" {
+		return "none"
+	}
+	return p
+}
+
 // ============================================================================
 // ТЕСТЫ ДЛЯ NewTaskHandlers
 // ============================================================================
@@ -55,12 +74,14 @@ func TestHandleCreateSuccess(t *testing.T) {
 	handlers := NewTaskHandlers(repo)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Test Task", "Test Description", false, now, nil)
+	rows := taskRows(
+		models.Task{ID: 1, UserID: 1, Name: "Test Task", Text: "Test Description", CreateTime: now})
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO tasks`).
-		WithArgs(1, "Test Task", "Test Description").
+		WithArgs(1, nil, "Test Task", "Test Description", nil, nil, "none", nil).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
 	body := `{"name":"Test Task","text":"Test Description"}`
 	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
@@ -157,9 +178,11 @@ func TestHandleCreateDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO tasks`).
-		WithArgs(1, "Test Task", "Test Description").
+		WithArgs(1, nil, "Test Task", "Test Description", nil, nil, "none", nil).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	body := `{"name":"Test Task","text":"Test Description"}`
 	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
@@ -183,11 +206,11 @@ func TestHandleGetAllSuccess(t *testing.T) {
 	handlers := NewTaskHandlers(repo)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Task 1", "Description 1", false, now, nil).
-		AddRow(2, 1, "Task 2", "Description 2", true, now, &now)
+	rows := taskRows(
+		models.Task{ID: 1, UserID: 1, Name: "Task 1", Text: "Description 1", CreateTime: now},
+		models.Task{ID: 2, UserID: 1, Name: "Task 2", Text: "Description 2", Complete: true, CreateTime: now, CompleteAt: &now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -248,7 +271,7 @@ func TestHandleGetAllDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -273,10 +296,10 @@ func TestHandleGetCompletedSuccess(t *testing.T) {
 	handlers := NewTaskHandlers(repo)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Completed Task", "Description", true, now, &now)
+	rows := taskRows(
+		models.Task{ID: 1, UserID: 1, Name: "Completed Task", Text: "Description", Complete: true, CreateTime: now, CompleteAt: &now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -328,7 +351,7 @@ func TestHandleGetCompletedDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -353,10 +376,10 @@ func TestHandleGetUncompletedSuccess(t *testing.T) {
 	handlers := NewTaskHandlers(repo)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Uncompleted Task", "Description", false, now, nil)
+	rows := taskRows(
+		models.Task{ID: 1, UserID: 1, Name: "Uncompleted Task", Text: "Description", CreateTime: now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
@@ -408,7 +431,7 @@ func TestHandleGetUncompletedDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -432,7 +455,7 @@ func TestHandleDeleteSuccess(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectExec(`DELETE FROM tasks WHERE id`).
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
 		WithArgs(1, 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -504,7 +527,7 @@ func TestHandleDeleteDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectExec(`DELETE FROM tasks WHERE id`).
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
 		WithArgs(1, 1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -529,9 +552,12 @@ func TestHandleCompleteSuccess(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectExec(`UPDATE tasks`).
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
 		WithArgs(1, 1).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task", Complete: true, CreateTime: now, CompleteAt: &now}))
+	mock.ExpectCommit()
 
 	req := httptest.NewRequest("PUT", "/complete/1?user_id=1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
@@ -542,6 +568,14 @@ func TestHandleCompleteSuccess(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Errorf("Ожидался код 200, получен %d", rr.Code)
 	}
+
+	var response map[string]*models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if next, ok := response["next_task"]; !ok || next != nil {
+		t.Errorf("Ожидался next_task: null, получено %v", response)
+	}
 }
 
 func TestHandleCompleteMissingUserID(t *testing.T) {
@@ -601,9 +635,11 @@ func TestHandleCompleteDBError(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	mock.ExpectExec(`UPDATE tasks`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
 		WithArgs(1, 1).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	req := httptest.NewRequest("PUT", "/complete/1?user_id=1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
@@ -626,15 +662,11 @@ func TestHandleGetByIDSuccess(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, "Task 1", "Description", false, now, nil)
-
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs(1).
-		WillReturnRows(rows)
+	mock.ExpectQuery(`WHERE id = \$1 AND \(collection_id IS NULL AND user_id = \$2 OR`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task 1", CreateTime: time.Now()}))
 
-	req := httptest.NewRequest("GET", "/getbyid/1", nil)
+	req := httptest.NewRequest("GET", "/getbyid/1?user_id=1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "1"})
 	rr := httptest.NewRecorder()
 
@@ -645,13 +677,54 @@ func TestHandleGetByIDSuccess(t *testing.T) {
 	}
 }
 
+func TestHandleGetByIDOtherUser(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`WHERE id = \$1 AND \(collection_id IS NULL AND user_id = \$2 OR`).
+		WithArgs(1, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/getbyid/1?user_id=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByID(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "Task 1") {
+		t.Error("Ответ не должен содержать данные чужой задачи")
+	}
+}
+
+func TestHandleGetByIDMissingUserID(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("GET", "/getbyid/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByID(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
 func TestHandleGetByIDInvalidID(t *testing.T) {
 	repo, _, db := setupMockRepo(t)
 	defer db.Close()
 
 	handlers := NewTaskHandlers(repo)
 
-	req := httptest.NewRequest("GET", "/getbyid/invalid", nil)
+	req := httptest.NewRequest("GET", "/getbyid/invalid?user_id=1", nil)
 	req = mux.SetURLVars(req, map[string]string{"id": "invalid"})
 	rr := httptest.NewRecorder()
 
@@ -672,21 +745,2030 @@ func TestHandleGetByNameSuccess(t *testing.T) {
 
 	handlers := NewTaskHandlers(repo)
 
-	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, "Task 1", "Description", false, now, nil)
+	mock.ExpectQuery(`WHERE name = \$1 AND \(collection_id IS NULL AND user_id = \$2 OR`).
+		WithArgs("Task 1", 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task 1", CreateTime: time.Now()}))
 
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs("Task 1").
-		WillReturnRows(rows)
+	req := httptest.NewRequest("GET", "/getbyname/Task%201?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"name": "Task 1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByName(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Ожидался код 200, получен %d", rr.Code)
+	}
+}
+
+func TestHandleGetByNameOtherUser(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
 
-	req := httptest.NewRequest("GET", "/getbyname/Task%201", nil)
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`WHERE name = \$1 AND \(collection_id IS NULL AND user_id = \$2 OR`).
+		WithArgs("Task 1", 2).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/getbyname/Task%201?user_id=2", nil)
 	req = mux.SetURLVars(req, map[string]string{"name": "Task 1"})
 	rr := httptest.NewRecorder()
 
 	handlers.HandleGetByName(rr, req)
 
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleUpdate
+// ============================================================================
+
+func TestHandleUpdateSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Old", Text: "Text", CreateTime: now}))
+	mock.ExpectQuery(`UPDATE tasks SET text`).
+		WithArgs("New text", 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Old", Text: "New text", CreateTime: now}))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=1", bytes.NewBufferString(`{"text":"New text"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var resp struct {
+		Task    models.Task          `json:"task"`
+		Changes []models.FieldChange `json:"changes"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if resp.Task.Text != "New text" || len(resp.Changes) != 1 {
+		t.Errorf("Неправильный ответ: %+v", resp)
+	}
+}
+
+func TestHandleUpdateNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=2", bytes.NewBufferString(`{"text":"x"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUpdateStartAfterStoredDue(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	due := time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task", CreateTime: due, DueAt: &due}))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=1", bytes.NewBufferString(`{"start_at":"2026-05-11T00:00:00Z"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleUpdateExistingTagsOnly(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now()}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM tags`).
+		WithArgs(1, `{"new"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=1&existing_tags_only=true", bytes.NewBufferString(`{"add_tags":["new"]}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Ожидался код 403, получен %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleUpdateEmptyName(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	// Пробелы обрезаются, а длина считается в символах: 256 кириллических букв не влезают в VARCHAR(255)
+	for _, name := range []string{"", "   ", strings.Repeat("я", models.MaxTaskNameLength+1)} {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		req := httptest.NewRequest("PATCH", "/update/1?user_id=1", bytes.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+		rr := httptest.NewRecorder()
+
+		handlers.HandleUpdate(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("name из %d символов: ожидался код 400, получен %d", len([]rune(name)), rr.Code)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleReopen
+// ============================================================================
+
+func TestHandleReopenSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("PUT", "/uncomplete/1?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReopen(rr, req)
+
 	if rr.Code != http.StatusOK {
 		t.Errorf("Ожидался код 200, получен %d", rr.Code)
 	}
 }
+
+func TestHandleReopenMissingUserID(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("PUT", "/uncomplete/1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReopen(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleReopenNotCompleted(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("PUT", "/uncomplete/1?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReopen(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Ожидался код 403, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleGetByDue
+// ============================================================================
+
+func TestHandleGetByDueToday(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	loc, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, startOfDay, startOfDay.AddDate(0, 0, 1)).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Today", CreateTime: now, DueAt: &now}))
+
+	req := httptest.NewRequest("GET", "/get?due=today&tz=Europe/Moscow&user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByDue(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleGetByDueInvalidMode(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("GET", "/get?due=someday&user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByDue(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleGetByDueInvalidTZ(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("GET", "/get?due=today&tz=Mars/Base&user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByDue(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleCreateStartAfterDue(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	body := `{"name":"Task","start_at":"2025-01-02T00:00:00Z","due_at":"2025-01-01T00:00:00Z"}`
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ приоритетов и сортировки
+// ============================================================================
+
+func TestHandleGetAllSortByPriority(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`SELECT (.+) FROM tasks\s+WHERE \(collection_id IS NULL AND user_id = \$1 OR (.+)\) AND deleted_at IS NULL\s+ORDER BY CASE priority (.+) ASC NULLS LAST, id ASC`).
+		WithArgs(1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Low", CreateTime: time.Now(), Priority: "low"}))
+
+	req := httptest.NewRequest("GET", "/get?user_id=1&sort=priority&order=asc", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetAll(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleGetAllInvalidSort(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, query := range []string{"sort=password", "sort=name;DROP TABLE tasks", "order=sideways"} {
+		req := httptest.NewRequest("GET", "/get?user_id=1&"+url.PathEscape(query), nil)
+		rr := httptest.NewRecorder()
+
+		handlers.HandleGetAll(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateInvalidPriority(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	body := `{"name":"Task","priority":"critical"}`
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUpdateInvalidPriority(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=1", bytes.NewBufferString(`{"priority":"asap"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ тегов
+// ============================================================================
+
+func TestHandleGetByTagsAll(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`HAVING COUNT\(DISTINCT tg.id\) = 2`).
+		WithArgs(1, `{"home","work"}`).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now(), Tags: []string{"home", "work"}}))
+
+	req := httptest.NewRequest("GET", "/get?tag=work,home&match=all&user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetByTags(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var tasks []models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(tasks) != 1 || len(tasks[0].Tags) != 2 {
+		t.Errorf("Неправильный ответ: %+v", tasks)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleGetByTagsInvalidMatch(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, query := range []string{"tag=work&match=some", "tag=", "tag=work,,home"} {
+		req := httptest.NewRequest("GET", "/get?user_id=1&"+query, nil)
+		rr := httptest.NewRecorder()
+
+		handlers.HandleGetByTags(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandleCreateTagConflict(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`INSERT INTO tags`).
+		WithArgs(1, "work", "#808080").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	req := httptest.NewRequest("POST", "/tags?user_id=1", bytes.NewBufferString(`{"name":" work "}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreateTag(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Ожидался код 409, получен %d", rr.Code)
+	}
+}
+
+func TestHandleCreateTagInvalidColor(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{`{"name":"work","color":"#ff000000"}`, `{"name":"work","color":"red"}`} {
+		req := httptest.NewRequest("POST", "/tags?user_id=1", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleCreateTag(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleUpdateTagInvalidColor(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("PATCH", "/tags/1?user_id=1", bytes.NewBufferString(`{"color":"#ff000000"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateTag(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleGetTagsSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`SELECT id, user_id, name, color, created_at FROM tags`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "color", "created_at"}).
+			AddRow(1, 1, "home", "#808080", time.Now()).
+			AddRow(2, 1, "work", "#ff0000", time.Now()))
+
+	req := httptest.NewRequest("GET", "/tags?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTags(rr, req)
+
+	var tags []models.Tag
+	if err := json.NewDecoder(rr.Body).Decode(&tags); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(tags) != 2 {
+		t.Errorf("Ожидалось 2 тега, получено %d", len(tags))
+	}
+}
+
+func TestHandleGetTagsPaginated(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	name := "home"
+	cursor := models.Cursor{Name: &name, ID: 1}.Encode()
+	mock.ExpectQuery(`WHERE user_id = \$1 AND \(name, id\) > \(\$2, \$3\)\s+ORDER BY name ASC, id ASC LIMIT 2`).
+		WithArgs(1, "home", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "name", "color", "created_at"}).
+			AddRow(3, 1, "personal", "#808080", time.Now()).
+			AddRow(2, 1, "work", "#ff0000", time.Now()))
+
+	req := httptest.NewRequest("GET", "/tags?user_id=1&limit=1&cursor="+cursor, nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTags(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var page models.Page[models.Tag]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Name != "personal" || page.NextCursor == "" {
+		t.Errorf("Ожидался один тег и курсор, получено %+v", page)
+	}
+}
+
+func TestHandleGetTagsRejectsForeignCursor(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	cursor := models.TaskCursor(models.Task{ID: 1, CreateTime: time.Now()}).Encode()
+	req := httptest.NewRequest("GET", "/tags?user_id=1&cursor="+cursor, nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTags(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleDeleteTagNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`DELETE FROM tags`).
+		WithArgs(9, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest("DELETE", "/tags/9?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "9"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDeleteTag(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleCreateInvalidTag(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(`{"name":"Task","tags":["a,b"]}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ чек-листа
+// ============================================================================
+
+func TestHandleAddChecklistItemSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`INSERT INTO checklist_items`).
+		WithArgs(1, 1, "Шаг 1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "task_id", "text", "done", "position", "created_at"}).
+			AddRow(1, 1, "Шаг 1", false, 0, time.Now()))
+
+	req := httptest.NewRequest("POST", "/tasks/1/checklist?user_id=1", bytes.NewBufferString(`{"text":"  Шаг 1 "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAddChecklistItem(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Ожидался код 201, получен %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleAddChecklistItemEmptyText(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("POST", "/tasks/1/checklist?user_id=1", bytes.NewBufferString(`{"text":"   "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAddChecklistItem(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUpdateChecklistItemNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`UPDATE checklist_items`).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("PATCH", "/tasks/1/checklist/5?user_id=2", bytes.NewBufferString(`{"done":true}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1", "itemId": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateChecklistItem(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleReorderChecklistMismatch(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`FROM checklist_items ci`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "task_id", "text", "done", "position", "created_at"}).
+			AddRow(1, 1, "A", false, 0, time.Now()))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("PUT", "/tasks/1/checklist/order?user_id=1", bytes.NewBufferString(`{"item_ids":[1,2]}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReorderChecklist(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleGetChecklistTaskNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`SELECT id FROM tasks`).
+		WithArgs(1, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("GET", "/tasks/1/checklist?user_id=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetChecklist(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ повторяющихся задач
+// ============================================================================
+
+func TestHandleCreateWithRecurrence(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	now := time.Now()
+	rule := "FREQ=WEEKLY;BYDAY=MO,FR"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tasks`).
+		WithArgs(1, nil, "Task", "", nil, nil, "none", &rule).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Task", CreateTime: now, Recurrence: &rule}))
+	mock.ExpectCommit()
+
+	body := `{"name":"Task","recurrence":"RRULE:freq=weekly;byday=mo,fr"}`
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Ожидался код 201, получен %d", rr.Code)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleCreateInvalidRecurrence(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	body := `{"name":"Task","recurrence":"FREQ=HOURLY"}`
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleUpdateInvalidRecurrence(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("PATCH", "/update/1?user_id=1", bytes.NewBufferString(`{"recurrence":"FREQ=DAILY;COUNT=0"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdate(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleCompleteReturnsNextTask(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	now := time.Now()
+	rule := "FREQ=DAILY"
+	nextDue := now.AddDate(0, 0, 1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(models.Task{ID: 1, UserID: 1, Name: "Daily", Complete: true, CreateTime: now, CompleteAt: &now, Recurrence: &rule}))
+	mock.ExpectQuery(`INSERT INTO tasks`).
+		WillReturnRows(taskRows(models.Task{ID: 2, UserID: 1, Name: "Daily", CreateTime: now, DueAt: &nextDue, Recurrence: &rule}))
+	mock.ExpectExec(`INSERT INTO checklist_items`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("PUT", "/complete/1?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleComplete(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var response struct {
+		NextTask *models.Task `json:"next_task"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if response.NextTask == nil || response.NextTask.ID != 2 {
+		t.Errorf("Ожидалась следующая задача с ID 2, получено %+v", response.NextTask)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ пагинации
+// ============================================================================
+
+func TestHandleGetAllPaginated(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	mock.ExpectQuery(`ORDER BY create_time DESC NULLS LAST, id DESC LIMIT 3`).
+		WithArgs(1).
+		WillReturnRows(taskRows(
+			models.Task{ID: 3, UserID: 1, Name: "Task 3", CreateTime: now},
+			models.Task{ID: 2, UserID: 1, Name: "Task 2", CreateTime: now.Add(-time.Minute)},
+			models.Task{ID: 1, UserID: 1, Name: "Task 1", CreateTime: now.Add(-2 * time.Minute)}))
+
+	req := httptest.NewRequest("GET", "/get?user_id=1&limit=2", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetAll(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var page models.Page[models.Task]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Errorf("Ожидалось 2 задачи, получено %d", len(page.Items))
+	}
+
+	cursor, err := models.DecodeCursor(page.NextCursor)
+	if err != nil || cursor.ID != 2 || cursor.Position != nil || !cursor.Time.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Курсор должен указывать на время создания задачи 2, получено %+v (%v)", cursor, err)
+	}
+}
+
+func TestHandleGetAllCursorUsesDefaultLimit(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	after := models.Cursor{Time: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), ID: 7}
+	mock.ExpectQuery(`AND \(create_time, id\) < \(\$2, \$3\)\s+ORDER BY create_time DESC NULLS LAST, id DESC LIMIT 51`).
+		WithArgs(1, after.Time, after.ID).
+		WillReturnRows(taskRows())
+
+	req := httptest.NewRequest("GET", "/get?user_id=1&sort=create_time&cursor="+after.Encode(), nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetAll(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"items":[],"next_cursor":""}` {
+		t.Errorf("Неожиданный ответ: %s", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleGetAllInvalidPagination(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	position := int64(1024)
+	positionCursor := models.Cursor{Position: &position, ID: 7}.Encode()
+	for _, query := range []string{"limit=0", "limit=abc", "limit=1000", "cursor=garbage", "limit=10&sort=priority", "cursor=" + positionCursor} {
+		req := httptest.NewRequest("GET", "/get?user_id=1&"+query, nil)
+		rr := httptest.NewRecorder()
+
+		handlers.HandleGetAll(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHandleGetCollectionsPaginated(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position", "role"}).
+		AddRow(1, 1, nil, "Work", "#ff0000", "💼", now, 1024, models.RoleOwner).
+		AddRow(2, 1, 1, "Home", "#00ff00", "🏠", now, 1024, models.RoleOwner)
+	mock.ExpectQuery(`ORDER BY position ASC, id ASC LIMIT 2`).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "/collections?user_id=1&limit=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetCollections(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var page models.Page[models.Collection]
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != 1 || page.NextCursor == "" {
+		t.Errorf("Ожидалась одна коллекция и курсор, получено %+v", page)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleSearch
+// ============================================================================
+
+func TestHandleSearchSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "collection_id", "name", "text", "complete", "create_time", "complete_at", "due_at", "start_at", "priority", "recurrence", "tags", "progress_done", "progress_total", "assignee_id", "assignment_status", "position", "search_rank", "ts_headline"}).
+		AddRow(4, 1, nil, "Buy milk", "", false, time.Now(), nil, nil, nil, "none", nil, "{}", 0, 0, nil, nil, 1024, 0.5, "Buy <mark>milk</mark>")
+	mock.ExpectQuery(`search_vector @@ q AND complete = \$3`).
+		WithArgs(1, "mil:*", false).
+		WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "/search?user_id=1&q=mil&complete=false", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleSearch(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var results []models.SearchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 4 || results[0].Snippet != "Buy <mark>milk</mark>" {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+}
+
+func TestHandleSearchBadRequest(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, query := range []string{"q=", "q=%21%21", "q=a&complete=maybe", "q=a&collection_id=x", "q=a&limit=0", "q=a&limit=500"} {
+		req := httptest.NewRequest("GET", "/search?user_id=1&"+query, nil)
+		rr := httptest.NewRecorder()
+
+		handlers.HandleSearch(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", query, rr.Code)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ корзины
+// ============================================================================
+
+func TestHandleGetTrash(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`FROM tasks\s+WHERE \(collection_id IS NULL AND user_id = \$1 OR (.+)\) AND deleted_at IS NOT NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`FROM collections c`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	req := httptest.NewRequest("GET", "/trash?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"tasks":[],"collections":[]}` {
+		t.Errorf("Неправильный ответ: %s", body)
+	}
+}
+
+func TestHandleRestoreTaskFromTrash(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`UPDATE tasks SET deleted_at = NULL`).
+		WithArgs(5, 1).
+		WillReturnRows(taskRows(models.Task{ID: 5, UserID: 1, Name: "Old task", CreateTime: time.Now()}))
+
+	req := httptest.NewRequest("POST", "/trash/5/restore?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRestoreFromTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil || task.ID != 5 {
+		t.Errorf("Ожидалась восстановленная задача 5, получено %+v (%v)", task, err)
+	}
+}
+
+func TestHandleRestoreCollectionNotInTrash(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM collections`).
+		WithArgs(3, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/trash/3/restore?user_id=2&type=collection", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRestoreFromTrash(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleRestoreInvalidType(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("POST", "/trash/3/restore?user_id=1&type=tag", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRestoreFromTrash(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandlePurgeTaskFromTrash(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectExec(`DELETE FROM tasks\s+WHERE id = \$1 AND \(collection_id IS NULL AND user_id = \$2 OR (.+)\) AND deleted_at IS NOT NULL`).
+		WithArgs(5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("DELETE", "/trash/5?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandlePurgeFromTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Ожидался код 200, получен %d", rr.Code)
+	}
+}
+
+func TestHandleEmptyTrash(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM tasks WHERE deleted_at IS NOT NULL AND \(collection_id IS NULL AND user_id = \$1 OR`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM collections WHERE deleted_at IS NOT NULL AND id IN \(SELECT collection_id FROM collection_members WHERE user_id = \$1 AND role IN \('owner'\)\)`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("DELETE", "/trash?user_id=1", nil)
+	rr := httptest.NewRecorder()
+
+	handlers.HandleEmptyTrash(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"tasks":3,"collections":0}` {
+		t.Errorf("Неправильный ответ: %s", body)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleUpdateCollection
+// ============================================================================
+
+func TestHandleUpdateCollectionSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	name := "Work"
+	color := "#FF8800"
+
+	mock.ExpectQuery(`UPDATE collections SET name = COALESCE\(\$1, name\), color = COALESCE\(\$2, color\), icon = COALESCE\(\$3, icon\)\s+WHERE id = \$4 AND deleted_at IS NULL AND id IN \(SELECT collection_id FROM collection_members WHERE user_id = \$5`).
+		WithArgs(&name, &color, nil, 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position"}).
+			AddRow(3, 1, nil, "Work", "#FF8800", "📁", time.Now(), 1024))
+
+	req := httptest.NewRequest("PATCH", "/collections/3?user_id=1", bytes.NewBufferString(`{"name":"  Work ","color":"#FF8800"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateCollection(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(rr.Body).Decode(&collection); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if collection.Name != "Work" || collection.Color != "#FF8800" {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestHandleUpdateCollectionInvalidFields(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	bodies := []string{
+		`{"color":"red"}`,
+		`{"color":"#12345"}`,
+		`{"name":"   "}`,
+		`{"icon":""}`,
+		`{"icon":"` + strings.Repeat("x", 51) + `"}`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest("PATCH", "/collections/3?user_id=1", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		rr := httptest.NewRecorder()
+
+		handlers.HandleUpdateCollection(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleUpdateCollectionNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`UPDATE collections`).
+		WithArgs(nil, nil, sqlmock.AnyArg(), 3, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("PATCH", "/collections/3?user_id=2", bytes.NewBufferString(`{"icon":"🏠"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateCollection(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ HandleMoveTasks
+// ============================================================================
+
+func TestHandleMoveTasksSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT id, collection_id, (.+) FROM tasks`).
+		WithArgs("{1,2}", 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).AddRow(1, nil, true).AddRow(2, 4, true))
+	mock.ExpectExec(`UPDATE tasks SET collection_id = \$1`).
+		WithArgs(3, "{1,2}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/tasks/move?user_id=1", bytes.NewBufferString(`{"task_ids":[1,2],"collection_id":3}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleMoveTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var moves []models.TaskMove
+	if err := json.NewDecoder(rr.Body).Decode(&moves); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(moves) != 2 || moves[1].From == nil || *moves[1].From != 4 {
+		t.Errorf("Неправильный результат: %+v", moves)
+	}
+}
+
+func TestHandleMoveTasksMissingCollection(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{`{"task_ids":[1]}`, `{"task_ids":[],"collection_id":3}`} {
+		req := httptest.NewRequest("POST", "/tasks/move?user_id=1", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleMoveTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleMoveTasksForeignCollection(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(7, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/tasks/move?user_id=1", bytes.NewBufferString(`{"task_ids":[1],"collection_id":7}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleMoveTasks(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleBulkTasksSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE tasks\s+SET complete = FALSE`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	body := `{"operations":[{"op":"delete","task_id":1},{"op":"reopen","task_id":2}]}`
+	req := httptest.NewRequest("POST", "/tasks/bulk?user_id=1", bytes.NewBufferString(body))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleBulkTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var results []models.BulkResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(results) != 2 || results[0].Status != models.BulkStatusOK || results[1].Status != models.BulkStatusError {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleBulkTasksInvalid(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{
+		`{"operations":[]}`,
+		`{"operations":[{"op":"archive","task_id":1}]}`,
+		`{"operations":[{"op":"move","task_id":1}]}`,
+		`{"operations":[{"op":"tag","task_id":1}]}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest("POST", "/tasks/bulk?user_id=1", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+
+		handlers.HandleBulkTasks(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleBulkTasksDatabaseError(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+
+	req := httptest.NewRequest("POST", "/tasks/bulk?user_id=1", bytes.NewBufferString(`{"operations":[{"op":"complete","task_id":1}]}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleBulkTasks(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Ожидался код 500, получен %d", rr.Code)
+	}
+}
+
+func TestHandleCreateForeignCollection(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(7, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/create?user_id=1", bytes.NewBufferString(`{"name":"Task","collection_id":7}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ участников коллекции
+// ============================================================================
+
+func TestHandleCreateInSharedCollectionAsViewer(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleViewer))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/create?user_id=2", bytes.NewBufferString(`{"name":"Task","collection_id":3}`))
+	rr := httptest.NewRecorder()
+
+	handlers.HandleCreate(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Ожидался код 403, получен %d", rr.Code)
+	}
+}
+
+func TestHandleGetMembers(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleEditor))
+	mock.ExpectQuery(`FROM collection_members m JOIN users u`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id", "username", "role", "added_at"}).
+			AddRow(3, 1, "alice", models.RoleOwner, time.Now()).
+			AddRow(3, 2, "bob", models.RoleEditor, time.Now()))
+
+	req := httptest.NewRequest("GET", "/collections/3/members?user_id=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetMembers(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d", rr.Code)
+	}
+
+	var members []models.Member
+	if err := json.NewDecoder(rr.Body).Decode(&members); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if len(members) != 2 || members[0].Username != "alice" {
+		t.Errorf("Неправильный список участников: %+v", members)
+	}
+}
+
+func TestHandleAddMemberSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`INSERT INTO collection_members`).
+		WithArgs(3, "bob", models.RoleViewer).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id", "username", "role", "added_at"}).
+			AddRow(3, 2, "bob", models.RoleViewer, time.Now()))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/collections/3/members?user_id=1", bytes.NewBufferString(`{"username":" bob ","role":"viewer"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAddMember(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Ожидался код 201, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleAddMemberInvalidRole(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{`{"username":"bob","role":"owner"}`, `{"username":"","role":"editor"}`, `{"username":"bob"}`} {
+		req := httptest.NewRequest("POST", "/collections/3/members?user_id=1", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "3"})
+		rr := httptest.NewRecorder()
+
+		handlers.HandleAddMember(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleAddMemberErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		role   string
+		insert error
+		want   int
+	}{
+		{"не владелец", models.RoleEditor, nil, http.StatusForbidden},
+		{"нет пользователя", models.RoleOwner, sql.ErrNoRows, http.StatusNotFound},
+		{"уже участник", models.RoleOwner, &pq.Error{Code: "23505"}, http.StatusConflict},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, db := setupMockRepo(t)
+			defer db.Close()
+
+			handlers := NewTaskHandlers(repo)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+				WithArgs(3, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tc.role))
+			if tc.insert != nil {
+				mock.ExpectQuery(`INSERT INTO collection_members`).WillReturnError(tc.insert)
+			}
+			mock.ExpectRollback()
+
+			req := httptest.NewRequest("POST", "/collections/3/members?user_id=1", bytes.NewBufferString(`{"username":"bob","role":"editor"}`))
+			req = mux.SetURLVars(req, map[string]string{"id": "3"})
+			rr := httptest.NewRecorder()
+
+			handlers.HandleAddMember(rr, req)
+
+			if rr.Code != tc.want {
+				t.Errorf("Ожидался код %d, получен %d", tc.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateMember(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`UPDATE collection_members SET role`).
+		WithArgs(3, 2, models.RoleEditor).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id", "username", "role", "added_at"}).
+			AddRow(3, 2, "bob", models.RoleEditor, time.Now()))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("PATCH", "/collections/3/members/2?user_id=1", bytes.NewBufferString(`{"role":"editor"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "userId": "2"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleUpdateMember(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleRemoveMemberOwnerCannotLeave(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("DELETE", "/collections/3/members/1?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "userId": "1"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRemoveMember(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Ожидался код 403, получен %d", rr.Code)
+	}
+}
+
+func TestHandleRemoveMemberNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectExec(`DELETE FROM collection_members`).
+		WithArgs(3, 5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("DELETE", "/collections/3/members/5?user_id=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3", "userId": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleRemoveMember(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleAssignTaskSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	assignee := 2
+	pending := models.AssignmentPending
+
+	mock.ExpectQuery(`SELECT id FROM users WHERE username = \$1`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`UPDATE tasks SET assignee_id`).
+		WithArgs(5, 1, 2).
+		WillReturnRows(taskRows(models.Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &pending}))
+
+	req := httptest.NewRequest("POST", "/tasks/5/assign?user_id=1", bytes.NewBufferString(`{"username":" bob "}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAssignTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if task.AssigneeID == nil || *task.AssigneeID != 2 || *task.AssignmentStatus != models.AssignmentPending {
+		t.Errorf("Неправильное поручение: %+v", task)
+	}
+}
+
+func TestHandleAssignTaskErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		lookup *sqlmock.Rows
+		want   int
+	}{
+		{"без имени", `{"username":"  "}`, nil, http.StatusBadRequest},
+		{"нет пользователя", `{"username":"nobody"}`, sqlmock.NewRows([]string{"id"}), http.StatusNotFound},
+		{"себе", `{"username":"alice"}`, sqlmock.NewRows([]string{"id"}).AddRow(1), http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mock, db := setupMockRepo(t)
+			defer db.Close()
+
+			handlers := NewTaskHandlers(repo)
+
+			if tc.lookup != nil {
+				mock.ExpectQuery(`SELECT id FROM users`).WillReturnRows(tc.lookup)
+			}
+
+			req := httptest.NewRequest("POST", "/tasks/5/assign?user_id=1", bytes.NewBufferString(tc.body))
+			req = mux.SetURLVars(req, map[string]string{"id": "5"})
+			rr := httptest.NewRecorder()
+
+			handlers.HandleAssignTask(rr, req)
+
+			if rr.Code != tc.want {
+				t.Errorf("Ожидался код %d, получен %d", tc.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandleAcceptTask(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+	assignee := 2
+	accepted := models.AssignmentAccepted
+
+	mock.ExpectQuery(`UPDATE tasks SET assignment_status = \$3`).
+		WithArgs(5, 2, models.AssignmentAccepted).
+		WillReturnRows(taskRows(models.Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &accepted}))
+
+	req := httptest.NewRequest("POST", "/tasks/5/accept?user_id=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleAcceptTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandleDeclineTaskNotPending(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectQuery(`UPDATE tasks SET assignment_status = \$3`).
+		WithArgs(5, 2, models.AssignmentDeclined).
+		WillReturnError(sql.ErrNoRows)
+
+	req := httptest.NewRequest("POST", "/tasks/5/decline?user_id=2", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleDeclineTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleMoveCollectionSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tree`).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`UPDATE collections SET parent_id`).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position"}).
+			AddRow(3, 1, 2, "Q1", "#2564cf", "📁", time.Now(), 1024))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/collections/3/move?user_id=1", bytes.NewBufferString(`{"parent_id":2}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleMoveCollection(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var collection models.Collection
+	if err := json.NewDecoder(rr.Body).Decode(&collection); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if collection.ParentID == nil || *collection.ParentID != 2 {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestHandleMoveCollectionMissingParentID(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("POST", "/collections/3/move?user_id=1", bytes.NewBufferString(`{}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleMoveCollection(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+func TestHandleMoveCollectionCycle(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	// Вложить коллекцию в саму себя нельзя
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tree`).
+		WithArgs(3, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/collections/3/move?user_id=1", bytes.NewBufferString(`{"parent_id":3}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleMoveCollection(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleGetTasksByCollectionInvalidRecursive(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	req := httptest.NewRequest("GET", "/collections/3/tasks?user_id=1&recursive=maybe", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleGetTasksByCollection(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ручного порядка
+// ============================================================================
+
+func TestHandleReorderTaskSuccess(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id"}).AddRow(nil, 1))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT a.position`).
+		WithArgs(nil, 1, 10, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"position", "neighbour"}).AddRow(2048, 3072))
+	mock.ExpectQuery(`UPDATE tasks SET position = \$2`).
+		WithArgs(10, int64(2560)).
+		WillReturnRows(taskRows(models.Task{ID: 10, UserID: 1, Name: "Task", CreateTime: time.Now(), Position: 2560}))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest("POST", "/tasks/10/reorder?user_id=1", bytes.NewBufferString(`{"after":7}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "10"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReorderTask(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Ожидался код 200, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var task models.Task
+	if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+		t.Fatalf("Ошибка декодирования ответа: %v", err)
+	}
+	if task.Position != 2560 {
+		t.Errorf("Ожидалась позиция 2560, получено %d", task.Position)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleReorderTaskInvalidBody(t *testing.T) {
+	repo, _, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	for _, body := range []string{`not json`, `{}`, `{"before":1,"after":2}`, `{"before":10}`} {
+		req := httptest.NewRequest("POST", "/tasks/10/reorder?user_id=1", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"id": "10"})
+		rr := httptest.NewRecorder()
+
+		handlers.HandleReorderTask(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: ожидался код 400, получен %d", body, rr.Code)
+		}
+	}
+}
+
+func TestHandleReorderTaskNotFound(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/tasks/10/reorder?user_id=1", bytes.NewBufferString(`{"before":7}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "10"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReorderTask(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался код 404, получен %d", rr.Code)
+	}
+}
+
+func TestHandleReorderCollectionAnchorFromOtherList(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleOwner))
+	mock.ExpectQuery(`SELECT parent_id FROM collections`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT a.position`).
+		WithArgs(nil, 1, 3, 9).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/collections/3/reorder?user_id=1", bytes.NewBufferString(`{"before":9}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReorderCollection(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Ожидался код 400, получен %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestHandleReorderCollectionForbidden(t *testing.T) {
+	repo, mock, db := setupMockRepo(t)
+	defer db.Close()
+
+	handlers := NewTaskHandlers(repo)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT m.role FROM collection_members m`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(models.RoleEditor))
+	mock.ExpectRollback()
+
+	req := httptest.NewRequest("POST", "/collections/3/reorder?user_id=1", bytes.NewBufferString(`{"after":9}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "3"})
+	rr := httptest.NewRecorder()
+
+	handlers.HandleReorderCollection(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Ожидался код 403, получен %d", rr.Code)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -69,25 +70,95 @@ func main() {
 	repo := models.NewTaskRepository(db)
 	taskHandlers := handlers.NewTaskHandlers(repo)
 
+	retention, err := trashRetentionFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if retention > 0 {
+		go runTrashRetention(repo, retention, time.Hour)
+	} else {
+		log.Println("Trash retention disabled, deleted items are kept until purged")
+	}
+	go runTokenCleanup(repo, time.Hour)
+
 	router := mux.NewRouter()
 
 	router.HandleFunc("/user/create", handlers.CreateUser(db)).Methods("POST")
 	router.HandleFunc("/user/{username}", handlers.GetUserByUsername(db)).Methods("GET")
+	router.Path("/tokens").Methods("POST").HandlerFunc(taskHandlers.HandleCreateRefreshToken)
+	router.Path("/tokens/rotate").Methods("POST").HandlerFunc(taskHandlers.HandleRotateRefreshToken)
+	router.Path("/revocations").Methods("GET").HandlerFunc(taskHandlers.HandleGetRevocations)
+	router.Path("/revocations").Methods("POST").HandlerFunc(taskHandlers.HandleRevokeToken)
+	router.Path("/revocations/all").Methods("POST").HandlerFunc(taskHandlers.HandleRevokeAllTokens)
+	router.Path("/account/password").Methods("PUT").HandlerFunc(taskHandlers.HandleChangePassword)
+	router.Path("/account").Methods("DELETE").HandlerFunc(taskHandlers.HandleDeleteAccount)
+	router.Path("/account/email").Methods("PUT").HandlerFunc(taskHandlers.HandleUpdateEmail)
+	router.Path("/password-resets").Methods("POST").HandlerFunc(taskHandlers.HandleCreatePasswordReset)
+	router.Path("/password-resets/consume").Methods("POST").HandlerFunc(taskHandlers.HandleResetPassword)
+	router.Path("/account/totp").Methods("GET").HandlerFunc(taskHandlers.HandleGetTOTP)
+	router.Path("/account/totp").Methods("PUT").HandlerFunc(taskHandlers.HandleSetupTOTP)
+	router.Path("/account/totp").Methods("DELETE").HandlerFunc(taskHandlers.HandleDisableTOTP)
+	router.Path("/account/totp/enable").Methods("POST").HandlerFunc(taskHandlers.HandleEnableTOTP)
+	router.Path("/account/totp/step").Methods("POST").HandlerFunc(taskHandlers.HandleUseTOTPStep)
+	router.Path("/account/totp/failures").Methods("POST").HandlerFunc(taskHandlers.HandleRecordTOTPFailure)
+	router.Path("/account/totp/recovery").Methods("POST").HandlerFunc(taskHandlers.HandleUseRecoveryCode)
+	router.Path("/account/access-tokens").Methods("GET").HandlerFunc(taskHandlers.HandleListAccessTokens)
+	router.Path("/account/access-tokens").Methods("POST").HandlerFunc(taskHandlers.HandleCreateAccessToken)
+	router.Path("/account/access-tokens/{id}").Methods("DELETE").HandlerFunc(taskHandlers.HandleDeleteAccessToken)
+	router.Path("/access-tokens/authenticate").Methods("POST").HandlerFunc(taskHandlers.HandleAuthenticateAccessToken)
 
 	router.Path("/create").Methods("POST").HandlerFunc(taskHandlers.HandleCreate)
 	router.Path("/get").Methods("GET").Queries("complete", "true").HandlerFunc(taskHandlers.HandleGetCompleted)
 	router.Path("/get").Methods("GET").Queries("complete", "false").HandlerFunc(taskHandlers.HandleGetUncompleted)
+	router.Path("/get").Methods("GET").Queries("due", "{due}").HandlerFunc(taskHandlers.HandleGetByDue)
+	router.Path("/get").Methods("GET").Queries("tag", "{tag}").HandlerFunc(taskHandlers.HandleGetByTags)
+	router.Path("/get").Methods("GET").Queries("assigned", "me").HandlerFunc(taskHandlers.HandleGetAssigned)
 	router.Path("/get").Methods("GET").HandlerFunc(taskHandlers.HandleGetAll)
 	router.Path("/delete/{id}").Methods("DELETE").HandlerFunc(taskHandlers.HandleDelete)
 	router.Path("/complete/{id}").Methods("PUT").HandlerFunc(taskHandlers.HandleComplete)
+	router.Path("/uncomplete/{id}").Methods("PUT").HandlerFunc(taskHandlers.HandleReopen)
+	router.Path("/update/{id}").Methods("PATCH").HandlerFunc(taskHandlers.HandleUpdate)
 	router.Path("/getbyid/{id}").Methods("GET").HandlerFunc(taskHandlers.HandleGetByID)
 	router.Path("/getbyname/{name}").Methods("GET").HandlerFunc(taskHandlers.HandleGetByName)
+	router.Path("/search").Methods("GET").HandlerFunc(taskHandlers.HandleSearch)
+	router.Path("/tasks/move").Methods("POST").HandlerFunc(taskHandlers.HandleMoveTasks)
+	router.Path("/tasks/bulk").Methods("POST").HandlerFunc(taskHandlers.HandleBulkTasks)
+	router.Path("/tasks/{id}/assign").Methods("POST").HandlerFunc(taskHandlers.HandleAssignTask)
+	router.Path("/tasks/{id}/accept").Methods("POST").HandlerFunc(taskHandlers.HandleAcceptTask)
+	router.Path("/tasks/{id}/decline").Methods("POST").HandlerFunc(taskHandlers.HandleDeclineTask)
+	router.Path("/tasks/{id}/reorder").Methods("POST").HandlerFunc(taskHandlers.HandleReorderTask)
+
+	// Checklist routes
+	router.Path("/tasks/{id}/checklist").Methods("GET").HandlerFunc(taskHandlers.HandleGetChecklist)
+	router.Path("/tasks/{id}/checklist").Methods("POST").HandlerFunc(taskHandlers.HandleAddChecklistItem)
+	router.Path("/tasks/{id}/checklist/order").Methods("PUT").HandlerFunc(taskHandlers.HandleReorderChecklist)
+	router.Path("/tasks/{id}/checklist/{itemId:[0-9]+}").Methods("PATCH").HandlerFunc(taskHandlers.HandleUpdateChecklistItem)
+	router.Path("/tasks/{id}/checklist/{itemId:[0-9]+}").Methods("DELETE").HandlerFunc(taskHandlers.HandleDeleteChecklistItem)
 
 	// Collection routes
 	router.Path("/collections").Methods("POST").HandlerFunc(taskHandlers.HandleCreateCollection)
 	router.Path("/collections").Methods("GET").HandlerFunc(taskHandlers.HandleGetCollections)
+	router.Path("/collections/{id}").Methods("PATCH").HandlerFunc(taskHandlers.HandleUpdateCollection)
 	router.Path("/collections/{id}").Methods("DELETE").HandlerFunc(taskHandlers.HandleDeleteCollection)
+	router.Path("/collections/{id}/move").Methods("POST").HandlerFunc(taskHandlers.HandleMoveCollection)
+	router.Path("/collections/{id}/reorder").Methods("POST").HandlerFunc(taskHandlers.HandleReorderCollection)
 	router.Path("/collections/{id}/tasks").Methods("GET").HandlerFunc(taskHandlers.HandleGetTasksByCollection)
+	router.Path("/collections/{id}/members").Methods("GET").HandlerFunc(taskHandlers.HandleGetMembers)
+	router.Path("/collections/{id}/members").Methods("POST").HandlerFunc(taskHandlers.HandleAddMember)
+	router.Path("/collections/{id}/members/{userId:[0-9]+}").Methods("PATCH").HandlerFunc(taskHandlers.HandleUpdateMember)
+	router.Path("/collections/{id}/members/{userId:[0-9]+}").Methods("DELETE").HandlerFunc(taskHandlers.HandleRemoveMember)
+
+	// Trash routes
+	router.Path("/trash").Methods("GET").HandlerFunc(taskHandlers.HandleGetTrash)
+	router.Path("/trash").Methods("DELETE").HandlerFunc(taskHandlers.HandleEmptyTrash)
+	router.Path("/trash/{id}/restore").Methods("POST").HandlerFunc(taskHandlers.HandleRestoreFromTrash)
+	router.Path("/trash/{id}").Methods("DELETE").HandlerFunc(taskHandlers.HandlePurgeFromTrash)
+
+	// Tag routes
+	router.Path("/tags").Methods("POST").HandlerFunc(taskHandlers.HandleCreateTag)
+	router.Path("/tags").Methods("GET").HandlerFunc(taskHandlers.HandleGetTags)
+	router.Path("/tags/{id}").Methods("PATCH").HandlerFunc(taskHandlers.HandleUpdateTag)
+	router.Path("/tags/{id}").Methods("DELETE").HandlerFunc(taskHandlers.HandleDeleteTag)
 
 	if err := http.ListenAndServe(":8080", router); err != nil {
 		log.Fatal(err)
@@ -178,6 +249,429 @@ func runMigrations(db *sql.DB) error {
 		return fmt.Errorf("failed to create index: %w", err)
 	}
 
+	//Добавляем колонки due_at и start_at (если их нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'due_at'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN due_at TIMESTAMPTZ;
+			END IF;
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'start_at'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN start_at TIMESTAMPTZ;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add due_at/start_at columns: %w", err)
+	}
+
+	//Индекс для выборок по сроку (today/overdue/week)
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tasks_user_due ON tasks(user_id, due_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create due_at index: %w", err)
+	}
+
+	//Добавляем колонку priority (если её нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'priority'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN priority VARCHAR(10) NOT NULL DEFAULT 'none'
+					CHECK (priority IN ('none', 'low', 'medium', 'high', 'urgent'));
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add priority column: %w", err)
+	}
+
+	//Создаём таблицу tags
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id SERIAL PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(50) NOT NULL,
+			color VARCHAR(7) DEFAULT '#808080',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, name)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	//Связь задач и тегов многие-ко-многим
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS task_tags (
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (task_id, tag_id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_tags table: %w", err)
+	}
+
+	//Индекс для фильтра задач по тегу
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_tags index: %w", err)
+	}
+
+	//Создаём таблицу checklist_items (пункты чек-листа задачи)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS checklist_items (
+			id SERIAL PRIMARY KEY,
+			task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			text VARCHAR(500) NOT NULL,
+			done BOOLEAN NOT NULL DEFAULT FALSE,
+			position INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create checklist_items table: %w", err)
+	}
+
+	//Индекс для выборки чек-листа и подсчёта прогресса по задаче
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_checklist_items_task ON checklist_items(task_id, position);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create checklist_items index: %w", err)
+	}
+
+	//Добавляем колонку recurrence с правилом повторения в формате RRULE (если её нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'recurrence'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN recurrence TEXT;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add recurrence column: %w", err)
+	}
+
+	//Добавляем сгенерированную колонку search_vector для полнотекстового поиска (если её нет).
+	//Конфигурация simple: названия задач пишут и на русском, и на английском
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'search_vector'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+					setweight(to_tsvector('simple', COALESCE(name, '')), 'A') ||
+					setweight(to_tsvector('simple', COALESCE(text, '')), 'B')
+				) STORED;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add search_vector column: %w", err)
+	}
+
+	//GIN-индекс для поиска по search_vector
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	//Добавляем колонку deleted_at в tasks и collections для корзины (если её нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'deleted_at'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMPTZ;
+			END IF;
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'collections' AND column_name = 'deleted_at'
+			) THEN
+				ALTER TABLE collections ADD COLUMN deleted_at TIMESTAMPTZ;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add deleted_at columns: %w", err)
+	}
+
+	//Частичный индекс по корзине: её просмотр и фоновая очистка не трогают активные задачи
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tasks_trash ON tasks(user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create trash index: %w", err)
+	}
+
+	//Создаём таблицу collection_members: доступ к коллекции и её задачам по ролям
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS collection_members (
+			collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+			added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (collection_id, user_id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create collection_members table: %w", err)
+	}
+
+	//Индекс для условий доступа "коллекции, где пользователь участник"
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_collection_members_user ON collection_members(user_id, role);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create collection_members index: %w", err)
+	}
+
+	//Создатели существующих коллекций становятся их владельцами
+	_, err = db.Exec(`
+		INSERT INTO collection_members (collection_id, user_id, role)
+		SELECT id, user_id, 'owner' FROM collections
+		ON CONFLICT DO NOTHING;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill collection owners: %w", err)
+	}
+
+	//Добавляем колонки assignee_id и assignment_status для поручения задач (если их нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'assignee_id'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN assignee_id INT REFERENCES users(id) ON DELETE SET NULL;
+			END IF;
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'assignment_status'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN assignment_status VARCHAR(10)
+					CHECK (assignment_status IN ('pending', 'accepted', 'declined'));
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add assignee columns: %w", err)
+	}
+
+	//Индекс для списка "поручено мне"
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tasks_assignee ON tasks(assignee_id) WHERE assignee_id IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create assignee index: %w", err)
+	}
+
+	//Добавляем колонку parent_id в collections для вложенных коллекций (если её нет)
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'collections' AND column_name = 'parent_id'
+			) THEN
+				ALTER TABLE collections ADD COLUMN parent_id INT REFERENCES collections(id) ON DELETE SET NULL;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add parent_id column: %w", err)
+	}
+
+	//Индекс для обхода дерева коллекций от родителя к детям
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_collections_parent ON collections(parent_id) WHERE parent_id IS NOT NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create collections parent index: %w", err)
+	}
+
+	//Добавляем колонку position в tasks и collections для ручного порядка (если её нет).
+	//Существующие записи нумеруются с шагом 1024 в том порядке, в каком списки отдавались раньше
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'tasks' AND column_name = 'position'
+			) THEN
+				ALTER TABLE tasks ADD COLUMN position BIGINT NOT NULL DEFAULT 0;
+				UPDATE tasks t SET position = r.rn * 1024 FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY collection_id, CASE WHEN collection_id IS NULL THEN user_id END
+						ORDER BY create_time DESC, id DESC) AS rn
+					FROM tasks
+				) r WHERE t.id = r.id;
+			END IF;
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'collections' AND column_name = 'position'
+			) THEN
+				ALTER TABLE collections ADD COLUMN position BIGINT NOT NULL DEFAULT 0;
+				UPDATE collections c SET position = r.rn * 1024 FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY user_id, parent_id
+						ORDER BY created_at ASC, id ASC) AS rn
+					FROM collections
+				) r WHERE c.id = r.id;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to add position columns: %w", err)
+	}
+
+	//Индексы для соседей в ручном порядке: задачи коллекции, личные задачи без коллекции
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tasks_collection_position ON tasks(collection_id, position) WHERE collection_id IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_tasks_personal_position ON tasks(user_id, position) WHERE collection_id IS NULL;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create position indexes: %w", err)
+	}
+
+	//Refresh-токены: храним только sha256 от токена. family_id объединяет цепочку ротаций одного входа,
+	//used_at отмечает уже обменянный токен — его повторное предъявление отзывает всю цепочку
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id UUID NOT NULL DEFAULT gen_random_uuid(),
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh_tokens table: %w", err)
+	}
+
+	//Отозванные access-токены по jti: хранятся до истечения самого токена.
+	//tokens_revoked_at в users — выход на всех устройствах: недействительны все токены, выданные не позже этого момента
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti VARCHAR(64) PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'users' AND column_name = 'tokens_revoked_at'
+			) THEN
+				ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMPTZ;
+			END IF;
+		END $$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create revoked_tokens table: %w", err)
+	}
+
+	//Почта для сброса пароля: необязательна, уникальна без учёта регистра.
+	//Токены сброса, как и refresh-токены, хранятся только как sha256 и действуют один раз
+	_, err = db.Exec(`
+		DO $$ 
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns 
+				WHERE table_name = 'users' AND column_name = 'email'
+			) THEN
+				ALTER TABLE users ADD COLUMN email VARCHAR(254);
+			END IF;
+		END $$;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (LOWER(email));
+		CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create password_reset_tokens table: %w", err)
+	}
+
+	//Двухфакторная аутентификация: секрет TOTP (enabled_at пуст, пока настройка не подтверждена кодом),
+	//последний принятый шаг времени против повтора кода и счётчик неудачных попыток.
+	//Коды восстановления, как и токены, хранятся только как sha256
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_totp (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret VARCHAR(64) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			enabled_at TIMESTAMPTZ,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			failures INT NOT NULL DEFAULT 0,
+			failed_at TIMESTAMPTZ
+		);
+		CREATE TABLE IF NOT EXISTS recovery_codes (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash CHAR(64) NOT NULL,
+			used_at TIMESTAMPTZ,
+			UNIQUE (user_id, code_hash)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create user_totp table: %w", err)
+	}
+
+	//Персональные токены доступа для скриптов и CI: хранится только sha256 токена.
+	//expires_at пуст у бессрочного токена, scopes — области доступа, которые проверяет apiservice
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS personal_access_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ,
+			last_used_at TIMESTAMPTZ,
+			UNIQUE (user_id, name)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create personal_access_tokens table: %w", err)
+	}
+
 	log.Println("Database migrations ran successfully")
 	return nil
 }
@@ -30,10 +30,106 @@ func TestRunMigrationsSuccess(t *testing.T) {
 	mock.ExpectExec(`DO \$\$`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
+	// Collections table
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS collections`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add collection_id column
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
 	// Create index
 	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_user_id`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
+	// Add due_at/start_at columns
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_user_due`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add priority column
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create tags, task_tags and index
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS tags`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS task_tags`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create checklist_items and index
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS checklist_items`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_checklist_items_task`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add recurrence column
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add search_vector column
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_search`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add deleted_at columns
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_trash`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS collection_members`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_collection_members_user`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO collection_members \(collection_id, user_id, role\)\s+SELECT id, user_id, 'owner' FROM collections\s+ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	// Add assignee columns
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_assignee`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add parent_id column
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_collections_parent`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add position columns
+	mock.ExpectExec(`DO \$\$`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE INDEX IF NOT EXISTS idx_tasks_collection_position`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create refresh_tokens table
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS refresh_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create revoked_tokens table
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS revoked_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Add email column, create password_reset_tokens table
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS password_reset_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create user_totp and recovery_codes tables
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS user_totp`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Create personal_access_tokens table
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS personal_access_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
 	err = runMigrations(db)
 	if err != nil {
 		t.Errorf("runMigrations вернул ошибку: %v", err)
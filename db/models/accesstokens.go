@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Персональные токены доступа для скриптов и CI. Токен выпускает apiservice и показывает владельцу один раз,
+// сюда приходит только его sha256. Токен действует, пока его не отзовут или не наступит expires_at, если он задан.
+// Области доступа (scopes) проверяет apiservice, здесь они только хранятся
+
+var (
+	ErrAccessTokenNotFound  = errors.New("access token not found")
+	ErrAccessTokenInvalid   = errors.New("access token not found or expired")
+	ErrAccessTokenNameTaken = errors.New("access token name is already in use")
+)
+
+// MaxAccessTokenNameLength максимальная длина имени токена, совпадает с размером колонки
+const MaxAccessTokenNameLength = 100
+
+// AccessToken персональный токен доступа без самого токена и его хэша
+type AccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateAccessTokenRequest тело POST /account/access-tokens; без expires_at токен бессрочный
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name"`
+	TokenHash string     `json:"token_hash"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AuthenticateAccessTokenRequest тело POST /access-tokens/authenticate
+type AuthenticateAccessTokenRequest struct {
+	TokenHash string `json:"token_hash"`
+}
+
+// AccessTokenOwner ответ POST /access-tokens/authenticate: чей токен и что он разрешает
+type AccessTokenOwner struct {
+	TokenID  int      `json:"token_id"`
+	UserID   int      `json:"user_id"`
+	Username string   `json:"username"`
+	Scopes   []string `json:"scopes"`
+}
+
+// CreateAccessToken сохраняет токен пользователя. Имя уникально среди токенов пользователя — ErrAccessTokenNameTaken
+func (r *TaskRepository) CreateAccessToken(userID int, req *CreateAccessTokenRequest) (*AccessToken, error) {
+	var token AccessToken
+	var expiresAt sql.NullTime
+	err := r.DB.QueryRow(`
+		INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, scopes, created_at, expires_at`,
+		userID, req.Name, req.TokenHash, pq.Array(req.Scopes), req.ExpiresAt).Scan(
+		&token.ID, &token.Name, pq.Array(&token.Scopes), &token.CreatedAt, &expiresAt)
+	if isUniqueViolation(err) {
+		return nil, ErrAccessTokenNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+
+	return &token, nil
+}
+
+// ListAccessTokens возвращает токены пользователя, новые первыми. Истёкшие тоже: их видно, пока не отозваны
+func (r *TaskRepository) ListAccessTokens(userID int) ([]AccessToken, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, name, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		var token AccessToken
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.Name, pq.Array(&token.Scopes), &token.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			token.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// DeleteAccessToken отзывает токен пользователя; чужой или несуществующий токен — ErrAccessTokenNotFound
+func (r *TaskRepository) DeleteAccessToken(id, userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAccessTokenNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAccessToken находит действующий токен по хэшу, отмечает его использованным и возвращает владельца.
+// Неизвестный или истёкший токен — ErrAccessTokenInvalid
+func (r *TaskRepository) AuthenticateAccessToken(tokenHash string) (*AccessTokenOwner, error) {
+	var owner AccessTokenOwner
+	err := r.DB.QueryRow(`
+		UPDATE personal_access_tokens t SET last_used_at = NOW()
+		FROM users u
+		WHERE u.id = t.user_id AND t.token_hash = $1 AND (t.expires_at IS NULL OR t.expires_at > NOW())
+		RETURNING t.id, u.id, u.username, t.scopes`, tokenHash).Scan(
+		&owner.TokenID, &owner.UserID, &owner.Username, pq.Array(&owner.Scopes))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccessTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &owner, nil
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ персональных токенов доступа
+// ============================================================================
+
+func TestCreateAccessToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	expiresAt := now.Add(30 * 24 * time.Hour)
+	req := &CreateAccessTokenRequest{
+		Name:      "ci",
+		TokenHash: newHash,
+		Scopes:    []string{"tasks:read", "tasks:write"},
+		ExpiresAt: &expiresAt,
+	}
+
+	mock.ExpectQuery(`INSERT INTO personal_access_tokens \(user_id, name, token_hash, scopes, expires_at\)\s+VALUES \(\$1, \$2, \$3, \$4, \$5\)\s+RETURNING id, name, scopes, created_at, expires_at`).
+		WithArgs(1, "ci", newHash, pq.Array(req.Scopes), expiresAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at"}).
+			AddRow(7, "ci", "{tasks:read,tasks:write}", now, expiresAt))
+
+	token, err := repo.CreateAccessToken(1, req)
+	if err != nil {
+		t.Fatalf("CreateAccessToken вернул ошибку: %v", err)
+	}
+	if token.ID != 7 || token.Name != "ci" || len(token.Scopes) != 2 || token.Scopes[1] != "tasks:write" ||
+		token.ExpiresAt == nil || !token.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Неправильный токен: %+v", token)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCreateAccessTokenNameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO personal_access_tokens`).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err = repo.CreateAccessToken(1, &CreateAccessTokenRequest{Name: "ci", TokenHash: newHash, Scopes: []string{"tasks:read"}})
+	if !errors.Is(err, ErrAccessTokenNameTaken) {
+		t.Errorf("Ожидалась ErrAccessTokenNameTaken, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestListAccessTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT id, name, scopes, created_at, expires_at, last_used_at\s+FROM personal_access_tokens\s+WHERE user_id = \$1\s+ORDER BY created_at DESC, id DESC`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at"}).
+			AddRow(2, "backup", "{tasks:read}", now, nil, now).
+			AddRow(1, "ci", "{tasks:write,tags:write}", now.Add(-time.Hour), now.Add(time.Hour), nil))
+
+	tokens, err := repo.ListAccessTokens(1)
+	if err != nil {
+		t.Fatalf("ListAccessTokens вернул ошибку: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("Ожидалось 2 токена, получено %d", len(tokens))
+	}
+	if tokens[0].ExpiresAt != nil || tokens[0].LastUsedAt == nil {
+		t.Errorf("Неправильный бессрочный токен: %+v", tokens[0])
+	}
+	if tokens[1].ExpiresAt == nil || tokens[1].LastUsedAt != nil || len(tokens[1].Scopes) != 2 {
+		t.Errorf("Неправильный токен со сроком: %+v", tokens[1])
+	}
+
+	// Без токенов — пустой список, а не null
+	mock.ExpectQuery(`FROM personal_access_tokens`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "scopes", "created_at", "expires_at", "last_used_at"}))
+
+	tokens, err = repo.ListAccessTokens(2)
+	if err != nil || tokens == nil || len(tokens) != 0 {
+		t.Errorf("Ожидался пустой список, получено %v, %v", tokens, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteAccessToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM personal_access_tokens WHERE id = \$1 AND user_id = \$2`).
+		WithArgs(7, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.DeleteAccessToken(7, 1); err != nil {
+		t.Errorf("DeleteAccessToken вернул ошибку: %v", err)
+	}
+
+	// Чужой токен
+	mock.ExpectExec(`DELETE FROM personal_access_tokens`).
+		WithArgs(7, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.DeleteAccessToken(7, 2); !errors.Is(err, ErrAccessTokenNotFound) {
+		t.Errorf("Ожидалась ErrAccessTokenNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestAuthenticateAccessToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`UPDATE personal_access_tokens t SET last_used_at = NOW\(\)\s+FROM users u\s+WHERE u.id = t.user_id AND t.token_hash = \$1 AND \(t.expires_at IS NULL OR t.expires_at > NOW\(\)\)\s+RETURNING t.id, u.id, u.username, t.scopes`).
+		WithArgs(oldHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "scopes"}).
+			AddRow(7, 1, "testuser", "{tasks:read}"))
+
+	owner, err := repo.AuthenticateAccessToken(oldHash)
+	if err != nil {
+		t.Fatalf("AuthenticateAccessToken вернул ошибку: %v", err)
+	}
+	if owner.TokenID != 7 || owner.UserID != 1 || owner.Username != "testuser" || len(owner.Scopes) != 1 || owner.Scopes[0] != "tasks:read" {
+		t.Errorf("Неправильный владелец: %+v", owner)
+	}
+
+	// Отозванный или истёкший токен
+	mock.ExpectQuery(`UPDATE personal_access_tokens t SET last_used_at`).
+		WithArgs(newHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "username", "scopes"}))
+
+	if _, err := repo.AuthenticateAccessToken(newHash); !errors.Is(err, ErrAccessTokenInvalid) {
+		t.Errorf("Ожидалась ErrAccessTokenInvalid, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UpdatePasswordRequest тело PUT /account/password: новый хэш пароля, посчитанный apiservice
+type UpdatePasswordRequest struct {
+	PasswordHash string `json:"password_hash"`
+}
+
+// ChangePassword сохраняет новый хэш пароля и отзывает все токены пользователя, как RevokeAllTokens.
+// Возвращает момент отзыва
+func (r *TaskRepository) ChangePassword(userID int, passwordHash string) (time.Time, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	revokedAt, err := updatePassword(tx, userID, passwordHash)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return revokedAt, tx.Commit()
+}
+
+// updatePassword меняет хэш пароля и отзывает все access- и refresh-токены пользователя
+func updatePassword(tx *sql.Tx, userID int, passwordHash string) (time.Time, error) {
+	var revokedAt time.Time
+	err := tx.QueryRow(`
+		UPDATE users SET password_hash = $2, tokens_revoked_at = NOW()
+		WHERE id = $1
+		RETURNING tokens_revoked_at`, userID, passwordHash).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrUserNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return revokedAt, revokeRefreshTokens(tx, userID)
+}
+
+// DeleteUser удаляет пользователя. Его задачи, коллекции, теги, участие в коллекциях и токены удаляются
+// внешними ключами ON DELETE CASCADE, назначенные ему чужие задачи остаются без исполнителя
+func (r *TaskRepository) DeleteUser(userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
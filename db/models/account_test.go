@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ChangePassword и DeleteUser
+// ============================================================================
+
+func TestChangePassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET password_hash = \$2, tokens_revoked_at = NOW\(\)\s+WHERE id = \$1\s+RETURNING tokens_revoked_at`).
+		WithArgs(1, "new-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)\s+WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	revokedAt, err := repo.ChangePassword(1, "new-hash")
+	if err != nil {
+		t.Fatalf("ChangePassword вернул ошибку: %v", err)
+	}
+	if !revokedAt.Equal(now) {
+		t.Errorf("Неправильный момент отзыва: получено %v, ожидается %v", revokedAt, now)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestChangePasswordUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET password_hash`).
+		WithArgs(99, "new-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}))
+	mock.ExpectRollback()
+
+	if _, err := repo.ChangePassword(99, "new-hash"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.DeleteUser(1); err != nil {
+		t.Errorf("DeleteUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM users WHERE id = \$1`).
+		WithArgs(99).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.DeleteUser(99); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+}
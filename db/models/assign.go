@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Задачу можно поручить другому пользователю. Поручение ждёт ответа (pending): исполнитель уже видит задачу
+// и принимает (accepted) или отклоняет (declined) её. Принятую задачу исполнитель меняет и выполняет
+// наравне с автором, отклонённая пропадает из его списков. Автор может переназначить задачу в любой момент
+
+// Статусы поручения
+const (
+	AssignmentPending  = "pending"
+	AssignmentAccepted = "accepted"
+	AssignmentDeclined = "declined"
+)
+
+var (
+	ErrSelfAssign         = errors.New("cannot assign a task to yourself")
+	ErrAssignmentNotFound = errors.New("no pending assignment for this task")
+)
+
+// AssignTaskRequest тело POST /tasks/{id}/assign
+type AssignTaskRequest struct {
+	Username string `json:"username"`
+}
+
+// AssignTaskByUser поручает задачу пользователю username. Поручать может тот, кто может менять задачу
+func (r *TaskRepository) AssignTaskByUser(id, userID int, username string) (*Task, error) {
+	var assigneeID int
+	err := r.DB.QueryRow(`SELECT id FROM users WHERE username = $1`, username).Scan(&assigneeID)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if assigneeID == userID {
+		return nil, ErrSelfAssign
+	}
+
+	var task Task
+	err = scanTask(r.DB.QueryRow(`
+	UPDATE tasks SET assignee_id = $3, assignment_status = 'pending'
+	WHERE id = $1 AND `+taskManageable("", "$2")+` AND deleted_at IS NULL
+	RETURNING `+taskColumns, id, userID, assigneeID), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// RespondToAssignment принимает или отклоняет поручение. Отвечает только исполнитель и только на ожидающее поручение
+func (r *TaskRepository) RespondToAssignment(id, userID int, accept bool) (*Task, error) {
+	status := AssignmentDeclined
+	if accept {
+		status = AssignmentAccepted
+	}
+
+	var task Task
+	err := scanTask(r.DB.QueryRow(`
+	UPDATE tasks SET assignment_status = $3
+	WHERE id = $1 AND assignee_id = $2 AND assignment_status = 'pending' AND deleted_at IS NULL
+	RETURNING `+taskColumns, id, userID, status), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrAssignmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetAssignedTasks задачи, поручённые пользователю: ожидающие ответа и принятые
+func (r *TaskRepository) GetAssignedTasks(userID int, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID)
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE assignee_id = $1 AND assignment_status IN ('pending', 'accepted') AND deleted_at IS NULL
+	`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// checkTaskManageable ErrForbidden, если пользователь меняет задачу только как исполнитель:
+// переносить её в другую коллекцию он не может
+func checkTaskManageable(q queryer, id, userID int) error {
+	var ok bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND `+taskManageable("", "$2")+`)`, id, userID).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
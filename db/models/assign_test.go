@@ -0,0 +1,218 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ AssignTaskByUser
+// ============================================================================
+
+func TestAssignTaskByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	assignee := 2
+	pending := AssignmentPending
+
+	mock.ExpectQuery(`SELECT id FROM users WHERE username = \$1`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`UPDATE tasks SET assignee_id = \$3, assignment_status = 'pending'\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NULL`).
+		WithArgs(5, 1, 2).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &pending}))
+
+	task, err := repo.AssignTaskByUser(5, 1, "bob")
+	if err != nil {
+		t.Fatalf("AssignTaskByUser вернул ошибку: %v", err)
+	}
+	if task.AssigneeID == nil || *task.AssigneeID != 2 || *task.AssignmentStatus != AssignmentPending {
+		t.Errorf("Неправильное поручение: %+v", task)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestAssignTaskByUserUnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`SELECT id FROM users`).
+		WithArgs("nobody").
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.AssignTaskByUser(5, 1, "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+}
+
+func TestAssignTaskByUserSelf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`SELECT id FROM users`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	if _, err := repo.AssignTaskByUser(5, 1, "alice"); !errors.Is(err, ErrSelfAssign) {
+		t.Errorf("Ожидалась ErrSelfAssign, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestAssignTaskByUserForeignTask(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`SELECT id FROM users`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`UPDATE tasks SET assignee_id`).
+		WithArgs(5, 3, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.AssignTaskByUser(5, 3, "bob"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ RespondToAssignment
+// ============================================================================
+
+func TestRespondToAssignment(t *testing.T) {
+	for accept, status := range map[bool]string{true: AssignmentAccepted, false: AssignmentDeclined} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Ошибка создания mock: %v", err)
+		}
+
+		repo := NewTaskRepository(db)
+		assignee := 2
+		got := status
+
+		mock.ExpectQuery(`UPDATE tasks SET assignment_status = \$3\s+WHERE id = \$1 AND assignee_id = \$2 AND assignment_status = 'pending' AND deleted_at IS NULL`).
+			WithArgs(5, 2, status).
+			WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &got}))
+
+		task, err := repo.RespondToAssignment(5, 2, accept)
+		if err != nil {
+			t.Fatalf("RespondToAssignment(%v) вернул ошибку: %v", accept, err)
+		}
+		if *task.AssignmentStatus != status {
+			t.Errorf("Ожидался статус %s, получено %s", status, *task.AssignmentStatus)
+		}
+		db.Close()
+	}
+}
+
+func TestRespondToAssignmentNotPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Уже принятое поручение или чужая задача не попадают под условие
+	mock.ExpectQuery(`UPDATE tasks SET assignment_status`).
+		WithArgs(5, 2, AssignmentAccepted).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.RespondToAssignment(5, 2, true); !errors.Is(err, ErrAssignmentNotFound) {
+		t.Errorf("Ожидалась ErrAssignmentNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetAssignedTasks
+// ============================================================================
+
+func TestGetAssignedTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	assignee := 2
+	pending := AssignmentPending
+
+	mock.ExpectQuery(`WHERE assignee_id = \$1 AND assignment_status IN \('pending', 'accepted'\) AND deleted_at IS NULL\s+ORDER BY create_time DESC`).
+		WithArgs(2).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &pending}))
+
+	tasks, err := repo.GetAssignedTasks(2, ListOptions{Sort: SortCreateTime, Order: "desc"})
+	if err != nil {
+		t.Fatalf("GetAssignedTasks вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].UserID != 1 {
+		t.Errorf("Неправильный список: %+v", tasks)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ прав исполнителя
+// ============================================================================
+
+func TestUpdateTaskByAssigneeCannotMoveTask(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	assignee := 2
+	accepted := AssignmentAccepted
+	target := 9
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(5, 2).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Report", CreateTime: time.Now(), AssigneeID: &assignee, AssignmentStatus: &accepted}))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tasks WHERE id = \$1 AND `+manageableRe(`$2`)+`\)`).
+		WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, _, err = repo.UpdateTaskByUser(5, 2, TaskUpdate{CollectionID: Optional[int]{Set: true, Value: &target}})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Пакетные операции: POST /tasks/bulk выполняет список операций над задачами в одной транзакции.
+// Операции идут по порядку, каждая видит результат предыдущих. Если операция не прошла из-за состояния
+// задачи или прав, ошибка записывается в её результат, а остальные выполняются дальше: все проверки
+// идут до записи, поэтому неудачная операция ничего не меняет. Ошибка базы откатывает весь пакет
+
+// MaxBulkOperations сколько операций можно передать одним запросом
+const MaxBulkOperations = 100
+
+// Виды пакетных операций
+const (
+	BulkComplete = "complete"
+	BulkReopen   = "reopen"
+	BulkDelete   = "delete"
+	BulkMove     = "move"
+	BulkTag      = "tag"
+)
+
+// Статусы результата операции
+const (
+	BulkStatusOK    = "ok"
+	BulkStatusError = "error"
+)
+
+var ErrNoBulkOperations = errors.New("operations must contain 1-100 items")
+
+// BulkOperation одна операция пакета. collection_id обязателен для move (null — убрать из коллекции),
+// add_tags/remove_tags — для tag
+type BulkOperation struct {
+	Op           string        `json:"op"`
+	TaskID       int           `json:"task_id"`
+	CollectionID Optional[int] `json:"collection_id"`
+	AddTags      []string      `json:"add_tags,omitempty"`
+	RemoveTags   []string      `json:"remove_tags,omitempty"`
+}
+
+// BulkTasksRequest тело POST /tasks/bulk
+type BulkTasksRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkResult результат одной операции. next_task — следующая задача серии после complete,
+// move — фактический перенос (нет, если коллекция не сменилась), changes — изменение тегов после tag
+type BulkResult struct {
+	Index    int           `json:"index"`
+	Op       string        `json:"op"`
+	TaskID   int           `json:"task_id"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	NextTask *Task         `json:"next_task,omitempty"`
+	Move     *TaskMove     `json:"move,omitempty"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// Validate проверяет состав пакета и нормализует имена тегов
+func (req *BulkTasksRequest) Validate() error {
+	if len(req.Operations) == 0 || len(req.Operations) > MaxBulkOperations {
+		return ErrNoBulkOperations
+	}
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		if op.TaskID <= 0 {
+			return fmt.Errorf("operations[%d]: task_id is required", i)
+		}
+		switch op.Op {
+		case BulkComplete, BulkReopen, BulkDelete:
+		case BulkMove:
+			if !op.CollectionID.Set {
+				return fmt.Errorf("operations[%d]: collection_id is required for move", i)
+			}
+		case BulkTag:
+			var err error
+			if op.AddTags, err = NormalizeTagNames(op.AddTags); err != nil {
+				return fmt.Errorf("operations[%d]: %w", i, err)
+			}
+			if op.RemoveTags, err = NormalizeTagNames(op.RemoveTags); err != nil {
+				return fmt.Errorf("operations[%d]: %w", i, err)
+			}
+			if len(op.AddTags) == 0 && len(op.RemoveTags) == 0 {
+				return fmt.Errorf("operations[%d]: add_tags or remove_tags is required for tag", i)
+			}
+		default:
+			return fmt.Errorf("operations[%d]: unsupported op %q", i, op.Op)
+		}
+	}
+	return nil
+}
+
+// bulkItemErrors ошибки, которые относятся к одной операции и не прерывают пакет
+var bulkItemErrors = []error{
+	ErrTaskNotFound,
+	ErrTaskNotCompletable,
+	ErrTaskNotReopenable,
+	ErrCollectionNotFound,
+	ErrForbidden,
+	ErrTagCreationDenied,
+}
+
+func isBulkItemError(err error) bool {
+	for _, target := range bulkItemErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkTasksByUser выполняет проверенный пакет операций в одной транзакции и возвращает результаты
+// в порядке операций. С existingTagsOnly операция tag не создаёт новых тегов
+func (r *TaskRepository) BulkTasksByUser(ops []BulkOperation, userID int, existingTagsOnly bool) ([]BulkResult, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]BulkResult, len(ops))
+	for i, op := range ops {
+		result := BulkResult{Index: i, Op: op.Op, TaskID: op.TaskID, Status: BulkStatusOK}
+
+		var err error
+		switch op.Op {
+		case BulkComplete:
+			result.NextTask, err = completeTask(tx, op.TaskID, userID)
+		case BulkReopen:
+			err = reopenTask(tx, op.TaskID, userID)
+		case BulkDelete:
+			err = trashTask(tx, op.TaskID, userID)
+		case BulkMove:
+			var moves []TaskMove
+			moves, err = moveTasks(tx, []int{op.TaskID}, userID, op.CollectionID.Value)
+			if len(moves) > 0 {
+				result.Move = &moves[0]
+			}
+		case BulkTag:
+			_, result.Changes, err = updateTask(tx, op.TaskID, userID, TaskUpdate{
+				AddTags: op.AddTags, RemoveTags: op.RemoveTags, ExistingTagsOnly: existingTagsOnly,
+			})
+		default:
+			err = fmt.Errorf("unsupported op %q", op.Op)
+		}
+
+		if err != nil {
+			if !isBulkItemError(err) {
+				return nil, err
+			}
+			result.Status = BulkStatusError
+			result.Error = err.Error()
+		}
+		results[i] = result
+	}
+
+	return results, tx.Commit()
+}
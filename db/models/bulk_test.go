@@ -0,0 +1,279 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ BulkTasksRequest.Validate
+// ============================================================================
+
+func TestBulkTasksRequestValidate(t *testing.T) {
+	target := 3
+	tooMany := make([]BulkOperation, MaxBulkOperations+1)
+	for i := range tooMany {
+		tooMany[i] = BulkOperation{Op: BulkComplete, TaskID: i + 1}
+	}
+
+	tests := []struct {
+		name  string
+		ops   []BulkOperation
+		valid bool
+	}{
+		{"пустой пакет", nil, false},
+		{"больше лимита", tooMany, false},
+		{"все виды операций", []BulkOperation{
+			{Op: BulkComplete, TaskID: 1},
+			{Op: BulkReopen, TaskID: 2},
+			{Op: BulkDelete, TaskID: 3},
+			{Op: BulkMove, TaskID: 4, CollectionID: Optional[int]{Set: true, Value: &target}},
+			{Op: BulkMove, TaskID: 5, CollectionID: Optional[int]{Set: true}},
+			{Op: BulkTag, TaskID: 6, AddTags: []string{" work "}},
+		}, true},
+		{"неизвестная операция", []BulkOperation{{Op: "archive", TaskID: 1}}, false},
+		{"без task_id", []BulkOperation{{Op: BulkComplete}}, false},
+		{"move без collection_id", []BulkOperation{{Op: BulkMove, TaskID: 1}}, false},
+		{"tag без тегов", []BulkOperation{{Op: BulkTag, TaskID: 1}}, false},
+		{"tag с пустым именем", []BulkOperation{{Op: BulkTag, TaskID: 1, RemoveTags: []string{" "}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := BulkTasksRequest{Operations: tt.ops}
+			err := req.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Ожидался валидный пакет, получено %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Ожидалась ошибка валидации")
+			}
+		})
+	}
+}
+
+func TestBulkTasksRequestValidateNormalizesTags(t *testing.T) {
+	req := BulkTasksRequest{Operations: []BulkOperation{
+		{Op: BulkTag, TaskID: 1, AddTags: []string{" work ", "home", "work"}},
+	}}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate вернул ошибку: %v", err)
+	}
+	if !equalStrings(req.Operations[0].AddTags, []string{"home", "work"}) {
+		t.Errorf("Теги не нормализованы: %v", req.Operations[0].AddTags)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ BulkTasksByUser
+// ============================================================================
+
+func TestBulkTasksByUserMixed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	target := 3
+	now := time.Now()
+
+	mock.ExpectBegin()
+	// complete: задача без повторения, следующей нет
+	mock.ExpectQuery(`UPDATE tasks\s+SET complete = TRUE`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "A", Complete: true, CreateTime: now, CompleteAt: &now}))
+	// reopen: задача не выполнена — ошибка операции, пакет продолжается
+	mock.ExpectExec(`UPDATE tasks\s+SET complete = FALSE`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// delete
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
+		WithArgs(3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// move
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectQuery(`SELECT id, collection_id, (.+) FROM tasks`).
+		WithArgs("{4}", 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).AddRow(4, nil, true))
+	mock.ExpectExec(`UPDATE tasks SET collection_id = \$1`).
+		WithArgs(3, "{4}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// tag
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(5, 1).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "E", CreateTime: now, Tags: []string{"home"}}))
+	mock.ExpectExec(`INSERT INTO tags`).
+		WithArgs(1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO task_tags`).
+		WithArgs(5, 1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	results, err := repo.BulkTasksByUser([]BulkOperation{
+		{Op: BulkComplete, TaskID: 1},
+		{Op: BulkReopen, TaskID: 2},
+		{Op: BulkDelete, TaskID: 3},
+		{Op: BulkMove, TaskID: 4, CollectionID: Optional[int]{Set: true, Value: &target}},
+		{Op: BulkTag, TaskID: 5, AddTags: []string{"work"}},
+	}, 1, false)
+	if err != nil {
+		t.Fatalf("BulkTasksByUser вернул ошибку: %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("Ожидалось 5 результатов, получено %+v", results)
+	}
+	for i, res := range results {
+		if res.Index != i {
+			t.Errorf("Результат %d с неправильным index: %+v", i, res)
+		}
+	}
+	if results[0].Status != BulkStatusOK || results[0].NextTask != nil {
+		t.Errorf("Неправильный результат complete: %+v", results[0])
+	}
+	if results[1].Status != BulkStatusError || results[1].Error != ErrTaskNotReopenable.Error() {
+		t.Errorf("Ожидалась ошибка reopen, получено %+v", results[1])
+	}
+	if results[2].Status != BulkStatusOK {
+		t.Errorf("Неправильный результат delete: %+v", results[2])
+	}
+	if results[3].Move == nil || results[3].Move.From != nil || *results[3].Move.To != 3 {
+		t.Errorf("Неправильный результат move: %+v", results[3])
+	}
+	if len(results[4].Changes) != 1 || results[4].Changes[0].Field != "tags" {
+		t.Errorf("Неправильный результат tag: %+v", results[4])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestBulkTasksByUserItemErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	target := 7
+
+	mock.ExpectBegin()
+	// Наблюдатель не может класть задачи в коллекцию
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(roleRows(RoleViewer))
+	// Чужая задача
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
+		WithArgs(9, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	results, err := repo.BulkTasksByUser([]BulkOperation{
+		{Op: BulkMove, TaskID: 1, CollectionID: Optional[int]{Set: true, Value: &target}},
+		{Op: BulkDelete, TaskID: 9},
+	}, 1, false)
+	if err != nil {
+		t.Fatalf("BulkTasksByUser вернул ошибку: %v", err)
+	}
+	if results[0].Status != BulkStatusError || results[0].Error != ErrForbidden.Error() {
+		t.Errorf("Ожидалась ErrForbidden, получено %+v", results[0])
+	}
+	if results[1].Status != BulkStatusError || results[1].Error != ErrTaskNotFound.Error() {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %+v", results[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestBulkTasksByUserTagExistingOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	// Существующий тег привязывается без INSERT INTO tags
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(5, 1).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "E", CreateTime: now}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM tags`).
+		WithArgs(1, `{"work"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO task_tags`).
+		WithArgs(5, 1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Несуществующий — ошибка операции до любых изменений задачи
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(6, 1).
+		WillReturnRows(taskRows(Task{ID: 6, UserID: 1, Name: "F", CreateTime: now, Tags: []string{"home"}}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM tags`).
+		WithArgs(1, `{"new"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectCommit()
+
+	results, err := repo.BulkTasksByUser([]BulkOperation{
+		{Op: BulkTag, TaskID: 5, AddTags: []string{"work"}},
+		{Op: BulkTag, TaskID: 6, AddTags: []string{"new"}, RemoveTags: []string{"home"}},
+	}, 1, true)
+	if err != nil {
+		t.Fatalf("BulkTasksByUser вернул ошибку: %v", err)
+	}
+	if results[0].Status != BulkStatusOK {
+		t.Errorf("Ожидался успех, получено %+v", results[0])
+	}
+	if results[1].Status != BulkStatusError || results[1].Error != ErrTagCreationDenied.Error() {
+		t.Errorf("Ожидалась ErrTagCreationDenied, получено %+v", results[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestBulkTasksByUserDatabaseError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Ошибка базы откатывает весь пакет, включая уже выполненные операции
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)`).
+		WithArgs(2, 1).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	results, err := repo.BulkTasksByUser([]BulkOperation{
+		{Op: BulkDelete, TaskID: 1},
+		{Op: BulkDelete, TaskID: 2},
+	}, 1, false)
+	if err == nil {
+		t.Fatalf("Ожидалась ошибка, получено %+v", results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
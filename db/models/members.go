@@ -0,0 +1,237 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Доступ к коллекции и её задачам определяется таблицей collection_members, а не колонкой user_id.
+// Личные задачи (без коллекции) видит только автор. Задачи коллекции видят все её участники,
+// меняют владелец и редакторы. Саму коллекцию переименовывают владелец и редакторы,
+// удаляет и управляет участниками только владелец. collections.user_id остаётся создателем-владельцем
+
+// Роли участников коллекции
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrForbidden      = errors.New("action not allowed for this collection role")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrMemberNotFound = errors.New("member not found")
+	ErrMemberExists   = errors.New("user is already a member of this collection")
+)
+
+// IsValidInviteRole роль, которую можно выдать участнику. Владелец у коллекции один
+func IsValidInviteRole(role string) bool {
+	return role == RoleEditor || role == RoleViewer
+}
+
+// Member участник коллекции
+type Member struct {
+	CollectionID int       `json:"collection_id"`
+	UserID       int       `json:"user_id"`
+	Username     string    `json:"username"`
+	Role         string    `json:"role"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+// AddMemberRequest тело POST /collections/{id}/members
+type AddMemberRequest struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// UpdateMemberRequest тело PATCH /collections/{id}/members/{userId}
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// memberOf условие "column — коллекция, где пользователь param участник с одной из ролей"; без ролей — с любой
+func memberOf(column, param string, roles ...string) string {
+	cond := column + " IN (SELECT collection_id FROM collection_members WHERE user_id = " + param
+	if len(roles) > 0 {
+		cond += " AND role IN ('" + strings.Join(roles, "', '") + "')"
+	}
+	return cond + ")"
+}
+
+// taskReadable условие "задача видна пользователю param". prefix — алиас таблицы tasks с точкой или пусто.
+// Исполнитель видит задачу, пока не отклонил поручение
+func taskReadable(prefix, param string) string {
+	return "(" + prefix + "collection_id IS NULL AND " + prefix + "user_id = " + param +
+		" OR " + memberOf(prefix+"collection_id", param) +
+		" OR " + prefix + "assignee_id = " + param + " AND " + prefix + "assignment_status IN ('pending', 'accepted'))"
+}
+
+// taskWritable условие "пользователь param может менять задачу". Исполнитель — только после принятия поручения
+func taskWritable(prefix, param string) string {
+	return "(" + taskManageable(prefix, param) +
+		" OR " + prefix + "assignee_id = " + param + " AND " + prefix + "assignment_status = 'accepted')"
+}
+
+// taskManageable условие "пользователь param распоряжается задачей": удаляет, переносит и поручает её.
+// Исполнителю это недоступно — иначе он мог бы увести задачу из-под автора
+func taskManageable(prefix, param string) string {
+	return "(" + prefix + "collection_id IS NULL AND " + prefix + "user_id = " + param +
+		" OR " + memberOf(prefix+"collection_id", param, RoleOwner, RoleEditor) + ")"
+}
+
+// collectionRole роль пользователя в активной коллекции; ErrCollectionNotFound, если он не участник
+func collectionRole(q queryer, collectionID, userID int) (string, error) {
+	var role string
+	err := q.QueryRow(`
+	SELECT m.role FROM collection_members m JOIN collections c ON c.id = m.collection_id
+	WHERE m.collection_id = $1 AND m.user_id = $2 AND c.deleted_at IS NULL`, collectionID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrCollectionNotFound
+	}
+	return role, err
+}
+
+// requireOwner пропускает только владельца коллекции; остальным участникам ErrForbidden
+func requireOwner(q queryer, collectionID, userID int) error {
+	role, err := collectionRole(q, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if role != RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
+
+const memberColumns = `m.collection_id, m.user_id, u.username, m.role, m.added_at`
+
+func scanMember(row rowScanner, m *Member) error {
+	return row.Scan(&m.CollectionID, &m.UserID, &m.Username, &m.Role, &m.AddedAt)
+}
+
+// GetMembers участники коллекции, владелец первым. Список доступен любому участнику
+func (r *TaskRepository) GetMembers(collectionID, userID int) ([]Member, error) {
+	if _, err := collectionRole(r.DB, collectionID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(`
+	SELECT `+memberColumns+` FROM collection_members m JOIN users u ON u.id = m.user_id
+	WHERE m.collection_id = $1
+	ORDER BY m.role = 'owner' DESC, m.added_at ASC, m.user_id ASC`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		if err := scanMember(rows, &m); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddMember приглашает пользователя по имени. Приглашать может только владелец
+func (r *TaskRepository) AddMember(collectionID, ownerID int, username, role string) (*Member, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireOwner(tx, collectionID, ownerID); err != nil {
+		return nil, err
+	}
+
+	var m Member
+	err = scanMember(tx.QueryRow(`
+	WITH m AS (
+		INSERT INTO collection_members (collection_id, user_id, role)
+		SELECT $1, id, $3 FROM users WHERE username = $2
+		RETURNING collection_id, user_id, role, added_at
+	)
+	SELECT `+memberColumns+` FROM m JOIN users u ON u.id = m.user_id`,
+		collectionID, username, role), &m)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrMemberExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, tx.Commit()
+}
+
+// UpdateMemberRole меняет роль участника. Менять роли может только владелец, свою роль владелец не меняет
+func (r *TaskRepository) UpdateMemberRole(collectionID, ownerID, memberID int, role string) (*Member, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := requireOwner(tx, collectionID, ownerID); err != nil {
+		return nil, err
+	}
+
+	var m Member
+	err = scanMember(tx.QueryRow(`
+	WITH m AS (
+		UPDATE collection_members SET role = $3
+		WHERE collection_id = $1 AND user_id = $2 AND role <> 'owner'
+		RETURNING collection_id, user_id, role, added_at
+	)
+	SELECT `+memberColumns+` FROM m JOIN users u ON u.id = m.user_id`,
+		collectionID, memberID, role), &m)
+	if err == sql.ErrNoRows {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, tx.Commit()
+}
+
+// RemoveMember исключает участника. Владелец исключает кого угодно, кроме себя;
+// остальные участники могут только выйти из коллекции сами
+func (r *TaskRepository) RemoveMember(collectionID, userID, memberID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	role, err := collectionRole(tx, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if userID == memberID && role == RoleOwner {
+		return ErrForbidden
+	}
+	if userID != memberID && role != RoleOwner {
+		return ErrForbidden
+	}
+
+	result, err := tx.Exec(`
+	DELETE FROM collection_members
+	WHERE collection_id = $1 AND user_id = $2 AND role <> 'owner'`, collectionID, memberID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrMemberNotFound
+	}
+
+	return tx.Commit()
+}
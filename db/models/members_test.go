@@ -0,0 +1,453 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// roleQuery запрос роли пользователя в коллекции (collectionRole)
+const roleQuery = `SELECT m.role FROM collection_members m JOIN collections c`
+
+func roleRows(role string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"role"}).AddRow(role)
+}
+
+// readableRe, writableRe и manageableRe условия доступа к задаче в виде регулярки для sqlmock
+func readableRe(param string) string {
+	return regexp.QuoteMeta(taskReadable("", param))
+}
+
+func writableRe(param string) string {
+	return regexp.QuoteMeta(taskWritable("", param))
+}
+
+func manageableRe(param string) string {
+	return regexp.QuoteMeta(taskManageable("", param))
+}
+
+func memberRows(members ...Member) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"collection_id", "user_id", "username", "role", "added_at"})
+	for _, m := range members {
+		rows.AddRow(m.CollectionID, m.UserID, m.Username, m.Role, m.AddedAt)
+	}
+	return rows
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ условий доступа
+// ============================================================================
+
+func TestTaskAccessConditions(t *testing.T) {
+	want := "(t.collection_id IS NULL AND t.user_id = $2 OR t.collection_id IN (SELECT collection_id FROM collection_members WHERE user_id = $2) OR t.assignee_id = $2 AND t.assignment_status IN ('pending', 'accepted'))"
+	if got := taskReadable("t.", "$2"); got != want {
+		t.Errorf("taskReadable = %q, ожидалось %q", got, want)
+	}
+
+	manageable := "(collection_id IS NULL AND user_id = $1 OR collection_id IN (SELECT collection_id FROM collection_members WHERE user_id = $1 AND role IN ('owner', 'editor')))"
+	if got := taskManageable("", "$1"); got != manageable {
+		t.Errorf("taskManageable = %q, ожидалось %q", got, manageable)
+	}
+
+	want = "(" + manageable + " OR assignee_id = $1 AND assignment_status = 'accepted')"
+	if got := taskWritable("", "$1"); got != want {
+		t.Errorf("taskWritable = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestIsValidInviteRole(t *testing.T) {
+	cases := map[string]bool{
+		RoleEditor: true,
+		RoleViewer: true,
+		RoleOwner:  false,
+		"admin":    false,
+		"":         false,
+	}
+	for role, want := range cases {
+		if got := IsValidInviteRole(role); got != want {
+			t.Errorf("IsValidInviteRole(%q) = %v, ожидалось %v", role, got, want)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetMembers
+// ============================================================================
+
+func TestGetMembersSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleViewer))
+	mock.ExpectQuery(`FROM collection_members m JOIN users u ON u.id = m.user_id\s+WHERE m.collection_id = \$1\s+ORDER BY m.role = 'owner' DESC`).
+		WithArgs(3).
+		WillReturnRows(memberRows(
+			Member{CollectionID: 3, UserID: 1, Username: "alice", Role: RoleOwner, AddedAt: now},
+			Member{CollectionID: 3, UserID: 2, Username: "bob", Role: RoleViewer, AddedAt: now},
+		))
+
+	members, err := repo.GetMembers(3, 2)
+	if err != nil {
+		t.Fatalf("GetMembers вернул ошибку: %v", err)
+	}
+	if len(members) != 2 || members[0].Role != RoleOwner || members[1].Username != "bob" {
+		t.Errorf("Неправильный список участников: %+v", members)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetMembersNotMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 9).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetMembers(3, 9); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ErrCollectionNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ AddMember
+// ============================================================================
+
+func TestAddMemberSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`INSERT INTO collection_members \(collection_id, user_id, role\)\s+SELECT \$1, id, \$3 FROM users WHERE username = \$2`).
+		WithArgs(3, "bob", RoleEditor).
+		WillReturnRows(memberRows(Member{CollectionID: 3, UserID: 2, Username: "bob", Role: RoleEditor, AddedAt: time.Now()}))
+	mock.ExpectCommit()
+
+	member, err := repo.AddMember(3, 1, "bob", RoleEditor)
+	if err != nil {
+		t.Fatalf("AddMember вернул ошибку: %v", err)
+	}
+	if member.UserID != 2 || member.Role != RoleEditor {
+		t.Errorf("Неправильный участник: %+v", member)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestAddMemberUnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`INSERT INTO collection_members`).
+		WithArgs(3, "nobody", RoleViewer).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := repo.AddMember(3, 1, "nobody", RoleViewer); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+}
+
+func TestAddMemberAlreadyMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`INSERT INTO collection_members`).
+		WithArgs(3, "bob", RoleViewer).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	if _, err := repo.AddMember(3, 1, "bob", RoleViewer); !errors.Is(err, ErrMemberExists) {
+		t.Errorf("Ожидалась ErrMemberExists, получено %v", err)
+	}
+}
+
+func TestAddMemberByEditorForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectRollback()
+
+	if _, err := repo.AddMember(3, 2, "carol", RoleViewer); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ UpdateMemberRole
+// ============================================================================
+
+func TestUpdateMemberRoleSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`UPDATE collection_members SET role = \$3\s+WHERE collection_id = \$1 AND user_id = \$2 AND role <> 'owner'`).
+		WithArgs(3, 2, RoleViewer).
+		WillReturnRows(memberRows(Member{CollectionID: 3, UserID: 2, Username: "bob", Role: RoleViewer, AddedAt: time.Now()}))
+	mock.ExpectCommit()
+
+	member, err := repo.UpdateMemberRole(3, 1, 2, RoleViewer)
+	if err != nil {
+		t.Fatalf("UpdateMemberRole вернул ошибку: %v", err)
+	}
+	if member.Role != RoleViewer {
+		t.Errorf("Ожидалась роль viewer, получено %+v", member)
+	}
+}
+
+func TestUpdateMemberRoleOwnerUntouched(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Строка владельца не попадает под role <> 'owner'
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`UPDATE collection_members SET role`).
+		WithArgs(3, 1, RoleEditor).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := repo.UpdateMemberRole(3, 1, 1, RoleEditor); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("Ожидалась ErrMemberNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ RemoveMember
+// ============================================================================
+
+func TestRemoveMemberByOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectExec(`DELETE FROM collection_members\s+WHERE collection_id = \$1 AND user_id = \$2 AND role <> 'owner'`).
+		WithArgs(3, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RemoveMember(3, 1, 2); err != nil {
+		t.Fatalf("RemoveMember вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRemoveMemberLeave(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleViewer))
+	mock.ExpectExec(`DELETE FROM collection_members`).
+		WithArgs(3, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RemoveMember(3, 2, 2); err != nil {
+		t.Fatalf("RemoveMember вернул ошибку: %v", err)
+	}
+}
+
+func TestRemoveMemberForbidden(t *testing.T) {
+	cases := []struct {
+		name     string
+		userID   int
+		role     string
+		memberID int
+	}{
+		{"владелец не может выйти", 1, RoleOwner, 1},
+		{"редактор не исключает других", 2, RoleEditor, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Ошибка создания mock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewTaskRepository(db)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(roleQuery).
+				WithArgs(3, tc.userID).
+				WillReturnRows(roleRows(tc.role))
+			mock.ExpectRollback()
+
+			if err := repo.RemoveMember(3, tc.userID, tc.memberID); !errors.Is(err, ErrForbidden) {
+				t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Не выполнены ожидания mock: %v", err)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ прав на коллекцию
+// ============================================================================
+
+func TestUpdateCollectionByUserViewerForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	name := "Work"
+
+	mock.ExpectQuery(`UPDATE collections`).
+		WithArgs(&name, nil, nil, 3, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleViewer))
+
+	if _, err := repo.UpdateCollectionByUser(3, 2, CollectionUpdate{Name: &name}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+}
+
+func TestCreateTaskInCollectionAsViewer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	collectionID := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleViewer))
+	mock.ExpectRollback()
+
+	err = repo.CreateTask(&Task{UserID: 2, Name: "Task", CollectionID: &collectionID, Priority: PriorityNone}, false)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+}
+
+func TestCreateCollectionAddsOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO collections (.+)INSERT INTO collection_members \(collection_id, user_id, role\)\s+SELECT id, user_id, 'owner' FROM c`).
+		WithArgs(1, nil, "Work", "#ff0000", "💼").
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, Name: "Work", Color: "#ff0000", Icon: "💼", CreatedAt: time.Now()}))
+
+	collection := &Collection{UserID: 1, Name: "Work", Color: "#ff0000", Icon: "💼"}
+	if err := repo.CreateCollection(collection); err != nil {
+		t.Fatalf("CreateCollection вернул ошибку: %v", err)
+	}
+	if collection.ID != 3 || collection.Role != RoleOwner {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
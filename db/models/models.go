@@ -2,28 +2,348 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// ErrTaskNotFound задача не существует или принадлежит другому пользователю
+var ErrTaskNotFound = errors.New("task not found or access denied")
+
+// ErrInvalidSchedule start_at задачи позже её due_at
+var ErrInvalidSchedule = errors.New("start_at must not be after due_at")
+
+// ErrCollectionNotFound коллекция не существует или принадлежит другому пользователю
+var ErrCollectionNotFound = errors.New("collection not found or access denied")
+
+var (
+	ErrTagNotFound = errors.New("tag not found or access denied")
+	ErrTagExists   = errors.New("tag with this name already exists")
+	// ErrTagCreationDenied задаче назначен несуществующий тег, а создавать теги вызывающему нельзя
+	ErrTagCreationDenied = errors.New("tag does not exist and creating tags is not allowed")
+)
+
+// ErrTaskNotCompletable и ErrTaskNotReopenable: задача не в том состоянии, не найдена или недоступна на запись
+var (
+	ErrTaskNotCompletable = errors.New("task already completed, not found, or access denied")
+	ErrTaskNotReopenable  = errors.New("task not completed, not found, or access denied")
+)
+
+var (
+	ErrChecklistItemNotFound = errors.New("checklist item not found or access denied")
+	ErrChecklistOrder        = errors.New("item_ids must list every checklist item of the task exactly once")
+)
+
+// MaxChecklistTextLength совпадает с размером колонки checklist_items.text
+const MaxChecklistTextLength = 500
+
+// MaxTaskNameLength совпадает с размером колонки tasks.name, длина считается в рунах
+const MaxTaskNameLength = 255
+
+// MaxTagNameLength совпадает с размером колонки tags.name, длина считается в рунах
+const MaxTagNameLength = 50
+
+// Ограничения совпадают с размерами колонок collections.name и collections.icon.
+// VARCHAR считает символы, а не байты, поэтому длина проверяется в рунах
+const (
+	MaxCollectionNameLength = 100
+	MaxCollectionIconLength = 50
 )
 
+// colorPattern цвет в формате #RRGGBB, ровно под колонку VARCHAR(7)
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func IsValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
 type Task struct {
-	ID           int        `json:"id"`
-	UserID       int        `json:"user_id"`
-	CollectionID *int       `json:"collection_id"`
-	Name         string     `json:"name"`
-	Text         string     `json:"text"`
-	CreateTime   time.Time  `json:"create_time"`
-	Complete     bool       `json:"complete"`
-	CompleteAt   *time.Time `json:"complete_at"`
+	ID               int               `json:"id"`
+	UserID           int               `json:"user_id"`
+	CollectionID     *int              `json:"collection_id"`
+	Name             string            `json:"name"`
+	Text             string            `json:"text"`
+	CreateTime       time.Time         `json:"create_time"`
+	Complete         bool              `json:"complete"`
+	CompleteAt       *time.Time        `json:"complete_at"`
+	DueAt            *time.Time        `json:"due_at"`
+	StartAt          *time.Time        `json:"start_at"`
+	Priority         string            `json:"priority"`
+	Recurrence       *string           `json:"recurrence"`
+	Tags             []string          `json:"tags"`
+	Progress         ChecklistProgress `json:"progress"`
+	AssigneeID       *int              `json:"assignee_id"`
+	AssignmentStatus *string           `json:"assignment_status"`
+	Position         int64             `json:"position"`
+}
+
+// ChecklistProgress сводка по чек-листу задачи: сколько пунктов отмечено из общего числа
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type ChecklistItem struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	Text      string    `json:"text"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChecklistItemUpdate частичное обновление пункта чек-листа
+type ChecklistItemUpdate struct {
+	Text *string `json:"text,omitempty"`
+	Done *bool   `json:"done,omitempty"`
+}
+
+// Приоритеты задачи, от низшего к высшему
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+func IsValidPriority(p string) bool {
+	switch p {
+	case PriorityNone, PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// Поля, по которым можно сортировать списки задач. SortPosition — ручной порядок пользователя
+const (
+	SortPosition   = "position"
+	SortPriority   = "priority"
+	SortDueAt      = "due_at"
+	SortName       = "name"
+	SortCreateTime = "create_time"
+	SortCompleteAt = "complete_at"
+)
+
+var taskSortExprs = map[string]string{
+	SortPosition:   "position",
+	SortPriority:   "CASE priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END",
+	SortDueAt:      "due_at",
+	SortName:       "name",
+	SortCreateTime: "create_time",
+	SortCompleteAt: "complete_at",
+}
+
+// ListOptions параметры сортировки и страницы списка задач. Нулевое значение — весь список, create_time DESC;
+// обработчики по умолчанию просят ручной порядок (position ASC)
+type ListOptions struct {
+	Sort  string
+	Order string
+	PageParams
 }
 
+// orderClause строит ORDER BY; неизвестное поле молча заменяется на create_time
+func (o ListOptions) orderClause() string {
+	expr, ok := taskSortExprs[o.Sort]
+	if !ok {
+		expr = taskSortExprs[SortCreateTime]
+	}
+
+	dir := "DESC"
+	if o.Order == "asc" {
+		dir = "ASC"
+	}
+	return "ORDER BY " + expr + " " + dir + " NULLS LAST, id " + dir
+}
+
+// listClause дописывает к WHERE условие страницы, ORDER BY и LIMIT; args — уже занятые параметры.
+// Keyset идёт по (position, id) или (create_time, id), поэтому постраничная выдача в остальных
+// сортировках идёт по create_time
+func (o ListOptions) listClause(args ...interface{}) (string, []interface{}) {
+	if o.Paginated() && o.Sort != SortPosition {
+		o.Sort = SortCreateTime
+	}
+	where, limit, args := o.keyset(o.Sort, o.Order != "asc", args)
+	return where + "\n\t" + o.orderClause() + limit, args
+}
+
+// CursorOf курсор задачи для keyset выбранной сортировки
+func (o ListOptions) CursorOf(t Task) Cursor {
+	if o.Sort == SortPosition {
+		return TaskPositionCursor(t)
+	}
+	return TaskCursor(t)
+}
+
+// taskColumns порядок колонок задачи для SELECT/RETURNING, совпадает с scanTask.
+// Теги и прогресс чек-листа подтягиваются подзапросами, чтобы списки не делали отдельный запрос на каждую задачу
+const taskColumns = `id, user_id, collection_id, name, text, complete, create_time, complete_at, due_at, start_at, priority, recurrence,
+	COALESCE((SELECT array_agg(tg.name ORDER BY tg.name) FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE tt.task_id = tasks.id), '{}'),
+	(SELECT COUNT(*) FILTER (WHERE ci.done) FROM checklist_items ci WHERE ci.task_id = tasks.id),
+	(SELECT COUNT(*) FROM checklist_items ci WHERE ci.task_id = tasks.id),
+	assignee_id, assignment_status, position`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner, task *Task) error {
+	return row.Scan(taskDest(task)...)
+}
+
+// taskDest адреса полей задачи в порядке taskColumns; запросы с дополнительными колонками дописывают свои в конец
+func taskDest(task *Task) []interface{} {
+	return []interface{}{
+		&task.ID,
+		&task.UserID,
+		&task.CollectionID,
+		&task.Name,
+		&task.Text,
+		&task.Complete,
+		&task.CreateTime,
+		&task.CompleteAt,
+		&task.DueAt,
+		&task.StartAt,
+		&task.Priority,
+		&task.Recurrence,
+		pq.Array(&task.Tags),
+		&task.Progress.Done,
+		&task.Progress.Total,
+		&task.AssigneeID,
+		&task.AssignmentStatus,
+		&task.Position,
+	}
+}
+
+func scanTasks(rows *sql.Rows) ([]Task, error) {
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		if err := scanTask(rows, &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// Optional различает отсутствующее поле и явный null в JSON
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
+
+// TaskUpdate частичное обновление задачи: nil/не заданные поля не трогаем
+type TaskUpdate struct {
+	Name         *string             `json:"name,omitempty"`
+	Text         *string             `json:"text,omitempty"`
+	CollectionID Optional[int]       `json:"collection_id,omitzero"`
+	DueAt        Optional[time.Time] `json:"due_at,omitzero"`
+	StartAt      Optional[time.Time] `json:"start_at,omitzero"`
+	Priority     *string             `json:"priority,omitempty"`
+	Recurrence   Optional[string]    `json:"recurrence,omitzero"`
+	AddTags      []string            `json:"add_tags,omitempty"`
+	RemoveTags   []string            `json:"remove_tags,omitempty"`
+	// ExistingTagsOnly запрещает создавать теги из AddTags, задаётся параметром запроса, а не телом
+	ExistingTagsOnly bool `json:"-"`
+}
+
+// FieldChange одно изменённое поле задачи
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Collection коллекция задач. UserID — владелец; ParentID — родительская коллекция, nil на верхнем уровне;
+// Role — роль текущего пользователя, заполняется в списке коллекций
 type Collection struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
+	ParentID  *int      `json:"parent_id"`
 	Name      string    `json:"name"`
 	Color     string    `json:"color"`
 	Icon      string    `json:"icon"`
 	CreatedAt time.Time `json:"created_at"`
+	Position  int64     `json:"position"`
+	Role      string    `json:"role,omitempty"`
+}
+
+// CollectionUpdate частичное обновление коллекции
+type CollectionUpdate struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+	Icon  *string `json:"icon,omitempty"`
+}
+
+// Validate проверяет заданные поля и обрезает пробелы в названии
+func (u *CollectionUpdate) Validate() error {
+	if u.Name != nil {
+		name := strings.TrimSpace(*u.Name)
+		if name == "" || utf8.RuneCountInString(name) > MaxCollectionNameLength {
+			return fmt.Errorf("collection name must be 1-%d characters", MaxCollectionNameLength)
+		}
+		u.Name = &name
+	}
+	if u.Color != nil && !IsValidColor(*u.Color) {
+		return fmt.Errorf("color must be a #RRGGBB hex value, got %q", *u.Color)
+	}
+	if u.Icon != nil && (*u.Icon == "" || utf8.RuneCountInString(*u.Icon) > MaxCollectionIconLength) {
+		return fmt.Errorf("icon must be 1-%d characters", MaxCollectionIconLength)
+	}
+	return nil
+}
+
+type Tag struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagUpdate частичное обновление тега
+type TagUpdate struct {
+	Name  *string `json:"name,omitempty"`
+	Color *string `json:"color,omitempty"`
+}
+
+// NormalizeTagNames обрезает пробелы, убирает дубликаты и сортирует имена тегов.
+// Запятая запрещена: она разделяет теги в фильтре /get?tag=
+func NormalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || utf8.RuneCountInString(name) > MaxTagNameLength || strings.Contains(name, ",") {
+			return nil, fmt.Errorf("invalid tag name %q", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
 }
 
 type User struct {
@@ -36,6 +356,8 @@ type User struct {
 type CreateUserRequest struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"password_hash"`
+	// Email необязателен: без него пароль нельзя сбросить по почте
+	Email string `json:"email,omitempty"`
 }
 
 type TaskRepository struct {
@@ -88,49 +410,65 @@ func (r *TaskRepository) CreateTable() error {
 	return err
 }
 
-func (r *TaskRepository) CreateTask(task *Task) error {
-	return r.DB.QueryRow(`
-	INSERT INTO tasks (user_id, collection_id, name, text, complete, create_time) 
-	VALUES ($1, $2, $3, $4, FALSE, Now()) 
-	RETURNING id, user_id, collection_id, name, text, complete, create_time, complete_at`,
-		task.UserID, task.CollectionID, task.Name, task.Text).Scan(
-		&task.ID,
-		&task.UserID,
-		&task.CollectionID,
-		&task.Name,
-		&task.Text,
-		&task.Complete,
-		&task.CreateTime,
-		&task.CompleteAt)
+// CreateTask создаёт задачу и привязывает к ней task.Tags. Несуществующие теги создаются, а с existingTagsOnly —
+// ErrTagCreationDenied. Класть задачу в коллекцию могут её владелец и редакторы (см. checkCollectionWritable)
+func (r *TaskRepository) CreateTask(task *Task, existingTagsOnly bool) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkCollectionWritable(tx, task.CollectionID, task.UserID); err != nil {
+		return err
+	}
+
+	if err := insertTask(tx, task, existingTagsOnly); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *TaskRepository) GetAllTasksByUser(userID int) ([]Task, error) {
-	rows, err := r.DB.Query(`
-	SELECT id, user_id, collection_id, name, text, complete, create_time, complete_at FROM tasks
-	WHERE user_id = $1
-	ORDER BY create_time DESC`, userID)
+// insertTask вставляет задачу вместе с тегами в рамках уже открытой транзакции
+func insertTask(tx *sql.Tx, task *Task, existingTagsOnly bool) error {
+	tags := task.Tags
+	if existingTagsOnly && len(tags) > 0 {
+		if err := checkTagsExist(tx, task.UserID, tags); err != nil {
+			return err
+		}
+	}
+
+	// Новая задача встаёт в начало своего списка, как и раньше, когда списки шли от новых к старым
+	err := scanTask(tx.QueryRow(`
+	INSERT INTO tasks (user_id, collection_id, name, text, complete, create_time, due_at, start_at, priority, recurrence, position) 
+	VALUES ($1, $2, $3, $4, FALSE, Now(), $5, $6, $7, $8,
+		(SELECT COALESCE(MIN(position), 0) - `+strconv.Itoa(positionGap)+` FROM tasks WHERE `+taskListScope("$2", "$1")+`)) 
+	RETURNING `+taskColumns,
+		task.UserID, task.CollectionID, task.Name, task.Text, task.DueAt, task.StartAt, task.Priority, task.Recurrence), task)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var tasks []Task
-	for rows.Next() {
-		var task Task
-		if err := rows.Scan(
-			&task.ID,
-			&task.UserID,
-			&task.CollectionID,
-			&task.Name,
-			&task.Text,
-			&task.Complete,
-			&task.CreateTime,
-			&task.CompleteAt); err != nil {
-			return nil, err
+	if len(tags) > 0 {
+		if err := attachTags(tx, task.UserID, task.ID, tags, !existingTagsOnly); err != nil {
+			return err
 		}
-		tasks = append(tasks, task)
+		task.Tags = tags
 	}
-	return tasks, nil
+	return nil
+}
+
+func (r *TaskRepository) GetAllTasksByUser(userID int, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID)
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE `+taskReadable("", "$1")+` AND deleted_at IS NULL
+	`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
 }
 
 func (r *TaskRepository) GetAllTasks() ([]Task, error) {
@@ -159,32 +497,16 @@ func (r *TaskRepository) GetAllTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-func (r *TaskRepository) GetCompletedTasksByUser(userID int) ([]Task, error) {
+func (r *TaskRepository) GetCompletedTasksByUser(userID int, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID)
 	rows, err := r.DB.Query(`
-	SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks 
-	WHERE complete = TRUE AND user_id = $1
-	ORDER BY create_time DESC`, userID)
+	SELECT `+taskColumns+` FROM tasks 
+	WHERE complete = TRUE AND `+taskReadable("", "$1")+` AND deleted_at IS NULL
+	`+clause, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var tasks []Task
-	for rows.Next() {
-		var task Task
-		if err := rows.Scan(
-			&task.ID,
-			&task.UserID,
-			&task.Name,
-			&task.Text,
-			&task.Complete,
-			&task.CreateTime,
-			&task.CompleteAt); err != nil {
-			return nil, err
-		}
-		tasks = append(tasks, task)
-	}
-	return tasks, nil
+	return scanTasks(rows)
 }
 
 func (r *TaskRepository) GetCompletedTasks() ([]Task, error) {
@@ -214,34 +536,43 @@ func (r *TaskRepository) GetCompletedTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-func (r *TaskRepository) GetUncompletedTasksByUser(userID int) ([]Task, error) {
+func (r *TaskRepository) GetUncompletedTasksByUser(userID int, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID)
 	rows, err := r.DB.Query(`
-	SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks
-	WHERE complete = FALSE AND user_id = $1
-	ORDER BY create_time DESC`, userID)
+	SELECT `+taskColumns+` FROM tasks
+	WHERE complete = FALSE AND `+taskReadable("", "$1")+` AND deleted_at IS NULL
+	`+clause, args...)
 
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanTasks(rows)
+}
 
-	var tasks []Task
+// GetTasksDueBetween задачи пользователя со сроком в интервале [from, to)
+func (r *TaskRepository) GetTasksDueBetween(userID int, from, to time.Time, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID, from, to)
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE `+taskReadable("", "$1")+` AND deleted_at IS NULL AND due_at >= $2 AND due_at < $3
+	`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
 
-	for rows.Next() {
-		var task Task
-		if err := rows.Scan(
-			&task.ID,
-			&task.UserID,
-			&task.Name,
-			&task.Text,
-			&task.Complete,
-			&task.CreateTime,
-			&task.CompleteAt); err != nil {
-			return nil, err
-		}
-		tasks = append(tasks, task)
+// GetOverdueTasksByUser невыполненные задачи, срок которых уже прошёл к моменту now
+func (r *TaskRepository) GetOverdueTasksByUser(userID int, now time.Time, opts ListOptions) ([]Task, error) {
+	clause, args := opts.listClause(userID, now)
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE `+taskReadable("", "$1")+` AND deleted_at IS NULL AND complete = FALSE AND due_at < $2
+	`+clause, args...)
+	if err != nil {
+		return nil, err
 	}
-	return tasks, nil
+	return scanTasks(rows)
 }
 
 func (r *TaskRepository) GetUncompletedTasks() ([]Task, error) {
@@ -273,56 +604,275 @@ func (r *TaskRepository) GetUncompletedTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-func (r *TaskRepository) GetTaskByID(id int) (*Task, error) {
+// GetTaskByUser возвращает задачу пользователя. Чужая задача неотличима от несуществующей
+func (r *TaskRepository) GetTaskByUser(id, userID int) (*Task, error) {
 	var task Task
-
-	err := r.DB.QueryRow(`
-	SELECT * FROM tasks 
-	WHERE id = $1`,
-		id).Scan(&task.ID,
-		&task.Name,
-		&task.Text,
-		&task.Complete,
-		&task.CreateTime,
-		&task.CompleteAt)
-
+	err := scanTask(r.DB.QueryRow(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE id = $1 AND `+taskReadable("", "$2")+` AND deleted_at IS NULL`, id, userID), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &task, nil
 }
 
-func (r *TaskRepository) GetIDByName(name string) (*Task, error) {
+// GetTaskByNameByUser возвращает задачу пользователя по точному названию.
+// Названия не уникальны, при совпадении берётся самая новая задача
+func (r *TaskRepository) GetTaskByNameByUser(name string, userID int) (*Task, error) {
 	var task Task
-
-	err := r.DB.QueryRow(`SELECT * FROM tasks 
-	WHERE name = $1`,
-		name).Scan(
-		&task.ID,
-		&task.Name,
-		&task.Text,
-		&task.Complete,
-		&task.CreateTime,
-		&task.CompleteAt)
-
+	err := scanTask(r.DB.QueryRow(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE name = $1 AND `+taskReadable("", "$2")+` AND deleted_at IS NULL
+	ORDER BY create_time DESC, id DESC
+	LIMIT 1`, name, userID), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &task, nil
 }
 
-func (r *TaskRepository) DeleteTaskByUser(id, userID int) error {
-	result, err := r.DB.Exec(`DELETE FROM tasks WHERE id = $1 AND user_id = $2`, id, userID)
+// UpdateTaskByUser меняет только переданные поля задачи пользователя и возвращает diff
+func (r *TaskRepository) UpdateTaskByUser(id, userID int, upd TaskUpdate) (*Task, []FieldChange, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	task, changes, err := updateTask(tx, id, userID, upd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return task, changes, tx.Commit()
+}
+
+// updateTask применяет частичное обновление в транзакции tx. Все проверки идут до первой записи,
+// поэтому при ошибке задача остаётся нетронутой
+func updateTask(tx *sql.Tx, id, userID int, upd TaskUpdate) (*Task, []FieldChange, error) {
+	var task Task
+	err := scanTask(tx.QueryRow(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE id = $1 AND `+taskWritable("", "$2")+` AND deleted_at IS NULL
+	FOR UPDATE`, id, userID), &task)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Интервал проверяется по итоговым значениям: PATCH может менять только одну его границу
+	if upd.StartAt.Set || upd.DueAt.Set {
+		startAt, dueAt := task.StartAt, task.DueAt
+		if upd.StartAt.Set {
+			startAt = upd.StartAt.Value
+		}
+		if upd.DueAt.Set {
+			dueAt = upd.DueAt.Value
+		}
+		if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+			return nil, nil, ErrInvalidSchedule
+		}
+	}
+
+	if upd.ExistingTagsOnly && len(upd.AddTags) > 0 {
+		if err := checkTagsExist(tx, userID, upd.AddTags); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	changes := []FieldChange{}
+	var sets []string
+	var args []interface{}
+
+	if upd.Name != nil && *upd.Name != task.Name {
+		changes = append(changes, FieldChange{Field: "name", Old: task.Name, New: *upd.Name})
+		args = append(args, *upd.Name)
+		sets = append(sets, "name = $"+strconv.Itoa(len(args)))
+	}
+	if upd.Text != nil && *upd.Text != task.Text {
+		changes = append(changes, FieldChange{Field: "text", Old: task.Text, New: *upd.Text})
+		args = append(args, *upd.Text)
+		sets = append(sets, "text = $"+strconv.Itoa(len(args)))
+	}
+	if upd.CollectionID.Set && !equalIntPtr(upd.CollectionID.Value, task.CollectionID) {
+		if task.AssigneeID != nil && *task.AssigneeID == userID {
+			if err := checkTaskManageable(tx, id, userID); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := checkCollectionWritable(tx, upd.CollectionID.Value, userID); err != nil {
+			return nil, nil, err
+		}
+		if task.UserID != userID {
+			if err := checkKeepsCreator(tx, id, userID, upd.CollectionID.Value); err != nil {
+				return nil, nil, err
+			}
+		}
+		changes = append(changes, FieldChange{Field: "collection_id", Old: task.CollectionID, New: upd.CollectionID.Value})
+		args = append(args, upd.CollectionID.Value)
+		sets = append(sets, "collection_id = $"+strconv.Itoa(len(args)))
+	}
+	if upd.DueAt.Set && !equalTimePtr(upd.DueAt.Value, task.DueAt) {
+		changes = append(changes, FieldChange{Field: "due_at", Old: task.DueAt, New: upd.DueAt.Value})
+		args = append(args, upd.DueAt.Value)
+		sets = append(sets, "due_at = $"+strconv.Itoa(len(args)))
+	}
+	if upd.StartAt.Set && !equalTimePtr(upd.StartAt.Value, task.StartAt) {
+		changes = append(changes, FieldChange{Field: "start_at", Old: task.StartAt, New: upd.StartAt.Value})
+		args = append(args, upd.StartAt.Value)
+		sets = append(sets, "start_at = $"+strconv.Itoa(len(args)))
+	}
+	if upd.Priority != nil && *upd.Priority != task.Priority {
+		changes = append(changes, FieldChange{Field: "priority", Old: task.Priority, New: *upd.Priority})
+		args = append(args, *upd.Priority)
+		sets = append(sets, "priority = $"+strconv.Itoa(len(args)))
+	}
+	if upd.Recurrence.Set && !equalStringPtr(upd.Recurrence.Value, task.Recurrence) {
+		changes = append(changes, FieldChange{Field: "recurrence", Old: task.Recurrence, New: upd.Recurrence.Value})
+		args = append(args, upd.Recurrence.Value)
+		sets = append(sets, "recurrence = $"+strconv.Itoa(len(args)))
+	}
+
+	oldTags := task.Tags
+	newTags := applyTagChanges(oldTags, upd.AddTags, upd.RemoveTags)
+	tagsChanged := !equalStrings(oldTags, newTags)
+	if tagsChanged {
+		changes = append(changes, FieldChange{Field: "tags", Old: oldTags, New: newTags})
+	}
+
+	if len(sets) > 0 {
+		args = append(args, id)
+		err = scanTask(tx.QueryRow(fmt.Sprintf(`
+	UPDATE tasks SET %s
+	WHERE id = $%d
+	RETURNING `+taskColumns,
+			strings.Join(sets, ", "), len(args)), args...), &task)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if tagsChanged {
+		if len(upd.RemoveTags) > 0 {
+			if err := detachTags(tx, userID, id, upd.RemoveTags); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(upd.AddTags) > 0 {
+			if err := attachTags(tx, userID, id, upd.AddTags, !upd.ExistingTagsOnly); err != nil {
+				return nil, nil, err
+			}
+		}
+		task.Tags = newTags
+	}
+
+	return &task, changes, nil
+}
+
+// applyTagChanges считает итоговый набор тегов: сначала удаление, потом добавление
+func applyTagChanges(current, add, remove []string) []string {
+	set := make(map[string]bool, len(current)+len(add))
+	for _, name := range current {
+		set[name] = true
+	}
+	for _, name := range remove {
+		delete(set, name)
+	}
+	for _, name := range add {
+		set[name] = true
+	}
+
+	result := make([]string, 0, len(set))
+	for name := range set {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTagsExist ErrTagCreationDenied, если какого-то из тегов у пользователя нет. names уже нормализованы,
+// повторов в них нет. Вызывается до первой записи, чтобы отказ не оставил задачу изменённой наполовину
+func checkTagsExist(tx *sql.Tx, userID int, names []string) error {
+	var existing int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM tags WHERE user_id = $1 AND name = ANY($2)`,
+		userID, pq.Array(names)).Scan(&existing)
 	if err != nil {
 		return err
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return fmt.Errorf("task not found or access denied")
+	if existing < len(names) {
+		return ErrTagCreationDenied
 	}
 	return nil
 }
 
+// attachTags привязывает теги к задаче; с createMissing недостающие теги пользователя создаются,
+// без него привязываются только существующие
+func attachTags(tx *sql.Tx, userID, taskID int, names []string, createMissing bool) error {
+	if createMissing {
+		_, err := tx.Exec(`
+	INSERT INTO tags (user_id, name)
+	SELECT $1, unnest($2::text[])
+	ON CONFLICT (user_id, name) DO NOTHING`, userID, pq.Array(names))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(`
+	INSERT INTO task_tags (task_id, tag_id)
+	SELECT $1, id FROM tags WHERE user_id = $2 AND name = ANY($3)
+	ON CONFLICT DO NOTHING`, taskID, userID, pq.Array(names))
+	return err
+}
+
+func detachTags(tx *sql.Tx, userID, taskID int, names []string) error {
+	_, err := tx.Exec(`
+	DELETE FROM task_tags
+	WHERE task_id = $1 AND tag_id IN (SELECT id FROM tags WHERE user_id = $2 AND name = ANY($3))`,
+		taskID, userID, pq.Array(names))
+	return err
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (r *TaskRepository) DeleteTask(id int) error {
 	_, err := r.DB.Exec(`DELETE FROM tasks WHERE id = $1`, id)
 	return err
@@ -330,25 +880,40 @@ func (r *TaskRepository) DeleteTask(id int) error {
 
 // Collection methods
 
+// CreateCollection создаёт коллекцию в конце списка соседей и в том же запросе записывает создателя её владельцем.
+// Вложить новую коллекцию можно только в свою: иначе ErrCollectionNotFound или ErrForbidden
 func (r *TaskRepository) CreateCollection(collection *Collection) error {
+	if collection.ParentID != nil {
+		if err := requireOwner(r.DB, *collection.ParentID, collection.UserID); err != nil {
+			return err
+		}
+	}
+
+	collection.Role = RoleOwner
 	return r.DB.QueryRow(`
-	INSERT INTO collections (user_id, name, color, icon, created_at) 
-	VALUES ($1, $2, $3, $4, Now()) 
-	RETURNING id, user_id, name, color, icon, created_at`,
-		collection.UserID, collection.Name, collection.Color, collection.Icon).Scan(
-		&collection.ID,
-		&collection.UserID,
-		&collection.Name,
-		&collection.Color,
-		&collection.Icon,
-		&collection.CreatedAt)
-}
-
-func (r *TaskRepository) GetCollectionsByUser(userID int) ([]Collection, error) {
+	WITH c AS (
+		INSERT INTO collections (user_id, parent_id, name, color, icon, created_at, position) 
+		VALUES ($1, $2, $3, $4, $5, Now(),
+			(SELECT COALESCE(MAX(position), 0) + `+strconv.Itoa(positionGap)+` FROM collections WHERE `+collectionListScope("$2", "$1")+`)) 
+		RETURNING `+collectionColumns+`
+	), owner AS (
+		INSERT INTO collection_members (collection_id, user_id, role)
+		SELECT id, user_id, 'owner' FROM c
+	)
+	SELECT `+collectionColumns+` FROM c`,
+		collection.UserID, collection.ParentID, collection.Name, collection.Color, collection.Icon).Scan(collectionDest(collection)...)
+}
+
+// GetCollectionsByUser плоский список коллекций, где пользователь участник; дерево собирается по parent_id.
+// Родитель, в котором пользователь не участвует, не раскрывается: такая коллекция для него на верхнем уровне
+func (r *TaskRepository) GetCollectionsByUser(userID int, page PageParams) ([]Collection, error) {
+	where, limit, args := page.keyset("position", false, []interface{}{userID})
 	rows, err := r.DB.Query(`
-	SELECT id, user_id, name, color, icon, created_at FROM collections
-	WHERE user_id = $1
-	ORDER BY created_at ASC`, userID)
+	SELECT c.id, c.user_id, CASE WHEN `+memberOf("c.parent_id", "$1")+` THEN c.parent_id END,
+		c.name, c.color, c.icon, c.created_at, c.position, m.role FROM collections c
+	JOIN collection_members m ON m.collection_id = c.id AND m.user_id = $1
+	WHERE c.deleted_at IS NULL`+where+`
+	ORDER BY position ASC, id ASC`+limit, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -357,13 +922,7 @@ func (r *TaskRepository) GetCollectionsByUser(userID int) ([]Collection, error)
 	var collections []Collection
 	for rows.Next() {
 		var collection Collection
-		if err := rows.Scan(
-			&collection.ID,
-			&collection.UserID,
-			&collection.Name,
-			&collection.Color,
-			&collection.Icon,
-			&collection.CreatedAt); err != nil {
+		if err := rows.Scan(append(collectionDest(&collection), &collection.Role)...); err != nil {
 			return nil, err
 		}
 		collections = append(collections, collection)
@@ -371,53 +930,423 @@ func (r *TaskRepository) GetCollectionsByUser(userID int) ([]Collection, error)
 	return collections, rows.Err()
 }
 
-func (r *TaskRepository) DeleteCollectionByUser(id, userID int) error {
-	result, err := r.DB.Exec(`DELETE FROM collections WHERE id = $1 AND user_id = $2`, id, userID)
+// UpdateCollectionByUser меняет название, цвет и/или иконку коллекции; незаданные поля остаются прежними.
+// Доступно владельцу и редакторам. Коллекцию из корзины сначала нужно восстановить
+func (r *TaskRepository) UpdateCollectionByUser(id, userID int, upd CollectionUpdate) (*Collection, error) {
+	var collection Collection
+	err := r.DB.QueryRow(`
+	UPDATE collections SET name = COALESCE($1, name), color = COALESCE($2, color), icon = COALESCE($3, icon)
+	WHERE id = $4 AND deleted_at IS NULL AND `+memberOf("id", "$5", RoleOwner, RoleEditor)+`
+	RETURNING `+collectionColumns,
+		upd.Name, upd.Color, upd.Icon, id, userID).Scan(collectionDest(&collection)...)
+	if err == sql.ErrNoRows {
+		// Коллекция видна, но только для чтения — наблюдателю отвечаем ErrForbidden
+		if _, roleErr := collectionRole(r.DB, id, userID); roleErr == nil {
+			return nil, ErrForbidden
+		}
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// GetTasksByCollection задачи коллекции; recursive=true — вместе с задачами всех вложенных коллекций
+func (r *TaskRepository) GetTasksByCollection(userID, collectionID int, recursive bool, opts ListOptions) ([]Task, error) {
+	tree, scope := "", "collection_id = $2"
+	if recursive {
+		tree, scope = collectionTree("$2", "sub.deleted_at IS NULL"), "collection_id IN (SELECT id FROM tree)"
+	}
+
+	clause, args := opts.listClause(userID, collectionID)
+	rows, err := r.DB.Query(tree+`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE `+scope+` AND `+taskReadable("", "$1")+` AND deleted_at IS NULL
+	`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// GetTasksByTags возвращает задачи с любым (matchAll=false) или со всеми (matchAll=true) тегами
+func (r *TaskRepository) GetTasksByTags(userID int, names []string, matchAll bool, opts ListOptions) ([]Task, error) {
+	having := ""
+	if matchAll {
+		having = "GROUP BY tt.task_id HAVING COUNT(DISTINCT tg.id) = " + strconv.Itoa(len(names))
+	}
+
+	clause, args := opts.listClause(userID, pq.Array(names))
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+` FROM tasks
+	WHERE `+taskReadable("", "$1")+` AND deleted_at IS NULL AND id IN (
+		SELECT tt.task_id FROM task_tags tt JOIN tags tg ON tg.id = tt.tag_id
+		WHERE tg.user_id = $1 AND tg.name = ANY($2)
+		`+having+`)
+	`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTasks(rows)
+}
+
+// Tag methods
+
+func (r *TaskRepository) CreateTag(tag *Tag) error {
+	err := r.DB.QueryRow(`
+	INSERT INTO tags (user_id, name, color, created_at)
+	VALUES ($1, $2, $3, Now())
+	RETURNING id, user_id, name, color, created_at`,
+		tag.UserID, tag.Name, tag.Color).Scan(
+		&tag.ID,
+		&tag.UserID,
+		&tag.Name,
+		&tag.Color,
+		&tag.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrTagExists
+	}
+	return err
+}
+
+func (r *TaskRepository) GetTagsByUser(userID int, page PageParams) ([]Tag, error) {
+	where, limit, args := page.keyset("name", false, []interface{}{userID})
+	rows, err := r.DB.Query(`
+	SELECT id, user_id, name, color, created_at FROM tags
+	WHERE user_id = $1`+where+`
+	ORDER BY name ASC, id ASC`+limit, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(
+			&tag.ID,
+			&tag.UserID,
+			&tag.Name,
+			&tag.Color,
+			&tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// UpdateTagByUser переименовывает тег и/или меняет цвет; незаданные поля остаются прежними
+func (r *TaskRepository) UpdateTagByUser(id, userID int, upd TagUpdate) (*Tag, error) {
+	var tag Tag
+	err := r.DB.QueryRow(`
+	UPDATE tags SET name = COALESCE($1, name), color = COALESCE($2, color)
+	WHERE id = $3 AND user_id = $4
+	RETURNING id, user_id, name, color, created_at`,
+		upd.Name, upd.Color, id, userID).Scan(
+		&tag.ID,
+		&tag.UserID,
+		&tag.Name,
+		&tag.Color,
+		&tag.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTagNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTagByUser удаляет тег; связи с задачами удаляются каскадом
+func (r *TaskRepository) DeleteTagByUser(id, userID int) error {
+	result, err := r.DB.Exec(`DELETE FROM tags WHERE id = $1 AND user_id = $2`, id, userID)
 	if err != nil {
 		return err
 	}
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("collection not found or access denied")
+		return ErrTagNotFound
 	}
 	return nil
 }
 
-func (r *TaskRepository) GetTasksByCollection(userID, collectionID int) ([]Task, error) {
-	rows, err := r.DB.Query(`
-	SELECT id, user_id, collection_id, name, text, complete, create_time, complete_at FROM tasks
-	WHERE user_id = $1 AND collection_id = $2
-	ORDER BY create_time DESC`, userID, collectionID)
+// Checklist methods
+
+const checklistColumns = `ci.id, ci.task_id, ci.text, ci.done, ci.position, ci.created_at`
+
+func scanChecklistItem(row rowScanner, item *ChecklistItem) error {
+	return row.Scan(
+		&item.ID,
+		&item.TaskID,
+		&item.Text,
+		&item.Done,
+		&item.Position,
+		&item.CreatedAt)
+}
+
+// queryer общий интерфейс *sql.DB и *sql.Tx для запросов, которые выполняются в обоих
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// execer общий интерфейс *sql.DB и *sql.Tx для изменений без RETURNING
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// checkTaskReadable возвращает ErrTaskNotFound, если задача не видна пользователю
+func checkTaskReadable(q queryer, taskID, userID int) error {
+	var id int
+	err := q.QueryRow(`SELECT id FROM tasks WHERE id = $1 AND `+taskReadable("", "$2")+` AND deleted_at IS NULL`, taskID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrTaskNotFound
+	}
+	return err
+}
+
+func queryChecklist(q queryer, taskID int) ([]ChecklistItem, error) {
+	rows, err := q.Query(`
+	SELECT `+checklistColumns+` FROM checklist_items ci
+	WHERE ci.task_id = $1
+	ORDER BY ci.position ASC, ci.id ASC`, taskID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tasks []Task
+	items := []ChecklistItem{}
 	for rows.Next() {
-		var task Task
-		if err := rows.Scan(
-			&task.ID,
-			&task.UserID,
-			&task.CollectionID,
-			&task.Name,
-			&task.Text,
-			&task.Complete,
-			&task.CreateTime,
-			&task.CompleteAt); err != nil {
+		var item ChecklistItem
+		if err := scanChecklistItem(rows, &item); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, task)
+		items = append(items, item)
 	}
-	return tasks, rows.Err()
+	return items, rows.Err()
+}
+
+func (r *TaskRepository) GetChecklist(taskID, userID int) ([]ChecklistItem, error) {
+	if err := checkTaskReadable(r.DB, taskID, userID); err != nil {
+		return nil, err
+	}
+	return queryChecklist(r.DB, taskID)
 }
 
-func (r *TaskRepository) CompleteTaskByUser(id, userID int) error {
+// AddChecklistItem добавляет пункт в конец чек-листа задачи
+func (r *TaskRepository) AddChecklistItem(taskID, userID int, text string) (*ChecklistItem, error) {
+	var item ChecklistItem
+	err := scanChecklistItem(r.DB.QueryRow(`
+	INSERT INTO checklist_items AS ci (task_id, text, position)
+	SELECT t.id, $3, COALESCE((SELECT MAX(position) + 1 FROM checklist_items WHERE task_id = t.id), 0)
+	FROM tasks t WHERE t.id = $1 AND `+taskWritable("t.", "$2")+` AND t.deleted_at IS NULL
+	RETURNING `+checklistColumns, taskID, userID, text), &item)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateChecklistItem меняет текст и/или отметку пункта; незаданные поля остаются прежними
+func (r *TaskRepository) UpdateChecklistItem(taskID, itemID, userID int, upd ChecklistItemUpdate) (*ChecklistItem, error) {
+	var item ChecklistItem
+	err := scanChecklistItem(r.DB.QueryRow(`
+	UPDATE checklist_items ci SET text = COALESCE($1, ci.text), done = COALESCE($2, ci.done)
+	FROM tasks t
+	WHERE ci.id = $3 AND ci.task_id = $4 AND t.id = ci.task_id AND `+taskWritable("t.", "$5")+` AND t.deleted_at IS NULL
+	RETURNING `+checklistColumns,
+		upd.Text, upd.Done, itemID, taskID, userID), &item)
+	if err == sql.ErrNoRows {
+		return nil, ErrChecklistItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *TaskRepository) DeleteChecklistItem(taskID, itemID, userID int) error {
 	result, err := r.DB.Exec(`
+	DELETE FROM checklist_items ci USING tasks t
+	WHERE ci.id = $1 AND ci.task_id = $2 AND t.id = ci.task_id AND `+taskWritable("t.", "$3")+` AND t.deleted_at IS NULL`,
+		itemID, taskID, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrChecklistItemNotFound
+	}
+	return nil
+}
+
+// ReorderChecklist расставляет пункты в порядке itemIDs. Список должен содержать
+// все пункты задачи ровно по одному разу, иначе ErrChecklistOrder
+func (r *TaskRepository) ReorderChecklist(taskID, userID int, itemIDs []int) ([]ChecklistItem, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`SELECT id FROM tasks WHERE id = $1 AND `+taskWritable("", "$2")+` AND deleted_at IS NULL FOR UPDATE`, taskID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := queryChecklist(tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) != len(itemIDs) {
+		return nil, ErrChecklistOrder
+	}
+	wanted := make(map[int]bool, len(itemIDs))
+	for _, itemID := range itemIDs {
+		wanted[itemID] = true
+	}
+	for _, item := range current {
+		if !wanted[item.ID] {
+			return nil, ErrChecklistOrder
+		}
+	}
+	if len(wanted) != len(itemIDs) {
+		return nil, ErrChecklistOrder
+	}
+
+	_, err = tx.Exec(`
+	UPDATE checklist_items SET position = array_position($2::int[], id) - 1
+	WHERE task_id = $1`, taskID, pq.Array(itemIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := queryChecklist(tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return items, tx.Commit()
+}
+
+// isUniqueViolation проверяет код ошибки Postgres 23505 (unique_violation)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// CompleteTaskByUser отмечает задачу выполненной. Если у задачи есть правило повторения,
+// в той же транзакции создаётся следующая задача серии и возвращается; иначе nil
+func (r *TaskRepository) CompleteTaskByUser(id, userID int) (*Task, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next, err := completeTask(tx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return next, tx.Commit()
+}
+
+// completeTask отмечает задачу выполненной в транзакции tx и возвращает следующую задачу серии, если она есть
+func completeTask(tx *sql.Tx, id, userID int) (*Task, error) {
+	var task Task
+	err := scanTask(tx.QueryRow(`
     UPDATE tasks 
     SET complete = TRUE,
     complete_at = Now()
-    WHERE id = $1 AND user_id = $2 AND complete = FALSE`, id, userID)
+    WHERE id = $1 AND `+taskWritable("", "$2")+` AND complete = FALSE AND deleted_at IS NULL
+    RETURNING `+taskColumns, id, userID), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotCompletable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return spawnNextOccurrence(tx, &task)
+}
+
+// spawnNextOccurrence создаёт следующую задачу серии: срок сдвигается по правилу,
+// start_at на тот же интервал, теги копируются, чек-лист копируется без отметок.
+// Без срока следующая дата считается от момента выполнения
+func spawnNextOccurrence(tx *sql.Tx, task *Task) (*Task, error) {
+	if task.Recurrence == nil {
+		return nil, nil
+	}
+	rule, err := ParseRRule(*task.Recurrence)
+	if err != nil {
+		// Правило проверяется при записи; битое значение в базе не должно мешать выполнить задачу
+		return nil, nil
+	}
+
+	base := *task.CompleteAt
+	if task.DueAt != nil {
+		base = *task.DueAt
+	}
+	nextDue, rest, ok := rule.Next(base)
+	if !ok {
+		return nil, nil
+	}
+
+	restRule := rest.String()
+	next := &Task{
+		UserID:       task.UserID,
+		CollectionID: task.CollectionID,
+		Name:         task.Name,
+		Text:         task.Text,
+		DueAt:        &nextDue,
+		Priority:     task.Priority,
+		Recurrence:   &restRule,
+		Tags:         task.Tags,
+	}
+	if task.StartAt != nil {
+		start := task.StartAt.Add(nextDue.Sub(base))
+		next.StartAt = &start
+	}
+
+	// Следующее вхождение получает теги самой задачи, они уже существуют
+	if err := insertTask(tx, next, false); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+	INSERT INTO checklist_items (task_id, text, position)
+	SELECT $1, text, position FROM checklist_items WHERE task_id = $2`, next.ID, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	copied, _ := result.RowsAffected()
+	next.Progress = ChecklistProgress{Total: int(copied)}
+
+	return next, nil
+}
+
+// ReopenTaskByUser снимает отметку о выполнении и очищает complete_at
+func (r *TaskRepository) ReopenTaskByUser(id, userID int) error {
+	return reopenTask(r.DB, id, userID)
+}
+
+func reopenTask(e execer, id, userID int) error {
+	result, err := e.Exec(`
+    UPDATE tasks 
+    SET complete = FALSE,
+    complete_at = NULL
+    WHERE id = $1 AND `+taskWritable("", "$2")+` AND complete = TRUE AND deleted_at IS NULL`, id, userID)
 
 	if err != nil {
 		return err
@@ -425,7 +1354,7 @@ func (r *TaskRepository) CompleteTaskByUser(id, userID int) error {
 
 	rows, _ := result.RowsAffected()
 	if rows == 0 {
-		return fmt.Errorf("task already completed, not found, or access denied")
+		return ErrTaskNotReopenable
 	}
 
 	return nil
@@ -2,12 +2,31 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 )
 
+// taskRows собирает строки sqlmock в порядке колонок taskColumns
+func taskRows(tasks ...Task) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "collection_id", "name", "text", "complete", "create_time", "complete_at", "due_at", "start_at", "priority", "recurrence", "tags", "progress_done", "progress_total", "assignee_id", "assignment_status", "position"})
+	for _, t := range tasks {
+		rows.AddRow(t.ID, t.UserID, t.CollectionID, t.Name, t.Text, t.Complete, t.CreateTime, t.CompleteAt, t.DueAt, t.StartAt, priorityOrNone(t.Priority), t.Recurrence, "{"+strings.Join(t.Tags, ",")+"}", t.Progress.Done, t.Progress.Total, t.AssigneeID, t.AssignmentStatus, t.Position)
+	}
+	return rows
+}
+
+func priorityOrNone(p string) string {
+	if p == "" {
+		return "none"
+	}
+	return p
+}
+
 // ============================================================================
 // ТЕСТЫ ДЛЯ NewTaskRepository
 // ============================================================================
@@ -42,20 +61,23 @@ func TestCreateTaskSuccess(t *testing.T) {
 	repo := NewTaskRepository(db)
 
 	task := &Task{
-		UserID: 1,
-		Name:   "Test Task",
-		Text:   "Test Description",
+		UserID:   1,
+		Name:     "Test Task",
+		Text:     "Test Description",
+		Priority: PriorityNone,
 	}
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Test Task", "Test Description", false, now, nil)
+	rows := taskRows(
+		Task{ID: 1, UserID: 1, Name: "Test Task", Text: "Test Description", CreateTime: now})
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO tasks`).
-		WithArgs(1, "Test Task", "Test Description").
+		WithArgs(1, nil, "Test Task", "Test Description", nil, nil, "none", nil).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	err = repo.CreateTask(task)
+	err = repo.CreateTask(task, false)
 	if err != nil {
 		t.Errorf("CreateTask вернул ошибку: %v", err)
 	}
@@ -79,21 +101,50 @@ func TestCreateTaskError(t *testing.T) {
 	repo := NewTaskRepository(db)
 
 	task := &Task{
-		UserID: 1,
-		Name:   "Test Task",
-		Text:   "Test Description",
+		UserID:   1,
+		Name:     "Test Task",
+		Text:     "Test Description",
+		Priority: PriorityNone,
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO tasks`).
-		WithArgs(1, "Test Task", "Test Description").
+		WithArgs(1, nil, "Test Task", "Test Description", nil, nil, "none", nil).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err = repo.CreateTask(task)
+	err = repo.CreateTask(task, false)
 	if err == nil {
 		t.Error("CreateTask должен вернуть ошибку")
 	}
 }
 
+func TestCreateTaskExistingTagsOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	task := &Task{UserID: 1, Name: "Test Task", Priority: PriorityNone, Tags: []string{"home", "new"}}
+
+	// Тега new нет: задача не вставляется, тег не создаётся
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM tags`).
+		WithArgs(1, `{"home","new"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	if err := repo.CreateTask(task, true); !errors.Is(err, ErrTagCreationDenied) {
+		t.Errorf("Ожидалась ErrTagCreationDenied, получено %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
 // ============================================================================
 // ТЕСТЫ ДЛЯ GetAllTasksByUser
 // ============================================================================
@@ -108,15 +159,15 @@ func TestGetAllTasksByUserSuccess(t *testing.T) {
 	repo := NewTaskRepository(db)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Task 1", "Description 1", false, now, nil).
-		AddRow(2, 1, "Task 2", "Description 2", true, now, &now)
+	rows := taskRows(
+		Task{ID: 1, UserID: 1, Name: "Task 1", Text: "Description 1", CreateTime: now},
+		Task{ID: 2, UserID: 1, Name: "Task 2", Text: "Description 2", Complete: true, CreateTime: now, CompleteAt: &now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	tasks, err := repo.GetAllTasksByUser(1)
+	tasks, err := repo.GetAllTasksByUser(1, ListOptions{})
 	if err != nil {
 		t.Errorf("GetAllTasksByUser вернул ошибку: %v", err)
 	}
@@ -143,13 +194,13 @@ func TestGetAllTasksByUserEmpty(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"})
+	rows := taskRows()
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	tasks, err := repo.GetAllTasksByUser(1)
+	tasks, err := repo.GetAllTasksByUser(1, ListOptions{})
 	if err != nil {
 		t.Errorf("GetAllTasksByUser вернул ошибку: %v", err)
 	}
@@ -168,11 +219,11 @@ func TestGetAllTasksByUserError(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnError(sql.ErrConnDone)
 
-	_, err = repo.GetAllTasksByUser(1)
+	_, err = repo.GetAllTasksByUser(1, ListOptions{})
 	if err == nil {
 		t.Error("GetAllTasksByUser должен вернуть ошибку")
 	}
@@ -192,14 +243,14 @@ func TestGetCompletedTasksByUserSuccess(t *testing.T) {
 	repo := NewTaskRepository(db)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Completed Task", "Description", true, now, &now)
+	rows := taskRows(
+		Task{ID: 1, UserID: 1, Name: "Completed Task", Text: "Description", Complete: true, CreateTime: now, CompleteAt: &now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	tasks, err := repo.GetCompletedTasksByUser(1)
+	tasks, err := repo.GetCompletedTasksByUser(1, ListOptions{})
 	if err != nil {
 		t.Errorf("GetCompletedTasksByUser вернул ошибку: %v", err)
 	}
@@ -227,14 +278,14 @@ func TestGetUncompletedTasksByUserSuccess(t *testing.T) {
 	repo := NewTaskRepository(db)
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, 1, "Uncompleted Task", "Description", false, now, nil)
+	rows := taskRows(
+		Task{ID: 1, UserID: 1, Name: "Uncompleted Task", Text: "Description", CreateTime: now})
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	tasks, err := repo.GetUncompletedTasksByUser(1)
+	tasks, err := repo.GetUncompletedTasksByUser(1, ListOptions{})
 	if err != nil {
 		t.Errorf("GetUncompletedTasksByUser вернул ошибку: %v", err)
 	}
@@ -261,7 +312,7 @@ func TestDeleteTaskByUserSuccess(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectExec(`DELETE FROM tasks WHERE id`).
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NULL`).
 		WithArgs(1, 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -284,7 +335,7 @@ func TestDeleteTaskByUserNotFound(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectExec(`DELETE FROM tasks WHERE id`).
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NULL`).
 		WithArgs(1, 1).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -303,7 +354,7 @@ func TestDeleteTaskByUserError(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectExec(`DELETE FROM tasks WHERE id`).
+	mock.ExpectExec(`UPDATE tasks SET deleted_at = Now\(\)\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NULL`).
 		WithArgs(1, 1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -325,15 +376,21 @@ func TestCompleteTaskByUserSuccess(t *testing.T) {
 	defer db.Close()
 
 	repo := NewTaskRepository(db)
+	now := time.Now()
 
-	mock.ExpectExec(`UPDATE tasks`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
 		WithArgs(1, 1).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", Complete: true, CreateTime: now, CompleteAt: &now}))
+	mock.ExpectCommit()
 
-	err = repo.CompleteTaskByUser(1, 1)
+	next, err := repo.CompleteTaskByUser(1, 1)
 	if err != nil {
 		t.Errorf("CompleteTaskByUser вернул ошибку: %v", err)
 	}
+	if next != nil {
+		t.Errorf("Для задачи без повторения следующая задача не создаётся, получено %+v", next)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Не выполнены ожидания mock: %v", err)
@@ -349,11 +406,13 @@ func TestCompleteTaskByUserNotFound(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectExec(`UPDATE tasks`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
 		WithArgs(1, 1).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	err = repo.CompleteTaskByUser(1, 1)
+	_, err = repo.CompleteTaskByUser(1, 1)
 	if err == nil {
 		t.Error("CompleteTaskByUser должен вернуть ошибку когда задача не найдена")
 	}
@@ -368,21 +427,116 @@ func TestCompleteTaskByUserError(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectExec(`UPDATE tasks`).
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
 		WithArgs(1, 1).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err = repo.CompleteTaskByUser(1, 1)
+	_, err = repo.CompleteTaskByUser(1, 1)
 	if err == nil {
 		t.Error("CompleteTaskByUser должен вернуть ошибку")
 	}
 }
 
+func TestCompleteTaskByUserSpawnsNextOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	due := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	start := due.Add(-2 * time.Hour)
+	rule := "FREQ=WEEKLY;COUNT=3"
+	collectionID := 5
+
+	nextDue := due.AddDate(0, 0, 7)
+	nextStart := start.AddDate(0, 0, 7)
+	restRule := "FREQ=WEEKLY;COUNT=2"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, CollectionID: &collectionID, Name: "Weekly", Text: "Report", Complete: true,
+			CreateTime: now, CompleteAt: &now, DueAt: &due, StartAt: &start, Priority: PriorityHigh, Recurrence: &rule, Tags: []string{"work"}}))
+	mock.ExpectQuery(`INSERT INTO tasks`).
+		WithArgs(1, &collectionID, "Weekly", "Report", &nextDue, &nextStart, PriorityHigh, &restRule).
+		WillReturnRows(taskRows(Task{ID: 2, UserID: 1, CollectionID: &collectionID, Name: "Weekly", Text: "Report",
+			CreateTime: now, DueAt: &nextDue, StartAt: &nextStart, Priority: PriorityHigh, Recurrence: &restRule}))
+	mock.ExpectExec(`INSERT INTO tags`).
+		WithArgs(1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO task_tags`).
+		WithArgs(2, 1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO checklist_items`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	next, err := repo.CompleteTaskByUser(1, 1)
+	if err != nil {
+		t.Fatalf("CompleteTaskByUser вернул ошибку: %v", err)
+	}
+	if next == nil || next.ID != 2 {
+		t.Fatalf("Ожидалась следующая задача с ID 2, получено %+v", next)
+	}
+	if !next.DueAt.Equal(nextDue) {
+		t.Errorf("Ожидался срок %v, получено %v", nextDue, next.DueAt)
+	}
+	if next.Recurrence == nil || *next.Recurrence != restRule {
+		t.Errorf("Ожидалось правило %q, получено %v", restRule, next.Recurrence)
+	}
+	if len(next.Tags) != 1 || next.Tags[0] != "work" {
+		t.Errorf("Теги должны скопироваться, получено %v", next.Tags)
+	}
+	if next.Progress.Total != 2 || next.Progress.Done != 0 {
+		t.Errorf("Чек-лист должен скопироваться без отметок, получено %+v", next.Progress)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCompleteTaskByUserLastOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	rule := "FREQ=DAILY;COUNT=1"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Last", Complete: true, CreateTime: now, CompleteAt: &now, Recurrence: &rule}))
+	mock.ExpectCommit()
+
+	next, err := repo.CompleteTaskByUser(1, 1)
+	if err != nil {
+		t.Errorf("CompleteTaskByUser вернул ошибку: %v", err)
+	}
+	if next != nil {
+		t.Errorf("После последнего повторения серия должна закончиться, получено %+v", next)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
 // ============================================================================
-// ТЕСТЫ ДЛЯ GetTaskByID
+// ТЕСТЫ ДЛЯ GetTaskByUser
 // ============================================================================
 
-func TestGetTaskByIDSuccess(t *testing.T) {
+func TestGetTaskByUserSuccess(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Ошибка создания mock: %v", err)
@@ -391,25 +545,25 @@ func TestGetTaskByIDSuccess(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, "Task 1", "Description", false, now, nil)
-
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs(1).
-		WillReturnRows(rows)
+	mock.ExpectQuery(`WHERE id = \$1 AND `+readableRe(`$2`)).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task 1", Text: "Description", CreateTime: time.Now()}))
 
-	task, err := repo.GetTaskByID(1)
+	task, err := repo.GetTaskByUser(1, 1)
 	if err != nil {
-		t.Errorf("GetTaskByID вернул ошибку: %v", err)
+		t.Fatalf("GetTaskByUser вернул ошибку: %v", err)
 	}
 
-	if task.ID != 1 {
-		t.Errorf("Ожидался ID 1, получен %d", task.ID)
+	if task.ID != 1 || task.UserID != 1 {
+		t.Errorf("Ожидалась задача 1 пользователя 1, получено %+v", task)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
 	}
 }
 
-func TestGetTaskByIDNotFound(t *testing.T) {
+func TestGetTaskByUserOtherUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Ошибка создания mock: %v", err)
@@ -418,21 +572,22 @@ func TestGetTaskByIDNotFound(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs(1).
+	// Задача 1 принадлежит другому пользователю: фильтр по user_id не находит строк
+	mock.ExpectQuery(`WHERE id = \$1 AND `+readableRe(`$2`)).
+		WithArgs(1, 2).
 		WillReturnError(sql.ErrNoRows)
 
-	_, err = repo.GetTaskByID(1)
-	if err == nil {
-		t.Error("GetTaskByID должен вернуть ошибку когда задача не найдена")
+	_, err = repo.GetTaskByUser(1, 2)
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ошибка ErrTaskNotFound, получено %v", err)
 	}
 }
 
 // ============================================================================
-// ТЕСТЫ ДЛЯ GetIDByName
+// ТЕСТЫ ДЛЯ GetTaskByNameByUser
 // ============================================================================
 
-func TestGetIDByNameSuccess(t *testing.T) {
+func TestGetTaskByNameByUserSuccess(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Ошибка создания mock: %v", err)
@@ -441,25 +596,25 @@ func TestGetIDByNameSuccess(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "name", "text", "complete", "create_time", "complete_at"}).
-		AddRow(1, "Task 1", "Description", false, now, nil)
-
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs("Task 1").
-		WillReturnRows(rows)
+	mock.ExpectQuery(`WHERE name = \$1 AND `+readableRe(`$2`)+` AND deleted_at IS NULL\s+ORDER BY create_time DESC, id DESC\s+LIMIT 1`).
+		WithArgs("Task 1", 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task 1", CreateTime: time.Now()}))
 
-	task, err := repo.GetIDByName("Task 1")
+	task, err := repo.GetTaskByNameByUser("Task 1", 1)
 	if err != nil {
-		t.Errorf("GetIDByName вернул ошибку: %v", err)
+		t.Fatalf("GetTaskByNameByUser вернул ошибку: %v", err)
 	}
 
 	if task.Name != "Task 1" {
 		t.Errorf("Ожидалось имя 'Task 1', получено %s", task.Name)
 	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
 }
 
-func TestGetIDByNameNotFound(t *testing.T) {
+func TestGetTaskByNameByUserNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("Ошибка создания mock: %v", err)
@@ -468,13 +623,13 @@ func TestGetIDByNameNotFound(t *testing.T) {
 
 	repo := NewTaskRepository(db)
 
-	mock.ExpectQuery(`SELECT \* FROM tasks`).
-		WithArgs("NonExistent").
+	mock.ExpectQuery(`WHERE name = \$1 AND `+readableRe(`$2`)).
+		WithArgs("NonExistent", 1).
 		WillReturnError(sql.ErrNoRows)
 
-	_, err = repo.GetIDByName("NonExistent")
-	if err == nil {
-		t.Error("GetIDByName должен вернуть ошибку когда задача не найдена")
+	_, err = repo.GetTaskByNameByUser("NonExistent", 1)
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ошибка ErrTaskNotFound, получено %v", err)
 	}
 }
 
@@ -706,6 +861,9 @@ func TestCreateTableSuccess(t *testing.T) {
 	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS users`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS collections`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
 	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS tasks`).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -775,11 +933,11 @@ func TestGetAllTasksByUserScanError(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "user_id", "name"}).
 		AddRow(1, 1, "Task 1")
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	_, err = repo.GetAllTasksByUser(1)
+	_, err = repo.GetAllTasksByUser(1, ListOptions{})
 	if err == nil {
 		t.Error("GetAllTasksByUser должен вернуть ошибку при ошибке Scan")
 	}
@@ -797,11 +955,11 @@ func TestGetCompletedTasksByUserScanError(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "user_id", "name"}).
 		AddRow(1, 1, "Task 1")
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	_, err = repo.GetCompletedTasksByUser(1)
+	_, err = repo.GetCompletedTasksByUser(1, ListOptions{})
 	if err == nil {
 		t.Error("GetCompletedTasksByUser должен вернуть ошибку при ошибке Scan")
 	}
@@ -819,11 +977,11 @@ func TestGetUncompletedTasksByUserScanError(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "user_id", "name"}).
 		AddRow(1, 1, "Task 1")
 
-	mock.ExpectQuery(`SELECT id, user_id, name, text, complete, create_time, complete_at FROM tasks`).
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	_, err = repo.GetUncompletedTasksByUser(1)
+	_, err = repo.GetUncompletedTasksByUser(1, ListOptions{})
 	if err == nil {
 		t.Error("GetUncompletedTasksByUser должен вернуть ошибку при ошибке Scan")
 	}
@@ -891,3 +1049,877 @@ func TestGetUncompletedTasksScanError(t *testing.T) {
 		t.Error("GetUncompletedTasks должен вернуть ошибку при ошибке Scan")
 	}
 }
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ UpdateTaskByUser
+// ============================================================================
+
+func TestUpdateTaskByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	collectionID := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Old", Text: "Text", CreateTime: now}))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectQuery(`UPDATE tasks SET name = \$1, collection_id = \$2`).
+		WithArgs("New", 3, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, CollectionID: &collectionID, Name: "New", Text: "Text", CreateTime: now}))
+	mock.ExpectCommit()
+
+	name := "New"
+	task, changes, err := repo.UpdateTaskByUser(1, 1, TaskUpdate{
+		Name:         &name,
+		CollectionID: Optional[int]{Set: true, Value: &collectionID},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTaskByUser вернул ошибку: %v", err)
+	}
+
+	if task.Name != "New" || task.CollectionID == nil || *task.CollectionID != 3 {
+		t.Errorf("Неправильная задача после обновления: %+v", task)
+	}
+	if len(changes) != 2 || changes[0].Field != "name" || changes[1].Field != "collection_id" {
+		t.Errorf("Неправильный diff: %+v", changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateTaskByUserNoChanges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Same", Text: "Text", CreateTime: time.Now()}))
+	mock.ExpectCommit()
+
+	name := "Same"
+	_, changes, err := repo.UpdateTaskByUser(1, 1, TaskUpdate{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateTaskByUser вернул ошибку: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Изменений быть не должно, получено %+v", changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// TestUpdateTaskByUserMoveTakesTaskFromCreator редактор общей коллекции не может унести чужую задачу
+// в коллекцию, где нет её автора
+func TestUpdateTaskByUserMoveTakesTaskFromCreator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	shared, private := 5, 7
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 2).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, CollectionID: &shared, Name: "Task", CreateTime: time.Now()}))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 2).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`SELECT \(tasks.user_id = \$2 OR (.+) OR \$3 IN \(SELECT collection_id FROM collection_members WHERE user_id = tasks.user_id\)\) FROM tasks WHERE id = \$1`).
+		WithArgs(1, 2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"keeps_creator"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, _, err = repo.UpdateTaskByUser(1, 2, TaskUpdate{CollectionID: Optional[int]{Set: true, Value: &private}})
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateTaskByUserStartAfterStoredDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	due := now.Add(24 * time.Hour)
+	start := now.Add(-24 * time.Hour)
+	afterDue := due.Add(time.Hour)
+	beforeStart := start.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		upd  TaskUpdate
+	}{
+		{"start_at позже сохранённого due_at", TaskUpdate{StartAt: Optional[time.Time]{Set: true, Value: &afterDue}}},
+		{"due_at раньше сохранённого start_at", TaskUpdate{DueAt: Optional[time.Time]{Set: true, Value: &beforeStart}}},
+	}
+
+	for _, tt := range tests {
+		// UPDATE не выполняется: проверка идёт по заблокированной строке до записи
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+			WithArgs(1, 1).
+			WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: now, StartAt: &start, DueAt: &due}))
+		mock.ExpectRollback()
+
+		if _, _, err := repo.UpdateTaskByUser(1, 1, tt.upd); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("%s: ожидалась ErrInvalidSchedule, получено %v", tt.name, err)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateTaskByUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	text := "x"
+	_, _, err = repo.UpdateTaskByUser(1, 2, TaskUpdate{Text: &text})
+	if err != ErrTaskNotFound {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ReopenTaskByUser
+// ============================================================================
+
+func TestReopenTaskByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`UPDATE tasks\s+SET complete = FALSE,\s+complete_at = NULL`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.ReopenTaskByUser(1, 1); err != nil {
+		t.Errorf("ReopenTaskByUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReopenTaskByUserNotCompleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`UPDATE tasks`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.ReopenTaskByUser(1, 1); err == nil {
+		t.Error("ReopenTaskByUser должен вернуть ошибку для невыполненной задачи")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetTasksDueBetween / GetOverdueTasksByUser
+// ============================================================================
+
+func TestGetTasksDueBetweenSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	due := from.Add(10 * time.Hour)
+
+	mock.ExpectQuery(`SELECT (.+) FROM tasks\s+WHERE `+readableRe(`$1`)+` AND deleted_at IS NULL AND due_at >= \$2 AND due_at < \$3`).
+		WithArgs(1, from, to).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Today", CreateTime: from, DueAt: &due}))
+
+	tasks, err := repo.GetTasksDueBetween(1, from, to, ListOptions{Sort: SortDueAt, Order: "asc"})
+	if err != nil {
+		t.Fatalf("GetTasksDueBetween вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].DueAt == nil || !tasks[0].DueAt.Equal(due) {
+		t.Errorf("Неправильный результат: %+v", tasks)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetOverdueTasksByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM tasks\s+WHERE `+readableRe(`$1`)+` AND deleted_at IS NULL AND complete = FALSE AND due_at < \$2`).
+		WithArgs(1, now).
+		WillReturnRows(taskRows())
+
+	tasks, err := repo.GetOverdueTasksByUser(1, now, ListOptions{Sort: SortDueAt, Order: "asc"})
+	if err != nil {
+		t.Fatalf("GetOverdueTasksByUser вернул ошибку: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("Ожидалось 0 задач, получено %d", len(tasks))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestListOptionsOrderClause(t *testing.T) {
+	tests := []struct {
+		opts ListOptions
+		want string
+	}{
+		{ListOptions{}, "ORDER BY create_time DESC NULLS LAST, id DESC"},
+		{ListOptions{Sort: SortPosition, Order: "asc"}, "ORDER BY position ASC NULLS LAST, id ASC"},
+		{ListOptions{Sort: SortDueAt, Order: "asc"}, "ORDER BY due_at ASC NULLS LAST, id ASC"},
+		{ListOptions{Sort: "password", Order: "asc"}, "ORDER BY create_time ASC NULLS LAST, id ASC"},
+		{ListOptions{Sort: SortPriority}, "ORDER BY " + taskSortExprs[SortPriority] + " DESC NULLS LAST, id DESC"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.opts.orderClause(); got != tt.want {
+			t.Errorf("orderClause(%+v) = %q, ожидалось %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateTaskByUserPriority(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	priority := PriorityHigh
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: now}))
+	mock.ExpectQuery(`UPDATE tasks SET priority = \$1`).
+		WithArgs(PriorityHigh, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: now, Priority: PriorityHigh}))
+	mock.ExpectCommit()
+
+	task, changes, err := repo.UpdateTaskByUser(1, 1, TaskUpdate{Priority: &priority})
+	if err != nil {
+		t.Fatalf("UpdateTaskByUser вернул ошибку: %v", err)
+	}
+	if task.Priority != PriorityHigh {
+		t.Errorf("Ожидался приоритет high, получен %s", task.Priority)
+	}
+	if len(changes) != 1 || changes[0].Field != "priority" || changes[0].Old != PriorityNone {
+		t.Errorf("Неправильный список изменений: %+v", changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ тегов
+// ============================================================================
+
+func TestNormalizeTagNames(t *testing.T) {
+	names, err := NormalizeTagNames([]string{" work", "home", "work "})
+	if err != nil {
+		t.Fatalf("NormalizeTagNames вернул ошибку: %v", err)
+	}
+	if len(names) != 2 || names[0] != "home" || names[1] != "work" {
+		t.Errorf("Неправильный результат: %v", names)
+	}
+
+	for _, bad := range []string{"", "   ", "a,b", strings.Repeat("x", MaxTagNameLength+1), strings.Repeat("я", MaxTagNameLength+1)} {
+		if _, err := NormalizeTagNames([]string{bad}); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", bad)
+		}
+	}
+
+	// Длина считается в символах: кириллическое имя в 50 символов занимает 100 байт
+	long := strings.Repeat("я", MaxTagNameLength)
+	if _, err := NormalizeTagNames([]string{long}); err != nil {
+		t.Errorf("Имя из %d кириллических символов должно быть допустимым: %v", MaxTagNameLength, err)
+	}
+}
+
+func TestCreateTaskWithTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	task := &Task{UserID: 1, Name: "Task", Priority: PriorityNone, Tags: []string{"home", "work"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tasks`).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Task", CreateTime: time.Now()}))
+	mock.ExpectExec(`INSERT INTO tags \(user_id, name\)`).
+		WithArgs(1, `{"home","work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO task_tags`).
+		WithArgs(5, 1, `{"home","work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := repo.CreateTask(task, false); err != nil {
+		t.Fatalf("CreateTask вернул ошибку: %v", err)
+	}
+	if task.ID != 5 || len(task.Tags) != 2 {
+		t.Errorf("Неправильная задача: %+v", task)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateTaskByUserTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now(), Tags: []string{"home", "old"}}))
+	mock.ExpectExec(`DELETE FROM task_tags`).
+		WithArgs(1, 1, `{"old"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO tags \(user_id, name\)`).
+		WithArgs(1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO task_tags`).
+		WithArgs(1, 1, `{"work"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	task, changes, err := repo.UpdateTaskByUser(1, 1, TaskUpdate{AddTags: []string{"work"}, RemoveTags: []string{"old"}})
+	if err != nil {
+		t.Fatalf("UpdateTaskByUser вернул ошибку: %v", err)
+	}
+	if strings.Join(task.Tags, ",") != "home,work" {
+		t.Errorf("Неправильные теги: %v", task.Tags)
+	}
+	if len(changes) != 1 || changes[0].Field != "tags" {
+		t.Errorf("Неправильный diff: %+v", changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateTaskByUserTagsUnchanged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now(), Tags: []string{"home"}}))
+	mock.ExpectCommit()
+
+	_, changes, err := repo.UpdateTaskByUser(1, 1, TaskUpdate{AddTags: []string{"home"}, RemoveTags: []string{"missing"}})
+	if err != nil {
+		t.Fatalf("UpdateTaskByUser вернул ошибку: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Ожидался пустой diff, получено %+v", changes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetTasksByTagsAny(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`WHERE tg.user_id = \$1 AND tg.name = ANY\(\$2\)\s+\)`).
+		WithArgs(1, `{"home","work"}`).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now(), Tags: []string{"work"}}))
+
+	tasks, err := repo.GetTasksByTags(1, []string{"home", "work"}, false, ListOptions{})
+	if err != nil {
+		t.Fatalf("GetTasksByTags вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || len(tasks[0].Tags) != 1 {
+		t.Errorf("Неправильный результат: %+v", tasks)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetTasksByTagsAll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`GROUP BY tt.task_id HAVING COUNT\(DISTINCT tg.id\) = 2`).
+		WithArgs(1, `{"home","work"}`).
+		WillReturnRows(taskRows())
+
+	tasks, err := repo.GetTasksByTags(1, []string{"home", "work"}, true, ListOptions{})
+	if err != nil {
+		t.Fatalf("GetTasksByTags вернул ошибку: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("Ожидалось 0 задач, получено %d", len(tasks))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCreateTagDuplicate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO tags`).
+		WithArgs(1, "work", "#808080").
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	err = repo.CreateTag(&Tag{UserID: 1, Name: "work", Color: "#808080"})
+	if !errors.Is(err, ErrTagExists) {
+		t.Errorf("Ожидалась ErrTagExists, получено %v", err)
+	}
+}
+
+func TestUpdateTagByUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	name := "renamed"
+
+	mock.ExpectQuery(`UPDATE tags SET name = COALESCE\(\$1, name\), color = COALESCE\(\$2, color\)`).
+		WithArgs(&name, nil, 9, 1).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.UpdateTagByUser(9, 1, TagUpdate{Name: &name})
+	if !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("Ожидалась ErrTagNotFound, получено %v", err)
+	}
+}
+
+func TestDeleteTagByUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM tags WHERE id = \$1 AND user_id = \$2`).
+		WithArgs(9, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.DeleteTagByUser(9, 1); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("Ожидалась ErrTagNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ чек-листа
+// ============================================================================
+
+func checklistRows(items ...ChecklistItem) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "task_id", "text", "done", "position", "created_at"})
+	for _, i := range items {
+		rows.AddRow(i.ID, i.TaskID, i.Text, i.Done, i.Position, i.CreatedAt)
+	}
+	return rows
+}
+
+func TestGetAllTasksByUserProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`SELECT (.+) FROM checklist_items ci WHERE ci.task_id = tasks.id(.+) FROM tasks`).
+		WithArgs(1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now(), Progress: ChecklistProgress{Done: 2, Total: 5}}))
+
+	tasks, err := repo.GetAllTasksByUser(1, ListOptions{})
+	if err != nil {
+		t.Fatalf("GetAllTasksByUser вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Progress.Done != 2 || tasks[0].Progress.Total != 5 {
+		t.Errorf("Неправильный прогресс: %+v", tasks)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetChecklistTaskNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`SELECT id FROM tasks WHERE id = \$1 AND `+readableRe(`$2`)).
+		WithArgs(7, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetChecklist(7, 2); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+}
+
+func TestAddChecklistItemSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO checklist_items AS ci \(task_id, text, position\)\s+SELECT t.id, \$3`).
+		WithArgs(1, 1, "Купить молоко").
+		WillReturnRows(checklistRows(ChecklistItem{ID: 4, TaskID: 1, Text: "Купить молоко", Position: 3, CreatedAt: time.Now()}))
+
+	item, err := repo.AddChecklistItem(1, 1, "Купить молоко")
+	if err != nil {
+		t.Fatalf("AddChecklistItem вернул ошибку: %v", err)
+	}
+	if item.ID != 4 || item.Position != 3 {
+		t.Errorf("Неправильный пункт: %+v", item)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestAddChecklistItemTaskNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO checklist_items`).
+		WithArgs(1, 2, "Шаг").
+		WillReturnRows(checklistRows())
+
+	if _, err := repo.AddChecklistItem(1, 2, "Шаг"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+}
+
+func TestUpdateChecklistItemDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	done := true
+
+	mock.ExpectQuery(`UPDATE checklist_items ci SET text = COALESCE\(\$1, ci.text\), done = COALESCE\(\$2, ci.done\)`).
+		WithArgs(nil, &done, 4, 1, 1).
+		WillReturnRows(checklistRows(ChecklistItem{ID: 4, TaskID: 1, Text: "Шаг", Done: true, CreatedAt: time.Now()}))
+
+	item, err := repo.UpdateChecklistItem(1, 4, 1, ChecklistItemUpdate{Done: &done})
+	if err != nil {
+		t.Fatalf("UpdateChecklistItem вернул ошибку: %v", err)
+	}
+	if !item.Done {
+		t.Error("Пункт должен быть отмечен")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteChecklistItemNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM checklist_items ci USING tasks t`).
+		WithArgs(4, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.DeleteChecklistItem(1, 4, 2); !errors.Is(err, ErrChecklistItemNotFound) {
+		t.Errorf("Ожидалась ErrChecklistItemNotFound, получено %v", err)
+	}
+}
+
+func TestReorderChecklistSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM tasks WHERE id = \$1 AND `+writableRe(`$2`)+` AND deleted_at IS NULL FOR UPDATE`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`FROM checklist_items ci`).
+		WithArgs(1).
+		WillReturnRows(checklistRows(
+			ChecklistItem{ID: 10, TaskID: 1, Text: "A", Position: 0, CreatedAt: now},
+			ChecklistItem{ID: 11, TaskID: 1, Text: "B", Position: 1, CreatedAt: now}))
+	mock.ExpectExec(`UPDATE checklist_items SET position = array_position\(\$2::int\[\], id\) - 1`).
+		WithArgs(1, "{11,10}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`FROM checklist_items ci`).
+		WithArgs(1).
+		WillReturnRows(checklistRows(
+			ChecklistItem{ID: 11, TaskID: 1, Text: "B", Position: 0, CreatedAt: now},
+			ChecklistItem{ID: 10, TaskID: 1, Text: "A", Position: 1, CreatedAt: now}))
+	mock.ExpectCommit()
+
+	items, err := repo.ReorderChecklist(1, 1, []int{11, 10})
+	if err != nil {
+		t.Fatalf("ReorderChecklist вернул ошибку: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != 11 {
+		t.Errorf("Неправильный порядок: %+v", items)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderChecklistMismatch(t *testing.T) {
+	now := time.Now()
+
+	for _, ids := range [][]int{{10}, {10, 10}, {10, 99}} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Ошибка создания mock: %v", err)
+		}
+
+		repo := NewTaskRepository(db)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM tasks`).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(`FROM checklist_items ci`).
+			WithArgs(1).
+			WillReturnRows(checklistRows(
+				ChecklistItem{ID: 10, TaskID: 1, Text: "A", CreatedAt: now},
+				ChecklistItem{ID: 11, TaskID: 1, Text: "B", CreatedAt: now}))
+		mock.ExpectRollback()
+
+		if _, err := repo.ReorderChecklist(1, 1, ids); !errors.Is(err, ErrChecklistOrder) {
+			t.Errorf("%v: ожидалась ErrChecklistOrder, получено %v", ids, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%v: не выполнены ожидания mock: %v", ids, err)
+		}
+		db.Close()
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ UpdateCollectionByUser
+// ============================================================================
+
+func TestIsValidColor(t *testing.T) {
+	cases := map[string]bool{
+		"#2564cf":  true,
+		"#FFAA00":  true,
+		"2564cf":   false,
+		"#fff":     false,
+		"#2564cfa": false,
+		"#gg0000":  false,
+		"":         false,
+	}
+	for color, want := range cases {
+		if got := IsValidColor(color); got != want {
+			t.Errorf("IsValidColor(%q) = %v, ожидалось %v", color, got, want)
+		}
+	}
+}
+
+func TestCollectionUpdateValidate(t *testing.T) {
+	name := "  Дом  "
+	icon := strings.Repeat("🏠", MaxCollectionIconLength)
+	upd := CollectionUpdate{Name: &name, Icon: &icon}
+	if err := upd.Validate(); err != nil {
+		t.Fatalf("Validate вернул ошибку: %v", err)
+	}
+	if *upd.Name != "Дом" {
+		t.Errorf("Название должно быть обрезано, получено %q", *upd.Name)
+	}
+
+	long := strings.Repeat("я", MaxCollectionNameLength+1)
+	if err := (&CollectionUpdate{Name: &long}).Validate(); err == nil {
+		t.Error("Слишком длинное название должно вернуть ошибку")
+	}
+}
+
+func TestUpdateCollectionByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	icon := "📚"
+
+	mock.ExpectQuery(`UPDATE collections SET name = COALESCE\(\$1, name\), color = COALESCE\(\$2, color\), icon = COALESCE\(\$3, icon\)`).
+		WithArgs(nil, nil, &icon, 3, 1).
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, Name: "Books", Color: "#2564cf", Icon: "📚", CreatedAt: time.Now()}))
+
+	collection, err := repo.UpdateCollectionByUser(3, 1, CollectionUpdate{Icon: &icon})
+	if err != nil {
+		t.Fatalf("UpdateCollectionByUser вернул ошибку: %v", err)
+	}
+	if collection.Icon != "📚" || collection.Name != "Books" {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateCollectionByUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	name := "Work"
+
+	mock.ExpectQuery(`UPDATE collections`).
+		WithArgs(&name, nil, nil, 3, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.UpdateCollectionByUser(3, 2, CollectionUpdate{Name: &name})
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ErrCollectionNotFound, получено %v", err)
+	}
+}
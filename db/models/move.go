@@ -0,0 +1,169 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// MaxMoveTasks сколько задач можно перенести одним запросом
+const MaxMoveTasks = 100
+
+var ErrNoTasksToMove = errors.New("task_ids must contain 1-100 task IDs")
+
+// TaskMove перенос одной задачи: из какой коллекции и в какую. nil — задача без коллекции
+type TaskMove struct {
+	TaskID int  `json:"task_id"`
+	From   *int `json:"from_collection_id"`
+	To     *int `json:"to_collection_id"`
+}
+
+// MoveTasksRequest тело POST /tasks/move. collection_id обязателен; null — убрать задачи из коллекции
+type MoveTasksRequest struct {
+	TaskIDs      []int         `json:"task_ids"`
+	CollectionID Optional[int] `json:"collection_id"`
+}
+
+// checkCollectionWritable проверяет, что пользователь может класть задачи в коллекцию: ErrCollectionNotFound,
+// если он не участник или коллекция в корзине, ErrForbidden для наблюдателя. nil — задача без коллекции
+func checkCollectionWritable(q queryer, collectionID *int, userID int) error {
+	if collectionID == nil {
+		return nil
+	}
+	role, err := collectionRole(q, *collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if role == RoleViewer {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// keepsCreator условие "перенос задачи tasks в коллекцию target оставит её автору": переносит сам автор
+// или владелец исходной коллекции, либо автор — участник target. Иначе редактор общей коллекции мог бы унести
+// чужую задачу в личную коллекцию, и автор потерял бы к ней доступ. Таблица tasks должна быть без алиаса
+func keepsCreator(param, target string) string {
+	return "(tasks.user_id = " + param +
+		" OR " + memberOf("tasks.collection_id", param, RoleOwner) +
+		" OR " + memberOf(target, "tasks.user_id") + ")"
+}
+
+// checkKeepsCreator ErrForbidden, если перенос задачи id в коллекцию target отнимет её у автора (см. keepsCreator).
+// Без коллекции задача возвращается в личный список автора, это разрешено
+func checkKeepsCreator(q queryer, id, userID int, target *int) error {
+	if target == nil {
+		return nil
+	}
+	var ok bool
+	err := q.QueryRow(`SELECT `+keepsCreator("$2", "$3")+` FROM tasks WHERE id = $1`, id, userID, *target).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// MoveTasksByUser переносит задачи пользователя в коллекцию collectionID (nil — убрать из коллекции).
+// Переносятся все задачи или ни одной: если хотя бы одна не найдена, возвращается ErrTaskNotFound,
+// если перенос отнимет чужую задачу у автора — ErrForbidden.
+// В результат попадают только задачи, у которых коллекция действительно сменилась
+func (r *TaskRepository) MoveTasksByUser(taskIDs []int, userID int, collectionID *int) ([]TaskMove, error) {
+	ids := uniqueInts(taskIDs)
+	if len(ids) == 0 || len(ids) > MaxMoveTasks {
+		return nil, ErrNoTasksToMove
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	moves, err := moveTasks(tx, ids, userID, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	return moves, tx.Commit()
+}
+
+// moveTasks переносит задачи ids в транзакции tx. Сначала проверяются коллекция и все задачи,
+// и только потом идёт UPDATE, поэтому при ошибке ничего не меняется
+func moveTasks(tx *sql.Tx, ids []int, userID int, collectionID *int) ([]TaskMove, error) {
+	if err := checkCollectionWritable(tx, collectionID, userID); err != nil {
+		return nil, err
+	}
+
+	keeps := "TRUE"
+	args := []interface{}{pq.Array(ids), userID}
+	if collectionID != nil {
+		keeps = keepsCreator("$2", "$3")
+		args = append(args, *collectionID)
+	}
+
+	rows, err := tx.Query(`
+	SELECT id, collection_id, `+keeps+` FROM tasks
+	WHERE id = ANY($1) AND `+taskManageable("", "$2")+` AND deleted_at IS NULL
+	ORDER BY id
+	FOR UPDATE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := 0
+	forbidden := false
+	moves := []TaskMove{}
+	for rows.Next() {
+		var move TaskMove
+		var keepsAccess bool
+		if err := rows.Scan(&move.TaskID, &move.From, &keepsAccess); err != nil {
+			return nil, err
+		}
+		found++
+		if !equalIntPtr(move.From, collectionID) {
+			forbidden = forbidden || !keepsAccess
+			move.To = collectionID
+			moves = append(moves, move)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if found != len(ids) {
+		return nil, ErrTaskNotFound
+	}
+	if forbidden {
+		return nil, ErrForbidden
+	}
+
+	if len(moves) > 0 {
+		movedIDs := make([]int, len(moves))
+		for i, move := range moves {
+			movedIDs[i] = move.TaskID
+		}
+		if _, err := tx.Exec(`
+	UPDATE tasks SET collection_id = $1
+	WHERE id = ANY($2)`, collectionID, pq.Array(movedIDs)); err != nil {
+			return nil, err
+		}
+	}
+
+	return moves, nil
+}
+
+// uniqueInts убирает повторы, сохраняя порядок
+func uniqueInts(values []int) []int {
+	seen := make(map[int]bool, len(values))
+	result := make([]int, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
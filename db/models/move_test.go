@@ -0,0 +1,248 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ проверки владельца коллекции
+// ============================================================================
+
+func TestCreateTaskForeignCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	collectionID := 7
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err = repo.CreateTask(&Task{UserID: 1, Name: "Task", CollectionID: &collectionID, Priority: PriorityNone}, false)
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ErrCollectionNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCreateTaskOwnCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	collectionID := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`INSERT INTO tasks`).
+		WillReturnRows(taskRows(Task{ID: 10, UserID: 1, CollectionID: &collectionID, Name: "Task", CreateTime: time.Now()}))
+	mock.ExpectCommit()
+
+	task := &Task{UserID: 1, Name: "Task", CollectionID: &collectionID, Priority: PriorityNone}
+	if err := repo.CreateTask(task, false); err != nil {
+		t.Fatalf("CreateTask вернул ошибку: %v", err)
+	}
+	if task.ID != 10 {
+		t.Errorf("Ожидалась задача 10, получено %+v", task)
+	}
+}
+
+func TestUpdateTaskByUserForeignCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	collectionID := 7
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM tasks`).
+		WithArgs(1, 1).
+		WillReturnRows(taskRows(Task{ID: 1, UserID: 1, Name: "Task", CreateTime: time.Now()}))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, _, err = repo.UpdateTaskByUser(1, 1, TaskUpdate{CollectionID: Optional[int]{Set: true, Value: &collectionID}})
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ErrCollectionNotFound, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ MoveTasksByUser
+// ============================================================================
+
+func TestMoveTasksByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	target := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`SELECT id, collection_id, (.+) FROM tasks\s+WHERE id = ANY\(\$1\) AND `+manageableRe(`$2`)+` AND deleted_at IS NULL`).
+		WithArgs("{1,2,5}", 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).
+			AddRow(1, nil, true).
+			AddRow(2, 3, true).
+			AddRow(5, 4, true))
+	mock.ExpectExec(`UPDATE tasks SET collection_id = \$1\s+WHERE id = ANY\(\$2\)`).
+		WithArgs(3, "{1,5}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	moves, err := repo.MoveTasksByUser([]int{1, 2, 5, 1}, 1, &target)
+	if err != nil {
+		t.Fatalf("MoveTasksByUser вернул ошибку: %v", err)
+	}
+
+	// Задача 2 уже в коллекции 3 и в результат не попадает
+	if len(moves) != 2 {
+		t.Fatalf("Ожидалось 2 переноса, получено %+v", moves)
+	}
+	if moves[0].TaskID != 1 || moves[0].From != nil || *moves[0].To != 3 {
+		t.Errorf("Неправильный перенос: %+v", moves[0])
+	}
+	if moves[1].TaskID != 5 || *moves[1].From != 4 || *moves[1].To != 3 {
+		t.Errorf("Неправильный перенос: %+v", moves[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestMoveTasksByUserOutOfCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Без коллекции проверять владельца нечего
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, collection_id, TRUE FROM tasks`).
+		WithArgs("{4}", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).AddRow(4, 3, true))
+	mock.ExpectExec(`UPDATE tasks SET collection_id = \$1`).
+		WithArgs(nil, "{4}").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	moves, err := repo.MoveTasksByUser([]int{4}, 1, nil)
+	if err != nil {
+		t.Fatalf("MoveTasksByUser вернул ошибку: %v", err)
+	}
+	if len(moves) != 1 || *moves[0].From != 3 || moves[0].To != nil {
+		t.Errorf("Неправильный результат: %+v", moves)
+	}
+}
+
+func TestMoveTasksByUserForeignTask(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Задача 9 чужая: ничего не переносится
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, collection_id, TRUE FROM tasks`).
+		WithArgs("{1,9}", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).AddRow(1, nil, true))
+	mock.ExpectRollback()
+
+	_, err = repo.MoveTasksByUser([]int{1, 9}, 1, nil)
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestMoveTasksByUserTakesTaskFromCreator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	private := 7
+
+	// Задача 4 чужая, а автора нет в коллекции 7: не переносится ни одна задача
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 2).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`SELECT id, collection_id, (.+) FROM tasks`).
+		WithArgs("{3,4}", 2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "collection_id", "keeps_creator"}).
+			AddRow(3, 5, true).
+			AddRow(4, 5, false))
+	mock.ExpectRollback()
+
+	_, err = repo.MoveTasksByUser([]int{3, 4}, 2, &private)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestMoveTasksByUserLimits(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	tooMany := make([]int, MaxMoveTasks+1)
+	for i := range tooMany {
+		tooMany[i] = i + 1
+	}
+	for _, ids := range [][]int{nil, tooMany} {
+		if _, err := repo.MoveTasksByUser(ids, 1, nil); !errors.Is(err, ErrNoTasksToMove) {
+			t.Errorf("%d задач: ожидалась ErrNoTasksToMove, получено %v", len(ids), err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Коллекции образуют дерево через parent_id. Вкладывать можно только коллекции одного владельца,
+// и переносит их он же: иначе удаление родителя задело бы чужие коллекции.
+// Удаление родителя уносит в корзину всё поддерево с задачами под одним deleted_at, восстановление
+// и окончательное удаление работают с тем же поддеревом. Активная коллекция никогда не лежит
+// внутри удалённой: восстановленная без родителя коллекция поднимается на верхний уровень
+
+var ErrCollectionCycle = errors.New("cannot move a collection into itself or its subcollection")
+
+// collectionTreeLock первый ключ advisory-блокировки дерева коллекций; второй — id владельца
+const collectionTreeLock = 1
+
+// MoveCollectionRequest тело POST /collections/{id}/move. parent_id обязателен; null — на верхний уровень
+type MoveCollectionRequest struct {
+	ParentID Optional[int] `json:"parent_id"`
+}
+
+const collectionColumns = `id, user_id, parent_id, name, color, icon, created_at, position`
+
+func collectionDest(c *Collection) []interface{} {
+	return []interface{}{&c.ID, &c.UserID, &c.ParentID, &c.Name, &c.Color, &c.Icon, &c.CreatedAt, &c.Position}
+}
+
+// collectionTree CTE tree(id): коллекция root и её потомки, отобранные условием childCond над алиасом sub
+func collectionTree(root, childCond string) string {
+	return `
+	WITH RECURSIVE tree AS (
+		SELECT id FROM collections WHERE id = ` + root + `
+		UNION ALL
+		SELECT sub.id FROM collections sub JOIN tree ON sub.parent_id = tree.id WHERE ` + childCond + `
+	)`
+}
+
+// MoveCollectionByUser вкладывает коллекцию в parentID (nil — поднимает на верхний уровень).
+// Обе коллекции должны принадлежать пользователю; перенос в себя или в потомка — ErrCollectionCycle
+func (r *TaskRepository) MoveCollectionByUser(id, userID int, parentID *int) (*Collection, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Переносы одного владельца идут по очереди: два встречных переноса могли бы вместе замкнуть цикл
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, collectionTreeLock, userID); err != nil {
+		return nil, err
+	}
+
+	if err := requireOwner(tx, id, userID); err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		if err := requireOwner(tx, *parentID, userID); err != nil {
+			return nil, err
+		}
+
+		var cycle bool
+		err := tx.QueryRow(collectionTree("$1", "sub.deleted_at IS NULL")+`
+	SELECT EXISTS (SELECT 1 FROM tree WHERE id = $2)`, id, *parentID).Scan(&cycle)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, ErrCollectionCycle
+		}
+	}
+
+	collection := Collection{Role: RoleOwner}
+	err = tx.QueryRow(`
+	UPDATE collections SET parent_id = $2
+	WHERE id = $1
+	RETURNING `+collectionColumns, id, parentID).Scan(collectionDest(&collection)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, tx.Commit()
+}
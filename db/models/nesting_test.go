@@ -0,0 +1,239 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// collectionRows строки sqlmock в порядке collectionColumns
+func collectionRows(collections ...Collection) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position"})
+	for _, c := range collections {
+		rows.AddRow(c.ID, c.UserID, c.ParentID, c.Name, c.Color, c.Icon, c.CreatedAt, c.Position)
+	}
+	return rows
+}
+
+// treeRe CTE поддерева коллекций в виде регулярки для sqlmock
+func treeRe(root, childCond string) string {
+	return regexp.QuoteMeta(collectionTree(root, childCond))
+}
+
+const treeLockQuery = `SELECT pg_advisory_xact_lock\(\$1, \$2\)`
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ MoveCollectionByUser
+// ============================================================================
+
+func TestMoveCollectionByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	parent := 2
+
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(2, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(treeRe(`$1`, `sub.deleted_at IS NULL`)+`\s+SELECT EXISTS \(SELECT 1 FROM tree WHERE id = \$2\)`).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(`UPDATE collections SET parent_id = \$2\s+WHERE id = \$1`).
+		WithArgs(3, 2).
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, ParentID: &parent, Name: "Q1", CreatedAt: time.Now()}))
+	mock.ExpectCommit()
+
+	collection, err := repo.MoveCollectionByUser(3, 1, &parent)
+	if err != nil {
+		t.Fatalf("MoveCollectionByUser вернул ошибку: %v", err)
+	}
+	if collection.ParentID == nil || *collection.ParentID != 2 || collection.Role != RoleOwner {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestMoveCollectionByUserToTopLevel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Без родителя проверять цикл не нужно
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`UPDATE collections SET parent_id = \$2`).
+		WithArgs(3, nil).
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, Name: "Q1", CreatedAt: time.Now()}))
+	mock.ExpectCommit()
+
+	collection, err := repo.MoveCollectionByUser(3, 1, nil)
+	if err != nil {
+		t.Fatalf("MoveCollectionByUser вернул ошибку: %v", err)
+	}
+	if collection.ParentID != nil {
+		t.Errorf("Ожидалась коллекция верхнего уровня, получено %+v", collection)
+	}
+}
+
+func TestMoveCollectionByUserCycle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	child := 7
+
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(7, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tree WHERE id = \$2\)`).
+		WithArgs(3, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if _, err := repo.MoveCollectionByUser(3, 1, &child); !errors.Is(err, ErrCollectionCycle) {
+		t.Errorf("Ожидалась ErrCollectionCycle, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestMoveCollectionByUserIntoSharedCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	parent := 8
+
+	// В чужую коллекцию нельзя вложить даже редактору
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(8, 1).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectRollback()
+
+	if _, err := repo.MoveCollectionByUser(3, 1, &parent); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ вложенных коллекций
+// ============================================================================
+
+func TestCreateCollectionInParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	parent := 2
+
+	mock.ExpectQuery(roleQuery).
+		WithArgs(2, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	// Новая коллекция встаёт в конец списка соседей под тем же родителем
+	mock.ExpectQuery(`INSERT INTO collections \(user_id, parent_id, name, color, icon, created_at, position\)\s+VALUES .+`+
+		regexp.QuoteMeta(`(SELECT COALESCE(MAX(position), 0) + 1024 FROM collections WHERE `+collectionListScope("$2", "$1")+`)`)).
+		WithArgs(1, &parent, "Q1", "#ff0000", "📁").
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, ParentID: &parent, Name: "Q1", Color: "#ff0000", Icon: "📁", CreatedAt: time.Now()}))
+
+	collection := &Collection{UserID: 1, ParentID: &parent, Name: "Q1", Color: "#ff0000", Icon: "📁"}
+	if err := repo.CreateCollection(collection); err != nil {
+		t.Fatalf("CreateCollection вернул ошибку: %v", err)
+	}
+	if collection.ID != 3 || *collection.ParentID != 2 {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+}
+
+func TestCreateCollectionInMissingParent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	parent := 9
+
+	mock.ExpectQuery(roleQuery).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+	err = repo.CreateCollection(&Collection{UserID: 1, ParentID: &parent, Name: "Q1"})
+	if !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ErrCollectionNotFound, получено %v", err)
+	}
+}
+
+func TestGetTasksByCollectionRecursive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	child := 4
+
+	mock.ExpectQuery(treeRe(`$2`, `sub.deleted_at IS NULL`)+`\s+SELECT (.+) FROM tasks\s+WHERE collection_id IN \(SELECT id FROM tree\) AND `+readableRe(`$1`)+` AND deleted_at IS NULL`).
+		WithArgs(1, 3).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, CollectionID: &child, Name: "Nested", CreateTime: time.Now()}))
+
+	tasks, err := repo.GetTasksByCollection(1, 3, true, ListOptions{Sort: SortCreateTime, Order: "desc"})
+	if err != nil {
+		t.Fatalf("GetTasksByCollection вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || *tasks[0].CollectionID != 4 {
+		t.Errorf("Ожидалась задача из вложенной коллекции, получено %+v", tasks)
+	}
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// Ручной порядок: у задач и коллекций есть position, списки по умолчанию идут по (position, id).
+// Позиции редкие, с шагом positionGap, поэтому перестановка "X перед/после Y" обычно меняет одну строку:
+// X получает середину промежутка между Y и его соседом. Когда промежуток исчерпан, список перенумеровывается.
+// Список задач — задачи одной коллекции или личные задачи автора без коллекции; список коллекций —
+// соседи одного владельца под общим родителем. При переносе в другой список позиция сохраняется
+
+var (
+	ErrReorderRequest = errors.New("exactly one of before or after is required")
+	ErrReorderAnchor  = errors.New("before/after must reference another item of the same list")
+)
+
+// positionGap шаг между соседними позициями при вставке в край списка и при перенумерации
+const positionGap = 1024
+
+// Первые ключи advisory-блокировки списка задач; второй — id коллекции или автора личных задач
+const (
+	collectionTasksLock = 2
+	personalTasksLock   = 3
+)
+
+// ReorderRequest тело POST /tasks/{id}/reorder и /collections/{id}/reorder: id соседа, перед или после которого встать
+type ReorderRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+// Anchor сосед и сторона вставки; ErrReorderRequest, если задано не ровно одно поле
+func (r ReorderRequest) Anchor() (id int, after bool, err error) {
+	switch {
+	case r.Before != nil && r.After == nil:
+		return *r.Before, false, nil
+	case r.After != nil && r.Before == nil:
+		return *r.After, true, nil
+	}
+	return 0, false, ErrReorderRequest
+}
+
+// taskListScope условие "задача в списке коллекции collection или, если она NULL, в личном списке автора user"
+func taskListScope(collection, user string) string {
+	return "(collection_id = " + collection + " OR " + collection + " IS NULL AND collection_id IS NULL AND user_id = " + user + ")"
+}
+
+// collectionListScope условие "коллекция владельца user под родителем parent" (NULL — верхний уровень)
+func collectionListScope(parent, user string) string {
+	return "user_id = " + user + " AND parent_id IS NOT DISTINCT FROM " + parent
+}
+
+// ReorderTaskByUser ставит задачу перед (after=false) или после anchorID в её списке.
+// Порядок меняет тот, кто распоряжается задачей; иначе ErrTaskNotFound
+func (r *TaskRepository) ReorderTaskByUser(id, userID, anchorID int, after bool) (*Task, error) {
+	if anchorID == id {
+		return nil, ErrReorderAnchor
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var collectionID *int
+	var authorID int
+	err = tx.QueryRow(`
+	SELECT collection_id, user_id FROM tasks
+	WHERE id = $1 AND `+taskManageable("", "$2")+` AND deleted_at IS NULL
+	FOR UPDATE`, id, userID).Scan(&collectionID, &authorID)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock, key := personalTasksLock, authorID
+	if collectionID != nil {
+		lock, key = collectionTasksLock, *collectionID
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, lock, key); err != nil {
+		return nil, err
+	}
+
+	position, err := placeNear(tx, "tasks", taskListScope("$1", "$2"), []interface{}{collectionID, authorID}, id, anchorID, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var task Task
+	err = scanTask(tx.QueryRow(`
+	UPDATE tasks SET position = $2
+	WHERE id = $1
+	RETURNING `+taskColumns, id, position), &task)
+	if err != nil {
+		return nil, err
+	}
+	return &task, tx.Commit()
+}
+
+// ReorderCollectionByUser ставит коллекцию перед (after=false) или после соседа anchorID.
+// Порядок коллекций общий для всех участников, поэтому менять его может только владелец
+func (r *TaskRepository) ReorderCollectionByUser(id, userID, anchorID int, after bool) (*Collection, error) {
+	if anchorID == id {
+		return nil, ErrReorderAnchor
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Та же блокировка, что у переноса: он меняет состав списков соседей
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, collectionTreeLock, userID); err != nil {
+		return nil, err
+	}
+
+	if err := requireOwner(tx, id, userID); err != nil {
+		return nil, err
+	}
+
+	var parentID *int
+	if err := tx.QueryRow(`SELECT parent_id FROM collections WHERE id = $1`, id).Scan(&parentID); err != nil {
+		return nil, err
+	}
+
+	position, err := placeNear(tx, "collections", collectionListScope("$1", "$2"), []interface{}{parentID, userID}, id, anchorID, after)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := Collection{Role: RoleOwner}
+	err = tx.QueryRow(`
+	UPDATE collections SET position = $2
+	WHERE id = $1
+	RETURNING `+collectionColumns, id, position).Scan(collectionDest(&collection)...)
+	if err != nil {
+		return nil, err
+	}
+	return &collection, tx.Commit()
+}
+
+// placeNear позиция для строки id рядом с anchorID в списке scope таблицы table. scope занимает параметры
+// scopeArgs; сама строка id среди соседей не учитывается. ErrReorderAnchor, если anchorID не из этого списка
+func placeNear(tx *sql.Tx, table, scope string, scopeArgs []interface{}, id, anchorID int, after bool) (int64, error) {
+	args := append(append([]interface{}{}, scopeArgs...), id, anchorID)
+	idParam, anchorParam := "$"+strconv.Itoa(len(args)-1), "$"+strconv.Itoa(len(args))
+
+	cmp, dir := "<", "DESC"
+	if after {
+		cmp, dir = ">", "ASC"
+	}
+	query := `
+	SELECT a.position, (
+		SELECT position FROM ` + table + `
+		WHERE ` + scope + ` AND deleted_at IS NULL AND id <> ` + idParam + ` AND (position, id) ` + cmp + ` (a.position, a.id)
+		ORDER BY position ` + dir + `, id ` + dir + ` LIMIT 1)
+	FROM ` + table + ` a
+	WHERE a.id = ` + anchorParam + ` AND a.deleted_at IS NULL AND ` + scope
+
+	for renumbered := false; ; renumbered = true {
+		var anchor int64
+		var neighbour sql.NullInt64
+		err := tx.QueryRow(query, args...).Scan(&anchor, &neighbour)
+		if err == sql.ErrNoRows {
+			return 0, ErrReorderAnchor
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if !neighbour.Valid {
+			if after {
+				return anchor + positionGap, nil
+			}
+			return anchor - positionGap, nil
+		}
+
+		lo, hi := anchor, neighbour.Int64
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if hi-lo > 1 || renumbered {
+			return lo + (hi-lo)/2, nil
+		}
+
+		// Промежуток исчерпан: раздвигаем весь список и ищем соседей заново
+		if _, err := tx.Exec(`
+	UPDATE `+table+` t SET position = r.rn * `+strconv.Itoa(positionGap)+`
+	FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS rn FROM `+table+` WHERE `+scope+`) r
+	WHERE t.id = r.id`, scopeArgs...); err != nil {
+			return 0, err
+		}
+	}
+}
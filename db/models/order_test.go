@@ -0,0 +1,315 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// neighbourQuery запрос позиции якоря и его соседа (placeNear)
+const neighbourQuery = `SELECT a.position, \(`
+
+func neighbourRows(anchor int64, neighbour interface{}) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"position", "neighbour"}).AddRow(anchor, neighbour)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ReorderRequest
+// ============================================================================
+
+func TestReorderRequestAnchor(t *testing.T) {
+	id, after, err := ReorderRequest{Before: intPtr(5)}.Anchor()
+	if err != nil || id != 5 || after {
+		t.Errorf("before=5: ожидалось (5, false), получено (%d, %v, %v)", id, after, err)
+	}
+
+	id, after, err = ReorderRequest{After: intPtr(6)}.Anchor()
+	if err != nil || id != 6 || !after {
+		t.Errorf("after=6: ожидалось (6, true), получено (%d, %v, %v)", id, after, err)
+	}
+
+	for _, req := range []ReorderRequest{{}, {Before: intPtr(1), After: intPtr(2)}} {
+		if _, _, err := req.Anchor(); !errors.Is(err, ErrReorderRequest) {
+			t.Errorf("%+v: ожидалась ErrReorderRequest, получено %v", req, err)
+		}
+	}
+}
+
+func TestCreateTaskGoesToTopOfList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`(SELECT COALESCE(MIN(position), 0) - 1024 FROM tasks WHERE ` + taskListScope("$2", "$1") + `)`)).
+		WillReturnRows(taskRows(Task{ID: 10, UserID: 1, Name: "Task", CreateTime: time.Now(), Position: -1024}))
+	mock.ExpectCommit()
+
+	task := &Task{UserID: 1, Name: "Task", Priority: PriorityNone}
+	if err := repo.CreateTask(task, false); err != nil {
+		t.Fatalf("CreateTask вернул ошибку: %v", err)
+	}
+	if task.Position != -1024 {
+		t.Errorf("Ожидалась позиция -1024, получено %d", task.Position)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ReorderTaskByUser
+// ============================================================================
+
+func TestReorderTaskByUserBetweenNeighbours(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	collectionID := 3
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NULL\s+FOR UPDATE`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id"}).AddRow(3, 2))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1, \$2\)`).
+		WithArgs(collectionTasksLock, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Перед задачей 7: сосед ищется выше неё, по убыванию
+	mock.ExpectQuery(neighbourQuery+`(.+)AND id <> \$3 AND \(position, id\) < \(a.position, a.id\)\s+ORDER BY position DESC, id DESC LIMIT 1\)`).
+		WithArgs(&collectionID, 2, 10, 7).
+		WillReturnRows(neighbourRows(2048, 1024))
+	mock.ExpectQuery(`UPDATE tasks SET position = \$2\s+WHERE id = \$1`).
+		WithArgs(10, int64(1536)).
+		WillReturnRows(taskRows(Task{ID: 10, UserID: 2, CollectionID: &collectionID, Name: "Task", CreateTime: time.Now(), Position: 1536}))
+	mock.ExpectCommit()
+
+	task, err := repo.ReorderTaskByUser(10, 1, 7, false)
+	if err != nil {
+		t.Fatalf("ReorderTaskByUser вернул ошибку: %v", err)
+	}
+	if task.Position != 1536 {
+		t.Errorf("Ожидалась позиция 1536, получено %d", task.Position)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderTaskByUserAfterLast(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id"}).AddRow(nil, 1))
+	// Личные задачи блокируются по автору
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1, \$2\)`).
+		WithArgs(personalTasksLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(neighbourQuery+`(.+)\(position, id\) > \(a.position, a.id\)\s+ORDER BY position ASC, id ASC LIMIT 1\)`).
+		WithArgs(nil, 1, 10, 7).
+		WillReturnRows(neighbourRows(4096, nil))
+	mock.ExpectQuery(`UPDATE tasks SET position = \$2`).
+		WithArgs(10, int64(4096+positionGap)).
+		WillReturnRows(taskRows(Task{ID: 10, UserID: 1, Name: "Task", CreateTime: time.Now(), Position: 4096 + positionGap}))
+	mock.ExpectCommit()
+
+	if _, err := repo.ReorderTaskByUser(10, 1, 7, true); err != nil {
+		t.Fatalf("ReorderTaskByUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderTaskByUserRenumbersWhenGapExhausted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id"}).AddRow(nil, 1))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(neighbourQuery).
+		WithArgs(nil, 1, 10, 7).
+		WillReturnRows(neighbourRows(101, 100))
+	mock.ExpectExec(`UPDATE tasks t SET position = r.rn \* 1024\s+FROM \(SELECT id, ROW_NUMBER\(\) OVER \(ORDER BY position, id\) AS rn FROM tasks WHERE `).
+		WithArgs(nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectQuery(neighbourQuery).
+		WithArgs(nil, 1, 10, 7).
+		WillReturnRows(neighbourRows(3072, 2048))
+	mock.ExpectQuery(`UPDATE tasks SET position = \$2`).
+		WithArgs(10, int64(2560)).
+		WillReturnRows(taskRows(Task{ID: 10, UserID: 1, Name: "Task", CreateTime: time.Now(), Position: 2560}))
+	mock.ExpectCommit()
+
+	if _, err := repo.ReorderTaskByUser(10, 1, 7, false); err != nil {
+		t.Fatalf("ReorderTaskByUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderTaskByUserAnchorFromOtherList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"collection_id", "user_id"}).AddRow(nil, 1))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(neighbourQuery).
+		WithArgs(nil, 1, 10, 7).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := repo.ReorderTaskByUser(10, 1, 7, false); !errors.Is(err, ErrReorderAnchor) {
+		t.Errorf("Ожидалась ErrReorderAnchor, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderTaskByUserNotManageable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT collection_id, user_id FROM tasks`).
+		WithArgs(10, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := repo.ReorderTaskByUser(10, 1, 7, false); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ErrTaskNotFound, получено %v", err)
+	}
+
+	if _, err := repo.ReorderTaskByUser(10, 1, 10, false); !errors.Is(err, ErrReorderAnchor) {
+		t.Errorf("Задача не может встать рядом с собой: ожидалась ErrReorderAnchor, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ReorderCollectionByUser
+// ============================================================================
+
+func TestReorderCollectionByUserSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	parent := 2
+
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(4, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	mock.ExpectQuery(`SELECT parent_id FROM collections WHERE id = \$1`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"parent_id"}).AddRow(2))
+	mock.ExpectQuery(neighbourQuery+`\s+SELECT position FROM collections\s+WHERE user_id = \$2 AND parent_id IS NOT DISTINCT FROM \$1`).
+		WithArgs(&parent, 1, 4, 5).
+		WillReturnRows(neighbourRows(1024, 2048))
+	mock.ExpectQuery(`UPDATE collections SET position = \$2\s+WHERE id = \$1`).
+		WithArgs(4, int64(1536)).
+		WillReturnRows(collectionRows(Collection{ID: 4, UserID: 1, ParentID: &parent, Name: "Q2", CreatedAt: time.Now(), Position: 1536}))
+	mock.ExpectCommit()
+
+	collection, err := repo.ReorderCollectionByUser(4, 1, 5, true)
+	if err != nil {
+		t.Fatalf("ReorderCollectionByUser вернул ошибку: %v", err)
+	}
+	if collection.Position != 1536 || collection.Role != RoleOwner {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestReorderCollectionByUserNotOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(treeLockQuery).
+		WithArgs(collectionTreeLock, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(roleQuery).
+		WithArgs(4, 1).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectRollback()
+
+	if _, err := repo.ReorderCollectionByUser(4, 1, 5, false); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
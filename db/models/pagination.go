@@ -0,0 +1,177 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyset-пагинация по паре (время создания, id), (ручная позиция, id) или (имя, id): курсор указывает на последнюю
+// отданную запись, следующая страница начинается строго после неё. В отличие от OFFSET
+// выборка не сдвигается, если между запросами добавились или удалились записи
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor позиция последней отданной записи. Position задан у курсоров ручного порядка, Name — у порядка
+// по имени, Time — у остальных
+type Cursor struct {
+	Time     time.Time
+	Position *int64
+	Name     *string
+	ID       int
+}
+
+// positionPrefix и namePrefix отличают курсоры ручного порядка и порядка по имени: время в RFC3339
+// ни с "p", ни с "n" не начинается
+const (
+	positionPrefix = "p"
+	namePrefix     = "n"
+)
+
+// Encode упаковывает курсор в непрозрачную для клиента строку
+func (c Cursor) Encode() string {
+	key := c.Time.UTC().Format(time.RFC3339Nano)
+	if c.Position != nil {
+		key = positionPrefix + strconv.FormatInt(*c.Position, 10)
+	}
+	if c.Name != nil {
+		key = namePrefix + *c.Name
+	}
+	raw := key + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor разбирает строку, полученную из Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Имя может содержать "|", id — нет, поэтому режем по последнему разделителю
+	sep := strings.LastIndex(string(raw), "|")
+	if sep < 0 {
+		return nil, ErrInvalidCursor
+	}
+	key, idStr := string(raw[:sep]), string(raw[sep+1:])
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	if pos, ok := strings.CutPrefix(key, positionPrefix); ok {
+		p, err := strconv.ParseInt(pos, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return &Cursor{Position: &p, ID: id}, nil
+	}
+	if name, ok := strings.CutPrefix(key, namePrefix); ok {
+		return &Cursor{Name: &name, ID: id}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, key)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Time: t, ID: id}, nil
+}
+
+// PageParams параметры страницы. Нулевое значение — без пагинации, весь список
+type PageParams struct {
+	Limit int
+	After *Cursor
+}
+
+// Paginated сообщает, запрошена ли постраничная выдача
+func (p PageParams) Paginated() bool {
+	return p.Limit > 0
+}
+
+// CheckSort ErrInvalidCursor, если курсор выдан для другого порядка: ручного (byPosition) или по времени
+func (p PageParams) CheckSort(byPosition bool) error {
+	if p.After != nil && (p.After.Name != nil || (p.After.Position != nil) != byPosition) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// CheckByName ErrInvalidCursor, если курсор выдан не для порядка по имени
+func (p PageParams) CheckByName() error {
+	if p.After != nil && p.After.Name == nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// keyset строит условие продолжения после курсора и LIMIT для колонки column: position, name или колонки времени.
+// Выбирается на одну запись больше лимита, чтобы узнать, есть ли следующая страница
+func (p PageParams) keyset(column string, desc bool, args []interface{}) (where, limit string, _ []interface{}) {
+	if !p.Paginated() {
+		return "", "", args
+	}
+
+	if p.After != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		var key interface{} = p.After.Time
+		if p.After.Position != nil {
+			key = *p.After.Position
+		}
+		if p.After.Name != nil {
+			key = *p.After.Name
+		}
+		args = append(args, key, p.After.ID)
+		where = " AND (" + column + ", id) " + op + " ($" + strconv.Itoa(len(args)-1) + ", $" + strconv.Itoa(len(args)) + ")"
+	}
+	limit = " LIMIT " + strconv.Itoa(p.Limit+1)
+	return where, limit, args
+}
+
+// Page страница списка. NextCursor пуст на последней странице
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// NewPage отрезает запись, выбранную сверх лимита, и по последней оставшейся строит курсор
+func NewPage[T any](items []T, limit int, cursor func(T) Cursor) Page[T] {
+	page := Page[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if limit > 0 && len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = cursor(page.Items[limit-1]).Encode()
+	}
+	return page
+}
+
+// TaskCursor позиция задачи в keyset-пагинации
+func TaskCursor(t Task) Cursor {
+	return Cursor{Time: t.CreateTime, ID: t.ID}
+}
+
+// TaskPositionCursor позиция задачи в keyset-пагинации по ручному порядку
+func TaskPositionCursor(t Task) Cursor {
+	return Cursor{Position: &t.Position, ID: t.ID}
+}
+
+// TagCursor позиция тега в keyset-пагинации; теги всегда идут по имени
+func TagCursor(t Tag) Cursor {
+	return Cursor{Name: &t.Name, ID: t.ID}
+}
+
+// CollectionCursor позиция коллекции в keyset-пагинации; коллекции всегда идут в ручном порядке
+func CollectionCursor(c Collection) Cursor {
+	return Cursor{Position: &c.Position, ID: c.ID}
+}
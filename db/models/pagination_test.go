@@ -0,0 +1,242 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ Cursor
+// ============================================================================
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := Cursor{Time: time.Date(2025, 3, 3, 9, 15, 30, 123456000, time.UTC), ID: 42}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor вернул ошибку: %v", err)
+	}
+	if !decoded.Time.Equal(c.Time) || decoded.ID != c.ID {
+		t.Errorf("Ожидался %+v, получено %+v", c, decoded)
+	}
+}
+
+func TestPositionCursorRoundTrip(t *testing.T) {
+	position := int64(-2048)
+	c := Cursor{Position: &position, ID: 7}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor вернул ошибку: %v", err)
+	}
+	if decoded.Position == nil || *decoded.Position != position || decoded.ID != c.ID {
+		t.Errorf("Ожидался %+v, получено %+v", c, decoded)
+	}
+}
+
+func TestNameCursorRoundTrip(t *testing.T) {
+	name := "team|q3"
+	c := Cursor{Name: &name, ID: 5}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor вернул ошибку: %v", err)
+	}
+	if decoded.Name == nil || *decoded.Name != name || decoded.ID != c.ID {
+		t.Errorf("Ожидался %+v, получено %+v", c, decoded)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	cases := []string{
+		"not base64!",
+		Cursor{ID: 1}.Encode()[:4],
+		"MjAyNS0wMy0wMw",                 // без id
+		"YmFkLWRhdGV8MQ",                 // "bad-date|1"
+		"MjAyNS0wMy0wM1QwOTowMDowMFp8MA", // id = 0
+		"cHh8MQ",                         // "px|1"
+	}
+
+	for _, c := range cases {
+		if _, err := DecodeCursor(c); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) должен вернуть ErrInvalidCursor, получено %v", c, err)
+		}
+	}
+}
+
+func TestPageParamsCheckSort(t *testing.T) {
+	position := int64(1024)
+	byTime := PageParams{Limit: 10, After: &Cursor{Time: time.Now(), ID: 1}}
+	byPosition := PageParams{Limit: 10, After: &Cursor{Position: &position, ID: 1}}
+
+	if byTime.CheckSort(false) != nil || byPosition.CheckSort(true) != nil || (PageParams{}).CheckSort(true) != nil {
+		t.Error("Курсор своего порядка должен приниматься")
+	}
+	if !errors.Is(byTime.CheckSort(true), ErrInvalidCursor) || !errors.Is(byPosition.CheckSort(false), ErrInvalidCursor) {
+		t.Error("Курсор другого порядка должен отклоняться с ErrInvalidCursor")
+	}
+}
+
+func TestPageParamsCheckByName(t *testing.T) {
+	name := "home"
+	byName := PageParams{Limit: 10, After: &Cursor{Name: &name, ID: 1}}
+	byTime := PageParams{Limit: 10, After: &Cursor{Time: time.Now(), ID: 1}}
+
+	if byName.CheckByName() != nil || (PageParams{}).CheckByName() != nil {
+		t.Error("Курсор порядка по имени должен приниматься")
+	}
+	if !errors.Is(byTime.CheckByName(), ErrInvalidCursor) || !errors.Is(byName.CheckSort(false), ErrInvalidCursor) {
+		t.Error("Курсор другого порядка должен отклоняться с ErrInvalidCursor")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ NewPage
+// ============================================================================
+
+func TestNewPageTrimsExtraItem(t *testing.T) {
+	now := time.Now()
+	tasks := []Task{{ID: 3, CreateTime: now}, {ID: 2, CreateTime: now}, {ID: 1, CreateTime: now}}
+
+	page := NewPage(tasks, 2, TaskCursor)
+	if len(page.Items) != 2 {
+		t.Fatalf("Ожидалось 2 задачи, получено %d", len(page.Items))
+	}
+	if page.NextCursor != TaskCursor(tasks[1]).Encode() {
+		t.Errorf("Курсор должен указывать на последнюю отданную задачу, получено %q", page.NextCursor)
+	}
+}
+
+func TestNewPageLastPage(t *testing.T) {
+	page := NewPage([]Task{{ID: 1}}, 2, TaskCursor)
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("На последней странице курсор должен быть пустым, получено %+v", page)
+	}
+
+	empty := NewPage[Task](nil, 2, TaskCursor)
+	if empty.Items == nil {
+		t.Error("Пустая страница должна отдавать [], а не null")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ постраничных запросов
+// ============================================================================
+
+func TestGetAllTasksByUserPaginated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	after := Cursor{Time: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), ID: 10}
+
+	mock.ExpectQuery(`WHERE `+readableRe(`$1`)+` AND deleted_at IS NULL\s+AND \(create_time, id\) < \(\$2, \$3\)\s+ORDER BY create_time DESC NULLS LAST, id DESC LIMIT 3`).
+		WithArgs(1, after.Time, after.ID).
+		WillReturnRows(taskRows(Task{ID: 9, UserID: 1, Name: "Task", CreateTime: after.Time}))
+
+	opts := ListOptions{Sort: SortPriority, PageParams: PageParams{Limit: 2, After: &after}}
+	tasks, err := repo.GetAllTasksByUser(1, opts)
+	if err != nil {
+		t.Fatalf("GetAllTasksByUser вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Ожидалась 1 задача, получено %d", len(tasks))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetTasksByCollectionPaginatedByPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	position := int64(2048)
+	after := Cursor{Position: &position, ID: 10}
+
+	mock.ExpectQuery(`AND \(position, id\) > \(\$3, \$4\)\s+ORDER BY position ASC NULLS LAST, id ASC LIMIT 3`).
+		WithArgs(1, 5, position, after.ID).
+		WillReturnRows(taskRows(Task{ID: 4, UserID: 1, Name: "Task", Position: 3072}))
+
+	opts := ListOptions{Sort: SortPosition, Order: "asc", PageParams: PageParams{Limit: 2, After: &after}}
+	tasks, err := repo.GetTasksByCollection(1, 5, false, opts)
+	if err != nil {
+		t.Fatalf("GetTasksByCollection вернул ошибку: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Position != 3072 {
+		t.Errorf("Ожидалась задача с позицией 3072, получено %+v", tasks)
+	}
+	if cursor := opts.CursorOf(tasks[0]); cursor.Position == nil || *cursor.Position != 3072 {
+		t.Errorf("Курсор ручного порядка должен нести позицию, получено %+v", cursor)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetTasksByTagsPaginatedAscending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	after := Cursor{Time: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), ID: 10}
+
+	mock.ExpectQuery(`AND \(create_time, id\) > \(\$3, \$4\)\s+ORDER BY create_time ASC NULLS LAST, id ASC LIMIT 6`).
+		WithArgs(1, `{"work"}`, after.Time, after.ID).
+		WillReturnRows(taskRows())
+
+	opts := ListOptions{Order: "asc", PageParams: PageParams{Limit: 5, After: &after}}
+	if _, err := repo.GetTasksByTags(1, []string{"work"}, false, opts); err != nil {
+		t.Fatalf("GetTasksByTags вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestGetCollectionsByUserPaginated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	position := int64(2048)
+	after := Cursor{Position: &position, ID: 4}
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position", "role"}).
+		AddRow(5, 2, nil, "Work", "#ff0000", "💼", now, 1024, RoleEditor)
+	mock.ExpectQuery(`AND \(position, id\) > \(\$2, \$3\)\s+ORDER BY position ASC, id ASC LIMIT 11`).
+		WithArgs(1, position, 4).
+		WillReturnRows(rows)
+
+	collections, err := repo.GetCollectionsByUser(1, PageParams{Limit: 10, After: &after})
+	if err != nil {
+		t.Fatalf("GetCollectionsByUser вернул ошибку: %v", err)
+	}
+	if len(collections) != 1 || collections[0].ID != 5 || collections[0].Role != RoleEditor {
+		t.Errorf("Ожидалась коллекция с ID 5, получено %+v", collections)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Сброс пароля по почте. Токен сброса выпускает apiservice и отправляет письмом, сюда приходит только его sha256.
+// Новый запрос сброса отменяет прежние неиспользованные токены, смена почты — тоже
+
+var (
+	ErrResetTokenInvalid = errors.New("password reset token not found, used or expired")
+	ErrEmailTaken        = errors.New("email is already in use")
+)
+
+// CreatePasswordResetRequest тело POST /password-resets
+type CreatePasswordResetRequest struct {
+	Email      string `json:"email"`
+	TokenHash  string `json:"token_hash"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// ResetPasswordRequest тело POST /password-resets/consume
+type ResetPasswordRequest struct {
+	TokenHash    string `json:"token_hash"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// PasswordReset ответ POST /password-resets/consume: чей пароль сброшен и с какого момента отозваны его токены
+type PasswordReset struct {
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// UpdateEmailRequest тело PUT /account/email; пустая строка удаляет почту
+type UpdateEmailRequest struct {
+	Email string `json:"email"`
+}
+
+// CreatePasswordResetToken сохраняет токен сброса для владельца почты и возвращает его.
+// Почта сравнивается без учёта регистра; нет такой почты — ErrUserNotFound
+func (r *TaskRepository) CreatePasswordResetToken(email, tokenHash string, ttl time.Duration) (*User, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user User
+	err = tx.QueryRow(`
+		SELECT id, username, created_at FROM users
+		WHERE LOWER(email) = LOWER($1)`, email).Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Действует только последняя ссылка
+	if err := deleteResetTokens(tx, user.ID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))`,
+		user.ID, tokenHash, int64(ttl/time.Second)); err != nil {
+		return nil, err
+	}
+
+	return &user, tx.Commit()
+}
+
+// ResetPassword гасит токен сброса, сохраняет новый хэш пароля и отзывает все токены пользователя
+func (r *TaskRepository) ResetPassword(tokenHash, passwordHash string) (*PasswordReset, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Условие used_at IS NULL в самом UPDATE: из двух одновременных сбросов одним токеном пройдёт один
+	var reset PasswordReset
+	err = tx.QueryRow(`
+		UPDATE password_reset_tokens t SET used_at = NOW()
+		FROM users u
+		WHERE u.id = t.user_id AND t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > NOW()
+		RETURNING u.id, u.username`, tokenHash).Scan(&reset.UserID, &reset.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if reset.RevokedBefore, err = updatePassword(tx, reset.UserID, passwordHash); err != nil {
+		return nil, err
+	}
+
+	return &reset, tx.Commit()
+}
+
+// UpdateEmail меняет почту пользователя и отменяет ссылки сброса, отправленные на прежнюю
+func (r *TaskRepository) UpdateEmail(userID int, email string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET email = NULLIF($2, '') WHERE id = $1`, userID, email)
+	if isUniqueViolation(err) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := deleteResetTokens(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteResetTokens удаляет неиспользованные токены сброса пользователя
+func deleteResetTokens(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+	return err
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ CreatePasswordResetToken, ResetPassword и UpdateEmail
+// ============================================================================
+
+func TestCreatePasswordResetToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, username, created_at FROM users\s+WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("User@Example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}).AddRow(1, "testuser", time.Now()))
+	// Прежние ссылки перестают действовать
+	mock.ExpectExec(`DELETE FROM password_reset_tokens WHERE user_id = \$1 AND used_at IS NULL`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO password_reset_tokens \(user_id, token_hash, expires_at\)\s+VALUES \(\$1, \$2, NOW\(\) \+ make_interval\(secs => \$3\)\)`).
+		WithArgs(1, oldHash, int64(3600)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	user, err := repo.CreatePasswordResetToken("User@Example.com", oldHash, time.Hour)
+	if err != nil {
+		t.Fatalf("CreatePasswordResetToken вернул ошибку: %v", err)
+	}
+	if user.ID != 1 || user.Username != "testuser" {
+		t.Errorf("Неправильный пользователь: %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestCreatePasswordResetTokenUnknownEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, username, created_at FROM users`).
+		WithArgs("nobody@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "created_at"}))
+	mock.ExpectRollback()
+
+	if _, err := repo.CreatePasswordResetToken("nobody@example.com", oldHash, time.Hour); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens t SET used_at = NOW\(\)\s+FROM users u\s+WHERE u.id = t.user_id AND t.token_hash = \$1 AND t.used_at IS NULL AND t.expires_at > NOW\(\)\s+RETURNING u.id, u.username`).
+		WithArgs(oldHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(1, "testuser"))
+	mock.ExpectQuery(`UPDATE users SET password_hash = \$2, tokens_revoked_at = NOW\(\)`).
+		WithArgs(1, "new-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	reset, err := repo.ResetPassword(oldHash, "new-hash")
+	if err != nil {
+		t.Fatalf("ResetPassword вернул ошибку: %v", err)
+	}
+	if reset.UserID != 1 || reset.Username != "testuser" || !reset.RevokedBefore.Equal(now) {
+		t.Errorf("Неправильный результат: %+v", reset)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestResetPasswordInvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE password_reset_tokens`).
+		WithArgs(oldHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+	mock.ExpectRollback()
+
+	if _, err := repo.ResetPassword(oldHash, "new-hash"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("Ожидалась ErrResetTokenInvalid, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUpdateEmailUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET email = NULLIF\(\$2, ''\) WHERE id = \$1`).
+		WithArgs(99, "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := repo.UpdateEmail(99, ""); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+}
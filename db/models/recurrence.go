@@ -0,0 +1,235 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Поддерживаемое подмножество RFC 5545 RRULE:
+// FREQ=DAILY|WEEKLY|MONTHLY|YEARLY, INTERVAL, BYDAY (для DAILY и WEEKLY), COUNT, UNTIL.
+// COUNT хранит, сколько повторений осталось включая текущее: у каждой следующей
+// задачи он уменьшается на единицу, поэтому отдельный счётчик в таблице не нужен
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+var rruleDayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Recurrence разобранное правило повторения
+type Recurrence struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    *time.Time
+}
+
+// ParseRRule разбирает строку вида "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10".
+// Префикс "RRULE:" допускается
+func ParseRRule(s string) (*Recurrence, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRecurrence)
+	}
+
+	rule := &Recurrence{Interval: 1}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRecurrence, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: duplicate %s", ErrInvalidRecurrence, key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRecurrence, value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, fmt.Errorf("%w: INTERVAL must be between 1 and 1000", ErrInvalidRecurrence)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be positive", ErrInvalidRecurrence)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad UNTIL %s", ErrInvalidRecurrence, value)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, ok := rruleWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("%w: unsupported BYDAY %s", ErrInvalidRecurrence, day)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRecurrence, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRecurrence)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRecurrence)
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != FreqDaily && rule.Freq != FreqWeekly {
+		return nil, fmt.Errorf("%w: BYDAY is only supported with DAILY and WEEKLY", ErrInvalidRecurrence)
+	}
+
+	return rule, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Дата без времени включает весь день
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// String собирает правило обратно в каноничном порядке частей
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, rruleDayNames[wd])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next возвращает следующее повторение после from и правило для новой задачи.
+// ok=false, если серия закончилась (COUNT исчерпан или вышли за UNTIL)
+func (r *Recurrence) Next(from time.Time) (next time.Time, rest *Recurrence, ok bool) {
+	if r.Count == 1 {
+		return time.Time{}, nil, false
+	}
+
+	switch r.Freq {
+	case FreqDaily, FreqWeekly:
+		next = r.nextByDays(from)
+	case FreqMonthly:
+		next = addMonthsKeepDay(from, r.Interval)
+	case FreqYearly:
+		next = addMonthsKeepDay(from, 12*r.Interval)
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, nil, false
+	}
+
+	rest = &Recurrence{Freq: r.Freq, Interval: r.Interval, ByDay: r.ByDay, Until: r.Until}
+	if r.Count > 0 {
+		rest.Count = r.Count - 1
+	}
+	return next, rest, true
+}
+
+// nextByDays перебирает дни после from: шаг INTERVAL в днях (DAILY) или неделях (WEEKLY),
+// с фильтром BYDAY. Неделя начинается с понедельника, как WKST=MO по умолчанию
+func (r *Recurrence) nextByDays(from time.Time) time.Time {
+	if len(r.ByDay) == 0 {
+		if r.Freq == FreqDaily {
+			return from.AddDate(0, 0, r.Interval)
+		}
+		return from.AddDate(0, 0, 7*r.Interval)
+	}
+
+	allowed := make(map[time.Weekday]bool, len(r.ByDay))
+	for _, wd := range r.ByDay {
+		allowed[wd] = true
+	}
+
+	fromWeek := weekStart(from)
+	for i := 1; i <= 7*r.Interval+7; i++ {
+		candidate := from.AddDate(0, 0, i)
+		if !allowed[candidate.Weekday()] {
+			continue
+		}
+		if r.Freq == FreqDaily && i%r.Interval != 0 {
+			continue
+		}
+		if r.Freq == FreqWeekly && weeksBetween(fromWeek, weekStart(candidate))%r.Interval != 0 {
+			continue
+		}
+		return candidate
+	}
+	return from.AddDate(0, 0, 7*r.Interval)
+}
+
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func weeksBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours()/24+0.5) / 7
+}
+
+// addMonthsKeepDay сдвигает на n месяцев, пропуская месяцы без нужного числа
+// (31 января -> 31 марта), как это делает RRULE, а не time.AddDate с переносом
+func addMonthsKeepDay(t time.Time, n int) time.Time {
+	y, m, d := t.Date()
+	hh, mm, ss := t.Clock()
+	for step := n; ; step += n {
+		candidate := time.Date(y, m+time.Month(step), d, hh, mm, ss, t.Nanosecond(), t.Location())
+		if candidate.Day() == d {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ParseRRule
+// ============================================================================
+
+func TestParseRRuleCanonical(t *testing.T) {
+	cases := map[string]string{
+		"FREQ=DAILY":                            "FREQ=DAILY",
+		"RRULE:freq=weekly;byday=mo,fr":         "FREQ=WEEKLY;BYDAY=MO,FR",
+		"FREQ=MONTHLY;INTERVAL=1;COUNT=5":       "FREQ=MONTHLY;COUNT=5",
+		"COUNT=3;INTERVAL=2;FREQ=YEARLY":        "FREQ=YEARLY;INTERVAL=2;COUNT=3",
+		"FREQ=WEEKLY;UNTIL=20250301T120000Z":    "FREQ=WEEKLY;UNTIL=20250301T120000Z",
+		"FREQ=DAILY;UNTIL=20250301":             "FREQ=DAILY;UNTIL=20250301T235959Z",
+		" FREQ=DAILY ; INTERVAL=3 ; BYDAY=SA  ": "FREQ=DAILY;INTERVAL=3;BYDAY=SA",
+	}
+
+	for input, want := range cases {
+		rule, err := ParseRRule(input)
+		if err != nil {
+			t.Errorf("ParseRRule(%q) вернул ошибку: %v", input, err)
+			continue
+		}
+		if got := rule.String(); got != want {
+			t.Errorf("ParseRRule(%q).String() = %q, ожидалось %q", input, got, want)
+		}
+	}
+}
+
+func TestParseRRuleInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=MONTHLY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;BYMONTH=1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ",
+	}
+
+	for _, input := range cases {
+		if _, err := ParseRRule(input); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("ParseRRule(%q) должен вернуть ErrInvalidRecurrence, получено %v", input, err)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ Recurrence.Next
+// ============================================================================
+
+func mustParseRRule(t *testing.T, s string) *Recurrence {
+	t.Helper()
+	rule, err := ParseRRule(s)
+	if err != nil {
+		t.Fatalf("ParseRRule(%q) вернул ошибку: %v", s, err)
+	}
+	return rule
+}
+
+func TestNextIntervals(t *testing.T) {
+	// Понедельник, 3 марта 2025
+	from := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		rule string
+		want time.Time
+	}{
+		{"FREQ=DAILY", time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=DAILY;INTERVAL=3", time.Date(2025, 3, 6, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;BYDAY=WE,FR", time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;BYDAY=MO", time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU", time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY", time.Date(2025, 4, 3, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=YEARLY", time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		next, _, ok := mustParseRRule(t, c.rule).Next(from)
+		if !ok {
+			t.Errorf("%s: серия не должна заканчиваться", c.rule)
+			continue
+		}
+		if !next.Equal(c.want) {
+			t.Errorf("%s: ожидалось %v, получено %v", c.rule, c.want, next)
+		}
+	}
+}
+
+func TestNextWeeklyIntervalSkipsWeek(t *testing.T) {
+	// Вторник: следующий понедельник попадает в пропускаемую неделю
+	from := time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	next, _, ok := mustParseRRule(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU").Next(from)
+	want := time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)
+	if !ok || !next.Equal(want) {
+		t.Errorf("Ожидалось %v, получено %v", want, next)
+	}
+}
+
+func TestNextMonthlySkipsShortMonths(t *testing.T) {
+	from := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	next, _, ok := mustParseRRule(t, "FREQ=MONTHLY").Next(from)
+	want := time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC)
+	if !ok || !next.Equal(want) {
+		t.Errorf("Ожидалось %v, получено %v", want, next)
+	}
+
+	next, _, ok = mustParseRRule(t, "FREQ=YEARLY").Next(time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC))
+	want = time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC)
+	if !ok || !next.Equal(want) {
+		t.Errorf("Ожидалось %v, получено %v", want, next)
+	}
+}
+
+func TestNextCount(t *testing.T) {
+	from := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+	_, rest, ok := mustParseRRule(t, "FREQ=DAILY;COUNT=2").Next(from)
+	if !ok {
+		t.Fatal("При COUNT=2 должно быть ещё одно повторение")
+	}
+	if got := rest.String(); got != "FREQ=DAILY;COUNT=1" {
+		t.Errorf("Ожидалось правило FREQ=DAILY;COUNT=1, получено %q", got)
+	}
+
+	if _, _, ok := rest.Next(from); ok {
+		t.Error("При COUNT=1 серия должна закончиться")
+	}
+}
+
+func TestNextUntil(t *testing.T) {
+	rule := mustParseRRule(t, "FREQ=WEEKLY;UNTIL=20250310")
+
+	next, rest, ok := rule.Next(time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC))
+	if !ok || !next.Equal(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Повторение в последний день UNTIL должно попасть в серию, получено %v, %v", next, ok)
+	}
+	if rest.String() != rule.String() {
+		t.Errorf("UNTIL должен переноситься без изменений, получено %q", rest.String())
+	}
+
+	if _, _, ok := rule.Next(next); ok {
+		t.Error("Повторение после UNTIL не должно создаваться")
+	}
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Отзыв access-токенов. Выход отзывает один токен по jti, выход на всех устройствах — все токены пользователя,
+// выданные не позже users.tokens_revoked_at. apiservice держит список отзывов в памяти и перечитывает его
+// через GetRevocations
+
+// RevokeTokenRequest тело POST /revocations. refresh_token_hash — sha256 refresh-токена этого входа:
+// его цепочка отзывается вместе с access-токеном
+type RevokeTokenRequest struct {
+	JTI              string    `json:"jti"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshTokenHash string    `json:"refresh_token_hash,omitempty"`
+}
+
+// RevokedToken отозванный access-токен; после expires_at запись не нужна
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UserRevocation все токены пользователя с iat не позже revoked_before недействительны
+type UserRevocation struct {
+	UserID        int       `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// Revocations ответ GET /revocations
+type Revocations struct {
+	Tokens []RevokedToken   `json:"tokens"`
+	Users  []UserRevocation `json:"users"`
+}
+
+// RevokeToken отзывает access-токен и, если передан refreshHash, цепочку refresh-токенов этого входа.
+// Чужой refresh-токен не трогается
+func (r *TaskRepository) RevokeToken(userID int, jti string, expiresAt time.Time, refreshHash string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING`, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	if refreshHash != "" {
+		if _, err := tx.Exec(`
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE revoked_at IS NULL AND family_id = (
+				SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2
+			)`, refreshHash, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RevokeAllTokens отзывает все выданные пользователю access- и refresh-токены и возвращает момент отзыва
+func (r *TaskRepository) RevokeAllTokens(userID int) (time.Time, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var revokedAt time.Time
+	err = tx.QueryRow(`
+		UPDATE users SET tokens_revoked_at = NOW()
+		WHERE id = $1
+		RETURNING tokens_revoked_at`, userID).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrUserNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := revokeRefreshTokens(tx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	return revokedAt, tx.Commit()
+}
+
+// revokeRefreshTokens отзывает все действующие refresh-токены пользователя
+func revokeRefreshTokens(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// GetRevocations отозванные токены, которые ещё не истекли, и отзывы всех токенов пользователей после since.
+// Более ранние отзывы всех токенов не нужны: выданные до них токены к since уже истекли
+func (r *TaskRepository) GetRevocations(since time.Time) (*Revocations, error) {
+	revocations := &Revocations{Tokens: []RevokedToken{}, Users: []UserRevocation{}}
+
+	rows, err := r.DB.Query(`SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var token RevokedToken
+		if err := rows.Scan(&token.JTI, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		revocations.Tokens = append(revocations.Tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	userRows, err := r.DB.Query(`SELECT id, tokens_revoked_at FROM users WHERE tokens_revoked_at > $1`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer userRows.Close()
+
+	for userRows.Next() {
+		var user UserRevocation
+		if err := userRows.Scan(&user.UserID, &user.RevokedBefore); err != nil {
+			return nil, err
+		}
+		revocations.Users = append(revocations.Users, user)
+	}
+
+	return revocations, userRows.Err()
+}
+
+// PurgeExpiredTokens удаляет отзывы истёкших access-токенов, истёкшие refresh-токены
+// и истёкшие или использованные токены сброса пароля
+func (r *TaskRepository) PurgeExpiredTokens() (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM revoked_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	revoked, _ := res.RowsAffected()
+
+	res, err = r.DB.Exec(`DELETE FROM refresh_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	refresh, _ := res.RowsAffected()
+
+	res, err = r.DB.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < NOW() OR used_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	reset, _ := res.RowsAffected()
+
+	return revoked + refresh + reset, nil
+}
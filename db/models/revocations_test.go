@@ -0,0 +1,189 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ RevokeToken и RevokeAllTokens
+// ============================================================================
+
+func TestRevokeTokenWithRefreshFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	exp := time.Now().Add(15 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_tokens \(jti, user_id, expires_at\)\s+VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT \(jti\) DO NOTHING`).
+		WithArgs("jti-1", 1, exp).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Цепочка ищется только среди токенов этого пользователя
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)\s+WHERE revoked_at IS NULL AND family_id = \(\s+SELECT family_id FROM refresh_tokens WHERE token_hash = \$1 AND user_id = \$2`).
+		WithArgs(oldHash, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RevokeToken(1, "jti-1", exp, oldHash); err != nil {
+		t.Fatalf("RevokeToken вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRevokeTokenWithoutRefresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	exp := time.Now().Add(15 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO revoked_tokens`).
+		WithArgs("jti-1", 1, exp).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RevokeToken(1, "jti-1", exp, ""); err != nil {
+		t.Fatalf("RevokeToken вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET tokens_revoked_at = NOW\(\)\s+WHERE id = \$1\s+RETURNING tokens_revoked_at`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}).AddRow(now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)\s+WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	revokedAt, err := repo.RevokeAllTokens(1)
+	if err != nil {
+		t.Fatalf("RevokeAllTokens вернул ошибку: %v", err)
+	}
+	if !revokedAt.Equal(now) {
+		t.Errorf("Ожидался момент отзыва %v, получено %v", now, revokedAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRevokeAllTokensUnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE users SET tokens_revoked_at`).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens_revoked_at"}))
+	mock.ExpectRollback()
+
+	if _, err := repo.RevokeAllTokens(42); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Ожидалась ErrUserNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetRevocations и PurgeExpiredTokens
+// ============================================================================
+
+func TestGetRevocations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	since := now.Add(-time.Hour)
+
+	mock.ExpectQuery(`SELECT jti, expires_at FROM revoked_tokens WHERE expires_at > NOW\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"jti", "expires_at"}).AddRow("jti-1", now.Add(time.Minute)))
+	mock.ExpectQuery(`SELECT id, tokens_revoked_at FROM users WHERE tokens_revoked_at > \$1`).
+		WithArgs(since).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tokens_revoked_at"}).AddRow(2, now))
+
+	revocations, err := repo.GetRevocations(since)
+	if err != nil {
+		t.Fatalf("GetRevocations вернул ошибку: %v", err)
+	}
+	if len(revocations.Tokens) != 1 || revocations.Tokens[0].JTI != "jti-1" {
+		t.Errorf("Неправильные отозванные токены: %+v", revocations.Tokens)
+	}
+	if len(revocations.Users) != 1 || revocations.Users[0].UserID != 2 {
+		t.Errorf("Неправильные отзывы пользователей: %+v", revocations.Users)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`DELETE FROM revoked_tokens WHERE expires_at < NOW\(\)`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM refresh_tokens WHERE expires_at < NOW\(\)`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM password_reset_tokens WHERE expires_at < NOW\(\) OR used_at IS NOT NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	purged, err := repo.PurgeExpiredTokens()
+	if err != nil {
+		t.Fatalf("PurgeExpiredTokens вернул ошибку: %v", err)
+	}
+	if purged != 6 {
+		t.Errorf("Ожидалось 6 удалённых записей, получено %d", purged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Полнотекстовый поиск идёт по сгенерированной колонке tasks.search_vector (см. runMigrations).
+// Конфигурация 'simple' без стемминга: задачи пишут вперемешку на русском и английском,
+// а языковые словари искажают слова чужого языка. Недостающую морфологию закрывает поиск по префиксу
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	// maxSearchTerms ограничивает размер tsquery, чтобы длинная строка не превращалась в тяжёлый запрос
+	maxSearchTerms = 10
+)
+
+var ErrEmptySearchQuery = errors.New("search query has no searchable words")
+
+// SearchOptions фильтры поиска. nil — фильтр не применяется
+type SearchOptions struct {
+	CollectionID *int
+	Complete     *bool
+	Limit        int
+}
+
+// SearchResult найденная задача с релевантностью и фрагментом текста,
+// в котором совпадения обёрнуты в <mark>...</mark>
+type SearchResult struct {
+	Task
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// BuildSearchQuery превращает пользовательскую строку в tsquery: каждое слово ищется по
+// префиксу, все слова обязательны. Знаки препинания и операторы tsquery отбрасываются,
+// поэтому синтаксическая ошибка в to_tsquery невозможна
+func BuildSearchQuery(q string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "", ErrEmptySearchQuery
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = word + ":*"
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+// SearchTasks ищет задачи пользователя по названию и описанию. Совпадения в названии весят больше
+func (r *TaskRepository) SearchTasks(userID int, query string, opts SearchOptions) ([]SearchResult, error) {
+	tsquery, err := BuildSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{userID, tsquery}
+	filters := ""
+	if opts.CollectionID != nil {
+		args = append(args, *opts.CollectionID)
+		filters += " AND collection_id = $" + strconv.Itoa(len(args))
+	}
+	if opts.Complete != nil {
+		args = append(args, *opts.Complete)
+		filters += " AND complete = $" + strconv.Itoa(len(args))
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+`,
+		ts_rank(search_vector, q) AS search_rank,
+		ts_headline('simple', name || ' ' || COALESCE(text, ''), q,
+			'StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=5, MaxFragments=2')
+	FROM tasks, to_tsquery('simple', $2) q
+	WHERE `+taskReadable("", "$1")+` AND deleted_at IS NULL AND search_vector @@ q`+filters+`
+	ORDER BY search_rank DESC, id DESC
+	LIMIT `+strconv.Itoa(limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		if err := rows.Scan(append(taskDest(&res.Task), &res.Rank, &res.Snippet)...); err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
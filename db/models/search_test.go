@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// searchRows строки поиска: колонки задачи плюс релевантность и фрагмент
+func searchRows(results ...SearchResult) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "collection_id", "name", "text", "complete", "create_time", "complete_at", "due_at", "start_at", "priority", "recurrence", "tags", "progress_done", "progress_total", "assignee_id", "assignment_status", "position", "search_rank", "ts_headline"})
+	for _, r := range results {
+		t := r.Task
+		rows.AddRow(t.ID, t.UserID, t.CollectionID, t.Name, t.Text, t.Complete, t.CreateTime, t.CompleteAt, t.DueAt, t.StartAt, priorityOrNone(t.Priority), t.Recurrence, "{"+strings.Join(t.Tags, ",")+"}", t.Progress.Done, t.Progress.Total, t.AssigneeID, t.AssignmentStatus, t.Position, r.Rank, r.Snippet)
+	}
+	return rows
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ BuildSearchQuery
+// ============================================================================
+
+func TestBuildSearchQuery(t *testing.T) {
+	cases := map[string]string{
+		"отчёт":                 "отчёт:*",
+		"  Quarterly   Report ": "quarterly:* & report:*",
+		"foo & bar | !baz:*":    "foo:* & bar:* & baz:*",
+		"it's v2.0":             "it:* & s:* & v2:* & 0:*",
+	}
+
+	for input, want := range cases {
+		got, err := BuildSearchQuery(input)
+		if err != nil {
+			t.Errorf("BuildSearchQuery(%q) вернул ошибку: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("BuildSearchQuery(%q) = %q, ожидалось %q", input, got, want)
+		}
+	}
+}
+
+func TestBuildSearchQueryEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "&|!():*"} {
+		if _, err := BuildSearchQuery(input); !errors.Is(err, ErrEmptySearchQuery) {
+			t.Errorf("BuildSearchQuery(%q) должен вернуть ErrEmptySearchQuery, получено %v", input, err)
+		}
+	}
+}
+
+func TestBuildSearchQueryLimitsTerms(t *testing.T) {
+	got, err := BuildSearchQuery(strings.Repeat("word ", 50))
+	if err != nil {
+		t.Fatalf("BuildSearchQuery вернул ошибку: %v", err)
+	}
+	if n := strings.Count(got, ":*"); n != maxSearchTerms {
+		t.Errorf("Ожидалось %d слов, получено %d", maxSearchTerms, n)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ SearchTasks
+// ============================================================================
+
+func TestSearchTasksWithFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+	collectionID := 3
+	complete := false
+
+	mock.ExpectQuery(`WHERE `+readableRe(`$1`)+` AND deleted_at IS NULL AND search_vector @@ q AND collection_id = \$3 AND complete = \$4\s+ORDER BY search_rank DESC, id DESC\s+LIMIT 5`).
+		WithArgs(1, "quarterly:* & rep:*", 3, false).
+		WillReturnRows(searchRows(SearchResult{
+			Task:    Task{ID: 7, UserID: 1, Name: "Quarterly report", CreateTime: now, CollectionID: &collectionID},
+			Rank:    0.6,
+			Snippet: "<mark>Quarterly</mark> <mark>report</mark>",
+		}))
+
+	results, err := repo.SearchTasks(1, "quarterly rep", SearchOptions{CollectionID: &collectionID, Complete: &complete, Limit: 5})
+	if err != nil {
+		t.Fatalf("SearchTasks вернул ошибку: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != 7 || results[0].Rank != 0.6 || !strings.Contains(results[0].Snippet, "<mark>") {
+		t.Errorf("Неправильный результат: %+v", results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestSearchTasksDefaultLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`LIMIT 20`).
+		WithArgs(1, "milk:*").
+		WillReturnRows(searchRows())
+
+	results, err := repo.SearchTasks(1, "milk", SearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchTasks вернул ошибку: %v", err)
+	}
+	if results == nil || len(results) != 0 {
+		t.Errorf("Ожидался пустой список, получено %v", results)
+	}
+}
+
+func TestSearchTasksEmptyQuery(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	if _, err := repo.SearchTasks(1, "!!!", SearchOptions{}); !errors.Is(err, ErrEmptySearchQuery) {
+		t.Errorf("Ожидалась ошибка ErrEmptySearchQuery, получено %v", err)
+	}
+}
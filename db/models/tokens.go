@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Refresh-токены выпускает apiservice; сюда приходит только sha256 от токена в hex. Каждый вход начинает
+// новую цепочку (family_id), ротация помечает старый токен использованным и выдаёт новый в той же цепочке.
+// Повторное предъявление использованного токена значит, что им завладел кто-то ещё, — цепочка отзывается целиком
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token not found, revoked or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// CreateRefreshTokenRequest тело POST /tokens
+type CreateRefreshTokenRequest struct {
+	TokenHash  string `json:"token_hash"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// RotateRefreshTokenRequest тело POST /tokens/rotate: предъявленный токен и его замена
+type RotateRefreshTokenRequest struct {
+	TokenHash    string `json:"token_hash"`
+	NewTokenHash string `json:"new_token_hash"`
+	TTLSeconds   int64  `json:"ttl_seconds"`
+}
+
+// RefreshTokenReuse тело ответа 409: чья цепочка отозвана после повторного предъявления токена
+type RefreshTokenReuse struct {
+	Error    string `json:"error"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// ValidTokenHash проверяет, что строка — sha256 в нижнем регистре hex
+func ValidTokenHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateRefreshToken сохраняет токен нового входа в новой цепочке
+func (r *TaskRepository) CreateRefreshToken(userID int, tokenHash string, ttl time.Duration) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3))`,
+		userID, tokenHash, int64(ttl/time.Second))
+	return err
+}
+
+// RotateRefreshToken обменивает действующий токен на newHash и возвращает владельца.
+// Для использованного токена отзывает всю цепочку и возвращает владельца вместе с ErrRefreshTokenReused
+func (r *TaskRepository) RotateRefreshToken(tokenHash, newHash string, ttl time.Duration) (*User, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID                int
+		familyID               string
+		used, revoked, expired bool
+		user                   User
+	)
+	// FOR UPDATE: два одновременных обмена одного токена не должны оба пройти как первый
+	err = tx.QueryRow(`
+		SELECT t.id, t.family_id, t.used_at IS NOT NULL, t.revoked_at IS NOT NULL, t.expires_at <= NOW(),
+			u.id, u.username, u.created_at
+		FROM refresh_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t`, tokenHash).Scan(
+		&tokenID, &familyID, &used, &revoked, &expired, &user.ID, &user.Username, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case used:
+		if _, err := tx.Exec(`
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL`, familyID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &user, ErrRefreshTokenReused
+	case revoked, expired:
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, tokenID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))`,
+		user.ID, familyID, newHash, int64(ttl/time.Second)); err != nil {
+		return nil, err
+	}
+
+	return &user, tx.Commit()
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var (
+	oldHash = strings.Repeat("a", 64)
+	newHash = strings.Repeat("b", 64)
+)
+
+// tokenRows строка выборки refresh-токена вместе с владельцем
+func tokenRows(used, revoked, expired bool) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "family_id", "used", "revoked", "expired", "user_id", "username", "created_at"}).
+		AddRow(10, "family-1", used, revoked, expired, 1, "alice", time.Now())
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ ValidTokenHash
+// ============================================================================
+
+func TestValidTokenHash(t *testing.T) {
+	tests := []struct {
+		hash  string
+		valid bool
+	}{
+		{oldHash, true},
+		{strings.Repeat("0123456789abcdef", 4), true},
+		{"", false},
+		{strings.Repeat("a", 63), false},
+		{strings.Repeat("A", 64), false},
+		{strings.Repeat("g", 64), false},
+	}
+	for _, tt := range tests {
+		if got := ValidTokenHash(tt.hash); got != tt.valid {
+			t.Errorf("ValidTokenHash(%q) = %v, ожидалось %v", tt.hash, got, tt.valid)
+		}
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ CreateRefreshToken и RotateRefreshToken
+// ============================================================================
+
+func TestCreateRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`INSERT INTO refresh_tokens \(user_id, token_hash, expires_at\)`).
+		WithArgs(1, oldHash, int64(3600)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := repo.CreateRefreshToken(1, oldHash, time.Hour); err != nil {
+		t.Fatalf("CreateRefreshToken вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens t\s+JOIN users u (.+) FOR UPDATE OF t`).
+		WithArgs(oldHash).
+		WillReturnRows(tokenRows(false, false, false))
+	mock.ExpectExec(`UPDATE refresh_tokens SET used_at = NOW\(\) WHERE id = \$1`).
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO refresh_tokens \(user_id, family_id, token_hash, expires_at\)`).
+		WithArgs(1, "family-1", newHash, int64(3600)).
+		WillReturnResult(sqlmock.NewResult(11, 1))
+	mock.ExpectCommit()
+
+	user, err := repo.RotateRefreshToken(oldHash, newHash, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken вернул ошибку: %v", err)
+	}
+	if user.ID != 1 || user.Username != "alice" {
+		t.Errorf("Неправильный владелец: %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Отзыв цепочки фиксируется, хотя обмен не состоялся
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).
+		WithArgs(oldHash).
+		WillReturnRows(tokenRows(true, false, false))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\)\s+WHERE family_id = \$1 AND revoked_at IS NULL`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	user, err := repo.RotateRefreshToken(oldHash, newHash, time.Hour)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Ожидалась ErrRefreshTokenReused, получено %v", err)
+	}
+	if user == nil || user.ID != 1 {
+		t.Errorf("При повторном использовании должен возвращаться владелец, получено %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRotateRefreshTokenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{"неизвестный токен", sqlmock.NewRows([]string{"id"})},
+		{"отозванный токен", tokenRows(false, true, false)},
+		{"истёкший токен", tokenRows(false, false, true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Ошибка создания mock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewTaskRepository(db)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens`).
+				WithArgs(oldHash).
+				WillReturnRows(tt.rows)
+			mock.ExpectRollback()
+
+			if _, err := repo.RotateRefreshToken(oldHash, newHash, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+				t.Errorf("Ожидалась ErrRefreshTokenInvalid, получено %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Не выполнены ожидания mock: %v", err)
+			}
+		})
+	}
+}
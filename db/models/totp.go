@@ -0,0 +1,242 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Двухфакторная аутентификация по TOTP (RFC 6238). Коды проверяет apiservice, здесь хранятся секрет,
+// последний принятый шаг времени (повторно тот же код не пройдёт), счётчик неудачных попыток подряд
+// и sha256 одноразовых кодов восстановления. Пока enabled_at пуст, настройка не подтверждена и вход не меняет
+
+var (
+	ErrTOTPNotFound        = errors.New("two-factor authentication is not set up")
+	ErrTOTPEnabled         = errors.New("two-factor authentication is already enabled")
+	ErrTOTPStepUsed        = errors.New("totp code already used")
+	ErrRecoveryCodeInvalid = errors.New("recovery code not found or already used")
+)
+
+// TOTP ответ GET /account/totp
+type TOTP struct {
+	Secret   string `json:"secret"`
+	Enabled  bool   `json:"enabled"`
+	LastStep int64  `json:"last_step"`
+	// Failures неудачных попыток подряд, последняя — в FailedAt; успешный вход обнуляет счётчик
+	Failures          int        `json:"failures"`
+	FailedAt          *time.Time `json:"failed_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// SetupTOTPRequest тело PUT /account/totp: секрет в base32
+type SetupTOTPRequest struct {
+	Secret string `json:"secret"`
+}
+
+// EnableTOTPRequest тело POST /account/totp/enable: шаг кода, которым пользователь подтвердил настройку,
+// и хэши новых кодов восстановления
+type EnableTOTPRequest struct {
+	Step               int64    `json:"step"`
+	RecoveryCodeHashes []string `json:"recovery_code_hashes"`
+}
+
+// TOTPStepRequest тело POST /account/totp/step
+type TOTPStepRequest struct {
+	Step int64 `json:"step"`
+}
+
+// RecoveryCodeRequest тело POST /account/totp/recovery
+type RecoveryCodeRequest struct {
+	CodeHash string `json:"code_hash"`
+}
+
+// RecoveryCodeResult ответ POST /account/totp/recovery: сколько неиспользованных кодов осталось
+type RecoveryCodeResult struct {
+	RecoveryCodesLeft int `json:"recovery_codes_left"`
+}
+
+// GetTOTP возвращает настройку двухфакторной аутентификации; нет настройки — ErrTOTPNotFound
+func (r *TaskRepository) GetTOTP(userID int) (*TOTP, error) {
+	var totp TOTP
+	var failedAt sql.NullTime
+	err := r.DB.QueryRow(`
+		SELECT t.secret, t.enabled_at IS NOT NULL, t.last_step, t.failures, t.failed_at,
+			(SELECT COUNT(*) FROM recovery_codes c WHERE c.user_id = t.user_id AND c.used_at IS NULL)
+		FROM user_totp t
+		WHERE t.user_id = $1`, userID).Scan(
+		&totp.Secret, &totp.Enabled, &totp.LastStep, &totp.Failures, &failedAt, &totp.RecoveryCodesLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTOTPNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if failedAt.Valid {
+		totp.FailedAt = &failedAt.Time
+	}
+
+	return &totp, nil
+}
+
+// SetupTOTP сохраняет секрет неподтверждённой настройки, заменяя прежний неподтверждённый.
+// Подтверждённую настройку не трогает — ErrTOTPEnabled
+func (r *TaskRepository) SetupTOTP(userID int, secret string) error {
+	result, err := r.DB.Exec(`
+		INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = NOW(), last_step = 0, failures = 0, failed_at = NULL
+		WHERE user_totp.enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP подтверждает настройку и заменяет коды восстановления. step запоминается, чтобы код,
+// которым подтвердили настройку, нельзя было сразу использовать для входа. Нет неподтверждённой настройки — ErrTOTPNotFound
+func (r *TaskRepository) EnableTOTP(userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_totp SET enabled_at = NOW(), last_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL`, userID, step)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])`, userID, pq.Array(recoveryCodeHashes)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep принимает код шага step и обнуляет счётчик неудач. Условие last_step < step в самом UPDATE:
+// из двух входов одним кодом пройдёт один, второй получит ErrTOTPStepUsed
+func (r *TaskRepository) UseTOTPStep(userID int, step int64) error {
+	result, err := r.DB.Exec(`
+		UPDATE user_totp SET last_step = $2, failures = 0, failed_at = NULL
+		WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_step < $2`, userID, step)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPStepUsed
+	}
+
+	return nil
+}
+
+// RecordTOTPFailure засчитывает неверный код
+func (r *TaskRepository) RecordTOTPFailure(userID int) error {
+	result, err := r.DB.Exec(`
+		UPDATE user_totp SET failures = failures + 1, failed_at = NOW()
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode гасит код восстановления, обнуляет счётчик неудач и возвращает число оставшихся кодов
+func (r *TaskRepository) UseRecoveryCode(userID int, codeHash string) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrRecoveryCodeInvalid
+	}
+
+	if _, err := tx.Exec(`UPDATE user_totp SET failures = 0, failed_at = NULL WHERE user_id = $1`, userID); err != nil {
+		return 0, err
+	}
+
+	var left int
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&left); err != nil {
+		return 0, err
+	}
+
+	return left, tx.Commit()
+}
+
+// DisableTOTP удаляет настройку и коды восстановления; нет настройки — ErrTOTPNotFound
+func (r *TaskRepository) DisableTOTP(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
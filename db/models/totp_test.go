@@ -0,0 +1,274 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ настройки TOTP и кодов восстановления
+// ============================================================================
+
+func TestGetTOTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	failedAt := time.Now()
+
+	mock.ExpectQuery(`SELECT t.secret, t.enabled_at IS NOT NULL, t.last_step, t.failures, t.failed_at,.+FROM user_totp t\s+WHERE t.user_id = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled", "last_step", "failures", "failed_at", "left"}).
+			AddRow("JBSWY3DPEHPK3PXP", true, int64(100), 2, failedAt, 9))
+
+	totp, err := repo.GetTOTP(1)
+	if err != nil {
+		t.Fatalf("GetTOTP вернул ошибку: %v", err)
+	}
+	if totp.Secret != "JBSWY3DPEHPK3PXP" || !totp.Enabled || totp.LastStep != 100 || totp.Failures != 2 ||
+		totp.FailedAt == nil || totp.RecoveryCodesLeft != 9 {
+		t.Errorf("Неправильная настройка: %+v", totp)
+	}
+
+	mock.ExpectQuery(`FROM user_totp t`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled", "last_step", "failures", "failed_at", "left"}))
+
+	if _, err := repo.GetTOTP(2); !errors.Is(err, ErrTOTPNotFound) {
+		t.Errorf("Ожидалась ErrTOTPNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestSetupTOTPAlreadyEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Подтверждённая настройка не перезаписывается: ON CONFLICT ... WHERE не обновляет строку
+	mock.ExpectExec(`INSERT INTO user_totp \(user_id, secret\) VALUES \(\$1, \$2\)\s+ON CONFLICT \(user_id\) DO UPDATE.+WHERE user_totp.enabled_at IS NULL`).
+		WithArgs(1, "JBSWY3DPEHPK3PXP").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.SetupTOTP(1, "JBSWY3DPEHPK3PXP"); !errors.Is(err, ErrTOTPEnabled) {
+		t.Errorf("Ожидалась ErrTOTPEnabled, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestEnableTOTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	hashes := []string{oldHash, newHash}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE user_totp SET enabled_at = NOW\(\), last_step = \$2\s+WHERE user_id = \$1 AND enabled_at IS NULL`).
+		WithArgs(1, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO recovery_codes \(user_id, code_hash\)\s+SELECT \$1, unnest\(\$2::text\[\]\)`).
+		WithArgs(1, pq.Array(hashes)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := repo.EnableTOTP(1, 100, hashes); err != nil {
+		t.Fatalf("EnableTOTP вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestEnableTOTPNotPending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE user_totp SET enabled_at = NOW\(\)`).
+		WithArgs(1, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := repo.EnableTOTP(1, 100, []string{oldHash}); !errors.Is(err, ErrTOTPNotFound) {
+		t.Errorf("Ожидалась ErrTOTPNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`UPDATE user_totp SET last_step = \$2, failures = 0, failed_at = NULL\s+WHERE user_id = \$1 AND enabled_at IS NOT NULL AND last_step < \$2`).
+		WithArgs(1, int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.UseTOTPStep(1, 101); err != nil {
+		t.Errorf("UseTOTPStep вернул ошибку: %v", err)
+	}
+
+	// Тот же код второй раз
+	mock.ExpectExec(`UPDATE user_totp SET last_step = \$2`).
+		WithArgs(1, int64(101)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := repo.UseTOTPStep(1, 101); !errors.Is(err, ErrTOTPStepUsed) {
+		t.Errorf("Ожидалась ErrTOTPStepUsed, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRecordTOTPFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectExec(`UPDATE user_totp SET failures = failures \+ 1, failed_at = NOW\(\)\s+WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := repo.RecordTOTPFailure(1); err != nil {
+		t.Errorf("RecordTOTPFailure вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE recovery_codes SET used_at = NOW\(\)\s+WHERE user_id = \$1 AND code_hash = \$2 AND used_at IS NULL`).
+		WithArgs(1, oldHash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE user_totp SET failures = 0, failed_at = NULL WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM recovery_codes WHERE user_id = \$1 AND used_at IS NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(9))
+	mock.ExpectCommit()
+
+	left, err := repo.UseRecoveryCode(1, oldHash)
+	if err != nil {
+		t.Fatalf("UseRecoveryCode вернул ошибку: %v", err)
+	}
+	if left != 9 {
+		t.Errorf("Ожидалось 9 оставшихся кодов, получено %d", left)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestUseRecoveryCodeInvalid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE recovery_codes SET used_at = NOW\(\)`).
+		WithArgs(1, oldHash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := repo.UseRecoveryCode(1, oldHash); !errors.Is(err, ErrRecoveryCodeInvalid) {
+		t.Errorf("Ожидалась ErrRecoveryCodeInvalid, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM user_totp WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM recovery_codes WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+
+	if err := repo.DisableTOTP(1); err != nil {
+		t.Errorf("DisableTOTP вернул ошибку: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM user_totp WHERE user_id = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := repo.DisableTOTP(2); !errors.Is(err, ErrTOTPNotFound) {
+		t.Errorf("Ожидалась ErrTOTPNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
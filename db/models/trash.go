@@ -0,0 +1,299 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Удаление задач и коллекций мягкое: строка получает deleted_at и попадает в корзину.
+// Все выборки активных данных фильтруют deleted_at IS NULL. Окончательно строки стираются
+// вручную (purge, очистка корзины) или фоновой очисткой по сроку хранения (PurgeTrash)
+
+// Типы элементов корзины
+const (
+	TrashTask       = "task"
+	TrashCollection = "collection"
+)
+
+// TrashedTask задача в корзине
+type TrashedTask struct {
+	Task
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TrashedCollection коллекция в корзине. TaskCount — задачи, удалённые вместе с ней и её вложенными коллекциями:
+// они восстанавливаются и стираются вместе с коллекцией
+type TrashedCollection struct {
+	Collection
+	DeletedAt time.Time `json:"deleted_at"`
+	TaskCount int       `json:"task_count"`
+}
+
+type Trash struct {
+	Tasks       []TrashedTask       `json:"tasks"`
+	Collections []TrashedCollection `json:"collections"`
+}
+
+// PurgeResult сколько строк стёрто окончательно
+type PurgeResult struct {
+	Tasks       int64 `json:"tasks"`
+	Collections int64 `json:"collections"`
+}
+
+// GetTrashByUser содержимое корзины пользователя, недавно удалённое первым: задачи, которыми он распоряжается,
+// и коллекции, которыми он владеет. Вложенная коллекция, удалённая вместе с родителем, отдельно не показывается
+func (r *TaskRepository) GetTrashByUser(userID int) (*Trash, error) {
+	trash := &Trash{Tasks: []TrashedTask{}, Collections: []TrashedCollection{}}
+
+	rows, err := r.DB.Query(`
+	SELECT `+taskColumns+`, deleted_at FROM tasks
+	WHERE `+taskManageable("", "$1")+` AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task TrashedTask
+		if err := rows.Scan(append(taskDest(&task.Task), &task.DeletedAt)...); err != nil {
+			return nil, err
+		}
+		trash.Tasks = append(trash.Tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.DB.Query(`
+	SELECT c.id, c.user_id, c.parent_id, c.name, c.color, c.icon, c.created_at, c.position, c.deleted_at,
+		(`+collectionTree("c.id", "sub.deleted_at = c.deleted_at")+`
+		SELECT COUNT(*) FROM tasks t WHERE t.collection_id IN (SELECT id FROM tree) AND t.deleted_at = c.deleted_at)
+	FROM collections c
+	WHERE `+memberOf("c.id", "$1", RoleOwner)+` AND c.deleted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM collections p WHERE p.id = c.parent_id AND p.deleted_at = c.deleted_at)
+	ORDER BY c.deleted_at DESC, c.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c TrashedCollection
+		if err := rows.Scan(append(collectionDest(&c.Collection), &c.DeletedAt, &c.TaskCount)...); err != nil {
+			return nil, err
+		}
+		trash.Collections = append(trash.Collections, c)
+	}
+	return trash, rows.Err()
+}
+
+// DeleteTaskByUser переносит задачу в корзину
+func (r *TaskRepository) DeleteTaskByUser(id, userID int) error {
+	return trashTask(r.DB, id, userID)
+}
+
+func trashTask(e execer, id, userID int) error {
+	result, err := e.Exec(`
+	UPDATE tasks SET deleted_at = Now()
+	WHERE id = $1 AND `+taskManageable("", "$2")+` AND deleted_at IS NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// DeleteCollectionByUser переносит в корзину коллекцию со всеми вложенными коллекциями и задачами всех их участников.
+// Удалить коллекцию может только владелец. Now() фиксируется на начало транзакции, поэтому у коллекций и задач одинаковый deleted_at —
+// по нему поддерево потом находится при восстановлении
+func (r *TaskRepository) DeleteCollectionByUser(id, userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := requireOwner(tx, id, userID); err != nil {
+		return err
+	}
+
+	// Сначала задачи: пока коллекции активны, поддерево находится по deleted_at IS NULL
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at IS NULL")+`
+	UPDATE tasks SET deleted_at = Now()
+	WHERE collection_id IN (SELECT id FROM tree) AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at IS NULL")+`
+	UPDATE collections SET deleted_at = Now()
+	WHERE id IN (SELECT id FROM tree)`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreTaskByUser возвращает задачу из корзины. Если её коллекция всё ещё в корзине,
+// задача восстанавливается без коллекции
+func (r *TaskRepository) RestoreTaskByUser(id, userID int) (*Task, error) {
+	var task Task
+	err := scanTask(r.DB.QueryRow(`
+	UPDATE tasks SET deleted_at = NULL,
+		collection_id = CASE WHEN EXISTS (
+			SELECT 1 FROM collections c WHERE c.id = tasks.collection_id AND c.deleted_at IS NOT NULL
+		) THEN NULL ELSE collection_id END
+	WHERE id = $1 AND `+taskManageable("", "$2")+` AND deleted_at IS NOT NULL
+	RETURNING `+taskColumns, id, userID), &task)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// RestoreCollectionByUser возвращает коллекцию из корзины вместе с вложенными коллекциями и задачами,
+// удалёнными вместе с ней. Если её родитель всё ещё в корзине, коллекция восстанавливается на верхнем уровне
+func (r *TaskRepository) RestoreCollectionByUser(id, userID int) (*Collection, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	deletedAt, err := lockTrashedCollection(tx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at = $2")+`
+	UPDATE tasks SET deleted_at = NULL
+	WHERE collection_id IN (SELECT id FROM tree) AND deleted_at = $2`, id, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at = $2")+`
+	UPDATE collections SET deleted_at = NULL
+	WHERE id IN (SELECT id FROM tree) AND id <> $1`, id, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection Collection
+	err = tx.QueryRow(`
+	UPDATE collections SET deleted_at = NULL,
+		parent_id = CASE WHEN EXISTS (
+			SELECT 1 FROM collections p WHERE p.id = collections.parent_id AND p.deleted_at IS NOT NULL
+		) THEN NULL ELSE parent_id END
+	WHERE id = $1
+	RETURNING `+collectionColumns, id).Scan(collectionDest(&collection)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, tx.Commit()
+}
+
+// PurgeTaskByUser окончательно удаляет задачу из корзины
+func (r *TaskRepository) PurgeTaskByUser(id, userID int) error {
+	result, err := r.DB.Exec(`
+	DELETE FROM tasks
+	WHERE id = $1 AND `+taskManageable("", "$2")+` AND deleted_at IS NOT NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// PurgeCollectionByUser окончательно удаляет коллекцию из корзины вместе с вложенными коллекциями и задачами,
+// удалёнными вместе с ней. Задачи стираются первыми: после удаления коллекций
+// ON DELETE SET NULL обнулил бы их collection_id
+func (r *TaskRepository) PurgeCollectionByUser(id, userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deletedAt, err := lockTrashedCollection(tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at = $2")+`
+	DELETE FROM tasks
+	WHERE collection_id IN (SELECT id FROM tree) AND deleted_at = $2`, id, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(collectionTree("$1", "sub.deleted_at = $2")+`
+	DELETE FROM collections
+	WHERE id IN (SELECT id FROM tree)`, id, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockTrashedCollection блокирует коллекцию владельца в корзине и возвращает время её удаления
+func lockTrashedCollection(tx *sql.Tx, id, userID int) (time.Time, error) {
+	var deletedAt time.Time
+	err := tx.QueryRow(`
+	SELECT deleted_at FROM collections
+	WHERE id = $1 AND `+memberOf("id", "$2", RoleOwner)+` AND deleted_at IS NOT NULL
+	FOR UPDATE`, id, userID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrCollectionNotFound
+	}
+	return deletedAt, err
+}
+
+// EmptyTrashByUser окончательно удаляет всё содержимое корзины пользователя
+func (r *TaskRepository) EmptyTrashByUser(userID int) (PurgeResult, error) {
+	return r.purgeTrash(taskManageable("", "$1"), memberOf("id", "$1", RoleOwner), userID)
+}
+
+// PurgeTrash стирает всё, что лежит в корзине дольше срока хранения, то есть удалено раньше before.
+// Задачи, удалённые вместе с коллекцией, имеют тот же deleted_at и уходят вместе с ней
+func (r *TaskRepository) PurgeTrash(before time.Time) (PurgeResult, error) {
+	return r.purgeTrash("deleted_at < $1", "deleted_at < $1", before)
+}
+
+// purgeTrash стирает задачи и коллекции из корзины по отдельным условиям с общими аргументами
+func (r *TaskRepository) purgeTrash(taskCond, collectionCond string, args ...interface{}) (PurgeResult, error) {
+	var purged PurgeResult
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return purged, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM tasks WHERE deleted_at IS NOT NULL AND `+taskCond, args...)
+	if err != nil {
+		return purged, err
+	}
+	purged.Tasks, _ = result.RowsAffected()
+
+	result, err = tx.Exec(`DELETE FROM collections WHERE deleted_at IS NOT NULL AND `+collectionCond, args...)
+	if err != nil {
+		return purged, err
+	}
+	purged.Collections, _ = result.RowsAffected()
+
+	return purged, tx.Commit()
+}
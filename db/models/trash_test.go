@@ -0,0 +1,368 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ DeleteCollectionByUser
+// ============================================================================
+
+func TestDeleteCollectionByUserMovesTasksToTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 1).
+		WillReturnRows(roleRows(RoleOwner))
+	// В корзину уходят задачи всех участников, а не только владельца, в том числе из вложенных коллекций
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at IS NULL`) + `\s+UPDATE tasks SET deleted_at = Now\(\)\s+WHERE collection_id IN \(SELECT id FROM tree\) AND deleted_at IS NULL`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at IS NULL`) + `\s+UPDATE collections SET deleted_at = Now\(\)\s+WHERE id IN \(SELECT id FROM tree\)`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := repo.DeleteCollectionByUser(3, 1); err != nil {
+		t.Fatalf("DeleteCollectionByUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteCollectionByUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if err := repo.DeleteCollectionByUser(3, 2); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ошибка ErrCollectionNotFound, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestDeleteCollectionByUserEditorForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(roleQuery).
+		WithArgs(3, 2).
+		WillReturnRows(roleRows(RoleEditor))
+	mock.ExpectRollback()
+
+	if err := repo.DeleteCollectionByUser(3, 2); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидалась ошибка ErrForbidden, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ GetTrashByUser
+// ============================================================================
+
+func TestGetTrashByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	now := time.Now()
+
+	taskCols := []string{"id", "user_id", "collection_id", "name", "text", "complete", "create_time", "complete_at", "due_at", "start_at", "priority", "recurrence", "tags", "progress_done", "progress_total", "assignee_id", "assignment_status", "position", "deleted_at"}
+	mock.ExpectQuery(`FROM tasks\s+WHERE ` + manageableRe(`$1`) + ` AND deleted_at IS NOT NULL\s+ORDER BY deleted_at DESC, id DESC`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(taskCols).
+			AddRow(5, 1, nil, "Old task", "", false, now, nil, nil, nil, "none", nil, "{}", 0, 0, nil, nil, 1024, now))
+	// Вложенные коллекции, удалённые вместе с родителем, в списке не повторяются
+	mock.ExpectQuery(`FROM collections c\s+WHERE ` + regexp.QuoteMeta(memberOf(`c.id`, `$1`, RoleOwner)) + ` AND c.deleted_at IS NOT NULL\s+AND NOT EXISTS \(SELECT 1 FROM collections p WHERE p.id = c.parent_id AND p.deleted_at = c.deleted_at\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "parent_id", "name", "color", "icon", "created_at", "position", "deleted_at", "task_count"}).
+			AddRow(3, 1, nil, "Work", "#ff0000", "💼", now, 1024, now, 2))
+
+	trash, err := repo.GetTrashByUser(1)
+	if err != nil {
+		t.Fatalf("GetTrashByUser вернул ошибку: %v", err)
+	}
+	if len(trash.Tasks) != 1 || trash.Tasks[0].ID != 5 || !trash.Tasks[0].DeletedAt.Equal(now) {
+		t.Errorf("Неправильные задачи в корзине: %+v", trash.Tasks)
+	}
+	if len(trash.Collections) != 1 || trash.Collections[0].TaskCount != 2 {
+		t.Errorf("Неправильные коллекции в корзине: %+v", trash.Collections)
+	}
+}
+
+func TestGetTrashByUserEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`FROM tasks`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`FROM collections c`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	trash, err := repo.GetTrashByUser(1)
+	if err != nil {
+		t.Fatalf("GetTrashByUser вернул ошибку: %v", err)
+	}
+	if trash.Tasks == nil || trash.Collections == nil {
+		t.Error("Пустая корзина должна отдавать [], а не null")
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ восстановления
+// ============================================================================
+
+func TestRestoreTaskByUserDetachesTrashedCollection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`UPDATE tasks SET deleted_at = NULL,\s+collection_id = CASE WHEN EXISTS \((.+)c.deleted_at IS NOT NULL\s+\) THEN NULL ELSE collection_id END\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NOT NULL`).
+		WithArgs(5, 1).
+		WillReturnRows(taskRows(Task{ID: 5, UserID: 1, Name: "Old task", CreateTime: time.Now()}))
+
+	task, err := repo.RestoreTaskByUser(5, 1)
+	if err != nil {
+		t.Fatalf("RestoreTaskByUser вернул ошибку: %v", err)
+	}
+	if task.ID != 5 {
+		t.Errorf("Ожидалась задача 5, получено %+v", task)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestRestoreTaskByUserNotInTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectQuery(`UPDATE tasks SET deleted_at = NULL`).
+		WithArgs(5, 2).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.RestoreTaskByUser(5, 2); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ошибка ErrTaskNotFound, получено %v", err)
+	}
+}
+
+func TestRestoreCollectionByUserRestoresItsTasks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	deletedAt := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM collections\s+WHERE id = \$1 AND `+regexp.QuoteMeta(memberOf(`id`, `$2`, RoleOwner))+` AND deleted_at IS NOT NULL\s+FOR UPDATE`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at = $2`)+`\s+UPDATE tasks SET deleted_at = NULL\s+WHERE collection_id IN \(SELECT id FROM tree\) AND deleted_at = \$2`).
+		WithArgs(3, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at = $2`)+`\s+UPDATE collections SET deleted_at = NULL\s+WHERE id IN \(SELECT id FROM tree\) AND id <> \$1`).
+		WithArgs(3, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Родитель всё ещё в корзине — коллекция поднимается на верхний уровень
+	mock.ExpectQuery(`UPDATE collections SET deleted_at = NULL,\s+parent_id = CASE WHEN EXISTS`).
+		WithArgs(3).
+		WillReturnRows(collectionRows(Collection{ID: 3, UserID: 1, Name: "Work", Color: "#ff0000", Icon: "💼", CreatedAt: now}))
+	mock.ExpectCommit()
+
+	collection, err := repo.RestoreCollectionByUser(3, 1)
+	if err != nil {
+		t.Fatalf("RestoreCollectionByUser вернул ошибку: %v", err)
+	}
+	if collection.ID != 3 || collection.Name != "Work" {
+		t.Errorf("Неправильная коллекция: %+v", collection)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ окончательного удаления
+// ============================================================================
+
+func TestPurgeTaskByUserOnlyFromTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	// Активная задача не попадает под условие deleted_at IS NOT NULL
+	mock.ExpectExec(`DELETE FROM tasks\s+WHERE id = \$1 AND `+manageableRe(`$2`)+` AND deleted_at IS NOT NULL`).
+		WithArgs(5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.PurgeTaskByUser(5, 1); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Ожидалась ошибка ErrTaskNotFound, получено %v", err)
+	}
+}
+
+func TestPurgeCollectionByUserDeletesTasksFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	deletedAt := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM collections`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at = $2`)+`\s+DELETE FROM tasks\s+WHERE collection_id IN \(SELECT id FROM tree\) AND deleted_at = \$2`).
+		WithArgs(3, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(treeRe(`$1`, `sub.deleted_at = $2`)+`\s+DELETE FROM collections\s+WHERE id IN \(SELECT id FROM tree\)`).
+		WithArgs(3, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := repo.PurgeCollectionByUser(3, 1); err != nil {
+		t.Fatalf("PurgeCollectionByUser вернул ошибку: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
+
+func TestPurgeCollectionByUserNotInTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM collections`).
+		WithArgs(3, 1).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if err := repo.PurgeCollectionByUser(3, 1); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Ожидалась ошибка ErrCollectionNotFound, получено %v", err)
+	}
+}
+
+func TestEmptyTrashByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM tasks WHERE deleted_at IS NOT NULL AND ` + manageableRe(`$1`)).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(`DELETE FROM collections WHERE deleted_at IS NOT NULL AND ` + regexp.QuoteMeta(memberOf(`id`, `$1`, RoleOwner))).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	purged, err := repo.EmptyTrashByUser(1)
+	if err != nil {
+		t.Fatalf("EmptyTrashByUser вернул ошибку: %v", err)
+	}
+	if purged.Tasks != 4 || purged.Collections != 1 {
+		t.Errorf("Ожидалось 4 задачи и 1 коллекция, получено %+v", purged)
+	}
+}
+
+func TestPurgeTrashBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Ошибка создания mock: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewTaskRepository(db)
+	before := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM tasks WHERE deleted_at IS NOT NULL AND deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err = repo.PurgeTrash(before)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("Ожидалась ошибка базы, получено %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Не выполнены ожидания mock: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"dbservice/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultTrashRetentionDays сколько дней удалённые задачи и коллекции лежат в корзине
+const defaultTrashRetentionDays = 30
+
+// trashRetentionFromEnv читает срок хранения корзины из TRASH_RETENTION_DAYS. 0 отключает очистку
+func trashRetentionFromEnv() (time.Duration, error) {
+	days := defaultTrashRetentionDays
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid TRASH_RETENTION_DAYS %q: must be a non-negative number of days", v)
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// trashPurger часть репозитория, нужная фоновой очистке
+type trashPurger interface {
+	PurgeTrash(before time.Time) (models.PurgeResult, error)
+}
+
+// runTrashRetention раз в interval стирает из корзины всё, что лежит там дольше retention.
+// Первый проход сразу при старте, чтобы не ждать interval после перезапуска
+func runTrashRetention(repo trashPurger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeExpiredTrash(repo, retention, time.Now())
+		<-ticker.C
+	}
+}
+
+func purgeExpiredTrash(repo trashPurger, retention time.Duration, now time.Time) {
+	purged, err := repo.PurgeTrash(now.Add(-retention))
+	if err != nil {
+		log.Printf("Failed to purge trash: %v", err)
+		return
+	}
+	if purged.Tasks > 0 || purged.Collections > 0 {
+		log.Printf("Purged expired trash: %d tasks, %d collections", purged.Tasks, purged.Collections)
+	}
+}
+
+// tokenPurger часть репозитория, нужная очистке токенов
+type tokenPurger interface {
+	PurgeExpiredTokens() (int64, error)
+}
+
+// runTokenCleanup раз в interval удаляет истёкшие refresh-токены и отзывы истёкших access-токенов,
+// чтобы таблицы не росли с каждым входом и выходом
+func runTokenCleanup(repo tokenPurger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeExpiredTokens(repo)
+		<-ticker.C
+	}
+}
+
+func purgeExpiredTokens(repo tokenPurger) {
+	purged, err := repo.PurgeExpiredTokens()
+	if err != nil {
+		log.Printf("Failed to purge expired tokens: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d expired tokens", purged)
+	}
+}
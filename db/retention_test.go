@@ -0,0 +1,97 @@
+package main
+
+import (
+	"dbservice/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePurger запоминает границу очистки
+type fakePurger struct {
+	before time.Time
+	err    error
+}
+
+func (f *fakePurger) PurgeTrash(before time.Time) (models.PurgeResult, error) {
+	f.before = before
+	return models.PurgeResult{Tasks: 1}, f.err
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ срока хранения корзины
+// ============================================================================
+
+func TestTrashRetentionFromEnv(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":   30 * 24 * time.Hour,
+		"7":  7 * 24 * time.Hour,
+		"0":  0,
+		"90": 90 * 24 * time.Hour,
+	}
+
+	for value, want := range cases {
+		t.Setenv("TRASH_RETENTION_DAYS", value)
+		got, err := trashRetentionFromEnv()
+		if err != nil {
+			t.Errorf("TRASH_RETENTION_DAYS=%q: ошибка %v", value, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("TRASH_RETENTION_DAYS=%q: ожидалось %v, получено %v", value, want, got)
+		}
+	}
+}
+
+func TestTrashRetentionFromEnvInvalid(t *testing.T) {
+	for _, value := range []string{"-1", "week", "1.5"} {
+		t.Setenv("TRASH_RETENTION_DAYS", value)
+		if _, err := trashRetentionFromEnv(); err == nil {
+			t.Errorf("TRASH_RETENTION_DAYS=%q должен вернуть ошибку", value)
+		}
+	}
+}
+
+func TestPurgeExpiredTrashCutoff(t *testing.T) {
+	purger := &fakePurger{}
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	purgeExpiredTrash(purger, 30*24*time.Hour, now)
+
+	want := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if !purger.before.Equal(want) {
+		t.Errorf("Ожидалась граница %v, получено %v", want, purger.before)
+	}
+}
+
+func TestPurgeExpiredTrashError(t *testing.T) {
+	// Ошибка базы только логируется: фоновая очистка не должна ронять сервис
+	purger := &fakePurger{err: errors.New("connection reset")}
+	purgeExpiredTrash(purger, time.Hour, time.Now())
+}
+
+// fakeTokenPurger считает проходы очистки токенов
+type fakeTokenPurger struct {
+	calls int
+	err   error
+}
+
+func (f *fakeTokenPurger) PurgeExpiredTokens() (int64, error) {
+	f.calls++
+	return 2, f.err
+}
+
+// ============================================================================
+// ТЕСТЫ ДЛЯ очистки токенов
+// ============================================================================
+
+func TestPurgeExpiredTokens(t *testing.T) {
+	purger := &fakeTokenPurger{}
+	purgeExpiredTokens(purger)
+	if purger.calls != 1 {
+		t.Errorf("Ожидался один проход очистки, получено %d", purger.calls)
+	}
+
+	// Ошибка только логируется
+	purgeExpiredTokens(&fakeTokenPurger{err: errors.New("connection reset")})
+}